@@ -0,0 +1,32 @@
+// Connects to the live updates websocket. For every received event, a "live-update" htmx event is
+// triggered on each element listing the event type in its data-live-events attribute.
+(function () {
+  const protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
+  const maxRetryDelay = 30000;
+  let retryDelay = 1000;
+
+  function connect() {
+    const socket = new WebSocket(`${protocol}//${window.location.host}/ws`);
+
+    socket.onopen = () => {
+      retryDelay = 1000;
+    };
+
+    socket.onmessage = (message) => {
+      const liveEvent = JSON.parse(message.data);
+      document.querySelectorAll("[data-live-events]").forEach((element) => {
+        if (element.dataset.liveEvents.split(" ").includes(liveEvent.type)) {
+          htmx.trigger(element, "live-update", liveEvent);
+        }
+      });
+    };
+
+    // Reconnect with increasing delay, e.g. after a server restart
+    socket.onclose = () => {
+      setTimeout(connect, retryDelay);
+      retryDelay = Math.min(retryDelay * 2, maxRetryDelay);
+    };
+  }
+
+  connect();
+})();
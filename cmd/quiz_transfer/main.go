@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Molnes/Nyhetsjeger/internal/database"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quiz_transfer"
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+)
+
+const usage = `Exports a quiz to, or imports a quiz from, a versioned JSON document.
+
+Usage:
+  quiz_transfer export -quiz <quiz-id> [-db <database-url>] [-o <file>]
+  quiz_transfer import [-db <database-url>] [-i <file>]
+
+The database url defaults to POSTGRESQL_URL_DEV. Standard output and input are used if no file is given.
+`
+
+// Script used to move single quizzes between databases, e.g. from staging to production, or to back them up.
+//
+// This is a separate main, this code never makes it into the application itself.
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	err := godotenv.Load()
+	if err != nil {
+		log.Default().Println("Error loading .env file")
+	}
+
+	switch os.Args[1] {
+	case "export":
+		exportCommand(os.Args[2:])
+	case "import":
+		importCommand(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func exportCommand(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	dbUrl := flags.String("db", os.Getenv("POSTGRESQL_URL_DEV"), "database url")
+	quizIDString := flags.String("quiz", "", "id of the quiz to export")
+	outputPath := flags.String("o", "", "file to write the document to")
+	flags.Parse(args)
+
+	quizID, err := uuid.Parse(*quizIDString)
+	if err != nil {
+		log.Fatal("Quiz transfer: Invalid or missing quiz id: ", err)
+	}
+
+	db, err := database.NewDatabaseConnection(*dbUrl)
+	if err != nil {
+		log.Fatal("Quiz transfer: Error connecting to database: ", err)
+	}
+	defer db.Close()
+
+	document, err := quiz_transfer.Export(db, quizID)
+	if err != nil {
+		log.Fatal("Quiz transfer: Error exporting quiz: ", err)
+	}
+
+	var output io.Writer = os.Stdout
+	if *outputPath != "" {
+		file, err := os.Create(*outputPath)
+		if err != nil {
+			log.Fatal("Quiz transfer: Error creating file: ", err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	if err := document.Write(output); err != nil {
+		log.Fatal("Quiz transfer: Error writing document: ", err)
+	}
+}
+
+func importCommand(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dbUrl := flags.String("db", os.Getenv("POSTGRESQL_URL_DEV"), "database url")
+	inputPath := flags.String("i", "", "file to read the document from")
+	flags.Parse(args)
+
+	var input io.Reader = os.Stdin
+	if *inputPath != "" {
+		file, err := os.Open(*inputPath)
+		if err != nil {
+			log.Fatal("Quiz transfer: Error opening file: ", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	document, err := quiz_transfer.Read(input)
+	if err != nil {
+		log.Fatal("Quiz transfer: Error reading document: ", err)
+	}
+
+	db, err := database.NewDatabaseConnection(*dbUrl)
+	if err != nil {
+		log.Fatal("Quiz transfer: Error connecting to database: ", err)
+	}
+	defer db.Close()
+
+	quizID, err := quiz_transfer.Import(db, context.Background(), document)
+	if err != nil {
+		log.Fatal("Quiz transfer: Error importing quiz: ", err)
+	}
+
+	log.Println("Imported quiz with id", quizID)
+}
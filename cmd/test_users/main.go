@@ -118,25 +118,19 @@ func setRole(db *sql.DB, id uuid.UUID, role user_roles.Role) error {
 func createUserList() []users.PartialUser {
 	return []users.PartialUser{
 		{
-			SsoID:        "test_user_sso_id",
-			Email:        "test1@email.com",
-			AccessToken:  "token1",
-			TokenExpire:  time.Now().Add(time.Hour * 24),
-			RefreshToken: "refresh1",
+			SsoProvider: "google",
+			SsoID:       "test_user_sso_id",
+			Email:       "test1@email.com",
 		},
 		{
-			SsoID:        "test_admin_sso_id",
-			Email:        "test2@email.com",
-			AccessToken:  "token2",
-			TokenExpire:  time.Now().Add(time.Hour * 24),
-			RefreshToken: "refresh2",
+			SsoProvider: "google",
+			SsoID:       "test_admin_sso_id",
+			Email:       "test2@email.com",
 		},
 		{
-			SsoID:        "test_organization_admin_sso_id",
-			Email:        "test3@email.com",
-			AccessToken:  "token3",
-			TokenExpire:  time.Now().Add(time.Hour * 24),
-			RefreshToken: "refresh3",
+			SsoProvider: "google",
+			SsoID:       "test_organization_admin_sso_id",
+			Email:       "test3@email.com",
 		},
 	}
 }
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE quizzes DROP CONSTRAINT IF EXISTS template_not_published;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS is_template;
+
+END;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Templates are quizzes that are never shown to users, only copied into new quizzes.
+ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS is_template BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE quizzes ADD CONSTRAINT template_not_published CHECK (NOT (is_template AND published));
+
+END;
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS quizzes_publish_at_idx;
+ALTER TABLE quizzes DROP CONSTRAINT IF EXISTS template_not_scheduled;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS is_archived;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS end_action;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS publish_at;
+DROP TYPE IF EXISTS quiz_end_action;
+
+END;
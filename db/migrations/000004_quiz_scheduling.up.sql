@@ -0,0 +1,14 @@
+BEGIN;
+
+-- What happens to a quiz once its active_to has passed.
+-- 'archive' keeps the quiz published, so it still counts in rankings, but hides it from the dashboard's quiz lists.
+CREATE TYPE quiz_end_action AS ENUM ('none', 'unpublish', 'archive');
+
+ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ;
+ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS end_action quiz_end_action NOT NULL DEFAULT 'none';
+ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS is_archived BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE quizzes ADD CONSTRAINT template_not_scheduled CHECK (NOT (is_template AND publish_at IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS quizzes_publish_at_idx ON quizzes (publish_at) WHERE publish_at IS NOT NULL;
+
+END;
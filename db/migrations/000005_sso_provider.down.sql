@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS users_sso_provider_user_id_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS sso_provider;
+
+END;
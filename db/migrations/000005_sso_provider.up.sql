@@ -0,0 +1,9 @@
+BEGIN;
+
+-- The login provider the sso_user_id belongs to, e.g. 'google' or a configured OpenID Connect provider.
+-- IDs are only unique per provider. Existing users all logged in with Google.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sso_provider TEXT NOT NULL DEFAULT 'google';
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_sso_provider_user_id_idx ON users (sso_provider, sso_user_id);
+
+END;
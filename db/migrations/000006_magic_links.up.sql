@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Single-use tokens for logging in through a link sent by email.
+-- Only a hash of the token is stored, so the table can not be used to log in.
+CREATE TABLE IF NOT EXISTS magic_links (
+    token_hash TEXT PRIMARY KEY,
+    email TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS magic_links_expires_at_idx ON magic_links (expires_at);
+
+END;
@@ -0,0 +1,9 @@
+BEGIN;
+
+UPDATE users SET access_token = '' WHERE access_token IS NULL;
+UPDATE users SET refresh_token = '' WHERE refresh_token IS NULL;
+
+ALTER TABLE users ALTER COLUMN access_token SET NOT NULL;
+ALTER TABLE users ALTER COLUMN refresh_token SET NOT NULL;
+
+END;
@@ -0,0 +1,11 @@
+BEGIN;
+
+-- The login provider's tokens are encrypted with a key from AES_KEY, as "<key id>:<base64 ciphertext>".
+-- They are cleared once they are no longer needed, so they are nullable.
+-- Tokens stored before this migration were placeholders, and are removed.
+ALTER TABLE users ALTER COLUMN access_token DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN refresh_token DROP NOT NULL;
+
+UPDATE users SET access_token = NULL, refresh_token = NULL, token_expires_at = NULL;
+
+END;
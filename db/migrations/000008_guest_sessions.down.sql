@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP VIEW IF EXISTS guest_question_points;
+DROP TABLE IF EXISTS guest_answers;
+DROP TABLE IF EXISTS guest_sessions;
+
+END;
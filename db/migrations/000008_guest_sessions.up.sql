@@ -0,0 +1,46 @@
+BEGIN;
+
+-- Anonymous sessions of guests playing the open quiz without logging in.
+CREATE TABLE IF NOT EXISTS guest_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS guest_sessions_last_seen_at_idx ON guest_sessions (last_seen_at);
+
+-- Same as user_answers, but for guest sessions. Moved to user_answers when the guest logs in.
+CREATE TABLE IF NOT EXISTS guest_answers (
+    guest_session_id UUID NOT NULL REFERENCES guest_sessions(id) ON DELETE CASCADE,
+    question_id UUID NOT NULL REFERENCES questions(id) ON DELETE CASCADE,
+    question_presented_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+     -- following columns are nullable; if they are null, the guest has not answered the question yet
+    chosen_answer_alternative_id UUID REFERENCES answer_alternatives(id) ON DELETE CASCADE,
+    answered_at TIMESTAMPTZ,
+    PRIMARY KEY (guest_session_id, question_id),
+    CONSTRAINT guest_ans_alt_belong_to_question
+        FOREIGN KEY (question_id, chosen_answer_alternative_id)
+        REFERENCES answer_alternatives(question_id, id)
+);
+
+-- Same as user_question_points, but for guest sessions.
+CREATE OR REPLACE VIEW guest_question_points AS
+SELECT
+ga.guest_session_id,
+ga.question_id,
+q.quiz_id,
+ga.answered_at AS answered_at,
+ga.chosen_answer_alternative_id,
+CASE
+    WHEN aa.correct THEN
+        calculate_points_awarded(ga.question_presented_at, ga.answered_at, q.time_limit_seconds, q.points)
+    ELSE
+        0
+END AS points_awarded
+
+FROM guest_answers ga
+JOIN questions q ON ga.question_id = q.id
+JOIN answer_alternatives aa ON ga.chosen_answer_alternative_id = aa.id
+WHERE ga.answered_at IS NOT NULL;
+
+END;
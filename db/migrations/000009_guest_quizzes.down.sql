@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE quizzes DROP CONSTRAINT IF EXISTS template_not_open_for_guests;
+ALTER TABLE quizzes DROP COLUMN IF EXISTS open_for_guests;
+
+END;
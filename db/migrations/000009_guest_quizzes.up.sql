@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Quizzes editors have chosen to show to guests. If none are chosen, guests get the most recently ended quiz.
+ALTER TABLE quizzes ADD COLUMN IF NOT EXISTS open_for_guests BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE quizzes ADD CONSTRAINT template_not_open_for_guests CHECK (NOT (is_template AND open_for_guests));
+
+END;
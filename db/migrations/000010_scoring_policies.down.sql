@@ -0,0 +1,80 @@
+BEGIN;
+
+-- calculates points awarded for a question based on the time spent on the question, question's max points and duration/time limit
+CREATE OR REPLACE FUNCTION calculate_points_awarded(
+    start_time TIMESTAMPTZ,
+    end_time TIMESTAMPTZ,
+    duration_seconds INTEGER,
+    max_points INTEGER
+    )
+RETURNS INTEGER AS $$
+DECLARE
+    awarded_points INTEGER;
+    time_spent float8;
+    min_points INTEGER;
+BEGIN
+    time_spent := EXTRACT(EPOCH FROM end_time - start_time);
+    min_points := max_points / 5;
+
+    -- f(x)=(-a*t + a*x + b*c - b*x)/c-t, where a=max_points, b=min_points, c=grace_seconds, t=time_limit
+    awarded_points := (-1*max_points*duration_seconds + max_points*time_spent + min_points*3 - min_points*time_spent)/(3-duration_seconds);
+    IF awarded_points < min_points THEN
+        awarded_points := min_points;
+    END IF;
+
+    IF awarded_points > max_points THEN
+        awarded_points := max_points;
+    END IF;
+
+    RETURN awarded_points;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE VIEW user_question_points AS
+SELECT
+ua.user_id,
+ua.question_id,
+q.quiz_id,
+ua.answered_at AS answered_at,
+ua.chosen_answer_alternative_id, 
+CASE
+    WHEN aa.correct THEN
+        calculate_points_awarded(ua.question_presented_at, ua.answered_at, q.time_limit_seconds, q.points)
+    ELSE
+        0
+END AS points_awarded
+
+FROM user_answers ua
+JOIN questions q ON ua.question_id = q.id
+JOIN answer_alternatives aa ON ua.chosen_answer_alternative_id = aa.id
+WHERE ua.answered_at IS NOT NULL;
+
+CREATE OR REPLACE VIEW guest_question_points AS
+SELECT
+ga.guest_session_id,
+ga.question_id,
+q.quiz_id,
+ga.answered_at AS answered_at,
+ga.chosen_answer_alternative_id,
+CASE
+    WHEN aa.correct THEN
+        calculate_points_awarded(ga.question_presented_at, ga.answered_at, q.time_limit_seconds, q.points)
+    ELSE
+        0
+END AS points_awarded
+
+FROM guest_answers ga
+JOIN questions q ON ga.question_id = q.id
+JOIN answer_alternatives aa ON ga.chosen_answer_alternative_id = aa.id
+WHERE ga.answered_at IS NOT NULL;
+
+DROP FUNCTION IF EXISTS calculate_points_awarded(TIMESTAMPTZ, TIMESTAMPTZ, INTEGER, INTEGER, BOOLEAN, BIGINT,
+    scoring_rule, INTEGER, INTEGER, INTEGER, INTEGER);
+
+DROP TRIGGER IF EXISTS insert_default_scoring_policy ON quizzes;
+DROP FUNCTION IF EXISTS insert_default_scoring_policy;
+
+DROP TABLE IF EXISTS quiz_scoring_policies;
+DROP TYPE IF EXISTS scoring_rule;
+
+END;
@@ -0,0 +1,166 @@
+BEGIN;
+
+-- the views got a score column, which can not be removed by replacing them
+DROP VIEW IF EXISTS user_quizzes;
+DROP VIEW IF EXISTS user_question_points;
+DROP VIEW IF EXISTS guest_question_points;
+
+-- calculates points awarded for an answer based on the quiz's scoring policy, whether the answer is correct,
+-- the time spent on the question, question's max points and duration/time limit,
+-- and how many correct answers in a row the user gave right before this one
+CREATE OR REPLACE FUNCTION calculate_points_awarded(
+    start_time TIMESTAMPTZ,
+    end_time TIMESTAMPTZ,
+    duration_seconds INTEGER,
+    max_points INTEGER,
+    is_correct BOOLEAN,
+    streak BIGINT,
+    rule scoring_rule,
+    grace_seconds INTEGER,
+    min_points_percent INTEGER,
+    streak_bonus_percent INTEGER,
+    wrong_answer_penalty_percent INTEGER
+    )
+RETURNS INTEGER AS $$
+DECLARE
+    awarded_points INTEGER;
+    time_spent float8;
+    min_points INTEGER;
+BEGIN
+    IF NOT is_correct THEN
+        IF rule = 'wrong_answer_penalty' THEN
+            RETURN -(max_points * wrong_answer_penalty_percent / 100);
+        END IF;
+        RETURN 0;
+    END IF;
+
+    IF rule = 'flat' THEN
+        RETURN max_points;
+    END IF;
+
+    time_spent := EXTRACT(EPOCH FROM end_time - start_time);
+    min_points := max_points * min_points_percent / 100;
+
+    IF time_spent <= grace_seconds OR duration_seconds <= grace_seconds THEN
+        awarded_points := max_points;
+    ELSE
+        -- decays linearly from max_points at the end of the grace period to min_points at the time limit
+        awarded_points := max_points - (max_points - min_points) * (time_spent - grace_seconds) / (duration_seconds - grace_seconds);
+    END IF;
+
+    IF awarded_points < min_points THEN
+        awarded_points := min_points;
+    END IF;
+
+    IF awarded_points > max_points THEN
+        awarded_points := max_points;
+    END IF;
+
+    IF rule = 'streak' THEN
+        awarded_points := awarded_points + awarded_points * LEAST(streak * streak_bonus_percent, 100) / 100;
+    END IF;
+
+    RETURN awarded_points;
+END;
+$$ LANGUAGE plpgsql;
+
+-- View with all questions user has answered, points awarded and when the question was answered.
+-- The streak is the number of correct answers in a row right before the answer, in the order of the questions.
+-- wrong_answers counts the wrong answers up to and including the answer, so the answers since the last wrong
+-- answer share it, and the first of them is the wrong answer itself unless there is none.
+CREATE VIEW user_question_points AS
+SELECT
+a.user_id,
+a.question_id,
+a.quiz_id,
+a.answered_at AS answered_at,
+a.chosen_answer_alternative_id,
+calculate_points_awarded(a.question_presented_at, a.answered_at, a.time_limit_seconds, a.points, a.correct,
+    ROW_NUMBER() OVER (PARTITION BY a.user_id, a.quiz_id, a.wrong_answers ORDER BY a.arrangement) - 1
+        - CASE WHEN a.wrong_answers > 0 THEN 1 ELSE 0 END,
+    sp.rule, sp.grace_seconds, sp.min_points_percent,
+    sp.streak_bonus_percent, sp.wrong_answer_penalty_percent
+) AS points_awarded
+
+FROM (
+    SELECT ua.user_id, ua.question_id, q.quiz_id, ua.answered_at, ua.chosen_answer_alternative_id,
+    ua.question_presented_at, q.time_limit_seconds, q.points, q.arrangement, aa.correct,
+    COUNT(*) FILTER (WHERE NOT aa.correct) OVER (PARTITION BY ua.user_id, q.quiz_id ORDER BY q.arrangement) AS wrong_answers
+    FROM user_answers ua
+    JOIN questions q ON ua.question_id = q.id
+    JOIN answer_alternatives aa ON ua.chosen_answer_alternative_id = aa.id
+    WHERE ua.answered_at IS NOT NULL
+) a
+JOIN quiz_scoring_policies sp ON sp.quiz_id = a.quiz_id;
+
+-- Same as user_question_points, but for guest sessions.
+CREATE VIEW guest_question_points AS
+SELECT
+a.guest_session_id,
+a.question_id,
+a.quiz_id,
+a.answered_at AS answered_at,
+a.chosen_answer_alternative_id,
+calculate_points_awarded(a.question_presented_at, a.answered_at, a.time_limit_seconds, a.points, a.correct,
+    ROW_NUMBER() OVER (PARTITION BY a.guest_session_id, a.quiz_id, a.wrong_answers ORDER BY a.arrangement) - 1
+        - CASE WHEN a.wrong_answers > 0 THEN 1 ELSE 0 END,
+    sp.rule, sp.grace_seconds, sp.min_points_percent,
+    sp.streak_bonus_percent, sp.wrong_answer_penalty_percent
+) AS points_awarded
+
+FROM (
+    SELECT ga.guest_session_id, ga.question_id, q.quiz_id, ga.answered_at, ga.chosen_answer_alternative_id,
+    ga.question_presented_at, q.time_limit_seconds, q.points, q.arrangement, aa.correct,
+    COUNT(*) FILTER (WHERE NOT aa.correct) OVER (PARTITION BY ga.guest_session_id, q.quiz_id ORDER BY q.arrangement) AS wrong_answers
+    FROM guest_answers ga
+    JOIN questions q ON ga.question_id = q.id
+    JOIN answer_alternatives aa ON ga.chosen_answer_alternative_id = aa.id
+    WHERE ga.answered_at IS NOT NULL
+) a
+JOIN quiz_scoring_policies sp ON sp.quiz_id = a.quiz_id;
+
+-- View to get all quizzes user has played, total points, last answer time, is_completed and answered_within_active_time
+CREATE VIEW user_quizzes AS
+SELECT
+uqp.user_id,
+uqp.quiz_id,
+SUM(uqp.points_awarded) AS total_points_awarded,
+ic.is_completed,
+MAX(uqp.answered_at) AS finished_at,
+MAX(uqp.answered_at) < qz.active_to AS answered_within_active_time
+FROM user_question_points uqp
+JOIN questions q ON uqp.question_id = q.id
+JOIN quizzes qz ON q.quiz_id = qz.id,
+LATERAL (
+    -- check if there are questions user has not answered in the quiz
+    SELECT COUNT(q.id) = 0 as is_completed
+		FROM questions q
+		WHERE quiz_id = uqp.quiz_id 
+		AND id NOT IN (
+			SELECT question_id
+			FROM user_answers
+			WHERE chosen_answer_alternative_id IS NOT NULL
+			AND user_id = uqp.user_id
+		)
+) ic
+GROUP BY uqp.user_id, uqp.quiz_id, ic.is_completed, qz.active_to;
+
+DROP FUNCTION IF EXISTS calculate_points_awarded(TIMESTAMPTZ, TIMESTAMPTZ, INTEGER, INTEGER, DOUBLE PRECISION, BIGINT,
+    scoring_rule, INTEGER, INTEGER, INTEGER, INTEGER);
+DROP FUNCTION IF EXISTS answer_text(question_type, UUID, UUID[], DOUBLE PRECISION, TEXT);
+DROP FUNCTION IF EXISTS answer_score(UUID, question_type, UUID, UUID[], DOUBLE PRECISION, DOUBLE PRECISION, DOUBLE PRECISION);
+
+ALTER TABLE guest_answers DROP COLUMN IF EXISTS numeric_answer;
+ALTER TABLE guest_answers DROP COLUMN IF EXISTS chosen_alternative_ids;
+ALTER TABLE user_answers DROP COLUMN IF EXISTS numeric_answer;
+ALTER TABLE user_answers DROP COLUMN IF EXISTS chosen_alternative_ids;
+
+ALTER TABLE questions DROP CONSTRAINT IF EXISTS numeric_estimate_has_answer;
+ALTER TABLE questions DROP COLUMN IF EXISTS numeric_unit;
+ALTER TABLE questions DROP COLUMN IF EXISTS numeric_tolerance;
+ALTER TABLE questions DROP COLUMN IF EXISTS numeric_answer;
+ALTER TABLE questions DROP COLUMN IF EXISTS question_type;
+
+DROP TYPE IF EXISTS question_type;
+
+END;
@@ -0,0 +1,277 @@
+BEGIN;
+
+CREATE TYPE question_type AS ENUM ('single_choice', 'multi_select', 'true_false', 'numeric_estimate', 'ordering');
+
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS question_type question_type NOT NULL DEFAULT 'single_choice';
+-- the correct answer of numeric estimate questions. Answers this far off or further get no points.
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS numeric_answer DOUBLE PRECISION;
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS numeric_tolerance DOUBLE PRECISION CHECK (numeric_tolerance >= 0);
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS numeric_unit TEXT NOT NULL DEFAULT '';
+ALTER TABLE questions ADD CONSTRAINT numeric_estimate_has_answer
+    CHECK (question_type != 'numeric_estimate' OR (numeric_answer IS NOT NULL AND numeric_tolerance IS NOT NULL));
+
+-- Single choice and true/false answers use chosen_answer_alternative_id.
+-- Multi-select answers list the chosen alternatives, and ordering answers list all alternatives in the chosen order.
+ALTER TABLE user_answers ADD COLUMN IF NOT EXISTS chosen_alternative_ids UUID[];
+ALTER TABLE user_answers ADD COLUMN IF NOT EXISTS numeric_answer DOUBLE PRECISION;
+ALTER TABLE guest_answers ADD COLUMN IF NOT EXISTS chosen_alternative_ids UUID[];
+ALTER TABLE guest_answers ADD COLUMN IF NOT EXISTS numeric_answer DOUBLE PRECISION;
+
+-- calculates how correct an answer is, from 0 (wrong) to 1 (correct), based on the type of the question.
+-- Multi-select answers lose as much for each wrong alternative as they get for each correct one,
+-- ordering answers get the share of alternatives in the right position,
+-- and numeric estimates get less the further off they are, down to 0 at the tolerance.
+CREATE OR REPLACE FUNCTION answer_score(
+    answered_question_id UUID,
+    answered_question_type question_type,
+    chosen_alternative_id UUID,
+    chosen_alternative_ids UUID[],
+    estimate DOUBLE PRECISION,
+    correct_estimate DOUBLE PRECISION,
+    tolerance DOUBLE PRECISION
+    )
+RETURNS DOUBLE PRECISION AS $$
+DECLARE
+    total_count INTEGER;
+    right_count INTEGER;
+    wrong_count INTEGER;
+BEGIN
+    CASE answered_question_type
+    WHEN 'multi_select' THEN
+        SELECT
+            COUNT(*) FILTER (WHERE aa.correct),
+            COUNT(*) FILTER (WHERE aa.correct AND aa.id = ANY(chosen_alternative_ids)),
+            COUNT(*) FILTER (WHERE NOT aa.correct AND aa.id = ANY(chosen_alternative_ids))
+        INTO total_count, right_count, wrong_count
+        FROM answer_alternatives aa
+        WHERE aa.question_id = answered_question_id;
+
+        IF total_count = 0 THEN
+            RETURN 0;
+        END IF;
+        RETURN GREATEST(0, (right_count - wrong_count)::DOUBLE PRECISION / total_count);
+
+    WHEN 'ordering' THEN
+        SELECT COUNT(*), COUNT(*) FILTER (WHERE o.correct_position = array_position(chosen_alternative_ids, o.id))
+        INTO total_count, right_count
+        FROM (
+            SELECT aa.id, ROW_NUMBER() OVER (ORDER BY aa.arrangement) AS correct_position
+            FROM answer_alternatives aa
+            WHERE aa.question_id = answered_question_id
+        ) o;
+
+        IF total_count = 0 THEN
+            RETURN 0;
+        END IF;
+        RETURN right_count::DOUBLE PRECISION / total_count;
+
+    WHEN 'numeric_estimate' THEN
+        IF estimate IS NULL OR correct_estimate IS NULL THEN
+            RETURN 0;
+        END IF;
+        IF tolerance IS NULL OR tolerance = 0 THEN
+            RETURN CASE WHEN estimate = correct_estimate THEN 1 ELSE 0 END;
+        END IF;
+        RETURN GREATEST(0, 1 - ABS(estimate - correct_estimate) / tolerance);
+
+    ELSE
+        RETURN COALESCE((
+            SELECT CASE WHEN aa.correct THEN 1 ELSE 0 END
+            FROM answer_alternatives aa
+            WHERE aa.id = chosen_alternative_id
+        ), 0);
+    END CASE;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- describes an answer, with the texts of the chosen alternatives in the chosen order or the estimate with its unit
+CREATE OR REPLACE FUNCTION answer_text(
+    answered_question_type question_type,
+    chosen_alternative_id UUID,
+    chosen_alternative_ids UUID[],
+    estimate DOUBLE PRECISION,
+    unit TEXT
+    )
+RETURNS TEXT AS $$
+BEGIN
+    CASE answered_question_type
+    WHEN 'numeric_estimate' THEN
+        IF estimate IS NULL THEN
+            RETURN '';
+        END IF;
+        RETURN replace(estimate::TEXT, '.', ',') || CASE WHEN unit != '' THEN ' ' || unit ELSE '' END;
+
+    WHEN 'multi_select', 'ordering' THEN
+        RETURN COALESCE((
+            SELECT string_agg(aa.text, ', ' ORDER BY array_position(chosen_alternative_ids, aa.id))
+            FROM answer_alternatives aa
+            WHERE aa.id = ANY(chosen_alternative_ids)
+        ), '');
+
+    ELSE
+        RETURN COALESCE((
+            SELECT aa.text
+            FROM answer_alternatives aa
+            WHERE aa.id = chosen_alternative_id
+        ), '');
+    END CASE;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- calculates points awarded for an answer based on the quiz's scoring policy, how correct the answer is (see answer_score),
+-- the time spent on the question, question's max points and duration/time limit,
+-- and how many correct answers in a row the user gave right before this one.
+-- Partly correct answers get their share of the points.
+CREATE OR REPLACE FUNCTION calculate_points_awarded(
+    start_time TIMESTAMPTZ,
+    end_time TIMESTAMPTZ,
+    duration_seconds INTEGER,
+    max_points INTEGER,
+    score DOUBLE PRECISION,
+    streak BIGINT,
+    rule scoring_rule,
+    grace_seconds INTEGER,
+    min_points_percent INTEGER,
+    streak_bonus_percent INTEGER,
+    wrong_answer_penalty_percent INTEGER
+    )
+RETURNS INTEGER AS $$
+DECLARE
+    awarded_points INTEGER;
+    time_spent float8;
+    min_points INTEGER;
+BEGIN
+    IF score <= 0 THEN
+        IF rule = 'wrong_answer_penalty' THEN
+            RETURN -(max_points * wrong_answer_penalty_percent / 100);
+        END IF;
+        RETURN 0;
+    END IF;
+
+    IF rule = 'flat' THEN
+        RETURN ROUND(max_points * score);
+    END IF;
+
+    time_spent := EXTRACT(EPOCH FROM end_time - start_time);
+    min_points := max_points * min_points_percent / 100;
+
+    IF time_spent <= grace_seconds OR duration_seconds <= grace_seconds THEN
+        awarded_points := max_points;
+    ELSE
+        -- decays linearly from max_points at the end of the grace period to min_points at the time limit
+        awarded_points := max_points - (max_points - min_points) * (time_spent - grace_seconds) / (duration_seconds - grace_seconds);
+    END IF;
+
+    IF awarded_points < min_points THEN
+        awarded_points := min_points;
+    END IF;
+
+    IF awarded_points > max_points THEN
+        awarded_points := max_points;
+    END IF;
+
+    awarded_points := awarded_points * score;
+
+    IF rule = 'streak' THEN
+        awarded_points := awarded_points + awarded_points * LEAST(streak * streak_bonus_percent, 100) / 100;
+    END IF;
+
+    RETURN awarded_points;
+END;
+$$ LANGUAGE plpgsql;
+
+-- View with all questions user has answered, points awarded, how correct the answer is and when the question was answered.
+-- The streak is the number of fully correct answers in a row right before the answer, in the order of the questions.
+CREATE OR REPLACE VIEW user_question_points AS
+SELECT
+s.user_id,
+s.question_id,
+s.quiz_id,
+s.answered_at AS answered_at,
+s.chosen_answer_alternative_id,
+calculate_points_awarded(s.question_presented_at, s.answered_at, s.time_limit_seconds, s.points, s.score, s.streak,
+    sp.rule, sp.grace_seconds, sp.min_points_percent,
+    sp.streak_bonus_percent, sp.wrong_answer_penalty_percent
+) AS points_awarded,
+s.score
+
+FROM (
+    SELECT a.*,
+    a.position - COALESCE(MAX(CASE WHEN a.score < 1 THEN a.position END) OVER (
+        PARTITION BY a.user_id, a.quiz_id ORDER BY a.position ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+    ), 0) - 1 AS streak
+    FROM (
+        SELECT ua.user_id, ua.question_id, q.quiz_id, ua.answered_at, ua.chosen_answer_alternative_id,
+        ua.question_presented_at, q.time_limit_seconds, q.points,
+        answer_score(q.id, q.question_type, ua.chosen_answer_alternative_id, ua.chosen_alternative_ids,
+            ua.numeric_answer, q.numeric_answer, q.numeric_tolerance) AS score,
+        ROW_NUMBER() OVER (PARTITION BY ua.user_id, q.quiz_id ORDER BY q.arrangement) AS position
+        FROM user_answers ua
+        JOIN questions q ON ua.question_id = q.id
+        WHERE ua.answered_at IS NOT NULL
+    ) a
+) s
+JOIN quiz_scoring_policies sp ON sp.quiz_id = s.quiz_id;
+
+-- Same as user_question_points, but for guest sessions.
+CREATE OR REPLACE VIEW guest_question_points AS
+SELECT
+s.guest_session_id,
+s.question_id,
+s.quiz_id,
+s.answered_at AS answered_at,
+s.chosen_answer_alternative_id,
+calculate_points_awarded(s.question_presented_at, s.answered_at, s.time_limit_seconds, s.points, s.score, s.streak,
+    sp.rule, sp.grace_seconds, sp.min_points_percent,
+    sp.streak_bonus_percent, sp.wrong_answer_penalty_percent
+) AS points_awarded,
+s.score
+
+FROM (
+    SELECT a.*,
+    a.position - COALESCE(MAX(CASE WHEN a.score < 1 THEN a.position END) OVER (
+        PARTITION BY a.guest_session_id, a.quiz_id ORDER BY a.position ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+    ), 0) - 1 AS streak
+    FROM (
+        SELECT ga.guest_session_id, ga.question_id, q.quiz_id, ga.answered_at, ga.chosen_answer_alternative_id,
+        ga.question_presented_at, q.time_limit_seconds, q.points,
+        answer_score(q.id, q.question_type, ga.chosen_answer_alternative_id, ga.chosen_alternative_ids,
+            ga.numeric_answer, q.numeric_answer, q.numeric_tolerance) AS score,
+        ROW_NUMBER() OVER (PARTITION BY ga.guest_session_id, q.quiz_id ORDER BY q.arrangement) AS position
+        FROM guest_answers ga
+        JOIN questions q ON ga.question_id = q.id
+        WHERE ga.answered_at IS NOT NULL
+    ) a
+) s
+JOIN quiz_scoring_policies sp ON sp.quiz_id = s.quiz_id;
+
+-- Questions are answered once answered_at is set, as not all types of answers have a chosen alternative.
+CREATE OR REPLACE VIEW user_quizzes AS
+SELECT
+uqp.user_id,
+uqp.quiz_id,
+SUM(uqp.points_awarded) AS total_points_awarded,
+ic.is_completed,
+MAX(uqp.answered_at) AS finished_at,
+MAX(uqp.answered_at) < qz.active_to AS answered_within_active_time
+FROM user_question_points uqp
+JOIN questions q ON uqp.question_id = q.id
+JOIN quizzes qz ON q.quiz_id = qz.id,
+LATERAL (
+    -- check if there are questions user has not answered in the quiz
+    SELECT COUNT(q.id) = 0 as is_completed
+		FROM questions q
+		WHERE quiz_id = uqp.quiz_id 
+		AND id NOT IN (
+			SELECT question_id
+			FROM user_answers
+			WHERE answered_at IS NOT NULL
+			AND user_id = uqp.user_id
+		)
+) ic
+GROUP BY uqp.user_id, uqp.quiz_id, ic.is_completed, qz.active_to;
+
+DROP FUNCTION IF EXISTS calculate_points_awarded(TIMESTAMPTZ, TIMESTAMPTZ, INTEGER, INTEGER, BOOLEAN, BIGINT,
+    scoring_rule, INTEGER, INTEGER, INTEGER, INTEGER);
+
+END;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- shown to players after they answer the question, along with a link to the question's article
+ALTER TABLE questions ADD COLUMN explanation TEXT NOT NULL DEFAULT '';
+
+END;
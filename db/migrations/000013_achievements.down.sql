@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS user_badges;
+DROP TYPE IF EXISTS badge;
+
+END;
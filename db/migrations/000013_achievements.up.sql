@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TYPE badge AS ENUM ('first_quiz', 'perfect_score', 'fast_answers', 'streak_4_weeks', 'streak_12_weeks', 'label_completed');
+
+-- badges awarded to users when they complete a quiz, each badge is only awarded once (once per label for label_completed)
+CREATE TABLE IF NOT EXISTS user_badges (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    badge badge NOT NULL,
+    label_id UUID REFERENCES labels(id) ON DELETE CASCADE,
+    -- the quiz which completion awarded the badge
+    quiz_id UUID REFERENCES quizzes(id) ON DELETE SET NULL,
+    awarded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT user_badges_unique UNIQUE NULLS NOT DISTINCT (user_id, badge, label_id),
+    CONSTRAINT label_badge_has_label CHECK ((badge = 'label_completed') = (label_id IS NOT NULL))
+);
+
+END;
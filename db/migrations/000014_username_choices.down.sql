@@ -0,0 +1,16 @@
+BEGIN;
+
+CREATE OR REPLACE VIEW available_usernames AS
+    SELECT a.adjective, n.noun
+    FROM adjectives a
+    CROSS JOIN nouns n
+    WHERE NOT EXISTS (
+        SELECT 1
+        FROM users u
+        WHERE u.username_adjective = a.adjective AND u.username_noun = n.noun
+    );
+
+DROP TABLE IF EXISTS username_reservations;
+DROP TABLE IF EXISTS blocked_usernames;
+
+END;
@@ -0,0 +1,41 @@
+BEGIN;
+
+-- Combinations of an adjective and a noun which are offensive together, even though each word is fine alone
+CREATE TABLE IF NOT EXISTS blocked_usernames (
+    adjective TEXT NOT NULL REFERENCES adjectives(adjective) ON UPDATE CASCADE ON DELETE CASCADE,
+    noun TEXT NOT NULL REFERENCES nouns(noun) ON UPDATE CASCADE ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (adjective, noun)
+);
+
+-- A username a user has picked but not yet saved, so no one else can take it in the meantime.
+-- Each user can only reserve one username at a time.
+CREATE TABLE IF NOT EXISTS username_reservations (
+    adjective TEXT NOT NULL REFERENCES adjectives(adjective) ON UPDATE CASCADE ON DELETE CASCADE,
+    noun TEXT NOT NULL REFERENCES nouns(noun) ON UPDATE CASCADE ON DELETE CASCADE,
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (adjective, noun)
+);
+
+CREATE OR REPLACE VIEW available_usernames AS
+    SELECT a.adjective, n.noun
+    FROM adjectives a
+    CROSS JOIN nouns n
+    WHERE NOT EXISTS (
+        SELECT 1
+        FROM users u
+        WHERE u.username_adjective = a.adjective AND u.username_noun = n.noun
+    )
+    AND NOT EXISTS (
+        SELECT 1
+        FROM blocked_usernames b
+        WHERE b.adjective = a.adjective AND b.noun = n.noun
+    )
+    AND NOT EXISTS (
+        SELECT 1
+        FROM username_reservations r
+        WHERE r.adjective = a.adjective AND r.noun = n.noun AND r.expires_at > now()
+    );
+
+END;
@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP TABLE IF EXISTS competition_results;
+DROP FUNCTION IF EXISTS anonymise_competition_result;
+
+ALTER TABLE labels
+    DROP CONSTRAINT IF EXISTS labels_competition_period,
+    DROP COLUMN IF EXISTS starts_at,
+    DROP COLUMN IF EXISTS ends_at,
+    DROP COLUMN IF EXISTS prize,
+    DROP COLUMN IF EXISTS results_frozen_at;
+
+END;
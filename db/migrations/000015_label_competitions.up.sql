@@ -0,0 +1,41 @@
+BEGIN;
+
+-- A label with an end time is a competition, ranked by the quizzes with the label completed between the start and end
+ALTER TABLE labels
+    ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS ends_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS prize TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS results_frozen_at TIMESTAMPTZ,
+    ADD CONSTRAINT labels_competition_period CHECK (starts_at IS NULL OR ends_at IS NULL OR starts_at < ends_at);
+
+-- The final standings of a competition, frozen when it ends.
+-- They are copied rather than computed, so they do not change if quizzes are later edited or deleted.
+CREATE TABLE IF NOT EXISTS competition_results (
+    label_id UUID NOT NULL REFERENCES labels(id) ON DELETE CASCADE,
+    -- The placement and points of deleted users are kept, so the standings never change.
+    -- The username is personal data, so it is removed with the user.
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    username TEXT,
+    points INT NOT NULL,
+    placement INT NOT NULL,
+    quizzes_completed INT NOT NULL,
+    CONSTRAINT competition_results_label_user UNIQUE (label_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS competition_results_placement_idx ON competition_results (label_id, placement);
+
+CREATE OR REPLACE FUNCTION anonymise_competition_result()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.username := NULL;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER anonymise_competition_result
+    BEFORE UPDATE ON competition_results
+    FOR EACH ROW
+    WHEN (NEW.user_id IS NULL)
+    EXECUTE FUNCTION anonymise_competition_result();
+
+END;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS prize_draw_entrants;
+DROP TABLE IF EXISTS prize_draws;
+
+END;
@@ -0,0 +1,37 @@
+BEGIN;
+
+-- Winners picked by organization admins, either the top of the ranking or a weighted random draw.
+-- The scope, seed and entrants are stored so a draw can be reproduced and verified later.
+CREATE TABLE IF NOT EXISTS prize_draws (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    method TEXT NOT NULL CHECK (method IN ('top', 'random')),
+    label_id UUID REFERENCES labels(id) ON DELETE SET NULL,
+    -- Kept so the scope is known after the label is deleted
+    label_name TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    winner_count INT NOT NULL CHECK (winner_count > 0),
+    -- Only set for random draws
+    seed BIGINT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_by_email TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS prize_draws_created_at_idx ON prize_draws (created_at DESC);
+
+-- Everyone who could win a draw, in the order the draw walked through them.
+-- Only the user id is kept, so contact details are never copied and disappear with the user,
+-- while the points and weights needed to reproduce the draw are kept.
+CREATE TABLE IF NOT EXISTS prize_draw_entrants (
+    draw_id UUID NOT NULL REFERENCES prize_draws(id) ON DELETE CASCADE,
+    entry_order INT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    points INT NOT NULL,
+    quizzes_completed INT NOT NULL,
+    -- The order the winners were picked in, NULL for entrants who did not win
+    winner_position INT,
+    PRIMARY KEY (draw_id, entry_order)
+);
+
+END;
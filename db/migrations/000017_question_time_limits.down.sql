@@ -0,0 +1,71 @@
+BEGIN;
+
+CREATE OR REPLACE VIEW user_question_points AS
+SELECT
+s.user_id,
+s.question_id,
+s.quiz_id,
+s.answered_at AS answered_at,
+s.chosen_answer_alternative_id,
+calculate_points_awarded(s.question_presented_at, s.answered_at, s.time_limit_seconds, s.points, s.score, s.streak,
+    sp.rule, sp.grace_seconds, sp.min_points_percent,
+    sp.streak_bonus_percent, sp.wrong_answer_penalty_percent
+) AS points_awarded,
+s.score
+
+FROM (
+    SELECT a.*,
+    a.position - COALESCE(MAX(CASE WHEN a.score < 1 THEN a.position END) OVER (
+        PARTITION BY a.user_id, a.quiz_id ORDER BY a.position ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+    ), 0) - 1 AS streak
+    FROM (
+        SELECT ua.user_id, ua.question_id, q.quiz_id, ua.answered_at, ua.chosen_answer_alternative_id,
+        ua.question_presented_at, q.time_limit_seconds, q.points,
+        answer_score(q.id, q.question_type, ua.chosen_answer_alternative_id, ua.chosen_alternative_ids,
+            ua.numeric_answer, q.numeric_answer, q.numeric_tolerance) AS score,
+        ROW_NUMBER() OVER (PARTITION BY ua.user_id, q.quiz_id ORDER BY q.arrangement) AS position
+        FROM user_answers ua
+        JOIN questions q ON ua.question_id = q.id
+        WHERE ua.answered_at IS NOT NULL
+    ) a
+) s
+JOIN quiz_scoring_policies sp ON sp.quiz_id = s.quiz_id;
+
+-- Same as user_question_points, but for guest sessions.
+CREATE OR REPLACE VIEW guest_question_points AS
+SELECT
+s.guest_session_id,
+s.question_id,
+s.quiz_id,
+s.answered_at AS answered_at,
+s.chosen_answer_alternative_id,
+calculate_points_awarded(s.question_presented_at, s.answered_at, s.time_limit_seconds, s.points, s.score, s.streak,
+    sp.rule, sp.grace_seconds, sp.min_points_percent,
+    sp.streak_bonus_percent, sp.wrong_answer_penalty_percent
+) AS points_awarded,
+s.score
+
+FROM (
+    SELECT a.*,
+    a.position - COALESCE(MAX(CASE WHEN a.score < 1 THEN a.position END) OVER (
+        PARTITION BY a.guest_session_id, a.quiz_id ORDER BY a.position ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+    ), 0) - 1 AS streak
+    FROM (
+        SELECT ga.guest_session_id, ga.question_id, q.quiz_id, ga.answered_at, ga.chosen_answer_alternative_id,
+        ga.question_presented_at, q.time_limit_seconds, q.points,
+        answer_score(q.id, q.question_type, ga.chosen_answer_alternative_id, ga.chosen_alternative_ids,
+            ga.numeric_answer, q.numeric_answer, q.numeric_tolerance) AS score,
+        ROW_NUMBER() OVER (PARTITION BY ga.guest_session_id, q.quiz_id ORDER BY q.arrangement) AS position
+        FROM guest_answers ga
+        JOIN questions q ON ga.question_id = q.id
+        WHERE ga.answered_at IS NOT NULL
+    ) a
+) s
+JOIN quiz_scoring_policies sp ON sp.quiz_id = s.quiz_id;
+
+ALTER TABLE guest_answers
+    DROP COLUMN IF EXISTS timed_out;
+ALTER TABLE user_answers
+    DROP COLUMN IF EXISTS timed_out;
+
+END;
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	ProviderGoogle        = "google"
+	GOOGLE_OAUTH_API_URL  = "https://www.googleapis.com/oauth2/v2/userinfo?access_token="
+	GOOGLE_REVOKE_API_URL = "https://oauth2.googleapis.com/revoke"
+)
+
+type GoogleSsoConfig struct {
+	RedirectUrl  string
+	ClientId     string
+	ClientSecret string
+}
+
+// Login with Google accounts.
+type GoogleProvider struct {
+	oauthConfig *oauth2.Config
+}
+
+// Creates a provider for logging in with Google.
+func NewGoogleProvider(config GoogleSsoConfig) *GoogleProvider {
+	return &GoogleProvider{
+		oauthConfig: &oauth2.Config{
+			ClientID:     config.ClientId,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectUrl,
+			Endpoint:     google.Endpoint,
+			Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email"},
+		},
+	}
+}
+
+func (gp *GoogleProvider) Name() string {
+	return ProviderGoogle
+}
+
+func (gp *GoogleProvider) DisplayName() string {
+	return "Google"
+}
+
+func (gp *GoogleProvider) AuthCodeURL(state string) string {
+	return gp.oauthConfig.AuthCodeURL(state)
+}
+
+func (gp *GoogleProvider) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	return gp.oauthConfig.Exchange(ctx, code)
+}
+
+func (gp *GoogleProvider) Refresh(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	return gp.oauthConfig.TokenSource(ctx, token).Token()
+}
+
+// Google does not require the client to authenticate when revoking tokens.
+func (gp *GoogleProvider) Revoke(ctx context.Context, token *oauth2.Token) error {
+	return revokeToken(ctx, GOOGLE_REVOKE_API_URL, "", "", token)
+}
+
+func (gp *GoogleProvider) GetUser(ctx context.Context, token *oauth2.Token) (ProviderUser, error) {
+	usr, err := GetGoogleUserData(token.AccessToken)
+	if err != nil {
+		return ProviderUser{}, err
+	}
+	return ProviderUser{
+		ID:            usr.ID,
+		Email:         usr.Email,
+		EmailVerified: usr.Verified_email,
+	}, nil
+}
+
+// Struct to hold the user data from the Google OAuth2 API
+type googleUser struct {
+	Email          string `json:"email"`
+	ID             string `json:"id"`
+	Picture        string `json:"picture"`
+	Verified_email bool   `json:"verified_email"`
+}
+
+// Gets the user data from the Google OAuth2 API
+func GetGoogleUserData(accessToken string) (googleUser, error) {
+	resp, err := http.Get(GOOGLE_OAUTH_API_URL + accessToken)
+	if err != nil {
+		return googleUser{}, fmt.Errorf("failed to get user info: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return googleUser{}, fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+
+	var usr googleUser
+	err = json.Unmarshal(content, &usr)
+	if err != nil {
+		return googleUser{}, fmt.Errorf("failed to unmarshal user info: %s", err.Error())
+	}
+	return usr, nil
+}
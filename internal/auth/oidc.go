@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrDiscoveryFailed = errors.New("auth: openid connect discovery failed")
+	ErrIssuerMismatch  = errors.New("auth: issuer in discovery document does not match the configured issuer")
+	ErrMissingClaims   = errors.New("auth: user info is missing the subject or email")
+)
+
+// Path of the discovery document, relative to the issuer.
+const oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+// Configuration of a generic OpenID Connect provider, such as the newspaper's own subscriber login.
+type OIDCConfig struct {
+	Name         string // Lowercase letters, digits and dashes, e.g. "subscriber".
+	DisplayName  string
+	IssuerURL    string // The endpoints are read from the discovery document below this URL.
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // Should end in "/auth/<name>/callback".
+}
+
+// Login with any OpenID Connect provider, configured through its discovery document.
+//
+// The user is read from the provider's user info endpoint with the access token,
+// which is received directly from the provider's token endpoint.
+type OIDCProvider struct {
+	name        string
+	displayName string
+	oauthConfig *oauth2.Config
+	userInfoURL string
+	revokeURL   string // Empty if the provider does not support revoking tokens.
+}
+
+// The fields of the discovery document needed to log in.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	RevocationEndpoint    string `json:"revocation_endpoint"`
+}
+
+// Creates a provider by fetching the discovery document of the issuer.
+func NewOIDCProvider(ctx context.Context, config OIDCConfig) (*OIDCProvider, error) {
+	if err := validateProviderName(config.Name); err != nil {
+		return nil, err
+	}
+
+	issuer := strings.TrimSuffix(config.IssuerURL, "/")
+	discovery, err := fetchDiscovery(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("%w: expected %q, got %q", ErrIssuerMismatch, issuer, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.UserInfoEndpoint == "" {
+		return nil, fmt.Errorf("%w: missing authorization, token or userinfo endpoint", ErrDiscoveryFailed)
+	}
+
+	displayName := config.DisplayName
+	if displayName == "" {
+		displayName = config.Name
+	}
+
+	return &OIDCProvider{
+		name:        config.Name,
+		displayName: displayName,
+		oauthConfig: &oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  discovery.AuthorizationEndpoint,
+				TokenURL: discovery.TokenEndpoint,
+			},
+			Scopes: []string{"openid", "email"},
+		},
+		userInfoURL: discovery.UserInfoEndpoint,
+		revokeURL:   discovery.RevocationEndpoint,
+	}, nil
+}
+
+func fetchDiscovery(ctx context.Context, issuer string) (oidcDiscovery, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+oidcDiscoveryPath, nil)
+	if err != nil {
+		return oidcDiscovery{}, fmt.Errorf("%w: %s", ErrDiscoveryFailed, err.Error())
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return oidcDiscovery{}, fmt.Errorf("%w: %s", ErrDiscoveryFailed, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return oidcDiscovery{}, fmt.Errorf("%w: status %d", ErrDiscoveryFailed, resp.StatusCode)
+	}
+
+	var discovery oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return oidcDiscovery{}, fmt.Errorf("%w: %s", ErrDiscoveryFailed, err.Error())
+	}
+	return discovery, nil
+}
+
+func (op *OIDCProvider) Name() string {
+	return op.name
+}
+
+func (op *OIDCProvider) DisplayName() string {
+	return op.displayName
+}
+
+func (op *OIDCProvider) AuthCodeURL(state string) string {
+	return op.oauthConfig.AuthCodeURL(state)
+}
+
+func (op *OIDCProvider) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	return op.oauthConfig.Exchange(ctx, code)
+}
+
+func (op *OIDCProvider) Refresh(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	return op.oauthConfig.TokenSource(ctx, token).Token()
+}
+
+func (op *OIDCProvider) Revoke(ctx context.Context, token *oauth2.Token) error {
+	if op.revokeURL == "" {
+		return ErrRevokeUnsupported
+	}
+	return revokeToken(ctx, op.revokeURL, op.oauthConfig.ClientID, op.oauthConfig.ClientSecret, token)
+}
+
+// The standard claims of the user info response needed to log in.
+type oidcUserInfo struct {
+	Subject       string       `json:"sub"`
+	Email         string       `json:"email"`
+	EmailVerified flexibleBool `json:"email_verified"`
+}
+
+func (op *OIDCProvider) GetUser(ctx context.Context, token *oauth2.Token) (ProviderUser, error) {
+	resp, err := op.oauthConfig.Client(ctx, token).Get(op.userInfoURL)
+	if err != nil {
+		return ProviderUser{}, fmt.Errorf("failed to get user info: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ProviderUser{}, fmt.Errorf("failed to get user info: status %d", resp.StatusCode)
+	}
+
+	var info oidcUserInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return ProviderUser{}, fmt.Errorf("failed to unmarshal user info: %s", err.Error())
+	}
+	if info.Subject == "" || info.Email == "" {
+		return ProviderUser{}, ErrMissingClaims
+	}
+	return ProviderUser{
+		ID:            info.Subject,
+		Email:         info.Email,
+		EmailVerified: bool(info.EmailVerified),
+	}, nil
+}
+
+// Some providers send email_verified as the string "true" instead of a boolean.
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	*b = flexibleBool(strings.Trim(string(data), `"`) == "true")
+	return nil
+}
@@ -0,0 +1,247 @@
+//go:build unit
+
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/auth"
+)
+
+const (
+	stubCode               = "stub-code"
+	stubAccessToken        = "stub-access-token"
+	stubRefreshToken       = "stub-refresh-token"
+	stubRefreshAccessToken = "stub-refreshed-access-token"
+)
+
+// Starts a local stub OpenID Connect server.
+// The discovery document reports the given issuer, or the server's own URL if issuer is empty.
+func newStubOIDCServer(t *testing.T, issuer string, userInfo map[string]any) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	if issuer == "" {
+		issuer = server.URL
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"userinfo_endpoint":      server.URL + "/userinfo",
+			"revocation_endpoint":    server.URL + "/revoke",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		accessToken := stubAccessToken
+		switch {
+		case r.FormValue("grant_type") == "authorization_code" && r.FormValue("code") == stubCode:
+		case r.FormValue("grant_type") == "refresh_token" && r.FormValue("refresh_token") == stubRefreshToken:
+			accessToken = stubRefreshAccessToken
+		default:
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  accessToken,
+			"refresh_token": stubRefreshToken,
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	})
+	mux.HandleFunc("/revoke", func(w http.ResponseWriter, r *http.Request) {
+		clientID, _, ok := r.BasicAuth()
+		if !ok || clientID != "client-id" || r.FormValue("token") != stubRefreshToken {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+stubAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(userInfo)
+	})
+	return server
+}
+
+func newStubProvider(t *testing.T, server *httptest.Server) *auth.OIDCProvider {
+	provider, err := auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+		Name:        "subscriber",
+		DisplayName: "Abonnent",
+		IssuerURL:   server.URL,
+		ClientID:    "client-id",
+		RedirectURL: "http://localhost:8080/auth/subscriber/callback",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	return provider
+}
+
+// TestOIDCProviderLogin tests the whole login flow against the stub server
+func TestOIDCProviderLogin(t *testing.T) {
+	server := newStubOIDCServer(t, "", map[string]any{
+		"sub":            "subscriber-123",
+		"email":          "reader@example.com",
+		"email_verified": true,
+	})
+	provider := newStubProvider(t, server)
+
+	if provider.Name() != "subscriber" || provider.DisplayName() != "Abonnent" {
+		t.Errorf("Expected name 'subscriber' and display name 'Abonnent', but got %q and %q", provider.Name(), provider.DisplayName())
+	}
+
+	authURL, err := url.Parse(provider.AuthCodeURL("some-state"))
+	if err != nil {
+		t.Fatalf("Expected a valid auth code URL, but got %v", err)
+	}
+	if authURL.Path != "/authorize" || authURL.Query().Get("state") != "some-state" || authURL.Query().Get("scope") != "openid email" {
+		t.Errorf("Expected the discovered authorization endpoint with state and scopes, but got %s", authURL)
+	}
+
+	token, err := provider.Exchange(context.Background(), stubCode)
+	if err != nil {
+		t.Fatalf("Expected no error exchanging code, but got %v", err)
+	}
+
+	user, err := provider.GetUser(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Expected no error getting user, but got %v", err)
+	}
+	expected := auth.ProviderUser{ID: "subscriber-123", Email: "reader@example.com", EmailVerified: true}
+	if user != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, user)
+	}
+}
+
+// TestOIDCProviderRefreshAndRevoke tests that expired tokens are refreshed, and that tokens are revoked with the client's credentials
+func TestOIDCProviderRefreshAndRevoke(t *testing.T) {
+	server := newStubOIDCServer(t, "", map[string]any{})
+	provider := newStubProvider(t, server)
+
+	token, err := provider.Exchange(context.Background(), stubCode)
+	if err != nil {
+		t.Fatalf("Expected no error exchanging code, but got %v", err)
+	}
+
+	refreshed, err := provider.Refresh(context.Background(), token)
+	if err != nil || refreshed.AccessToken != stubAccessToken {
+		t.Errorf("Expected a valid token to be returned as it is, but got %v (%v)", refreshed, err)
+	}
+
+	token.Expiry = time.Now().Add(-time.Minute)
+	refreshed, err = provider.Refresh(context.Background(), token)
+	if err != nil || refreshed.AccessToken != stubRefreshAccessToken {
+		t.Errorf("Expected an expired token to be refreshed, but got %v (%v)", refreshed, err)
+	}
+
+	err = provider.Revoke(context.Background(), refreshed)
+	if err != nil {
+		t.Errorf("Expected no error revoking token, but got %v", err)
+	}
+}
+
+// TestOIDCProviderEmailVerifiedString tests that email_verified sent as a string is understood
+func TestOIDCProviderEmailVerifiedString(t *testing.T) {
+	server := newStubOIDCServer(t, "", map[string]any{
+		"sub":            "subscriber-123",
+		"email":          "reader@example.com",
+		"email_verified": "true",
+	})
+	provider := newStubProvider(t, server)
+
+	token, err := provider.Exchange(context.Background(), stubCode)
+	if err != nil {
+		t.Fatalf("Expected no error exchanging code, but got %v", err)
+	}
+	user, err := provider.GetUser(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Expected no error getting user, but got %v", err)
+	}
+	if !user.EmailVerified {
+		t.Error("Expected email to be verified")
+	}
+}
+
+// TestOIDCProviderMissingClaims tests that a user without an email is rejected
+func TestOIDCProviderMissingClaims(t *testing.T) {
+	server := newStubOIDCServer(t, "", map[string]any{"sub": "subscriber-123"})
+	provider := newStubProvider(t, server)
+
+	token, err := provider.Exchange(context.Background(), stubCode)
+	if err != nil {
+		t.Fatalf("Expected no error exchanging code, but got %v", err)
+	}
+	_, err = provider.GetUser(context.Background(), token)
+	if !errors.Is(err, auth.ErrMissingClaims) {
+		t.Errorf("Expected %v, but got %v", auth.ErrMissingClaims, err)
+	}
+}
+
+// TestOIDCProviderInvalidCode tests that exchanging an invalid code fails
+func TestOIDCProviderInvalidCode(t *testing.T) {
+	server := newStubOIDCServer(t, "", map[string]any{})
+	provider := newStubProvider(t, server)
+
+	_, err := provider.Exchange(context.Background(), "wrong-code")
+	if err == nil {
+		t.Error("Expected an error exchanging an invalid code")
+	}
+}
+
+// TestNewOIDCProviderInvalid tests that invalid configurations and discovery documents are rejected
+func TestNewOIDCProviderInvalid(t *testing.T) {
+	server := newStubOIDCServer(t, "", map[string]any{})
+	mismatchServer := newStubOIDCServer(t, "https://other-issuer.example.com", map[string]any{})
+
+	tests := []struct {
+		config   auth.OIDCConfig
+		expected error
+	}{
+		{auth.OIDCConfig{Name: "Subscriber", IssuerURL: server.URL}, auth.ErrInvalidProviderName},
+		{auth.OIDCConfig{Name: auth.ProviderEmail, IssuerURL: server.URL}, auth.ErrInvalidProviderName},
+		{auth.OIDCConfig{Name: "subscriber", IssuerURL: mismatchServer.URL}, auth.ErrIssuerMismatch},
+		{auth.OIDCConfig{Name: "subscriber", IssuerURL: server.URL + "/missing"}, auth.ErrDiscoveryFailed},
+	}
+	for _, test := range tests {
+		_, err := auth.NewOIDCProvider(context.Background(), test.config)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Expected %v for %+v, but got %v", test.expected, test.config, err)
+		}
+	}
+}
+
+// TestNewProviders tests that the providers can be looked up by name, and that names must be unique
+func TestNewProviders(t *testing.T) {
+	server := newStubOIDCServer(t, "", map[string]any{})
+	subscriber := newStubProvider(t, server)
+	google := auth.NewGoogleProvider(auth.GoogleSsoConfig{})
+
+	providers, err := auth.NewProviders(google, subscriber)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if provider, ok := providers.Get("subscriber"); !ok || provider != subscriber {
+		t.Error("Expected to find the subscriber provider")
+	}
+	if _, ok := providers.Get("unknown"); ok {
+		t.Error("Expected not to find an unknown provider")
+	}
+
+	_, err = auth.NewProviders(subscriber, subscriber)
+	if !errors.Is(err, auth.ErrDuplicateProviderName) {
+		t.Errorf("Expected %v, but got %v", auth.ErrDuplicateProviderName, err)
+	}
+}
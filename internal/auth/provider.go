@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrInvalidProviderName   = errors.New("auth: provider names may only contain lowercase letters, digits and dashes, and can not be \"email\"")
+	ErrDuplicateProviderName = errors.New("auth: provider name is used more than once")
+	ErrRevokeUnsupported     = errors.New("auth: provider does not support revoking tokens")
+)
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Name stored for users who logged in with a link sent by email. Not available to other providers.
+const ProviderEmail = "email"
+
+// Provider is a login provider users can sign in with, using the OAuth2 authorization code flow.
+type Provider interface {
+	// Name identifies the provider in URLs and is stored next to the users' SSO ID.
+	// Changing it makes existing users of the provider unable to log in to their accounts.
+	Name() string
+	// DisplayName is shown to users on the login page.
+	DisplayName() string
+	// AuthCodeURL returns the URL of the provider's login page.
+	AuthCodeURL(state string) string
+	// Exchange converts the code from the callback into a token.
+	Exchange(ctx context.Context, code string) (*oauth2.Token, error)
+	// GetUser gets the user the token belongs to.
+	GetUser(ctx context.Context, token *oauth2.Token) (ProviderUser, error)
+	// Refresh returns a new token if the access token has expired and there is a refresh token.
+	// Otherwise the token is returned as it is.
+	Refresh(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error)
+	// Revoke revokes the token with the provider, ending the access given when the user logged in.
+	// Returns ErrRevokeUnsupported if the provider can not revoke tokens.
+	Revoke(ctx context.Context, token *oauth2.Token) error
+}
+
+// A user as reported by a login provider.
+type ProviderUser struct {
+	ID            string // Unique per provider, not across providers.
+	Email         string
+	EmailVerified bool
+}
+
+// The configured login providers, in the order they are shown on the login page.
+type Providers []Provider
+
+// Creates the list of login providers.
+// Returns an error if two providers have the same name.
+func NewProviders(providers ...Provider) (Providers, error) {
+	seen := map[string]bool{}
+	for _, provider := range providers {
+		if seen[provider.Name()] {
+			return nil, ErrDuplicateProviderName
+		}
+		seen[provider.Name()] = true
+	}
+	return providers, nil
+}
+
+// Gets the provider with the given name.
+func (p Providers) Get(name string) (Provider, bool) {
+	for _, provider := range p {
+		if provider.Name() == name {
+			return provider, true
+		}
+	}
+	return nil, false
+}
+
+func validateProviderName(name string) error {
+	if !providerNamePattern.MatchString(name) || name == ProviderEmail {
+		return ErrInvalidProviderName
+	}
+	return nil
+}
+
+// Revokes a token at an OAuth2 revocation endpoint (RFC 7009).
+// The refresh token is revoked if there is one, since revoking it ends the whole grant.
+// The client authenticates with basic auth if a client ID is given.
+func revokeToken(ctx context.Context, endpoint string, clientID string, clientSecret string, token *oauth2.Token) error {
+	value, hint := token.AccessToken, "access_token"
+	if token.RefreshToken != "" {
+		value, hint = token.RefreshToken, "refresh_token"
+	}
+	form := url.Values{"token": {value}, "token_type_hint": {hint}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to revoke token: status %d", resp.StatusCode)
+	}
+	return nil
+}
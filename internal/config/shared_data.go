@@ -2,7 +2,13 @@ package config
 
 import (
 	"database/sql"
+	"time"
 
+	"github.com/Molnes/Nyhetsjeger/internal/auth"
+	"github.com/Molnes/Nyhetsjeger/internal/live_updates"
+	"github.com/Molnes/Nyhetsjeger/internal/mailer"
+	"github.com/Molnes/Nyhetsjeger/internal/models/ai"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_tokens"
 	"github.com/antonlindstrom/pgstore"
 	"github.com/minio/minio-go/v7"
 )
@@ -10,7 +16,20 @@ import (
 type SharedData struct {
 	DB           *sql.DB
 	SessionStore *pgstore.PGStore
-	CryptoKey    []byte
 	Bucket       *minio.Client
-	OpenAIKey    string
+	LiveHub      *live_updates.Hub
+	// The providers users can log in with. Empty if users only log in by email.
+	AuthProviders auth.Providers
+	// Stores the tokens users get from their login provider.
+	Tokens *user_tokens.TokenService
+	// Nil if no mailer is configured, in which case login by email is disabled.
+	Mailer mailer.Mailer
+	// Where users reach the site, e.g. "https://quiz.example.com". Used in links sent by email.
+	PublicURL string
+	// Signs the tokens in login links sent by email.
+	LinkSigningKey []byte
+	// Nil if no AI provider is configured, in which case AI features are disabled.
+	QuestionGenerator ai.QuestionGenerator
+	// How long after a question's time limit answers are still accepted, to make up for network delay.
+	AnswerGracePeriod time.Duration
 }
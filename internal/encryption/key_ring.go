@@ -0,0 +1,143 @@
+// Package encryption encrypts secrets stored in the database with AES-GCM.
+//
+// Keys are kept in a key ring, so the key can be rotated. New values are encrypted with the current key,
+// and values encrypted with older keys can still be decrypted until they have been re-encrypted.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidKeyRing = errors.New("encryption: invalid key ring")
+	ErrUnknownKey     = errors.New("encryption: value is encrypted with a key not in the key ring")
+	ErrInvalidValue   = errors.New("encryption: invalid encrypted value")
+)
+
+// ID of the key when the key ring is a single key without an ID.
+const LegacyKeyID = "0"
+
+// Marks a value as a key ring with key IDs rather than a single raw key.
+const KeyRingPrefix = "keyring:"
+
+// KeyRing holds the keys used to encrypt and decrypt values.
+type KeyRing struct {
+	currentID string
+	keys      map[string]cipher.AEAD
+}
+
+// Parses a key ring, e.g. from the AES_KEY environment variable.
+//
+// The key ring starts with "keyring:", followed by a comma separated list of "<id>:<base64 key>"
+// where the first key is the current one, e.g. "keyring:2:c2VjcmV0...,1:b2xk...". IDs may not contain colons or commas.
+// For backwards compatibility a value without the prefix is used as a single raw key with the ID "0",
+// even if it contains colons or commas.
+// Keys must be 16, 24 or 32 bytes, selecting AES-128, AES-192 or AES-256.
+func ParseKeyRing(value string) (*KeyRing, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%w: no keys", ErrInvalidKeyRing)
+	}
+	entries, isKeyRing := strings.CutPrefix(value, KeyRingPrefix)
+	if !isKeyRing {
+		return NewKeyRing(LegacyKeyID, map[string][]byte{LegacyKeyID: []byte(value)})
+	}
+	if strings.TrimSpace(entries) == "" {
+		return nil, fmt.Errorf("%w: no keys", ErrInvalidKeyRing)
+	}
+
+	keys := map[string][]byte{}
+	currentID := ""
+	for _, entry := range strings.Split(entries, ",") {
+		id, encoded, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("%w: expected <id>:<base64 key>", ErrInvalidKeyRing)
+		}
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("%w: key id %q is used more than once", ErrInvalidKeyRing, id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q is not valid base64", ErrInvalidKeyRing, id)
+		}
+		keys[id] = key
+		if currentID == "" {
+			currentID = id
+		}
+	}
+	return NewKeyRing(currentID, keys)
+}
+
+// Creates a key ring encrypting with the key with the current ID.
+func NewKeyRing(currentID string, keys map[string][]byte) (*KeyRing, error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf("%w: current key %q is missing", ErrInvalidKeyRing, currentID)
+	}
+
+	ring := &KeyRing{currentID: currentID, keys: map[string]cipher.AEAD{}}
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q: %s", ErrInvalidKeyRing, id, err.Error())
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[id] = aead
+	}
+	return ring, nil
+}
+
+// Encrypts the value with the current key.
+// The associated data, e.g. the ID of the row the value is stored in, must be given again to decrypt it,
+// so encrypted values can not be moved between rows.
+//
+// The result has the form "<key id>:<base64 nonce and ciphertext>".
+func (kr *KeyRing) Encrypt(plaintext []byte, associatedData []byte) (string, error) {
+	aead := kr.keys[kr.currentID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, associatedData)
+	return kr.currentID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypts a value encrypted by Encrypt with any key in the key ring.
+func (kr *KeyRing) Decrypt(value string, associatedData []byte) ([]byte, error) {
+	id, encoded, found := strings.Cut(value, ":")
+	if !found {
+		return nil, ErrInvalidValue
+	}
+	aead, ok := kr.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidValue
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, associatedData)
+	if err != nil {
+		return nil, ErrInvalidValue
+	}
+	return plaintext, nil
+}
+
+// Whether the value is encrypted with another key than the current one, and should be re-encrypted.
+func (kr *KeyRing) NeedsReencryption(value string) bool {
+	id, _, _ := strings.Cut(value, ":")
+	return id != kr.currentID
+}
+
+// ID of the key new values are encrypted with.
+func (kr *KeyRing) CurrentKeyID() string {
+	return kr.currentID
+}
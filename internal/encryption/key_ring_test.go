@@ -0,0 +1,133 @@
+//go:build unit
+
+package encryption
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var (
+	oldKey = []byte("0123456789abcdef0123456789abcdef")
+	newKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+// TestEncryptDecrypt tests that an encrypted value can be decrypted with the same associated data only
+func TestEncryptDecrypt(t *testing.T) {
+	ring, err := NewKeyRing("1", map[string][]byte{"1": oldKey})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	encrypted, err := ring.Encrypt([]byte("secret token"), []byte("user-1"))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if !strings.HasPrefix(encrypted, "1:") || strings.Contains(encrypted, "secret token") {
+		t.Errorf("Expected value encrypted with key 1, but got %q", encrypted)
+	}
+
+	decrypted, err := ring.Decrypt(encrypted, []byte("user-1"))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if string(decrypted) != "secret token" {
+		t.Errorf("Expected 'secret token', but got %q", decrypted)
+	}
+
+	_, err = ring.Decrypt(encrypted, []byte("user-2"))
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Expected %v with other associated data, but got %v", ErrInvalidValue, err)
+	}
+}
+
+// TestKeyRotation tests that values encrypted with an old key can still be decrypted, and are marked for re-encryption
+func TestKeyRotation(t *testing.T) {
+	oldRing, _ := NewKeyRing("1", map[string][]byte{"1": oldKey})
+	encrypted, err := oldRing.Encrypt([]byte("secret token"), nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	rotated, err := ParseKeyRing(KeyRingPrefix + "2:" + base64.StdEncoding.EncodeToString(newKey) + ",1:" + base64.StdEncoding.EncodeToString(oldKey))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if rotated.CurrentKeyID() != "2" {
+		t.Errorf("Expected current key 2, but got %q", rotated.CurrentKeyID())
+	}
+	if !rotated.NeedsReencryption(encrypted) {
+		t.Error("Expected value encrypted with the old key to need re-encryption")
+	}
+
+	decrypted, err := rotated.Decrypt(encrypted, nil)
+	if err != nil || string(decrypted) != "secret token" {
+		t.Fatalf("Expected 'secret token', but got %q (%v)", decrypted, err)
+	}
+
+	reencrypted, _ := rotated.Encrypt(decrypted, nil)
+	if rotated.NeedsReencryption(reencrypted) {
+		t.Error("Expected value encrypted with the current key not to need re-encryption")
+	}
+
+	_, err = oldRing.Decrypt(reencrypted, nil)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected %v, but got %v", ErrUnknownKey, err)
+	}
+}
+
+// TestParseKeyRingLegacy tests that a single raw key is accepted for backwards compatibility
+func TestParseKeyRingLegacy(t *testing.T) {
+	ring, err := ParseKeyRing(string(oldKey))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if ring.CurrentKeyID() != LegacyKeyID {
+		t.Errorf("Expected current key %q, but got %q", LegacyKeyID, ring.CurrentKeyID())
+	}
+}
+
+// TestParseKeyRingLegacyWithColon tests that a raw key containing colons and commas is still used as a single key
+func TestParseKeyRingLegacyWithColon(t *testing.T) {
+	rawKey := []byte("0123:4567,89ab:cdef0123456789abc")
+	ring, err := ParseKeyRing(string(rawKey))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if ring.CurrentKeyID() != LegacyKeyID {
+		t.Errorf("Expected current key %q, but got %q", LegacyKeyID, ring.CurrentKeyID())
+	}
+
+	legacyRing, _ := NewKeyRing(LegacyKeyID, map[string][]byte{LegacyKeyID: rawKey})
+	encrypted, err := legacyRing.Encrypt([]byte("secret token"), nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	decrypted, err := ring.Decrypt(encrypted, nil)
+	if err != nil || string(decrypted) != "secret token" {
+		t.Errorf("Expected 'secret token', but got %q (%v)", decrypted, err)
+	}
+}
+
+// TestParseKeyRingInvalid tests that invalid key rings are rejected
+func TestParseKeyRingInvalid(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(oldKey)
+	tests := []string{
+		"",
+		"too short",
+		KeyRingPrefix,
+		KeyRingPrefix + "1:not base64!",
+		KeyRingPrefix + "1:" + base64.StdEncoding.EncodeToString([]byte("too short")),
+		KeyRingPrefix + "1:" + encoded + ",1:" + encoded,
+		KeyRingPrefix + ":" + encoded,
+		KeyRingPrefix + encoded,
+	}
+	for _, test := range tests {
+		_, err := ParseKeyRing(test)
+		if !errors.Is(err, ErrInvalidKeyRing) {
+			t.Errorf("Expected %v for %q, but got %v", ErrInvalidKeyRing, test, err)
+		}
+	}
+}
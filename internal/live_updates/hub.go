@@ -0,0 +1,105 @@
+// Package live_updates provides an in-process publish/subscribe hub used to push events to connected clients.
+package live_updates
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+// Events sent to connected clients.
+const (
+	QuestionAnswered  EventType = "question-answered"
+	ScoreboardChanged EventType = "scoreboard-changed"
+	QuizPublished     EventType = "quiz-published"
+	QuizUnpublished   EventType = "quiz-unpublished"
+	QuizEnded         EventType = "quiz-ended"
+)
+
+// The number of events buffered for each subscriber before new events are dropped for that subscriber.
+const subscriberBufferSize = 16
+
+// Event represents a single live update. QuestionID is uuid.Nil for events not related to a question.
+type Event struct {
+	Type       EventType `json:"type"`
+	QuizID     uuid.UUID `json:"quizId"`
+	QuestionID uuid.UUID `json:"questionId"`
+}
+
+// Publisher is anything events can be published to. Implemented by Hub.
+type Publisher interface {
+	Publish(event Event)
+}
+
+// Subscriber receives the events published to the hub it is subscribed to.
+type Subscriber struct {
+	events chan Event
+}
+
+// Returns the channel the subscriber receives events on. The channel is closed when the subscriber is unsubscribed.
+func (s *Subscriber) Events() <-chan Event {
+	return s.events
+}
+
+// Hub fans out published events to all its subscribers.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscriber]struct{}
+}
+
+// Creates a new Hub without any subscribers.
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Creates a new subscriber and adds it to the hub.
+func (h *Hub) Subscribe() *Subscriber {
+	subscriber := &Subscriber{
+		events: make(chan Event, subscriberBufferSize),
+	}
+
+	h.mu.Lock()
+	h.subscribers[subscriber] = struct{}{}
+	h.mu.Unlock()
+
+	return subscriber
+}
+
+// Removes the subscriber from the hub and closes its event channel.
+// Unsubscribing the same subscriber more than once has no effect.
+func (h *Hub) Unsubscribe(subscriber *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[subscriber]; ok {
+		delete(h.subscribers, subscriber)
+		close(subscriber.events)
+	}
+}
+
+// Sends the event to all subscribers.
+//
+// Never blocks: if a subscriber's buffer is full, the event is dropped for that subscriber,
+// so one slow client can not hold back the others.
+func (h *Hub) Publish(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for subscriber := range h.subscribers {
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+}
+
+// Returns the number of current subscribers.
+func (h *Hub) SubscriberCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
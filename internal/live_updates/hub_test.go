@@ -0,0 +1,63 @@
+//go:build unit
+
+package live_updates
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// TestPublishReachesAllSubscribers tests that every subscriber receives a published event
+func TestPublishReachesAllSubscribers(t *testing.T) {
+	hub := NewHub()
+	first := hub.Subscribe()
+	second := hub.Subscribe()
+
+	event := Event{Type: QuizPublished, QuizID: uuid.New()}
+	hub.Publish(event)
+
+	for _, subscriber := range []*Subscriber{first, second} {
+		select {
+		case received := <-subscriber.Events():
+			if received != event {
+				t.Errorf("Expected %v, but got %v", event, received)
+			}
+		default:
+			t.Error("Expected subscriber to receive the published event")
+		}
+	}
+}
+
+// TestUnsubscribeClosesChannel tests that unsubscribing removes the subscriber and closes its channel
+func TestUnsubscribeClosesChannel(t *testing.T) {
+	hub := NewHub()
+	subscriber := hub.Subscribe()
+
+	hub.Unsubscribe(subscriber)
+	hub.Unsubscribe(subscriber)
+
+	if hub.SubscriberCount() != 0 {
+		t.Errorf("Expected 0 subscribers, but got %d", hub.SubscriberCount())
+	}
+	if _, ok := <-subscriber.Events(); ok {
+		t.Error("Expected the event channel to be closed")
+	}
+
+	// publishing without subscribers must not panic or block
+	hub.Publish(Event{Type: ScoreboardChanged})
+}
+
+// TestPublishDoesNotBlockOnFullBuffer tests that a subscriber not reading does not block the publisher
+func TestPublishDoesNotBlockOnFullBuffer(t *testing.T) {
+	hub := NewHub()
+	subscriber := hub.Subscribe()
+
+	for range subscriberBufferSize + 5 {
+		hub.Publish(Event{Type: QuestionAnswered})
+	}
+
+	if len(subscriber.Events()) != subscriberBufferSize {
+		t.Errorf("Expected %d buffered events, but got %d", subscriberBufferSize, len(subscriber.Events()))
+	}
+}
@@ -0,0 +1,63 @@
+package live_updates
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Periodically checks for published quizzes whose active_to has passed since the previous check,
+// and publishes a QuizEnded and a ScoreboardChanged event for each of them.
+//
+// Blocks until the context is cancelled, so it should be started in its own goroutine.
+func WatchQuizEndings(ctx context.Context, db *sql.DB, publisher Publisher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastCheck := time.Now().UTC()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now().UTC()
+			endedQuizzes, err := getQuizzesEndedBetween(db, lastCheck, now)
+			if err != nil {
+				log.Println("live updates: failed to check for ended quizzes:", err)
+				continue
+			}
+			lastCheck = now
+
+			for _, quizID := range endedQuizzes {
+				publisher.Publish(Event{Type: QuizEnded, QuizID: quizID})
+				publisher.Publish(Event{Type: ScoreboardChanged, QuizID: quizID})
+			}
+		}
+	}
+}
+
+// Returns the IDs of published quizzes with active_to in the interval (from, to].
+func getQuizzesEndedBetween(db *sql.DB, from time.Time, to time.Time) ([]uuid.UUID, error) {
+	rows, err := db.Query(
+		`SELECT id
+		FROM quizzes
+		WHERE published = true AND is_deleted = false
+		AND active_to > $1 AND active_to <= $2;`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quizIDs := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		quizIDs = append(quizIDs, id)
+	}
+	return quizIDs, rows.Err()
+}
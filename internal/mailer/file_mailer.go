@@ -0,0 +1,29 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer writes each email to its own file instead of sending it. Meant for development and tests.
+type FileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) *FileMailer {
+	return &FileMailer{dir}
+}
+
+// Writes the email to a new file in the directory, named by the time it was sent.
+func (fm *FileMailer) Send(ctx context.Context, message Message) error {
+	if err := os.MkdirAll(fm.dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.NewString())
+	return os.WriteFile(filepath.Join(fm.dir, name), []byte(formatMessage("", message)), 0o600)
+}
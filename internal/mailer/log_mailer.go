@@ -0,0 +1,18 @@
+package mailer
+
+import (
+	"context"
+	"log"
+)
+
+// LogMailer writes emails to the log instead of sending them. Meant for development.
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (lm *LogMailer) Send(ctx context.Context, message Message) error {
+	log.Printf("Email to %s\nSubject: %s\n\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
@@ -0,0 +1,73 @@
+// Package mailer sends emails, either through SMTP or to a log or files during development.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// A plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Kind is the name of a Mailer implementation, as used in configuration.
+type Kind string
+
+const (
+	KindNone Kind = ""
+	KindLog  Kind = "log"
+	KindFile Kind = "file"
+	KindSMTP Kind = "smtp"
+)
+
+var (
+	ErrUnknownKind    = errors.New("mailer: unknown mailer")
+	ErrMissingDir     = errors.New("mailer: missing directory")
+	ErrMissingSMTP    = errors.New("mailer: missing smtp host or sender address")
+	ErrInvalidAddress = errors.New("mailer: invalid recipient address")
+)
+
+// Config holds the configuration needed to create a Mailer.
+type Config struct {
+	Kind Kind
+	// Directory the file mailer writes emails to.
+	Dir string
+	// SMTP server, e.g. "smtp.example.com:587". Only used by the SMTP mailer.
+	SMTPAddress  string
+	SMTPUsername string
+	SMTPPassword string
+	// Sender address. Only used by the SMTP mailer.
+	From string
+}
+
+// NewMailer creates the Mailer chosen in the configuration.
+// Returns nil and no error if no mailer is configured, meaning features sending emails are disabled.
+func NewMailer(config Config) (Mailer, error) {
+	switch config.Kind {
+	case KindNone:
+		return nil, nil
+	case KindLog:
+		return NewLogMailer(), nil
+	case KindFile:
+		if config.Dir == "" {
+			return nil, ErrMissingDir
+		}
+		return NewFileMailer(config.Dir), nil
+	case KindSMTP:
+		if config.SMTPAddress == "" || config.From == "" {
+			return nil, ErrMissingSMTP
+		}
+		return NewSMTPMailer(config.SMTPAddress, config.SMTPUsername, config.SMTPPassword, config.From), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKind, config.Kind)
+	}
+}
@@ -0,0 +1,75 @@
+//go:build unit
+
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestNewMailerNone tests that no mailer is created when none is configured
+func TestNewMailerNone(t *testing.T) {
+	m, err := NewMailer(Config{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if m != nil {
+		t.Errorf("Expected nil mailer, but got %T", m)
+	}
+}
+
+// TestNewMailerInvalid tests that invalid configurations are rejected
+func TestNewMailerInvalid(t *testing.T) {
+	tests := []struct {
+		config   Config
+		expected error
+	}{
+		{Config{Kind: "unknown"}, ErrUnknownKind},
+		{Config{Kind: KindFile}, ErrMissingDir},
+		{Config{Kind: KindSMTP, SMTPAddress: "smtp.example.com:587"}, ErrMissingSMTP},
+	}
+	for _, test := range tests {
+		_, err := NewMailer(test.config)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Expected %v for %+v, but got %v", test.expected, test.config, err)
+		}
+	}
+}
+
+// TestFileMailer tests that the file mailer writes the email to a file in the directory
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := NewFileMailer(dir)
+
+	err := m.Send(context.Background(), Message{To: "reader@example.com", Subject: "Logg inn", Body: "Hei!"})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one file, but got %d (%v)", len(files), err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatalf("Expected no error reading the file, but got %v", err)
+	}
+	if !strings.Contains(string(content), "To: reader@example.com\r\n") || !strings.HasSuffix(string(content), "\r\n\r\nHei!") {
+		t.Errorf("Expected the recipient header and body, but got %q", content)
+	}
+}
+
+// TestFormatMessageStripsNewlines tests that the recipient and subject can not add headers
+func TestFormatMessageStripsNewlines(t *testing.T) {
+	formatted := formatMessage("quiz@example.com", Message{
+		To:      "reader@example.com\r\nBcc: other@example.com",
+		Subject: "Hei\nBcc: other@example.com",
+	})
+	if strings.Contains(formatted, "\nBcc:") {
+		t.Errorf("Expected no Bcc header, but got %q", formatted)
+	}
+}
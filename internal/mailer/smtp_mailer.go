@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPMailer sends emails through an SMTP server.
+type SMTPMailer struct {
+	address string
+	auth    smtp.Auth // Nil if the server does not require authentication.
+	from    string
+}
+
+// Creates a mailer sending through the SMTP server at the given address, e.g. "smtp.example.com:587".
+// No authentication is used if the username is empty.
+func NewSMTPMailer(address string, username string, password string, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := net.SplitHostPort(address)
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{address, auth, from}
+}
+
+func (sm *SMTPMailer) Send(ctx context.Context, message Message) error {
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAddress, err.Error())
+	}
+	return smtp.SendMail(sm.address, sm.auth, sm.from, []string{to.Address}, []byte(formatMessage(sm.from, message)))
+}
+
+// Formats the message with headers as a plain text email.
+func formatMessage(from string, message Message) string {
+	var b strings.Builder
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", stripNewlines(message.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", stripNewlines(message.Subject)))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(message.Body)
+	return b.String()
+}
+
+// Removes line breaks, so values can not add headers.
+func stripNewlines(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -1,42 +1,9 @@
 package ai
 
 import (
-	"context"
-	"encoding/json"
-	"strings"
-
 	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
-	openai "github.com/sashabaranov/go-openai"
 )
 
-// GetJsonQuestions takes an article and an API key and returns a question and error.
-func GetJsonQuestions(c context.Context, article articles.ArticleSMP, apiKey string) (Question, error) {
-
-	client := openai.NewClient(apiKey)
-	resp, err := client.CreateChatCompletion(c,
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4Turbo,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: combinePromptAndArticle(article),
-				},
-			},
-		},
-	)
-	if err != nil {
-		return Question{}, err
-	}
-
-	question, err := ParseJsonQuestion(c, resp.Choices[0].Message.Content)
-	if err != nil {
-		return Question{}, err
-	}
-
-	return question, nil
-
-}
-
 // combinePromptAndArticle combines the prompt with the article text.
 func combinePromptAndArticle(article articles.ArticleSMP) string {
 	prompt := `make a quiz question out of the provided article.
@@ -47,14 +14,18 @@ alternatives: [
     {alternative_text:"",
      correct: bool 
 } 
-] 
+],
+explanation: ""
 }
 
 There should be at the minimum 2 to maximum 4 alternatives. 
+Exactly one alternative should be correct.
 No other text should be returned. 
 Both the question and the alternatives should be based on the provided article, but do not mention that the question is based on an article.
 Make sure the question contains enough context that anyone who has previously read the article knows to which article the question refers.
 The alternatives should not be longer than 50 characters.
+The explanation should in one or two sentences explain why the correct alternative is correct, using facts from the article.
+It is shown after the question is answered, and should not be longer than 500 characters.
 And it should be in norwegian, if the article is bokmål, the question should be in bokmål.
 If the article is nynorsk, the question should be in nynorsk.
 Article:`
@@ -72,6 +43,7 @@ Article:`
 type Question struct {
 	Question     string        `json:"question"`
 	Alternatives []Alternative `json:"alternatives"`
+	Explanation  string        `json:"explanation"` // Why the correct alternative is correct. May be empty.
 }
 
 // Alternative is a struct that represents an alternative to a question.
@@ -79,21 +51,3 @@ type Alternative struct {
 	AlternativeText string `json:"alternative_text"`
 	Correct         bool   `json:"correct"`
 }
-
-// ParseJsonQuestions parses the JSON string and returns a Question struct.
-func ParseJsonQuestion(c context.Context, jsonMessage string) (Question, error) {
-	question := Question{}
-
-	// remove all ` from the json string
-	jsonMessage = strings.ReplaceAll(jsonMessage, "`", "")
-
-	// remove all "json" from the json string
-	jsonMessage = strings.ReplaceAll(jsonMessage, "json", "")
-
-	err := json.Unmarshal([]byte(jsonMessage), &question)
-	if err != nil {
-		return Question{}, err
-	}
-	return question, nil
-
-}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+)
+
+// CompatibleGenerator generates questions using any server implementing the
+// OpenAI chat completions API, such as self-hosted models.
+type CompatibleGenerator struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+// NewCompatibleGenerator creates a generator sending requests to {baseURL}/chat/completions.
+// The api key is optional, and is sent as a bearer token if given.
+func NewCompatibleGenerator(baseURL string, apiKey string, model string) *CompatibleGenerator {
+	return &CompatibleGenerator{
+		client:  &http.Client{Timeout: 2 * time.Minute},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// GenerateQuestion asks the model to make a question out of the article.
+func (g *CompatibleGenerator) GenerateQuestion(ctx context.Context, article articles.ArticleSMP) (Question, error) {
+	return generateValidQuestion(ctx, g, article)
+}
+
+// complete sends the conversation to the chat completions endpoint.
+func (g *CompatibleGenerator) complete(ctx context.Context, messages []chatMessage) (string, error) {
+	body, err := json.Marshal(chatCompletionRequest{
+		Model:    g.model,
+		Messages: messages,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.apiKey)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ai: unexpected status from model server: %s", resp.Status)
+	}
+
+	var completion chatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return "", err
+	}
+	if len(completion.Choices) == 0 {
+		return "", ErrEmptyResponse
+	}
+
+	return completion.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,31 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+)
+
+// FakeGenerator returns deterministic questions without calling any model.
+// Meant for tests and local development.
+type FakeGenerator struct{}
+
+// NewFakeGenerator creates a generator returning deterministic questions.
+func NewFakeGenerator() *FakeGenerator {
+	return &FakeGenerator{}
+}
+
+// GenerateQuestion returns a question about the title of the article,
+// where the first alternative is correct, with an explanation.
+func (g *FakeGenerator) GenerateQuestion(ctx context.Context, article articles.ArticleSMP) (Question, error) {
+	return Question{
+		Question: fmt.Sprintf("Hva handlet artikkelen \"%s\" om?", article.Title.Value),
+		Alternatives: []Alternative{
+			{AlternativeText: "Det som står i tittelen", Correct: true},
+			{AlternativeText: "Noe helt annet", Correct: false},
+			{AlternativeText: "Været i morgen", Correct: false},
+		},
+		Explanation: fmt.Sprintf("Artikkelen \"%s\" handlet om det som står i tittelen.", article.Title.Value),
+	}, nil
+}
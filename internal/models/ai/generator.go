@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+)
+
+// QuestionGenerator generates quiz questions from articles using a large language model.
+type QuestionGenerator interface {
+	// GenerateQuestion returns a single question based on the given article.
+	GenerateQuestion(ctx context.Context, article articles.ArticleSMP) (Question, error)
+}
+
+// Provider is the name of a QuestionGenerator implementation, as used in configuration.
+type Provider string
+
+const (
+	ProviderNone             Provider = ""
+	ProviderOpenAI           Provider = "openai"
+	ProviderOpenAICompatible Provider = "openai-compatible"
+	ProviderFake             Provider = "fake"
+)
+
+var (
+	ErrUnknownProvider = errors.New("ai: unknown provider")
+	ErrMissingAPIKey   = errors.New("ai: missing api key")
+	ErrMissingBaseURL  = errors.New("ai: missing base url")
+	ErrEmptyResponse   = errors.New("ai: empty response from model")
+)
+
+// ProviderConfig holds the configuration needed to create a QuestionGenerator.
+type ProviderConfig struct {
+	Provider Provider
+	// API key, required for OpenAI and optional for OpenAI compatible servers.
+	APIKey string
+	// Name of the model, falls back to a default for the provider if empty.
+	Model string
+	// Base URL of the API, only used for OpenAI compatible servers. E.g. "http://localhost:11434/v1".
+	BaseURL string
+}
+
+// NewQuestionGenerator creates the QuestionGenerator chosen in the configuration.
+// Returns nil and no error if no provider is configured, meaning AI features are disabled.
+func NewQuestionGenerator(config ProviderConfig) (QuestionGenerator, error) {
+	switch config.Provider {
+	case ProviderNone:
+		return nil, nil
+	case ProviderOpenAI:
+		if config.APIKey == "" {
+			return nil, ErrMissingAPIKey
+		}
+		return NewOpenAIGenerator(config.APIKey, config.Model), nil
+	case ProviderOpenAICompatible:
+		if config.BaseURL == "" {
+			return nil, ErrMissingBaseURL
+		}
+		return NewCompatibleGenerator(config.BaseURL, config.APIKey, config.Model), nil
+	case ProviderFake:
+		return NewFakeGenerator(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, config.Provider)
+	}
+}
@@ -0,0 +1,124 @@
+//go:build unit
+
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/ai"
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+)
+
+// TestNewQuestionGeneratorNone tests that no generator is created when no provider is configured
+func TestNewQuestionGeneratorNone(t *testing.T) {
+	generator, err := ai.NewQuestionGenerator(ai.ProviderConfig{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if generator != nil {
+		t.Errorf("Expected nil generator, but got %T", generator)
+	}
+}
+
+// TestNewQuestionGeneratorInvalid tests that invalid configurations are rejected
+func TestNewQuestionGeneratorInvalid(t *testing.T) {
+	tests := []struct {
+		config   ai.ProviderConfig
+		expected error
+	}{
+		{ai.ProviderConfig{Provider: "unknown"}, ai.ErrUnknownProvider},
+		{ai.ProviderConfig{Provider: ai.ProviderOpenAI}, ai.ErrMissingAPIKey},
+		{ai.ProviderConfig{Provider: ai.ProviderOpenAICompatible}, ai.ErrMissingBaseURL},
+	}
+	for _, test := range tests {
+		_, err := ai.NewQuestionGenerator(test.config)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Expected %v for provider %q, but got %v", test.expected, test.config.Provider, err)
+		}
+	}
+}
+
+// TestFakeGenerator tests that the fake generator is deterministic
+func TestFakeGenerator(t *testing.T) {
+	generator, err := ai.NewQuestionGenerator(ai.ProviderConfig{Provider: ai.ProviderFake})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	article := articles.ArticleSMP{}
+	article.Title.Value = "Tittel"
+
+	first, err := generator.GenerateQuestion(context.Background(), article)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	second, _ := generator.GenerateQuestion(context.Background(), article)
+	if first.Question != second.Question || len(first.Alternatives) != len(second.Alternatives) {
+		t.Errorf("Expected equal questions, but got %v and %v", first, second)
+	}
+	if !first.Alternatives[0].Correct {
+		t.Errorf("Expected the first alternative to be correct")
+	}
+}
+
+// TestCompatibleGenerator tests the generator against a stub OpenAI compatible server
+func TestCompatibleGenerator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Unexpected authorization header %q", r.Header.Get("Authorization"))
+		}
+
+		var request struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Model != "llama3" {
+			t.Errorf("Expected model llama3, but got %s", request.Model)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":` +
+			"\"```json\\n{\\\"question\\\":\\\"Hva?\\\",\\\"alternatives\\\":[{\\\"alternative_text\\\":\\\"Ja\\\",\\\"correct\\\":true},{\\\"alternative_text\\\":\\\"Nei\\\",\\\"correct\\\":false}]}\\n```\"" +
+			`}}]}`))
+	}))
+	defer server.Close()
+
+	generator, err := ai.NewQuestionGenerator(ai.ProviderConfig{
+		Provider: ai.ProviderOpenAICompatible,
+		BaseURL:  server.URL + "/v1/",
+		APIKey:   "secret",
+		Model:    "llama3",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	question, err := generator.GenerateQuestion(context.Background(), articles.ArticleSMP{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if question.Question != "Hva?" || len(question.Alternatives) != 2 || !question.Alternatives[0].Correct {
+		t.Errorf("Unexpected question %+v", question)
+	}
+}
+
+// TestCompatibleGeneratorErrorStatus tests that non-OK responses are reported as errors
+func TestCompatibleGeneratorErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	generator := ai.NewCompatibleGenerator(server.URL, "", "model")
+	if _, err := generator.GenerateQuestion(context.Background(), articles.ArticleSMP{}); err == nil {
+		t.Errorf("Expected an error, but got none")
+	}
+}
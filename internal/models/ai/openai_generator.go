@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// OpenAIGenerator generates questions using the OpenAI API.
+type OpenAIGenerator struct {
+	client *openai.Client
+	model  string
+}
+
+// NewOpenAIGenerator creates a generator using the OpenAI API.
+// Uses GPT-4 Turbo if no model is given.
+func NewOpenAIGenerator(apiKey string, model string) *OpenAIGenerator {
+	if model == "" {
+		model = openai.GPT4Turbo
+	}
+	return &OpenAIGenerator{
+		client: openai.NewClient(apiKey),
+		model:  model,
+	}
+}
+
+// GenerateQuestion asks the OpenAI model to make a question out of the article.
+func (g *OpenAIGenerator) GenerateQuestion(ctx context.Context, article articles.ArticleSMP) (Question, error) {
+	return generateValidQuestion(ctx, g, article)
+}
+
+// complete sends the conversation to the OpenAI chat completions API.
+func (g *OpenAIGenerator) complete(ctx context.Context, messages []chatMessage) (string, error) {
+	openaiMessages := make([]openai.ChatCompletionMessage, len(messages))
+	for i, message := range messages {
+		openaiMessages[i] = openai.ChatCompletionMessage{
+			Role:    message.Role,
+			Content: message.Content,
+		}
+	}
+
+	resp, err := g.client.CreateChatCompletion(ctx,
+		openai.ChatCompletionRequest{
+			Model:    g.model,
+			Messages: openaiMessages,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", ErrEmptyResponse
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
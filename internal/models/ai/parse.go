@@ -0,0 +1,146 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MinAlternatives         = 2
+	MaxAlternatives         = 4
+	MaxAlternativeTextRunes = 50
+	// Also the limit of explanations written by editors, see questions.MaxExplanationLength.
+	MaxExplanationRunes = 500
+)
+
+var (
+	ErrNoJsonObject         = errors.New("ai: no json object found in response")
+	ErrInvalidJson          = errors.New("ai: response is not a valid question")
+	ErrEmptyQuestion        = errors.New("ai: question text is empty")
+	ErrTooFewAlternatives   = fmt.Errorf("ai: fewer than %d alternatives", MinAlternatives)
+	ErrTooManyAlternatives  = fmt.Errorf("ai: more than %d alternatives", MaxAlternatives)
+	ErrEmptyAlternative     = errors.New("ai: alternative text is empty")
+	ErrAlternativeTooLong   = fmt.Errorf("ai: alternative longer than %d characters", MaxAlternativeTextRunes)
+	ErrNoCorrectAlternative = errors.New("ai: no correct alternative")
+	ErrMultipleCorrect      = errors.New("ai: more than one correct alternative")
+	ErrExplanationTooLong   = fmt.Errorf("ai: explanation longer than %d characters", MaxExplanationRunes)
+)
+
+// ValidationError holds every rule a generated question breaks.
+// Use errors.Is with the Err* variables to check for a specific rule.
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errs
+}
+
+// ParseJsonQuestion extracts the first JSON object in the message, which may be wrapped in
+// a markdown code fence or surrounded by other text, and returns it as a validated Question.
+// Returns ErrNoJsonObject, ErrInvalidJson or a *ValidationError if the message is not a valid question.
+func ParseJsonQuestion(c context.Context, jsonMessage string) (Question, error) {
+	object, err := extractJsonObject(jsonMessage)
+	if err != nil {
+		return Question{}, err
+	}
+
+	question := Question{}
+	if err := json.Unmarshal([]byte(object), &question); err != nil {
+		return Question{}, fmt.Errorf("%w: %v", ErrInvalidJson, err)
+	}
+
+	if err := ValidateQuestion(question); err != nil {
+		return Question{}, err
+	}
+	return question, nil
+}
+
+// ValidateQuestion checks the question against the rules given to the model in the prompt.
+// Returns a *ValidationError listing all broken rules, or nil if the question is valid.
+func ValidateQuestion(question Question) error {
+	var errs []error
+
+	if strings.TrimSpace(question.Question) == "" {
+		errs = append(errs, ErrEmptyQuestion)
+	}
+
+	if len(question.Alternatives) < MinAlternatives {
+		errs = append(errs, ErrTooFewAlternatives)
+	} else if len(question.Alternatives) > MaxAlternatives {
+		errs = append(errs, ErrTooManyAlternatives)
+	}
+
+	correct := 0
+	for _, alternative := range question.Alternatives {
+		text := strings.TrimSpace(alternative.AlternativeText)
+		if text == "" {
+			errs = append(errs, ErrEmptyAlternative)
+		} else if utf8.RuneCountInString(text) > MaxAlternativeTextRunes {
+			errs = append(errs, fmt.Errorf("%w: %q", ErrAlternativeTooLong, text))
+		}
+		if alternative.Correct {
+			correct++
+		}
+	}
+
+	if correct == 0 {
+		errs = append(errs, ErrNoCorrectAlternative)
+	} else if correct > 1 {
+		errs = append(errs, ErrMultipleCorrect)
+	}
+
+	// The explanation is optional, as it is only shown after answering
+	if utf8.RuneCountInString(strings.TrimSpace(question.Explanation)) > MaxExplanationRunes {
+		errs = append(errs, ErrExplanationTooLong)
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errs: errs}
+	}
+	return nil
+}
+
+// Returns the first balanced JSON object in the text, ignoring braces inside strings.
+func extractJsonObject(text string) (string, error) {
+	start := strings.IndexByte(text, '{')
+	if start == -1 {
+		return "", ErrNoJsonObject
+	}
+
+	depth := 0
+	inString := false
+	escaped := false
+	for i := start; i < len(text); i++ {
+		char := text[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && char == '\\':
+			escaped = true
+		case char == '"':
+			inString = !inString
+		case inString:
+		case char == '{':
+			depth++
+		case char == '}':
+			depth--
+			if depth == 0 {
+				return text[start : i+1], nil
+			}
+		}
+	}
+	return "", ErrNoJsonObject
+}
@@ -0,0 +1,154 @@
+//go:build unit
+
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/ai"
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+)
+
+const validQuestion = `{"question":"Hva er json?","alternatives":[{"alternative_text":"Et dataformat","correct":true},{"alternative_text":"En fisk {}","correct":false}]}`
+
+// TestParseJsonQuestionFenced tests that fenced output with surrounding text is parsed without corrupting the text
+func TestParseJsonQuestionFenced(t *testing.T) {
+	message := "Her er spørsmålet:\n```json\n" + validQuestion + "\n```\nLykke til!"
+
+	question, err := ai.ParseJsonQuestion(context.Background(), message)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if question.Question != "Hva er json?" {
+		t.Errorf("Expected question text to be kept, but got %q", question.Question)
+	}
+	if question.Alternatives[1].AlternativeText != "En fisk {}" {
+		t.Errorf("Expected braces in strings to be kept, but got %q", question.Alternatives[1].AlternativeText)
+	}
+}
+
+// TestParseJsonQuestionNoObject tests that output without a JSON object is rejected
+func TestParseJsonQuestionNoObject(t *testing.T) {
+	for _, message := range []string{"", "Beklager, jeg kan ikke", `{"question": "unclosed"`} {
+		_, err := ai.ParseJsonQuestion(context.Background(), message)
+		if !errors.Is(err, ai.ErrNoJsonObject) {
+			t.Errorf("Expected ErrNoJsonObject for %q, but got %v", message, err)
+		}
+	}
+}
+
+// TestValidateQuestion tests each of the rules stated in the prompt
+func TestValidateQuestion(t *testing.T) {
+	alternative := func(text string, correct bool) ai.Alternative {
+		return ai.Alternative{AlternativeText: text, Correct: correct}
+	}
+
+	tests := []struct {
+		name     string
+		question ai.Question
+		expected error
+	}{
+		{"empty question", ai.Question{Question: " ", Alternatives: []ai.Alternative{alternative("a", true), alternative("b", false)}}, ai.ErrEmptyQuestion},
+		{"too few", ai.Question{Question: "q", Alternatives: []ai.Alternative{alternative("a", true)}}, ai.ErrTooFewAlternatives},
+		{"too many", ai.Question{Question: "q", Alternatives: []ai.Alternative{
+			alternative("a", true), alternative("b", false), alternative("c", false), alternative("d", false), alternative("e", false)}}, ai.ErrTooManyAlternatives},
+		{"empty alternative", ai.Question{Question: "q", Alternatives: []ai.Alternative{alternative("a", true), alternative("", false)}}, ai.ErrEmptyAlternative},
+		{"too long", ai.Question{Question: "q", Alternatives: []ai.Alternative{alternative("a", true), alternative(strings.Repeat("æ", 51), false)}}, ai.ErrAlternativeTooLong},
+		{"no correct", ai.Question{Question: "q", Alternatives: []ai.Alternative{alternative("a", false), alternative("b", false)}}, ai.ErrNoCorrectAlternative},
+		{"multiple correct", ai.Question{Question: "q", Alternatives: []ai.Alternative{alternative("a", true), alternative("b", true)}}, ai.ErrMultipleCorrect},
+		{"long explanation", ai.Question{Question: "q", Alternatives: []ai.Alternative{alternative("a", true), alternative("b", false)},
+			Explanation: strings.Repeat("æ", ai.MaxExplanationRunes+1)}, ai.ErrExplanationTooLong},
+	}
+
+	for _, test := range tests {
+		err := ai.ValidateQuestion(test.question)
+		var validationErr *ai.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: expected a ValidationError, but got %v", test.name, err)
+			continue
+		}
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, but got %v", test.name, test.expected, err)
+		}
+	}
+
+	valid := ai.Question{Question: "q", Alternatives: []ai.Alternative{alternative(strings.Repeat("æ", 50), true), alternative("b", false)}}
+	if err := ai.ValidateQuestion(valid); err != nil {
+		t.Errorf("Expected valid question, but got %v", err)
+	}
+	valid.Explanation = strings.Repeat("æ", ai.MaxExplanationRunes)
+	if err := ai.ValidateQuestion(valid); err != nil {
+		t.Errorf("Expected valid question with explanation, but got %v", err)
+	}
+}
+
+// Returns a stub chat completions server replying with the given contents in order,
+// and a pointer to the messages received in the last request.
+func stubModelServer(t *testing.T, replies ...string) (*httptest.Server, *[]map[string]string) {
+	requests := 0
+	lastMessages := []map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		lastMessages = request.Messages
+
+		reply, _ := json.Marshal(replies[requests%len(replies)])
+		requests++
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":` + string(reply) + `}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &lastMessages
+}
+
+// TestGenerateQuestionRetries tests that validation errors are fed back to the model
+func TestGenerateQuestionRetries(t *testing.T) {
+	invalid := `{"question":"q","alternatives":[{"alternative_text":"a","correct":true}]}`
+	server, lastMessages := stubModelServer(t, invalid, validQuestion)
+
+	generator := ai.NewCompatibleGenerator(server.URL, "", "model")
+	question, err := generator.GenerateQuestion(context.Background(), articles.ArticleSMP{})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if question.Question != "Hva er json?" {
+		t.Errorf("Expected the corrected question, but got %q", question.Question)
+	}
+
+	messages := *lastMessages
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages in the retry, but got %d", len(messages))
+	}
+	if messages[1]["content"] != invalid {
+		t.Errorf("Expected the invalid reply to be sent back, but got %q", messages[1]["content"])
+	}
+	if !strings.Contains(messages[2]["content"], ai.ErrTooFewAlternatives.Error()) {
+		t.Errorf("Expected the validation error in the feedback, but got %q", messages[2]["content"])
+	}
+}
+
+// TestGenerateQuestionGivesUp tests that a GenerationError is returned after the last attempt
+func TestGenerateQuestionGivesUp(t *testing.T) {
+	server, _ := stubModelServer(t, "ingen json her")
+
+	generator := ai.NewCompatibleGenerator(server.URL, "", "model")
+	_, err := generator.GenerateQuestion(context.Background(), articles.ArticleSMP{})
+
+	var generationErr *ai.GenerationError
+	if !errors.As(err, &generationErr) {
+		t.Fatalf("Expected a GenerationError, but got %v", err)
+	}
+	if generationErr.Attempts != ai.MaxGenerationAttempts {
+		t.Errorf("Expected %d attempts, but got %d", ai.MaxGenerationAttempts, generationErr.Attempts)
+	}
+	if !errors.Is(err, ai.ErrNoJsonObject) {
+		t.Errorf("Expected ErrNoJsonObject, but got %v", err)
+	}
+}
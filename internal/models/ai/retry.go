@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+)
+
+// How many times the model is asked for a question before giving up.
+const MaxGenerationAttempts = 3
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// completer sends a conversation to a chat model and returns the content of its reply.
+type completer interface {
+	complete(ctx context.Context, messages []chatMessage) (string, error)
+}
+
+// GenerationError is returned when the model did not produce a valid question within MaxGenerationAttempts.
+// Err is the parsing or validation error of the last attempt.
+type GenerationError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *GenerationError) Error() string {
+	return fmt.Sprintf("ai: no valid question after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *GenerationError) Unwrap() error {
+	return e.Err
+}
+
+// Asks the model for a question about the article. If the reply is invalid,
+// the problems are sent back to the model and it is asked to correct its answer.
+// Errors from the model itself are returned right away without retrying.
+func generateValidQuestion(ctx context.Context, model completer, article articles.ArticleSMP) (Question, error) {
+	messages := []chatMessage{
+		{Role: "user", Content: combinePromptAndArticle(article)},
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < MaxGenerationAttempts; attempt++ {
+		reply, err := model.complete(ctx, messages)
+		if err != nil {
+			return Question{}, err
+		}
+
+		question, err := ParseJsonQuestion(ctx, reply)
+		if err == nil {
+			return question, nil
+		}
+		lastErr = err
+
+		messages = append(messages,
+			chatMessage{Role: "assistant", Content: reply},
+			chatMessage{Role: "user", Content: correctionPrompt(err)},
+		)
+	}
+
+	return Question{}, &GenerationError{Attempts: MaxGenerationAttempts, Err: lastErr}
+}
+
+// correctionPrompt asks the model to fix the problems in its previous answer.
+func correctionPrompt(err error) string {
+	return fmt.Sprintf(`The previous answer was not valid: %s.
+Fix these problems and return only the corrected json, in the same format as before.`, err.Error())
+}
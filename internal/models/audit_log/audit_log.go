@@ -0,0 +1,166 @@
+// Package audit_log records administrative actions, who performed them and what they changed.
+package audit_log
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionDelete    Action = "delete"
+	ActionDuplicate Action = "duplicate"
+	ActionImport    Action = "import"
+	ActionGenerate  Action = "generate"
+)
+
+// All actions, in the order they are shown in filters.
+var Actions = []Action{ActionCreate, ActionUpdate, ActionDelete, ActionDuplicate, ActionImport, ActionGenerate}
+
+type TargetType string
+
+const (
+	TargetQuiz      TargetType = "quiz"
+	TargetQuestion  TargetType = "question"
+	TargetLabel     TargetType = "label"
+	TargetUsername  TargetType = "username"
+	TargetAdmin     TargetType = "admin"
+	TargetPrizeDraw TargetType = "prize_draw"
+)
+
+// All target types, in the order they are shown in filters.
+var TargetTypes = []TargetType{TargetQuiz, TargetQuestion, TargetLabel, TargetUsername, TargetAdmin, TargetPrizeDraw}
+
+type Entry struct {
+	ID         uuid.UUID
+	ActorID    uuid.NullUUID // Not valid if the actor has since been deleted.
+	ActorEmail string
+	Action     Action
+	TargetType TargetType
+	TargetID   string
+	Before     string // JSON of the value before the action, empty if there was none.
+	After      string // JSON of the value after the action, empty if there is none.
+	CreatedAt  time.Time
+}
+
+// Filter for audit log entries. Zero values are not filtered on.
+type Filter struct {
+	ActorEmail string // Matches any part of the email.
+	Action     Action
+	TargetType TargetType
+	TargetID   string
+	From       time.Time
+	To         time.Time
+}
+
+// Records an action performed by the given actor.
+// Before and after are stored as JSON, and a nil value is stored as no value.
+func Record(db *sql.DB, actorID uuid.UUID, action Action, targetType TargetType, targetID string, before any, after any) error {
+	beforeJSON, err := toNullJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := toNullJSON(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		`INSERT INTO audit_log (actor_id, actor_email, action, target_type, target_id, before_value, after_value)
+		SELECT u.id, u.email, $2, $3, $4, $5, $6
+		FROM users u
+		WHERE u.id = $1;`,
+		actorID, action, targetType, targetID, beforeJSON, afterJSON)
+	return err
+}
+
+func toNullJSON(value any) (sql.NullString, error) {
+	if value == nil {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+// Gets a page of entries matching the filter, newest first, and the total number of matching entries.
+// Pages start at 1.
+func GetEntries(db *sql.DB, filter Filter, page int, perPage int) ([]Entry, int, error) {
+	where, args := filter.whereClause()
+
+	var total int
+	err := db.QueryRow(`SELECT COUNT(*) FROM audit_log `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, perPage, (page-1)*perPage)
+	rows, err := db.Query(
+		fmt.Sprintf(
+			`SELECT id, actor_id, actor_email, action, target_type, target_id,
+				COALESCE(before_value::TEXT, ''), COALESCE(after_value::TEXT, ''), created_at
+			FROM audit_log
+			%s
+			ORDER BY created_at DESC
+			LIMIT $%d OFFSET $%d;`, where, len(args)-1, len(args)),
+		args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		err := rows.Scan(&e.ID, &e.ActorID, &e.ActorEmail, &e.Action, &e.TargetType, &e.TargetID, &e.Before, &e.After, &e.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// Builds the WHERE clause and its arguments for the filter.
+func (f Filter) whereClause() (string, []any) {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.ActorEmail != "" {
+		add("actor_email ILIKE '%%' || $%d || '%%'", f.ActorEmail)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
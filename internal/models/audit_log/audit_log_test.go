@@ -0,0 +1,43 @@
+//go:build unit
+
+package audit_log
+
+import (
+	"testing"
+	"time"
+)
+
+// TestWhereClauseEmpty tests that an empty filter does not filter anything
+func TestWhereClauseEmpty(t *testing.T) {
+	where, args := Filter{}.whereClause()
+	if where != "" || len(args) != 0 {
+		t.Errorf("Expected no WHERE clause, but got %q with %v", where, args)
+	}
+}
+
+// TestWhereClause tests that set fields are numbered in order
+func TestWhereClause(t *testing.T) {
+	from := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	where, args := Filter{ActorEmail: "admin", TargetType: TargetQuiz, From: from}.whereClause()
+
+	expected := "WHERE actor_email ILIKE '%' || $1 || '%' AND target_type = $2 AND created_at >= $3"
+	if where != expected {
+		t.Errorf("Expected %q, but got %q", expected, where)
+	}
+	if len(args) != 3 || args[0] != "admin" || args[1] != TargetQuiz || args[2] != from {
+		t.Errorf("Expected arguments in filter order, but got %v", args)
+	}
+}
+
+// TestToNullJSON tests that nil is stored as no value and other values as JSON
+func TestToNullJSON(t *testing.T) {
+	value, err := toNullJSON(nil)
+	if err != nil || value.Valid {
+		t.Errorf("Expected invalid value for nil, but got %v, %v", value, err)
+	}
+
+	value, err = toNullJSON(map[string]string{"title": "Quiz"})
+	if err != nil || value.String != `{"title":"Quiz"}` {
+		t.Errorf("Expected JSON object, but got %v, %v", value, err)
+	}
+}
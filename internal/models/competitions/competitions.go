@@ -0,0 +1,239 @@
+// Package competitions ranks the users in labels with a start and an end, and freezes the final results when they end.
+package competitions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotCompetition = errors.New("competitions: the label is not a competition")
+	ErrNotEnded       = errors.New("competitions: the competition has not ended")
+)
+
+// A user's place in a competition.
+type Standing struct {
+	UserID           uuid.NullUUID // Not valid if the user was deleted after the results were frozen.
+	Username         string        // Empty if the user has been deleted.
+	Points           int
+	Placement        int
+	QuizzesCompleted int
+}
+
+// The standings of a competition.
+type Results struct {
+	Competition labels.Label
+	Standings   []Standing
+	// Whether the standings are frozen. Until then they change as users complete quizzes.
+	Final bool
+}
+
+// Returns the standings in first place, more than one if they share it.
+func (r *Results) Winners() []Standing {
+	winners := []Standing{}
+	for _, standing := range r.Standings {
+		if standing.Placement == 1 {
+			winners = append(winners, standing)
+		}
+	}
+	return winners
+}
+
+// Either a database or a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Calculates the standings of the competition from the quizzes completed within it,
+// counting only users who have opted in to the ranking.
+func getLiveStandings(q querier, competition labels.Label) ([]Standing, error) {
+	rows, err := q.Query(
+		`SELECT
+			uq.user_id,
+			CONCAT(u.username_adjective, ' ', u.username_noun) AS username,
+			SUM(uq.total_points_awarded) AS points,
+			RANK() OVER (ORDER BY SUM(uq.total_points_awarded) DESC) AS placement,
+			COUNT(*) AS quizzes_completed
+		FROM user_quizzes uq
+		JOIN quiz_labels ql ON ql.quiz_id = uq.quiz_id AND ql.label_id = $1
+		JOIN quizzes q ON q.id = uq.quiz_id
+		JOIN users u ON u.id = uq.user_id
+		WHERE uq.is_completed = true
+		AND uq.answered_within_active_time = true
+		AND q.published = true
+		AND q.is_deleted = false
+		AND u.opt_in_ranking = true
+		AND ($2::timestamptz IS NULL OR uq.finished_at >= $2)
+		AND uq.finished_at < $3
+		GROUP BY uq.user_id, username
+		ORDER BY placement, username;`,
+		competition.ID, competition.StartsAt, competition.EndsAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	return scanStandings(rows)
+}
+
+// Returns the frozen standings of the competition.
+func getFrozenStandings(db *sql.DB, labelID uuid.UUID) ([]Standing, error) {
+	rows, err := db.Query(
+		`SELECT user_id, COALESCE(username, ''), points, placement, quizzes_completed
+		FROM competition_results
+		WHERE label_id = $1
+		ORDER BY placement, username;`, labelID)
+	if err != nil {
+		return nil, err
+	}
+	return scanStandings(rows)
+}
+
+func scanStandings(rows *sql.Rows) ([]Standing, error) {
+	defer rows.Close()
+
+	standings := []Standing{}
+	for rows.Next() {
+		var s Standing
+		if err := rows.Scan(&s.UserID, &s.Username, &s.Points, &s.Placement, &s.QuizzesCompleted); err != nil {
+			return nil, err
+		}
+		standings = append(standings, s)
+	}
+	return standings, rows.Err()
+}
+
+// Returns the standings of the competition. They are final if the results are frozen.
+// Returns ErrNotCompetition if the label is not a competition.
+func GetResults(db *sql.DB, labelID uuid.UUID) (*Results, error) {
+	competition, err := labels.GetLabelByID(db, labelID)
+	if err != nil {
+		return nil, err
+	}
+	if !competition.IsCompetition() {
+		return nil, ErrNotCompetition
+	}
+
+	results := Results{Competition: competition, Final: competition.ResultsFrozenAt.Valid}
+	if results.Final {
+		results.Standings, err = getFrozenStandings(db, labelID)
+	} else {
+		results.Standings, err = getLiveStandings(db, competition)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// Copies the standings of the ended competition, so they do not change if quizzes are later edited or deleted,
+// or users are deleted.
+// Does nothing if the results are already frozen.
+//
+// Returns ErrNotCompetition if the label is not a competition, and ErrNotEnded if it has not ended at the given time.
+func FreezeResults(db *sql.DB, ctx context.Context, labelID uuid.UUID, now time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the label makes other replicas freezing the same competition wait, and then see it frozen
+	competition := labels.Label{ID: labelID}
+	err = tx.QueryRowContext(ctx,
+		`SELECT starts_at, ends_at, results_frozen_at
+		FROM labels
+		WHERE id = $1
+		FOR UPDATE;`, labelID,
+	).Scan(&competition.StartsAt, &competition.EndsAt, &competition.ResultsFrozenAt)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !competition.IsCompetition():
+		return ErrNotCompetition
+	case !competition.HasEnded(now):
+		return ErrNotEnded
+	case competition.ResultsFrozenAt.Valid:
+		return nil
+	}
+
+	standings, err := getLiveStandings(tx, competition)
+	if err != nil {
+		return err
+	}
+	for _, s := range standings {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO competition_results (label_id, user_id, username, points, placement, quizzes_completed)
+			VALUES ($1, $2, $3, $4, $5, $6);`,
+			labelID, s.UserID, s.Username, s.Points, s.Placement, s.QuizzesCompleted)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE labels SET results_frozen_at = $2 WHERE id = $1;`, labelID, now)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Freezes the results of every competition which has ended at the given time.
+// A competition failing to freeze does not stop the others, all the errors are returned joined.
+func freezeEndedCompetitions(db *sql.DB, ctx context.Context, now time.Time) error {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id
+		FROM labels
+		WHERE ends_at <= $1 AND results_frozen_at IS NULL;`, now)
+	if err != nil {
+		return err
+	}
+	ended := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ended = append(ended, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, id := range ended {
+		if err := FreezeResults(db, ctx, id, now); err != nil {
+			log.Printf("competitions: failed to freeze results of label %s: %v", id, err)
+			errs = append(errs, fmt.Errorf("label %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Periodically freezes the results of competitions which have ended.
+//
+// Blocks until the context is cancelled, so it should be started in its own goroutine.
+func RunResultsFreezer(ctx context.Context, db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := freezeEndedCompetitions(db, ctx, time.Now().UTC())
+			if err != nil {
+				log.Println("competitions: failed to freeze results:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+//go:build integration
+
+package competitions
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompetitionsIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestCompetitionsIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(CompetitionsIntegrationTestSuite))
+}
+
+// Creates a published quiz with the label and one question worth 100 points, answered correctly by the test user at the given time.
+func (s *CompetitionsIntegrationTestSuite) createCompletedQuiz(labelID uuid.UUID, answeredAt time.Time) uuid.UUID {
+	quiz := quizzes.CreateDefaultQuiz()
+	quiz.ActiveFrom = answeredAt.Add(-time.Hour)
+	quiz.Published = true
+	_, err := quizzes.CreateQuiz(s.DB, quiz)
+	s.Require().NoError(err)
+	s.Require().NoError(labels.AddLabelToQuiz(s.DB, quiz.ID, labelID))
+
+	questionID, alternativeID := uuid.New(), uuid.New()
+	_, err = s.DB.Exec(`INSERT INTO questions (id, question, quiz_id, points) VALUES ($1, 'Spørsmål', $2, 100)`, questionID, quiz.ID)
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(`INSERT INTO answer_alternatives (id, text, correct, question_id) VALUES ($1, 'Riktig', true, $2)`,
+		alternativeID, questionID)
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(`INSERT INTO user_answers (user_id, question_id, question_presented_at, chosen_answer_alternative_id, answered_at)
+		VALUES ($1, $2, $3, $4, $3)`, s.InsertedValues.UserId, questionID, answeredAt, alternativeID)
+	s.Require().NoError(err)
+	return quiz.ID
+}
+
+// Creates a label which is a competition running from an hour ago until the given end.
+func (s *CompetitionsIntegrationTestSuite) createCompetition(endsAt time.Time) uuid.UUID {
+	labelID, err := labels.CreateLabel(s.DB, "Konkurranse "+uuid.NewString())
+	s.Require().NoError(err)
+	startsAt := time.Now().Add(-time.Hour)
+	err = labels.UpdateCompetition(s.DB, labelID,
+		sql.NullTime{Time: startsAt, Valid: true}, sql.NullTime{Time: endsAt, Valid: true}, "Gavekort")
+	s.Require().NoError(err)
+	return labelID
+}
+
+func (s *CompetitionsIntegrationTestSuite) TestLiveResults() {
+	labelID := s.createCompetition(time.Now().Add(time.Hour))
+	s.createCompletedQuiz(labelID, time.Now().Add(-time.Minute))
+	// Completed before the competition started, so it does not count
+	s.createCompletedQuiz(labelID, time.Now().Add(-2*time.Hour))
+
+	results, err := GetResults(s.DB, labelID)
+	s.Require().NoError(err)
+	s.Require().False(results.Final)
+	s.Require().Len(results.Standings, 1)
+	s.Require().Equal(uuid.NullUUID{UUID: s.InsertedValues.UserId, Valid: true}, results.Standings[0].UserID)
+	s.Require().Equal(100, results.Standings[0].Points)
+	s.Require().Equal(1, results.Standings[0].QuizzesCompleted)
+
+	err = FreezeResults(s.DB, context.Background(), labelID, time.Now())
+	s.Require().ErrorIs(err, ErrNotEnded)
+}
+
+func (s *CompetitionsIntegrationTestSuite) TestFrozenResultsDoNotChange() {
+	endsAt := time.Now().Add(-time.Second)
+	labelID := s.createCompetition(endsAt)
+	quizID := s.createCompletedQuiz(labelID, time.Now().Add(-time.Minute))
+
+	s.Require().NoError(freezeEndedCompetitions(s.DB, context.Background(), time.Now()))
+	frozen, err := GetResults(s.DB, labelID)
+	s.Require().NoError(err)
+	s.Require().True(frozen.Final)
+	s.Require().Len(frozen.Winners(), 1)
+
+	// Freezing again does nothing
+	s.Require().NoError(FreezeResults(s.DB, context.Background(), labelID, time.Now()))
+
+	s.Require().NoError(quizzes.DeleteQuizByID(s.DB, quizID))
+	s.Require().NoError(labels.RemoveLabelFromQuiz(s.DB, quizID, labelID))
+	_, err = s.DB.Exec(`UPDATE questions SET points = 500 WHERE quiz_id = $1`, quizID)
+	s.Require().NoError(err)
+
+	results, err := GetResults(s.DB, labelID)
+	s.Require().NoError(err)
+	s.Require().Equal(frozen.Standings, results.Standings)
+
+	err = labels.UpdateCompetition(s.DB, labelID, sql.NullTime{}, sql.NullTime{Time: time.Now(), Valid: true}, "")
+	s.Require().ErrorIs(err, labels.ErrResultsFrozen)
+}
+
+func (s *CompetitionsIntegrationTestSuite) TestFreezeContinuesAfterFailure() {
+	failingID := s.createCompetition(time.Now().Add(-time.Second))
+	s.createCompletedQuiz(failingID, time.Now().Add(-time.Minute))
+	// The user already has a result, so copying the standings fails
+	_, err := s.DB.Exec(`INSERT INTO competition_results (label_id, user_id, username, points, placement, quizzes_completed)
+		VALUES ($1, $2, 'bruker', 0, 1, 0)`, failingID, s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	labelID := s.createCompetition(time.Now().Add(-time.Second))
+	s.createCompletedQuiz(labelID, time.Now().Add(-time.Minute))
+
+	err = freezeEndedCompetitions(s.DB, context.Background(), time.Now())
+	s.Require().Error(err)
+	s.Require().Contains(err.Error(), failingID.String())
+
+	results, err := GetResults(s.DB, labelID)
+	s.Require().NoError(err)
+	s.Require().True(results.Final)
+	failing, err := GetResults(s.DB, failingID)
+	s.Require().NoError(err)
+	s.Require().False(failing.Final)
+}
+
+func (s *CompetitionsIntegrationTestSuite) TestFrozenResultsKeepDeletedUsers() {
+	labelID := s.createCompetition(time.Now().Add(-time.Second))
+	s.createCompletedQuiz(labelID, time.Now().Add(-time.Minute))
+	s.Require().NoError(FreezeResults(s.DB, context.Background(), labelID, time.Now()))
+
+	_, err := s.DB.Exec(`DELETE FROM users WHERE id = $1`, s.InsertedValues.UserId)
+	s.Require().NoError(err)
+
+	results, err := GetResults(s.DB, labelID)
+	s.Require().NoError(err)
+	s.Require().Len(results.Standings, 1)
+	s.Require().False(results.Standings[0].UserID.Valid)
+	s.Require().Empty(results.Standings[0].Username)
+	s.Require().Equal(100, results.Standings[0].Points)
+	s.Require().Equal(1, results.Standings[0].Placement)
+}
+
+func (s *CompetitionsIntegrationTestSuite) TestLabelWithoutEnd() {
+	labelID, err := labels.CreateLabel(s.DB, "Ikke en konkurranse")
+	s.Require().NoError(err)
+
+	_, err = GetResults(s.DB, labelID)
+	s.Require().ErrorIs(err, ErrNotCompetition)
+	err = FreezeResults(s.DB, context.Background(), labelID, time.Now())
+	s.Require().ErrorIs(err, ErrNotCompetition)
+}
@@ -0,0 +1,71 @@
+//go:build unit
+
+package competitions_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/competitions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
+)
+
+func TestCompetitionPeriod(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	competition := labels.Label{
+		StartsAt: sql.NullTime{Time: start, Valid: true},
+		EndsAt:   sql.NullTime{Time: end, Valid: true},
+	}
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		wantOngoing bool
+		wantEnded   bool
+	}{
+		{"before start", start.Add(-time.Second), false, false},
+		{"at start", start, true, false},
+		{"before end", end.Add(-time.Second), true, false},
+		{"at end", end, false, true},
+		{"after end", end.Add(time.Hour), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := competition.IsOngoing(tt.now); got != tt.wantOngoing {
+				t.Errorf("IsOngoing() = %v, want %v", got, tt.wantOngoing)
+			}
+			if got := competition.HasEnded(tt.now); got != tt.wantEnded {
+				t.Errorf("HasEnded() = %v, want %v", got, tt.wantEnded)
+			}
+		})
+	}
+}
+
+func TestLabelWithoutEndIsNoCompetition(t *testing.T) {
+	label := labels.Label{StartsAt: sql.NullTime{Time: time.Now(), Valid: true}}
+	if label.IsCompetition() {
+		t.Error("IsCompetition() = true for a label without an end")
+	}
+	if label.HasEnded(time.Now().Add(time.Hour)) || label.IsOngoing(time.Now().Add(time.Hour)) {
+		t.Error("a label which is not a competition should neither be ongoing nor ended")
+	}
+}
+
+func TestWinners(t *testing.T) {
+	results := competitions.Results{Standings: []competitions.Standing{
+		{Username: "a", Points: 300, Placement: 1},
+		{Username: "b", Points: 300, Placement: 1},
+		{Username: "c", Points: 200, Placement: 3},
+	}}
+
+	winners := results.Winners()
+	if len(winners) != 2 || winners[0].Username != "a" || winners[1].Username != "b" {
+		t.Errorf("Winners() = %v, want the two users sharing first place", winners)
+	}
+
+	if winners := (&competitions.Results{}).Winners(); len(winners) != 0 {
+		t.Errorf("Winners() = %v, want none without standings", winners)
+	}
+}
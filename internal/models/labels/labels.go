@@ -2,6 +2,7 @@ package labels
 
 import (
 	"database/sql"
+	"errors"
 
 	"time"
 
@@ -9,40 +10,78 @@ import (
 )
 
 // Label struct
+//
+// A label with an end time is also a competition, ranking the users by the quizzes with the label
+// completed between the start and the end. The results are frozen when the competition ends.
 type Label struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	Active    bool      `json:"active"`
+	ID              uuid.UUID    `json:"id"`
+	Name            string       `json:"name"`
+	CreatedAt       time.Time    `json:"created_at"`
+	Active          bool         `json:"active"`
+	StartsAt        sql.NullTime `json:"starts_at"`
+	EndsAt          sql.NullTime `json:"ends_at"`
+	Prize           string       `json:"prize"`
+	ResultsFrozenAt sql.NullTime `json:"results_frozen_at"`
 }
 
-// GetLabels returns a list of all labels in the database.
-func GetLabels(db *sql.DB) ([]Label, error) {
-	rows, err := db.Query("SELECT id, name, created_at, is_active FROM labels")
-	if err != nil {
-		return nil, err
-	}
+var (
+	ErrInvalidCompetitionPeriod = errors.New("labels: the competition must end after it starts")
+	ErrResultsFrozen            = errors.New("labels: the results of the competition are frozen")
+)
+
+// Whether the label is a competition.
+func (l *Label) IsCompetition() bool {
+	return l.EndsAt.Valid
+}
+
+// Whether the competition has ended at the given time. Always false for labels which are not competitions.
+func (l *Label) HasEnded(now time.Time) bool {
+	return l.EndsAt.Valid && !now.Before(l.EndsAt.Time)
+}
+
+// Whether the competition is running at the given time.
+func (l *Label) IsOngoing(now time.Time) bool {
+	return l.IsCompetition() && !l.HasEnded(now) && (!l.StartsAt.Valid || !now.Before(l.StartsAt.Time))
+}
+
+// The columns scanned by scanLabel.
+const labelColumns = "l.id, l.name, l.created_at, l.is_active, l.starts_at, l.ends_at, l.prize, l.results_frozen_at"
+
+// Scans a row with the labelColumns.
+func scanLabel(row interface{ Scan(...any) error }) (Label, error) {
+	var label Label
+	err := row.Scan(&label.ID, &label.Name, &label.CreatedAt, &label.Active,
+		&label.StartsAt, &label.EndsAt, &label.Prize, &label.ResultsFrozenAt)
+	return label, err
+}
+
+// Scans all rows with the labelColumns.
+func scanLabels(rows *sql.Rows) ([]Label, error) {
 	defer rows.Close()
 
 	labels := []Label{}
 	for rows.Next() {
-		var label Label
-		if err := rows.Scan(&label.ID, &label.Name, &label.CreatedAt, &label.Active); err != nil {
+		label, err := scanLabel(rows)
+		if err != nil {
 			return nil, err
 		}
 		labels = append(labels, label)
 	}
-	return labels, nil
+	return labels, rows.Err()
 }
 
-// GetLabelByID returns a label by its ID.
-func GetLabelByID(db *sql.DB, id uuid.UUID) (Label, error) {
-	var label Label
-	err := db.QueryRow("SELECT id, name, created_at, is_active FROM labels WHERE id=$1", id).Scan(&label.ID, &label.Name, &label.CreatedAt, &label.Active)
+// GetLabels returns a list of all labels in the database.
+func GetLabels(db *sql.DB) ([]Label, error) {
+	rows, err := db.Query("SELECT " + labelColumns + " FROM labels l")
 	if err != nil {
-		return label, err
+		return nil, err
 	}
-	return label, nil
+	return scanLabels(rows)
+}
+
+// GetLabelByID returns a label by its ID.
+func GetLabelByID(db *sql.DB, id uuid.UUID) (Label, error) {
+	return scanLabel(db.QueryRow("SELECT "+labelColumns+" FROM labels l WHERE l.id=$1", id))
 }
 
 // CreateLabel creates a new label in the database.
@@ -58,21 +97,11 @@ func CreateLabel(db *sql.DB, name string) (uuid.UUID, error) {
 // GetLabelByQuizzID returns a list of labels that are associated with the given quizz.
 // It will return an empty list if the quizz is not associated with any label.
 func GetLabelByQuizID(db *sql.DB, quizID uuid.UUID) ([]Label, error) {
-	rows, err := db.Query("SELECT l.id, l.name, l.created_at, l.is_active FROM labels l JOIN quiz_labels ql ON l.id = ql.label_id WHERE ql.quiz_id=$1", quizID)
+	rows, err := db.Query("SELECT "+labelColumns+" FROM labels l JOIN quiz_labels ql ON l.id = ql.label_id WHERE ql.quiz_id=$1", quizID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	labels := []Label{}
-	for rows.Next() {
-		var label Label
-		if err := rows.Scan(&label.ID, &label.Name, &label.CreatedAt, &label.Active); err != nil {
-			return nil, err
-		}
-		labels = append(labels, label)
-	}
-	return labels, nil
+	return scanLabels(rows)
 }
 
 // GetQuizzesByLabelID returns a list of quizz IDs that are associated with the given label.
@@ -133,19 +162,48 @@ func UpdateLabel(db *sql.DB, id uuid.UUID, active bool) error {
 
 // GetActiveLabels returns a list of all active labels in the database.
 func GetActiveLabels(db *sql.DB) ([]Label, error) {
-	rows, err := db.Query("SELECT id, name, created_at, is_active FROM labels WHERE is_active=true")
+	rows, err := db.Query("SELECT " + labelColumns + " FROM labels l WHERE l.is_active=true")
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanLabels(rows)
+}
 
-	labels := []Label{}
-	for rows.Next() {
-		var label Label
-		if err := rows.Scan(&label.ID, &label.Name, &label.CreatedAt, &label.Active); err != nil {
-			return nil, err
-		}
-		labels = append(labels, label)
+// GetCompetitions returns all labels which are competitions, the most recently ending first.
+func GetCompetitions(db *sql.DB) ([]Label, error) {
+	rows, err := db.Query("SELECT " + labelColumns + " FROM labels l WHERE l.ends_at IS NOT NULL ORDER BY l.ends_at DESC")
+	if err != nil {
+		return nil, err
 	}
-	return labels, nil
+	return scanLabels(rows)
+}
+
+// UpdateCompetition sets when the competition starts and ends, and the prize.
+// The label stops being a competition if endsAt is not valid. A missing start means the competition
+// counts every quiz with the label completed before the end.
+// It will fail with ErrResultsFrozen if the results of the competition are already frozen.
+func UpdateCompetition(db *sql.DB, id uuid.UUID, startsAt sql.NullTime, endsAt sql.NullTime, prize string) error {
+	if startsAt.Valid && endsAt.Valid && !startsAt.Time.Before(endsAt.Time) {
+		return ErrInvalidCompetitionPeriod
+	}
+
+	result, err := db.Exec(
+		"UPDATE labels SET starts_at=$2, ends_at=$3, prize=$4 WHERE id=$1 AND results_frozen_at IS NULL",
+		id, startsAt, endsAt, prize)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected > 0 {
+		return err
+	}
+
+	// Either the label does not exist or the results are frozen
+	label, err := GetLabelByID(db, id)
+	if err != nil {
+		return err
+	}
+	if label.ResultsFrozenAt.Valid {
+		return ErrResultsFrozen
+	}
+	return nil
 }
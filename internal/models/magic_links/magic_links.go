@@ -0,0 +1,103 @@
+// Package magic_links issues and consumes single-use login tokens sent to users by email.
+package magic_links
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken    = errors.New("magic_links: invalid, expired or already used token")
+	ErrTooManyRequests = errors.New("magic_links: too many links requested for the email")
+)
+
+const (
+	// How long a link can be used after it was sent.
+	TokenLifetime = 15 * time.Minute
+	// How many unused links an email can have at once, so the login form can not be used to flood an inbox.
+	maxActiveLinks = 3
+)
+
+// Creates a login token for the email and stores it.
+// The returned token is signed with the key, and only its hash is stored.
+func Create(db *sql.DB, key []byte, email string) (string, error) {
+	_, err := db.Exec(`DELETE FROM magic_links WHERE expires_at < now();`)
+	if err != nil {
+		return "", err
+	}
+
+	var active int
+	err = db.QueryRow(
+		`SELECT COUNT(*) FROM magic_links
+		WHERE email = $1 AND used_at IS NULL;`,
+		email).Scan(&active)
+	if err != nil {
+		return "", err
+	}
+	if active >= maxActiveLinks {
+		return "", ErrTooManyRequests
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(random)
+
+	_, err = db.Exec(
+		`INSERT INTO magic_links (token_hash, email, expires_at)
+		VALUES ($1, $2, $3);`,
+		hashToken(raw), email, time.Now().Add(TokenLifetime))
+	if err != nil {
+		return "", err
+	}
+	return raw + "." + sign(key, raw), nil
+}
+
+// Marks the token as used and returns the email it was created for.
+// Returns ErrInvalidToken if the signature is wrong, or the token is unknown, expired or already used.
+func Consume(db *sql.DB, key []byte, token string) (string, error) {
+	raw, err := verify(key, token)
+	if err != nil {
+		return "", err
+	}
+
+	var email string
+	err = db.QueryRow(
+		`UPDATE magic_links
+		SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING email;`,
+		hashToken(raw)).Scan(&email)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidToken
+	}
+	return email, err
+}
+
+// Checks the signature of the token and returns the unsigned part.
+func verify(key []byte, token string) (string, error) {
+	raw, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(sign(key, raw))) {
+		return "", ErrInvalidToken
+	}
+	return raw, nil
+}
+
+func sign(key []byte, raw string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(raw))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func hashToken(raw string) string {
+	hash := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,53 @@
+//go:build integration
+
+package magic_links
+
+import (
+	"testing"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/stretchr/testify/suite"
+)
+
+type MagicLinksIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestMagicLinksIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(MagicLinksIntegrationTestSuite))
+}
+
+var testKey = []byte("test key")
+
+func (s *MagicLinksIntegrationTestSuite) TestConsumeOnce() {
+	token, err := Create(s.DB, testKey, "magic_link_once@email.com")
+	s.Require().NoError(err)
+
+	email, err := Consume(s.DB, testKey, token)
+	s.Require().NoError(err)
+	s.Require().Equal("magic_link_once@email.com", email)
+
+	_, err = Consume(s.DB, testKey, token)
+	s.Require().ErrorIs(err, ErrInvalidToken)
+}
+
+func (s *MagicLinksIntegrationTestSuite) TestConsumeExpired() {
+	token, err := Create(s.DB, testKey, "magic_link_expired@email.com")
+	s.Require().NoError(err)
+
+	_, err = s.DB.Exec(`UPDATE magic_links SET expires_at = now() - interval '1 minute' WHERE email = $1`, "magic_link_expired@email.com")
+	s.Require().NoError(err)
+
+	_, err = Consume(s.DB, testKey, token)
+	s.Require().ErrorIs(err, ErrInvalidToken)
+}
+
+func (s *MagicLinksIntegrationTestSuite) TestTooManyRequests() {
+	for i := 0; i < maxActiveLinks; i++ {
+		_, err := Create(s.DB, testKey, "magic_link_many@email.com")
+		s.Require().NoError(err)
+	}
+
+	_, err := Create(s.DB, testKey, "magic_link_many@email.com")
+	s.Require().ErrorIs(err, ErrTooManyRequests)
+}
@@ -0,0 +1,33 @@
+//go:build unit
+
+package magic_links
+
+import (
+	"testing"
+)
+
+// TestVerify tests that only tokens signed with the same key are accepted
+func TestVerify(t *testing.T) {
+	key := []byte("key")
+	token := "some-token." + sign(key, "some-token")
+
+	raw, err := verify(key, token)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if raw != "some-token" {
+		t.Errorf("Expected 'some-token', but got %q", raw)
+	}
+
+	tests := []string{
+		"some-token",
+		"some-token.",
+		"other-token." + sign(key, "some-token"),
+		"some-token." + sign([]byte("other key"), "some-token"),
+	}
+	for _, test := range tests {
+		if _, err := verify(key, test); err != ErrInvalidToken {
+			t.Errorf("Expected %v for %q, but got %v", ErrInvalidToken, test, err)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// Package prize_draws picks the winners of prizes among the users who have completed quizzes,
+// either the top of the ranking or a weighted random draw, and records how they were picked.
+package prize_draws
+
+import (
+	"context"
+	crypto_rand "crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Method string
+
+const (
+	// The users with the most points. Users sharing the last place all win.
+	MethodTop Method = "top"
+	// A random draw where each completed quiz is one ticket, so users who complete more quizzes are more likely to win.
+	MethodRandom Method = "random"
+)
+
+// The most winners a single draw can pick.
+const MaxWinnerCount = 100
+
+var (
+	ErrNoScope            = errors.New("prize_draws: a label or a period is required")
+	ErrInvalidPeriod      = errors.New("prize_draws: the period must start before it ends")
+	ErrInvalidMethod      = errors.New("prize_draws: unknown method")
+	ErrInvalidWinnerCount = errors.New("prize_draws: invalid number of winners")
+	ErrNoEntrants         = errors.New("prize_draws: no users have completed a quiz within the scope")
+)
+
+// The quizzes counted in a draw. At least one of the fields must be set.
+type Scope struct {
+	LabelID uuid.NullUUID
+	From    sql.NullTime // Inclusive
+	To      sql.NullTime // Exclusive
+}
+
+// Checks that the scope limits the quizzes, and that the period is not empty.
+func (s Scope) validate() error {
+	switch {
+	case !s.LabelID.Valid && !s.From.Valid && !s.To.Valid:
+		return ErrNoScope
+	case s.From.Valid && s.To.Valid && !s.From.Time.Before(s.To.Time):
+		return ErrInvalidPeriod
+	default:
+		return nil
+	}
+}
+
+// A user who could win a draw.
+type Entrant struct {
+	UserID           uuid.NullUUID // Not valid if the user has since been deleted.
+	Points           int
+	QuizzesCompleted int
+	// The order the entrant was picked in, starting at 1. 0 if the entrant did not win.
+	WinnerPosition int
+
+	// Contact details, empty if the user has been deleted.
+	Username string
+	Email    string
+	Phone    string
+}
+
+// The number of tickets the entrant has in a random draw.
+func (e *Entrant) tickets() int {
+	return e.QuizzesCompleted
+}
+
+type Draw struct {
+	ID             uuid.UUID
+	Method         Method
+	Scope          Scope
+	LabelName      string
+	WinnerCount    int
+	Seed           sql.NullInt64 // Only valid for random draws.
+	CreatedByEmail string
+	CreatedAt      time.Time
+	// In the order the draw walked through them. Not loaded when listing draws.
+	Entrants []Entrant
+}
+
+// Returns the entrants who won, in the order they were picked.
+func (d *Draw) Winners() []Entrant {
+	winners := []Entrant{}
+	for _, entrant := range d.Entrants {
+		if entrant.WinnerPosition > 0 {
+			winners = append(winners, entrant)
+		}
+	}
+	slices.SortFunc(winners, func(a, b Entrant) int {
+		return a.WinnerPosition - b.WinnerPosition
+	})
+	return winners
+}
+
+// Picks the winners again from the recorded entrants and seed, and reports whether they are the recorded winners.
+func (d *Draw) Verify() bool {
+	picked, err := PickWinners(d.Method, d.Entrants, d.WinnerCount, d.Seed.Int64)
+	if err != nil {
+		return false
+	}
+	positions := make([]int, len(d.Entrants))
+	for position, index := range picked {
+		positions[index] = position + 1
+	}
+	for i, entrant := range d.Entrants {
+		if entrant.WinnerPosition != positions[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the indexes of the winning entrants, in the order they are picked. The seed is only used by random draws,
+// and the same method, entrants, count and seed always pick the same winners.
+func PickWinners(method Method, entrants []Entrant, count int, seed int64) ([]int, error) {
+	switch method {
+	case MethodTop:
+		return pickTop(entrants, count), nil
+	case MethodRandom:
+		return pickRandom(entrants, count, seed), nil
+	default:
+		return nil, ErrInvalidMethod
+	}
+}
+
+// Returns the indexes of the entrants placed within the count by points, the highest first.
+// Entrants with the same points share a placement, so more than count entrants are returned if they share the last one.
+func pickTop(entrants []Entrant, count int) []int {
+	indexes := make([]int, len(entrants))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	slices.SortStableFunc(indexes, func(a, b int) int {
+		return entrants[b].Points - entrants[a].Points
+	})
+
+	for i, index := range indexes {
+		// A placement is one more than the number of entrants with more points
+		if i >= count && entrants[index].Points < entrants[indexes[i-1]].Points {
+			return indexes[:i]
+		}
+	}
+	return indexes
+}
+
+// Returns the indexes of count entrants drawn without replacement, where the chance of being drawn is
+// proportional to the entrant's tickets. The same entrants and seed always give the same result.
+func pickRandom(entrants []Entrant, count int, seed int64) []int {
+	rng := rand.New(rand.NewPCG(uint64(seed), 0))
+
+	remaining := 0
+	for i := range entrants {
+		remaining += entrants[i].tickets()
+	}
+	drawn := make([]bool, len(entrants))
+	winners := []int{}
+	for len(winners) < count && remaining > 0 {
+		ticket := rng.IntN(remaining)
+		for i := range entrants {
+			if drawn[i] {
+				continue
+			}
+			if ticket < entrants[i].tickets() {
+				drawn[i] = true
+				remaining -= entrants[i].tickets()
+				winners = append(winners, i)
+				break
+			}
+			ticket -= entrants[i].tickets()
+		}
+	}
+	return winners
+}
+
+// Returns a random seed for a draw.
+func newSeed() (int64, error) {
+	var b [8]byte
+	if _, err := crypto_rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b[:])), nil
+}
+
+// Returns the users who have opted in to the ranking and completed a quiz within the scope, ordered by id.
+func getEntrants(tx *sql.Tx, ctx context.Context, scope Scope) ([]Entrant, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT uq.user_id, SUM(uq.total_points_awarded), COUNT(*)
+		FROM user_quizzes uq
+		JOIN quizzes q ON q.id = uq.quiz_id
+		JOIN users u ON u.id = uq.user_id
+		WHERE uq.is_completed = true
+		AND uq.answered_within_active_time = true
+		AND q.published = true
+		AND q.is_deleted = false
+		AND u.opt_in_ranking = true
+		AND ($1::uuid IS NULL OR EXISTS (SELECT 1 FROM quiz_labels ql WHERE ql.quiz_id = uq.quiz_id AND ql.label_id = $1))
+		AND ($2::timestamptz IS NULL OR uq.finished_at >= $2)
+		AND ($3::timestamptz IS NULL OR uq.finished_at < $3)
+		GROUP BY uq.user_id
+		ORDER BY uq.user_id;`,
+		scope.LabelID, scope.From, scope.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entrants := []Entrant{}
+	for rows.Next() {
+		var e Entrant
+		if err := rows.Scan(&e.UserID, &e.Points, &e.QuizzesCompleted); err != nil {
+			return nil, err
+		}
+		entrants = append(entrants, e)
+	}
+	return entrants, rows.Err()
+}
+
+// Picks the winners among the users who have completed quizzes within the scope, and records the draw
+// with everything needed to reproduce it.
+//
+// Returns the ID of the draw.
+// Returns ErrNoEntrants if no users have completed a quiz within the scope.
+func CreateDraw(db *sql.DB, ctx context.Context, actorID uuid.UUID, method Method, scope Scope, winnerCount int) (uuid.UUID, error) {
+	if err := scope.validate(); err != nil {
+		return uuid.Nil, err
+	}
+	if winnerCount < 1 || winnerCount > MaxWinnerCount {
+		return uuid.Nil, ErrInvalidWinnerCount
+	}
+
+	var seed sql.NullInt64
+	switch method {
+	case MethodTop:
+	case MethodRandom:
+		s, err := newSeed()
+		if err != nil {
+			return uuid.Nil, err
+		}
+		seed = sql.NullInt64{Int64: s, Valid: true}
+	default:
+		return uuid.Nil, ErrInvalidMethod
+	}
+
+	// The entrants must not change while the draw is recorded
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback()
+
+	labelName := ""
+	if scope.LabelID.Valid {
+		err = tx.QueryRowContext(ctx, `SELECT name FROM labels WHERE id = $1;`, scope.LabelID).Scan(&labelName)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	entrants, err := getEntrants(tx, ctx, scope)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if len(entrants) == 0 {
+		return uuid.Nil, ErrNoEntrants
+	}
+	winners, err := PickWinners(method, entrants, winnerCount, seed.Int64)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	for position, index := range winners {
+		entrants[index].WinnerPosition = position + 1
+	}
+
+	var drawID uuid.UUID
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO prize_draws (method, label_id, label_name, starts_at, ends_at, winner_count, seed, created_by, created_by_email)
+		SELECT $1, $2, $3, $4, $5, $6, $7, u.id, u.email
+		FROM users u
+		WHERE u.id = $8
+		RETURNING id;`,
+		method, scope.LabelID, labelName, scope.From, scope.To, winnerCount, seed, actorID,
+	).Scan(&drawID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	for i, e := range entrants {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO prize_draw_entrants (draw_id, entry_order, user_id, points, quizzes_completed, winner_position)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0));`,
+			drawID, i, e.UserID, e.Points, e.QuizzesCompleted, e.WinnerPosition)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, err
+	}
+	return drawID, nil
+}
+
+const drawColumns = `id, method, label_id, label_name, starts_at, ends_at, winner_count, seed, created_by_email, created_at`
+
+func scanDraw(row interface{ Scan(...any) error }) (Draw, error) {
+	var d Draw
+	err := row.Scan(&d.ID, &d.Method, &d.Scope.LabelID, &d.LabelName, &d.Scope.From, &d.Scope.To,
+		&d.WinnerCount, &d.Seed, &d.CreatedByEmail, &d.CreatedAt)
+	return d, err
+}
+
+// Returns all draws without their entrants, the newest first.
+func GetDraws(db *sql.DB) ([]Draw, error) {
+	rows, err := db.Query(`SELECT ` + drawColumns + ` FROM prize_draws ORDER BY created_at DESC;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	draws := []Draw{}
+	for rows.Next() {
+		d, err := scanDraw(rows)
+		if err != nil {
+			return nil, err
+		}
+		draws = append(draws, d)
+	}
+	return draws, rows.Err()
+}
+
+// Returns the draw with its entrants and their contact details.
+func GetDraw(db *sql.DB, id uuid.UUID) (*Draw, error) {
+	d, err := scanDraw(db.QueryRow(`SELECT `+drawColumns+` FROM prize_draws WHERE id = $1;`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		`SELECT
+			e.user_id, e.points, e.quizzes_completed, COALESCE(e.winner_position, 0),
+			CASE WHEN u.id IS NULL THEN '' ELSE CONCAT(u.username_adjective, ' ', u.username_noun) END,
+			COALESCE(u.email, ''), COALESCE(u.phone, '')
+		FROM prize_draw_entrants e
+		LEFT JOIN users u ON u.id = e.user_id
+		WHERE e.draw_id = $1
+		ORDER BY e.entry_order;`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	d.Entrants = []Entrant{}
+	for rows.Next() {
+		var e Entrant
+		err := rows.Scan(&e.UserID, &e.Points, &e.QuizzesCompleted, &e.WinnerPosition, &e.Username, &e.Email, &e.Phone)
+		if err != nil {
+			return nil, err
+		}
+		d.Entrants = append(d.Entrants, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
@@ -0,0 +1,88 @@
+//go:build integration
+
+package prize_draws
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type PrizeDrawsIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestPrizeDrawsIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(PrizeDrawsIntegrationTestSuite))
+}
+
+// Creates a published quiz with the label and one question worth 100 points, answered correctly by the test user.
+func (s *PrizeDrawsIntegrationTestSuite) createCompletedQuiz(labelID uuid.UUID) {
+	quiz := quizzes.CreateDefaultQuiz()
+	quiz.ActiveFrom = time.Now().Add(-time.Hour)
+	quiz.Published = true
+	_, err := quizzes.CreateQuiz(s.DB, quiz)
+	s.Require().NoError(err)
+	s.Require().NoError(labels.AddLabelToQuiz(s.DB, quiz.ID, labelID))
+
+	questionID, alternativeID := uuid.New(), uuid.New()
+	_, err = s.DB.Exec(`INSERT INTO questions (id, question, quiz_id, points) VALUES ($1, 'Spørsmål', $2, 100)`, questionID, quiz.ID)
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(`INSERT INTO answer_alternatives (id, text, correct, question_id) VALUES ($1, 'Riktig', true, $2)`,
+		alternativeID, questionID)
+	s.Require().NoError(err)
+	answeredAt := time.Now().Add(-time.Minute)
+	_, err = s.DB.Exec(`INSERT INTO user_answers (user_id, question_id, question_presented_at, chosen_answer_alternative_id, answered_at)
+		VALUES ($1, $2, $3, $4, $3)`, s.InsertedValues.UserId, questionID, answeredAt, alternativeID)
+	s.Require().NoError(err)
+}
+
+func (s *PrizeDrawsIntegrationTestSuite) TestRandomDraw() {
+	labelID, err := labels.CreateLabel(s.DB, "Trekning")
+	s.Require().NoError(err)
+	s.createCompletedQuiz(labelID)
+	s.createCompletedQuiz(labelID)
+	scope := Scope{LabelID: uuid.NullUUID{UUID: labelID, Valid: true}}
+
+	drawID, err := CreateDraw(s.DB, context.Background(), s.InsertedValues.UserId, MethodRandom, scope, 3)
+	s.Require().NoError(err)
+
+	draw, err := GetDraw(s.DB, drawID)
+	s.Require().NoError(err)
+	s.Require().True(draw.Seed.Valid)
+	s.Require().Equal("Trekning", draw.LabelName)
+	s.Require().True(draw.Verify())
+	winners := draw.Winners()
+	s.Require().Len(winners, 1)
+	s.Require().Equal(s.InsertedValues.UserId, winners[0].UserID.UUID)
+	s.Require().Equal(200, winners[0].Points)
+	s.Require().Equal(2, winners[0].QuizzesCompleted)
+	s.Require().Equal("test_user@email.com", winners[0].Email)
+
+	draws, err := GetDraws(s.DB)
+	s.Require().NoError(err)
+	s.Require().Equal(drawID, draws[0].ID)
+}
+
+func (s *PrizeDrawsIntegrationTestSuite) TestDrawScope() {
+	_, err := CreateDraw(s.DB, context.Background(), s.InsertedValues.UserId, MethodTop, Scope{}, 1)
+	s.Require().ErrorIs(err, ErrNoScope)
+
+	now := time.Now()
+	emptyPeriod := Scope{From: sql.NullTime{Time: now, Valid: true}, To: sql.NullTime{Time: now, Valid: true}}
+	_, err = CreateDraw(s.DB, context.Background(), s.InsertedValues.UserId, MethodTop, emptyPeriod, 1)
+	s.Require().ErrorIs(err, ErrInvalidPeriod)
+
+	labelID, err := labels.CreateLabel(s.DB, "Uten quizer")
+	s.Require().NoError(err)
+	scope := Scope{LabelID: uuid.NullUUID{UUID: labelID, Valid: true}}
+	_, err = CreateDraw(s.DB, context.Background(), s.InsertedValues.UserId, MethodTop, scope, 1)
+	s.Require().ErrorIs(err, ErrNoEntrants)
+}
@@ -0,0 +1,123 @@
+//go:build unit
+
+package prize_draws_test
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/prize_draws"
+)
+
+func entrants(points ...int) []prize_draws.Entrant {
+	result := []prize_draws.Entrant{}
+	for _, p := range points {
+		result = append(result, prize_draws.Entrant{Points: p, QuizzesCompleted: 1})
+	}
+	return result
+}
+
+func TestPickTop(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []int
+		count  int
+		want   []int
+	}{
+		{"highest first", []int{100, 300, 200}, 2, []int{1, 2}},
+		{"shared last place", []int{300, 200, 200, 100}, 2, []int{0, 1, 2}},
+		{"shared first place", []int{200, 200, 100}, 1, []int{0, 1}},
+		{"fewer entrants than count", []int{100, 200}, 5, []int{1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prize_draws.PickWinners(prize_draws.MethodTop, entrants(tt.points...), tt.count, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("PickWinners() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickRandomIsReproducible(t *testing.T) {
+	candidates := entrants(100, 200, 300, 400, 500, 600)
+	first, err := prize_draws.PickWinners(prize_draws.MethodRandom, candidates, 3, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 3 {
+		t.Fatalf("PickWinners() picked %d winners, want 3", len(first))
+	}
+
+	again, _ := prize_draws.PickWinners(prize_draws.MethodRandom, candidates, 3, 42)
+	if !slices.Equal(first, again) {
+		t.Errorf("the same seed picked %v and %v", first, again)
+	}
+
+	seen := map[int]bool{}
+	for _, index := range first {
+		if seen[index] {
+			t.Errorf("entrant %d was picked more than once in %v", index, first)
+		}
+		seen[index] = true
+	}
+}
+
+func TestPickRandomWeights(t *testing.T) {
+	candidates := []prize_draws.Entrant{{QuizzesCompleted: 0}, {QuizzesCompleted: 3}, {QuizzesCompleted: 0}}
+	for seed := int64(0); seed < 20; seed++ {
+		got, _ := prize_draws.PickWinners(prize_draws.MethodRandom, candidates, 3, seed)
+		if !slices.Equal(got, []int{1}) {
+			t.Fatalf("seed %d picked %v, want only the entrant with tickets", seed, got)
+		}
+	}
+
+	// One entrant has 9 of 10 tickets, so should win far more often than not
+	candidates = []prize_draws.Entrant{{QuizzesCompleted: 1}, {QuizzesCompleted: 9}}
+	wins := 0
+	for seed := int64(0); seed < 1000; seed++ {
+		got, _ := prize_draws.PickWinners(prize_draws.MethodRandom, candidates, 1, seed)
+		if got[0] == 1 {
+			wins++
+		}
+	}
+	if wins < 850 || wins > 950 {
+		t.Errorf("the entrant with 90%% of the tickets won %d of 1000 draws", wins)
+	}
+}
+
+func TestPickWinnersUnknownMethod(t *testing.T) {
+	if _, err := prize_draws.PickWinners("lottery", entrants(100), 1, 0); err != prize_draws.ErrInvalidMethod {
+		t.Errorf("PickWinners() error = %v, want ErrInvalidMethod", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	draw := prize_draws.Draw{
+		Method:      prize_draws.MethodRandom,
+		WinnerCount: 2,
+		Seed:        sql.NullInt64{Int64: 7, Valid: true},
+		Entrants:    entrants(100, 200, 300, 400),
+	}
+	picked, _ := prize_draws.PickWinners(draw.Method, draw.Entrants, draw.WinnerCount, draw.Seed.Int64)
+	for position, index := range picked {
+		draw.Entrants[index].WinnerPosition = position + 1
+	}
+
+	if !draw.Verify() {
+		t.Error("Verify() = false for the recorded winners")
+	}
+	winners := draw.Winners()
+	if len(winners) != 2 || winners[0].WinnerPosition != 1 || winners[1].WinnerPosition != 2 {
+		t.Errorf("Winners() = %v, want the two winners in the order they were picked", winners)
+	}
+
+	draw.Entrants[picked[0]].WinnerPosition, draw.Entrants[picked[1]].WinnerPosition = 2, 1
+	if draw.Verify() {
+		t.Error("Verify() = true for winners in the wrong order")
+	}
+}
@@ -0,0 +1,101 @@
+package questions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/ai"
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+	"github.com/google/uuid"
+)
+
+const MaxDraftQuestionsPerArticle = 5
+
+var ErrInvalidQuestionsPerArticle = errors.New("questions: questions per article must be between 1 and 5")
+
+// ArticleDraftResult is the outcome of generating draft questions for a single article.
+type ArticleDraftResult struct {
+	Article   articles.Article
+	Questions []Question // The questions added to the quiz.
+	Err       error      // Set if one or more questions could not be generated or saved.
+	// Generated questions dropped because they repeated an earlier question for the article,
+	// so the article got fewer questions than asked for.
+	Duplicates int
+}
+
+// GenerateDraftQuestions generates questionsPerArticle questions for every article attached to the quiz,
+// and adds them to the quiz in the order of the articles.
+// A failure for one article is recorded in its result instead of aborting the whole batch.
+func GenerateDraftQuestions(db *sql.DB, ctx context.Context, generator ai.QuestionGenerator, quizID uuid.UUID, questionsPerArticle int) ([]ArticleDraftResult, error) {
+	if questionsPerArticle < 1 || questionsPerArticle > MaxDraftQuestionsPerArticle {
+		return nil, ErrInvalidQuestionsPerArticle
+	}
+
+	articleList, err := articles.GetArticlesByQuizID(db, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The model is slow, so the articles are generated for concurrently.
+	generated := make([][]ai.Question, len(*articleList))
+	results := make([]ArticleDraftResult, len(*articleList))
+	var wg sync.WaitGroup
+	for i, article := range *articleList {
+		results[i].Article = article
+		wg.Add(1)
+		go func(i int, article articles.Article) {
+			defer wg.Done()
+			generated[i], results[i].Duplicates, results[i].Err = generateForArticle(ctx, generator, article, questionsPerArticle)
+		}(i, article)
+	}
+	wg.Wait()
+
+	// Questions are added one at a time to keep the order of the articles.
+	for i, aiQuestions := range generated {
+		for _, aiQuestion := range aiQuestions {
+			question := ConvertAiQuestionToQuestion(quizID, results[i].Article.ID.UUID, aiQuestion)
+			if err := AddNewQuestion(db, ctx, question); err != nil {
+				results[i].Err = errors.Join(results[i].Err, err)
+				continue
+			}
+			results[i].Questions = append(results[i].Questions, *question)
+		}
+	}
+
+	return results, nil
+}
+
+// Generates up to the given amount of distinct questions for the article.
+// Returns the questions generated so far, the number of duplicates dropped, and the errors of the failed attempts.
+func generateForArticle(ctx context.Context, generator ai.QuestionGenerator, article articles.Article, amount int) ([]ai.Question, int, error) {
+	smpArticle, err := articles.GetSmpArticleByURL(&article.ArticleURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	return generateDistinct(ctx, generator, smpArticle, amount)
+}
+
+// Asks the generator for the given amount of questions about the article, dropping questions it repeats.
+func generateDistinct(ctx context.Context, generator ai.QuestionGenerator, smpArticle articles.ArticleSMP, amount int) ([]ai.Question, int, error) {
+	var generated []ai.Question
+	var errs []error
+	duplicates := 0
+	seen := make(map[string]bool)
+	for i := 0; i < amount; i++ {
+		question, err := generator.GenerateQuestion(ctx, smpArticle)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if seen[question.Question] {
+			duplicates++
+			continue
+		}
+		seen[question.Question] = true
+		generated = append(generated, question)
+	}
+
+	return generated, duplicates, errors.Join(errs...)
+}
@@ -0,0 +1,23 @@
+//go:build unit
+
+package questions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/ai"
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+)
+
+// TestGenerateDistinctCountsDuplicates tests that repeated questions are dropped and counted
+func TestGenerateDistinctCountsDuplicates(t *testing.T) {
+	// The fake generator asks the same question every time
+	generated, duplicates, err := generateDistinct(context.Background(), ai.NewFakeGenerator(), articles.ArticleSMP{}, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if len(generated) != 1 || duplicates != 2 {
+		t.Errorf("Expected 1 question and 2 duplicates, but got %d questions and %d duplicates", len(generated), duplicates)
+	}
+}
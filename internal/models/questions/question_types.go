@@ -0,0 +1,225 @@
+package questions
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var ErrInvalidAnswer = errors.New("questions: answer does not fit the question")
+var ErrInvalidNumber = errors.New("questions: invalid number")
+
+// The type of a question, which decides how it is answered and scored.
+// The score of an answer is calculated by the database, see the answer_score function.
+type QuestionType string
+
+const (
+	// One alternative is chosen. The answer is correct if the chosen alternative is.
+	QuestionTypeSingleChoice QuestionType = "single_choice"
+	// Any number of alternatives are chosen. Each correct alternative chosen gives its share of the points,
+	// and each wrong alternative chosen takes the same share away.
+	QuestionTypeMultiSelect QuestionType = "multi_select"
+	// A statement which is either true or false. The question always has the alternatives "Sant" and "Usant".
+	QuestionTypeTrueFalse QuestionType = "true_false"
+	// A number is given. The answer gets fewer points the further it is from the correct number,
+	// and none when it is the tolerance or more off.
+	QuestionTypeNumericEstimate QuestionType = "numeric_estimate"
+	// The alternatives are put in order. The arrangement of the alternatives is the correct order,
+	// and each alternative in the right place gives its share of the points.
+	QuestionTypeOrdering QuestionType = "ordering"
+)
+
+// All question types, in the order they are shown when editing a question.
+var QuestionTypes = []QuestionType{
+	QuestionTypeSingleChoice, QuestionTypeMultiSelect, QuestionTypeTrueFalse, QuestionTypeNumericEstimate, QuestionTypeOrdering,
+}
+
+// The texts of the alternatives of true/false questions, in their order.
+const (
+	TrueAlternativeText  = "Sant"
+	FalseAlternativeText = "Usant"
+)
+
+func (t QuestionType) IsValid() bool {
+	switch t {
+	case QuestionTypeSingleChoice, QuestionTypeMultiSelect, QuestionTypeTrueFalse, QuestionTypeNumericEstimate, QuestionTypeOrdering:
+		return true
+	}
+	return false
+}
+
+// Whether the question is answered using its alternatives.
+func (t QuestionType) HasAlternatives() bool {
+	return t != QuestionTypeNumericEstimate
+}
+
+// An answer to a question.
+// Single choice and true/false answers have the chosen alternative, multi-select answers all chosen alternatives
+// and ordering answers all alternatives in the chosen order. Numeric estimates only have the number.
+type Answer struct {
+	AlternativeIDs []uuid.UUID
+	Number         float64
+}
+
+// Whether the alternative with the given ID is part of the answer.
+func (a *Answer) Contains(alternativeID uuid.UUID) bool {
+	return a.Position(alternativeID) > 0
+}
+
+// Returns the position of the alternative with the given ID in the answer, counting from 1.
+// Returns 0 if the alternative is not part of the answer.
+func (a *Answer) Position(alternativeID uuid.UUID) int {
+	for i, id := range a.AlternativeIDs {
+		if id == alternativeID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Returns ErrInvalidAnswer if the answer can not be given to the question,
+// e.g. if it has an alternative from another question or the wrong number of alternatives.
+func (q *Question) ValidateAnswer(answer Answer) error {
+	switch q.Type {
+	case QuestionTypeNumericEstimate:
+		if len(answer.AlternativeIDs) != 0 || math.IsNaN(answer.Number) || math.IsInf(answer.Number, 0) {
+			return ErrInvalidAnswer
+		}
+		return nil
+	case QuestionTypeMultiSelect:
+		if len(answer.AlternativeIDs) == 0 {
+			return ErrInvalidAnswer
+		}
+	case QuestionTypeOrdering:
+		if len(answer.AlternativeIDs) != len(q.Alternatives) {
+			return ErrInvalidAnswer
+		}
+	default:
+		if len(answer.AlternativeIDs) != 1 {
+			return ErrInvalidAnswer
+		}
+	}
+
+	chosen := make(map[uuid.UUID]bool)
+	for _, id := range answer.AlternativeIDs {
+		if chosen[id] || q.getAlternative(id) == nil {
+			return ErrInvalidAnswer
+		}
+		chosen[id] = true
+	}
+	return nil
+}
+
+// Returns the values the answer is stored as in user_answers and guest_answers: the chosen alternative
+// of single choice and true/false questions, the chosen alternatives of multi-select and ordering questions,
+// and the number of numeric estimates. The other values are NULL.
+func (q *Question) AnswerColumns(answer Answer) (uuid.NullUUID, pq.StringArray, sql.NullFloat64) {
+	switch q.Type {
+	case QuestionTypeNumericEstimate:
+		return uuid.NullUUID{}, nil, sql.NullFloat64{Float64: answer.Number, Valid: true}
+	case QuestionTypeMultiSelect, QuestionTypeOrdering:
+		ids := pq.StringArray{}
+		for _, id := range answer.AlternativeIDs {
+			ids = append(ids, id.String())
+		}
+		return uuid.NullUUID{}, ids, sql.NullFloat64{}
+	default:
+		chosen := uuid.NullUUID{}
+		if len(answer.AlternativeIDs) > 0 {
+			chosen = uuid.NullUUID{UUID: answer.AlternativeIDs[0], Valid: true}
+		}
+		return chosen, nil, sql.NullFloat64{}
+	}
+}
+
+// Returns a text describing the answer, such as the chosen alternatives or the number with its unit.
+func (q *Question) AnswerText(answer Answer) string {
+	if q.Type == QuestionTypeNumericEstimate {
+		return q.NumberText(answer.Number)
+	}
+
+	texts := []string{}
+	for _, id := range answer.AlternativeIDs {
+		if alternative := q.getAlternative(id); alternative != nil {
+			texts = append(texts, alternative.Text)
+		}
+	}
+	return strings.Join(texts, ", ")
+}
+
+// Returns a text describing the correct answer to the question.
+func (q *Question) CorrectAnswerText() string {
+	if q.Type == QuestionTypeNumericEstimate {
+		return q.NumberText(q.NumericAnswer)
+	}
+
+	texts := []string{}
+	for _, alternative := range q.Alternatives {
+		if alternative.IsCorrect {
+			texts = append(texts, alternative.Text)
+		}
+	}
+	return strings.Join(texts, ", ")
+}
+
+// Returns the number formatted with a decimal comma, followed by the unit of the question if it has one.
+func (q *Question) NumberText(number float64) string {
+	text := FormatNumber(number)
+	if q.NumericUnit != "" {
+		text += " " + q.NumericUnit
+	}
+	return text
+}
+
+// Returns the alternatives in a random order other than the correct one, so ordering questions are not answered by default.
+// Questions with less than two alternatives can not be shuffled, and are returned as they are.
+func (q *Question) ShuffledAlternatives() []Alternative {
+	shuffled := make([]Alternative, len(q.Alternatives))
+	copy(shuffled, q.Alternatives)
+	if len(shuffled) < 2 {
+		return shuffled
+	}
+
+	for {
+		rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		for i := range shuffled {
+			if shuffled[i].ID != q.Alternatives[i].ID {
+				return shuffled
+			}
+		}
+	}
+}
+
+// Returns the alternative with the given ID, or nil if the question does not have it.
+func (q *Question) getAlternative(alternativeID uuid.UUID) *Alternative {
+	for i := range q.Alternatives {
+		if q.Alternatives[i].ID == alternativeID {
+			return &q.Alternatives[i]
+		}
+	}
+	return nil
+}
+
+// Parses a number written by a user. Both "," and "." are accepted as the decimal separator,
+// and spaces are ignored, so "1 234,5" is 1234.5.
+func ParseNumber(text string) (float64, error) {
+	text = strings.Join(strings.Fields(text), "")
+	number, err := strconv.ParseFloat(strings.ReplaceAll(text, ",", "."), 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, ErrInvalidNumber
+	}
+	return number, nil
+}
+
+// Formats the number with a decimal comma and no more decimals than needed. Example: 1234.5 -> "1234,5"
+func FormatNumber(number float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(number, 'f', -1, 64), ".", ",")
+}
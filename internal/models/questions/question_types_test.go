@@ -0,0 +1,234 @@
+//go:build unit
+
+package questions_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
+	"github.com/google/uuid"
+)
+
+func questionWithAlternatives(questionType questions.QuestionType, correct ...bool) *questions.Question {
+	question := questions.Question{ID: uuid.New(), Type: questionType}
+	for i, isCorrect := range correct {
+		question.Alternatives = append(question.Alternatives, questions.Alternative{
+			ID:          uuid.New(),
+			Text:        string(rune('A' + i)),
+			IsCorrect:   isCorrect,
+			Arrangement: uint(i + 1),
+		})
+	}
+	return &question
+}
+
+func questionForm(questionType questions.QuestionType, alternatives ...string) questions.QuestionForm {
+	form := questions.QuestionForm{
+		ID:        uuid.New(),
+		Text:      "Spørsmål",
+		ImageURL:  &url.URL{},
+		ArticleID: &uuid.NullUUID{},
+		QuizID:    &uuid.UUID{},
+		Type:      questionType,
+	}
+	for i, text := range alternatives {
+		form.Alternatives[i] = questions.PartialAlternative{Text: text, IsCorrect: i == 0}
+	}
+	return form
+}
+
+// TestParseNumber tests that both decimal separators are accepted and spaces are ignored
+func TestParseNumber(t *testing.T) {
+	valid := map[string]float64{
+		"1814":    1814,
+		"1 234,5": 1234.5,
+		"1234.5":  1234.5,
+		" -0,25 ": -0.25,
+		"1 000":   1000,
+	}
+	for text, expected := range valid {
+		number, err := questions.ParseNumber(text)
+		if err != nil || number != expected {
+			t.Errorf("Expected %q to be %v, but got %v and %v", text, expected, number, err)
+		}
+	}
+
+	for _, text := range []string{"", "tusen", "1,2,3", "NaN", "Inf"} {
+		if _, err := questions.ParseNumber(text); err != questions.ErrInvalidNumber {
+			t.Errorf("Expected %q to be invalid, but got %v", text, err)
+		}
+	}
+}
+
+// TestFormatNumber tests that numbers are formatted with a decimal comma
+func TestFormatNumber(t *testing.T) {
+	if text := questions.FormatNumber(1234.5); text != "1234,5" {
+		t.Errorf("Expected 1234,5, but got %s", text)
+	}
+	if text := questions.FormatNumber(1814); text != "1814" {
+		t.Errorf("Expected 1814, but got %s", text)
+	}
+}
+
+// TestValidateAnswer tests that answers must fit the type and alternatives of the question
+func TestValidateAnswer(t *testing.T) {
+	single := questionWithAlternatives(questions.QuestionTypeSingleChoice, true, false)
+	multi := questionWithAlternatives(questions.QuestionTypeMultiSelect, true, true, false)
+	ordering := questionWithAlternatives(questions.QuestionTypeOrdering, true, true, true)
+	numeric := &questions.Question{Type: questions.QuestionTypeNumericEstimate}
+	ids := func(question *questions.Question, indexes ...int) questions.Answer {
+		answer := questions.Answer{}
+		for _, i := range indexes {
+			answer.AlternativeIDs = append(answer.AlternativeIDs, question.Alternatives[i].ID)
+		}
+		return answer
+	}
+
+	valid := map[string]struct {
+		question *questions.Question
+		answer   questions.Answer
+	}{
+		"single choice":       {single, ids(single, 1)},
+		"multi-select":        {multi, ids(multi, 0, 2)},
+		"ordering":            {ordering, ids(ordering, 2, 0, 1)},
+		"numeric estimate":    {numeric, questions.Answer{Number: 1814}},
+		"negative estimate":   {numeric, questions.Answer{Number: -3.5}},
+		"single multi-select": {multi, ids(multi, 1)},
+	}
+	for name, test := range valid {
+		if err := test.question.ValidateAnswer(test.answer); err != nil {
+			t.Errorf("Expected %s answer to be valid, but got %v", name, err)
+		}
+	}
+
+	invalid := map[string]struct {
+		question *questions.Question
+		answer   questions.Answer
+	}{
+		"two single choice":       {single, ids(single, 0, 1)},
+		"no single choice":        {single, questions.Answer{}},
+		"other question":          {single, ids(multi, 0)},
+		"empty multi-select":      {multi, questions.Answer{}},
+		"repeated multi-select":   {multi, ids(multi, 0, 0)},
+		"incomplete ordering":     {ordering, ids(ordering, 0, 1)},
+		"repeated ordering":       {ordering, ids(ordering, 0, 1, 1)},
+		"alternative to estimate": {numeric, ids(single, 0)},
+	}
+	for name, test := range invalid {
+		if err := test.question.ValidateAnswer(test.answer); err != questions.ErrInvalidAnswer {
+			t.Errorf("Expected %s answer to be invalid, but got %v", name, err)
+		}
+	}
+}
+
+// TestAnswerColumns tests that each type of answer is stored in its own column
+func TestAnswerColumns(t *testing.T) {
+	single := questionWithAlternatives(questions.QuestionTypeTrueFalse, true, false)
+	chosen, chosenList, number := single.AnswerColumns(questions.Answer{AlternativeIDs: []uuid.UUID{single.Alternatives[0].ID}})
+	if !chosen.Valid || chosen.UUID != single.Alternatives[0].ID || chosenList != nil || number.Valid {
+		t.Errorf("Expected only the chosen alternative, but got %v, %v and %v", chosen, chosenList, number)
+	}
+
+	ordering := questionWithAlternatives(questions.QuestionTypeOrdering, true, true)
+	answer := questions.Answer{AlternativeIDs: []uuid.UUID{ordering.Alternatives[1].ID, ordering.Alternatives[0].ID}}
+	chosen, chosenList, number = ordering.AnswerColumns(answer)
+	if chosen.Valid || len(chosenList) != 2 || chosenList[0] != ordering.Alternatives[1].ID.String() || number.Valid {
+		t.Errorf("Expected only the alternatives in the chosen order, but got %v, %v and %v", chosen, chosenList, number)
+	}
+
+	numeric := &questions.Question{Type: questions.QuestionTypeNumericEstimate}
+	chosen, chosenList, number = numeric.AnswerColumns(questions.Answer{Number: 12.5})
+	if chosen.Valid || chosenList != nil || !number.Valid || number.Float64 != 12.5 {
+		t.Errorf("Expected only the number, but got %v, %v and %v", chosen, chosenList, number)
+	}
+}
+
+// TestShuffledAlternatives tests that ordering questions are never shown in the correct order
+func TestShuffledAlternatives(t *testing.T) {
+	question := questionWithAlternatives(questions.QuestionTypeOrdering, true, true)
+	for range 20 {
+		shuffled := question.ShuffledAlternatives()
+		if len(shuffled) != 2 || shuffled[0].ID != question.Alternatives[1].ID {
+			t.Fatalf("Expected the alternatives to be swapped, but got %v", shuffled)
+		}
+	}
+}
+
+// TestAnswerText tests the descriptions of answers of different types
+func TestAnswerText(t *testing.T) {
+	multi := questionWithAlternatives(questions.QuestionTypeMultiSelect, true, false, true)
+	answer := questions.Answer{AlternativeIDs: []uuid.UUID{multi.Alternatives[2].ID, multi.Alternatives[0].ID}}
+	if text := multi.AnswerText(answer); text != "C, A" {
+		t.Errorf("Expected C, A, but got %s", text)
+	}
+	if text := multi.CorrectAnswerText(); text != "A, C" {
+		t.Errorf("Expected A, C, but got %s", text)
+	}
+
+	numeric := &questions.Question{Type: questions.QuestionTypeNumericEstimate, NumericAnswer: 5.5, NumericUnit: "kr"}
+	if text := numeric.AnswerText(questions.Answer{Number: 4}); text != "4 kr" {
+		t.Errorf("Expected 4 kr, but got %s", text)
+	}
+	if text := numeric.CorrectAnswerText(); text != "5,5 kr" {
+		t.Errorf("Expected 5,5 kr, but got %s", text)
+	}
+}
+
+// TestCreateQuestionFromFormTypes tests the validation of each type of question
+func TestCreateQuestionFromFormTypes(t *testing.T) {
+	if _, errorText := questions.CreateQuestionFromForm(questionForm("essay", "A", "B")); errorText == "" {
+		t.Error("Expected an unknown type to be invalid")
+	}
+
+	multi := questionForm(questions.QuestionTypeMultiSelect, "A", "B", "C")
+	multi.Alternatives[1].IsCorrect = true
+	if _, errorText := questions.CreateQuestionFromForm(multi); errorText != "" {
+		t.Errorf("Expected multi-select question to be valid, but got %s", errorText)
+	}
+
+	ordering := questionForm(questions.QuestionTypeOrdering, "A", "B", "C")
+	question, errorText := questions.CreateQuestionFromForm(ordering)
+	if errorText != "" {
+		t.Errorf("Expected ordering question to be valid, but got %s", errorText)
+	}
+	for _, alternative := range question.Alternatives {
+		if !alternative.IsCorrect {
+			t.Errorf("Expected all alternatives of an ordering question to be correct")
+		}
+	}
+	if _, errorText := questions.CreateQuestionFromForm(questionForm(questions.QuestionTypeOrdering, "A")); errorText == "" {
+		t.Error("Expected ordering question with one item to be invalid")
+	}
+
+	trueFalse := questionForm(questions.QuestionTypeTrueFalse)
+	trueFalse.Alternatives[2] = questions.PartialAlternative{ID: uuid.New(), Text: "Gammelt alternativ"}
+	if _, errorText := questions.CreateQuestionFromForm(trueFalse); errorText == "" {
+		t.Error("Expected true/false question without an answer to be invalid")
+	}
+	trueFalse.Alternatives[1].IsCorrect = true
+	question, errorText = questions.CreateQuestionFromForm(trueFalse)
+	if errorText != "" {
+		t.Errorf("Expected true/false question to be valid, but got %s", errorText)
+	}
+	if len(question.Alternatives) != 3 || question.Alternatives[0].Text != questions.TrueAlternativeText ||
+		question.Alternatives[1].Text != questions.FalseAlternativeText || !question.Alternatives[1].IsCorrect ||
+		question.Alternatives[2].Text != "" {
+		t.Errorf("Expected Sant, Usant and a deleted alternative, but got %+v", question.Alternatives)
+	}
+
+	numeric := questionForm(questions.QuestionTypeNumericEstimate, "A")
+	numeric.Alternatives[0].ID = uuid.New()
+	numeric.NumericAnswer, numeric.NumericTolerance = 1814, 10
+	question, errorText = questions.CreateQuestionFromForm(numeric)
+	if errorText != "" {
+		t.Errorf("Expected numeric estimate question to be valid, but got %s", errorText)
+	}
+	if len(question.Alternatives) != 1 || question.Alternatives[0].Text != "" || question.NumericAnswer != 1814 {
+		t.Errorf("Expected the answer and a deleted alternative, but got %+v", question)
+	}
+	numeric.NumericTolerance = -1
+	if _, errorText := questions.CreateQuestionFromForm(numeric); errorText == "" {
+		t.Error("Expected negative tolerance to be invalid")
+	}
+}
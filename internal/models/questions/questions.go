@@ -4,10 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"math"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Molnes/Nyhetsjeger/internal/models/ai"
 	data_handling "github.com/Molnes/Nyhetsjeger/internal/utils/data"
@@ -21,6 +24,9 @@ var ErrNoImageUpdated = errors.New("questions: no image updated")
 var ErrLastQuestion = errors.New("questions: cannot delete the last question in a published quiz")
 var ErrNonSequentialQuestions = errors.New("questions: question arrangement is not sequential")
 
+// The maximum number of characters in the explanation of a question. Generated explanations have the same limit.
+const MaxExplanationLength = ai.MaxExplanationRunes
+
 type Question struct {
 	ID               uuid.UUID
 	Text             string
@@ -30,6 +36,11 @@ type Question struct {
 	QuizID           uuid.UUID
 	TimeLimitSeconds uint
 	Points           uint
+	Type             QuestionType
+	NumericAnswer    float64 // The correct answer of numeric estimate questions.
+	NumericTolerance float64 // How far off a numeric estimate can be and still get points.
+	NumericUnit      string  // Shown after the number in numeric estimate questions, e.g. "kr". May be empty.
+	Explanation      string  // Shown to players after they answer, along with a link to the article. May be empty.
 	Alternatives     []Alternative
 }
 
@@ -59,6 +70,7 @@ func GetDefaultQuestion(quizId uuid.UUID) Question {
 		ArticleID:    uuid.NullUUID{},
 		QuizID:       quizId,
 		Points:       100,
+		Type:         QuestionTypeSingleChoice,
 		Alternatives: []Alternative{},
 	}
 }
@@ -71,6 +83,8 @@ func ConvertAiQuestionToQuestion(quizId uuid.UUID, articleId uuid.UUID, aiQuesti
 		Text:             aiQuestion.Question,
 		Points:           100,
 		TimeLimitSeconds: 30,
+		Type:             QuestionTypeSingleChoice,
+		Explanation:      aiQuestion.Explanation,
 		ArticleID: uuid.NullUUID{
 			UUID:  articleId,
 			Valid: true,
@@ -127,6 +141,12 @@ func (q *Question) GetRemainingTimeSeconds(duration time.Duration) uint {
 	return timeLeft
 }
 
+// Returns the time after which answers to the question presented at the given time are timed out.
+// The grace period makes up for the time it takes an answer given just before the time limit to reach the server.
+func (q *Question) AnswerDeadline(presentedAt time.Time, grace time.Duration) time.Time {
+	return presentedAt.Add(time.Duration(q.TimeLimitSeconds)*time.Second + grace)
+}
+
 // Initializes the percentage of times each alternative has been chosen.
 func (q *Question) initPercentChosen() {
 	total := 0
@@ -159,7 +179,8 @@ func GetFirstQuestionID(db *sql.DB, quizID uuid.UUID) (uuid.UUID, error) {
 func GetQuestionsByQuizID(db *sql.DB, id *uuid.UUID) (*[]Question, error) {
 	rows, err := db.Query(
 		`SELECT
-				q.id, q.question, q.image_url, q.arrangement, q.article_id, q.quiz_id, q.time_limit_seconds, q.points
+				q.id, q.question, q.image_url, q.arrangement, q.article_id, q.quiz_id, q.time_limit_seconds, q.points,
+				q.question_type, q.numeric_answer, q.numeric_tolerance, q.numeric_unit, q.explanation
 			FROM
 				questions q
 			WHERE
@@ -181,7 +202,8 @@ func GetQuestionsByQuizID(db *sql.DB, id *uuid.UUID) (*[]Question, error) {
 func GetNthQuestionByQuizId(db *sql.DB, quizId uuid.UUID, questionNumber uint) (*Question, error) {
 	row := db.QueryRow(
 		`SELECT
-				q.id, q.question, q.image_url, q.arrangement, q.article_id, q.quiz_id, q.time_limit_seconds, q.points
+				q.id, q.question, q.image_url, q.arrangement, q.article_id, q.quiz_id, q.time_limit_seconds, q.points,
+				q.question_type, q.numeric_answer, q.numeric_tolerance, q.numeric_unit, q.explanation
 			FROM
 				questions q
 			WHERE
@@ -227,12 +249,15 @@ func GetNextQuestionInQuizByQuestionId(db *sql.DB, questionId uuid.UUID) (uuid.U
 func scanQuestionFromFullRow(db *sql.DB, row *sql.Row) (*Question, error) {
 	var q Question
 	var imageURL sql.NullString
+	var numericAnswer, numericTolerance sql.NullFloat64
 	err := row.Scan(
 		&q.ID, &q.Text, &imageURL, &q.Arrangement, &q.ArticleID, &q.QuizID, &q.TimeLimitSeconds, &q.Points,
+		&q.Type, &numericAnswer, &numericTolerance, &q.NumericUnit, &q.Explanation,
 	)
 	if err != nil {
 		return nil, err
 	}
+	q.NumericAnswer, q.NumericTolerance = numericAnswer.Float64, numericTolerance.Float64
 
 	// Set image URL
 	tempURL, err := data_handling.ConvertNullStringToURL(&imageURL)
@@ -259,12 +284,15 @@ func scanQuestionsFromFullRows(db *sql.DB, rows *sql.Rows) (*[]Question, error)
 	for rows.Next() {
 		var q Question
 		var imageURL sql.NullString
+		var numericAnswer, numericTolerance sql.NullFloat64
 		err := rows.Scan(
 			&q.ID, &q.Text, &imageURL, &q.Arrangement, &q.ArticleID, &q.QuizID, &q.TimeLimitSeconds, &q.Points,
+			&q.Type, &numericAnswer, &numericTolerance, &q.NumericUnit, &q.Explanation,
 		)
 		if err != nil {
 			return nil, err
 		}
+		q.NumericAnswer, q.NumericTolerance = numericAnswer.Float64, numericTolerance.Float64
 
 		// Set image URL
 		tempURL, err := data_handling.ConvertNullStringToURL(&imageURL)
@@ -312,10 +340,12 @@ func IsCorrectAnswer(db *sql.DB, questionID uuid.UUID, answerID uuid.UUID) (bool
 func GetQuestionByID(db *sql.DB, id uuid.UUID) (*Question, error) {
 	var q Question
 	var imageUrlString sql.NullString
+	var numericAnswer, numericTolerance sql.NullFloat64
 	row := db.QueryRow(
 		`
 		SELECT
-			id, question, image_url AS quiz_image, arrangement, article_id, quiz_id, time_limit_seconds, points
+			id, question, image_url AS quiz_image, arrangement, article_id, quiz_id, time_limit_seconds, points,
+			question_type, numeric_answer, numeric_tolerance, numeric_unit, explanation
 		FROM
 			questions
 		WHERE
@@ -323,10 +353,12 @@ func GetQuestionByID(db *sql.DB, id uuid.UUID) (*Question, error) {
 		`, id)
 	err := row.Scan(
 		&q.ID, &q.Text, &imageUrlString, &q.Arrangement, &q.ArticleID, &q.QuizID, &q.TimeLimitSeconds, &q.Points,
+		&q.Type, &numericAnswer, &numericTolerance, &q.NumericUnit, &q.Explanation,
 	)
 	if err != nil {
 		return nil, err
 	}
+	q.NumericAnswer, q.NumericTolerance = numericAnswer.Float64, numericTolerance.Float64
 
 	// Parse the image URL
 	imageUrl, err := data_handling.ConvertNullStringToURL(&imageUrlString)
@@ -338,11 +370,11 @@ func GetQuestionByID(db *sql.DB, id uuid.UUID) (*Question, error) {
 
 	answerRows, err := db.Query(
 		`SELECT
-			aa.id, aa.text, aa.correct, aa.arrangement, aa.question_id, COUNT(ua.chosen_answer_alternative_id)
+			aa.id, aa.text, aa.correct, aa.arrangement, aa.question_id, COUNT(ua.question_id)
 		FROM
 			answer_alternatives aa
 		LEFT JOIN
-			user_answers ua ON aa.id = ua.chosen_answer_alternative_id
+			user_answers ua ON aa.id = ua.chosen_answer_alternative_id OR aa.id = ANY(ua.chosen_alternative_ids)
 		WHERE
 			aa.question_id = $1
 		GROUP BY
@@ -364,8 +396,8 @@ func GetQuestionByID(db *sql.DB, id uuid.UUID) (*Question, error) {
 		}
 		alternatives = append(alternatives, a)
 	}
-	if len(alternatives) == 0 {
-		// not a "rows not found", it is a server error - questions must have alternatives
+	if len(alternatives) == 0 && q.Type.HasAlternatives() {
+		// not a "rows not found", it is a server error - questions of this type must have alternatives
 		return nil, errors.New("no alternatives found for question with id: " + id.String())
 	}
 	q.Alternatives = alternatives
@@ -453,6 +485,11 @@ type QuestionForm struct {
 	QuizID           *uuid.UUID
 	Points           uint
 	TimeLimitSeconds uint
+	Type             QuestionType
+	NumericAnswer    float64
+	NumericTolerance float64
+	NumericUnit      string
+	Explanation      string
 	Alternatives     [4]PartialAlternative
 }
 
@@ -495,6 +532,9 @@ func ParseAndValidateQuestionData(questionText string, questionPoints string, ar
 
 // Create a question object from a form.
 // Returns the created question and an error message.
+//
+// Alternatives the type of question does not use are kept with empty text, so they are deleted when the question is updated.
+// True/false questions always get the alternatives "Sant" and "Usant", in the place of the first two alternatives.
 func CreateQuestionFromForm(form QuestionForm) (Question, string) {
 	question := Question{
 		ID:               form.ID,
@@ -504,13 +544,40 @@ func CreateQuestionFromForm(form QuestionForm) (Question, string) {
 		QuizID:           *form.QuizID,
 		Points:           form.Points,
 		TimeLimitSeconds: form.TimeLimitSeconds,
+		Type:             form.Type,
+		Explanation:      strings.TrimSpace(form.Explanation),
 		Alternatives:     []Alternative{},
 	}
 
+	if !form.Type.IsValid() {
+		return question, "Ukjent spørsmålstype"
+	}
+	if utf8.RuneCountInString(question.Explanation) > MaxExplanationLength {
+		return question, fmt.Sprintf("Forklaringen kan ha maks %d tegn", MaxExplanationLength)
+	}
+
 	hasCorrectAlternative := false
+	correctAlternatives := 0
+	nonEmptyAlternatives := 0
 
 	// Only add alternatives that are not empty
 	for i, alt := range form.Alternatives {
+		switch form.Type {
+		case QuestionTypeNumericEstimate:
+			alt.Text = ""
+		case QuestionTypeTrueFalse:
+			alt.Text = ""
+			if i < 2 {
+				alt.Text = [2]string{TrueAlternativeText, FalseAlternativeText}[i]
+				if alt.ID == uuid.Nil {
+					alt.ID = uuid.New()
+				}
+			}
+		case QuestionTypeOrdering:
+			// All alternatives belong in the answer, only their order matters
+			alt.IsCorrect = true
+		}
+
 		// Do not count empty white space as an alternative
 		if (strings.TrimSpace(alt.Text) != "") || (strings.TrimSpace(alt.Text) == "" && alt.ID != uuid.Nil) {
 			question.Alternatives = append(question.Alternatives, Alternative{
@@ -520,28 +587,69 @@ func CreateQuestionFromForm(form QuestionForm) (Question, string) {
 				Arrangement: uint(i + 1),
 			})
 
-			if alt.IsCorrect {
-				hasCorrectAlternative = true
+			if strings.TrimSpace(alt.Text) != "" {
+				nonEmptyAlternatives++
+				if alt.IsCorrect {
+					hasCorrectAlternative = true
+					correctAlternatives++
+				}
 			}
 		}
 	}
 
+	switch form.Type {
+	case QuestionTypeNumericEstimate:
+		if math.IsNaN(form.NumericAnswer) || math.IsInf(form.NumericAnswer, 0) {
+			return question, "Klarte ikke å tolke svaret"
+		}
+		if math.IsNaN(form.NumericTolerance) || math.IsInf(form.NumericTolerance, 0) {
+			return question, "Klarte ikke å tolke toleransen"
+		}
+		if form.NumericTolerance < 0 {
+			return question, "Toleransen kan ikke være negativ"
+		}
+		question.NumericAnswer = form.NumericAnswer
+		question.NumericTolerance = form.NumericTolerance
+		question.NumericUnit = strings.TrimSpace(form.NumericUnit)
+		return question, ""
+
+	case QuestionTypeTrueFalse:
+		if correctAlternatives != 1 {
+			return question, "Velg om påstanden er sann eller usann"
+		}
+		return question, ""
+
+	case QuestionTypeOrdering:
+		if nonEmptyAlternatives < 2 {
+			return question, "Spørsmålet må ha minst 2 elementer å sortere. Tomme elementer teller ikke"
+		}
+		return question, ""
+	}
+
 	// Check that there is a correct alternative
 	if !hasCorrectAlternative {
 		return question, "Spørsmålet har ingen korrekt svaralternativ"
 	}
 
 	// Check that there are two to four alternatives
-	if len(question.Alternatives) < 2 {
+	if nonEmptyAlternatives < 2 {
 		return question, "Spørsmålet må ha minst 2 svaralternativer. Tomme alternativer teller ikke"
 	}
-	if len(question.Alternatives) > 4 {
+	if nonEmptyAlternatives > 4 {
 		return question, "Spørsmålet kan ha maks 4 svaralternativer"
 	}
 
 	return question, ""
 }
 
+// Returns the numeric answer and tolerance to store for the question, which are NULL unless it is a numeric estimate.
+func (q *Question) numericColumns() (sql.NullFloat64, sql.NullFloat64) {
+	if q.Type != QuestionTypeNumericEstimate {
+		return sql.NullFloat64{}, sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: q.NumericAnswer, Valid: true}, sql.NullFloat64{Float64: q.NumericTolerance, Valid: true}
+}
+
 // Add a new question to the database.
 // Adds the question alternatives to the database.
 // Returns the ID of the new question.
@@ -552,11 +660,18 @@ func AddNewQuestion(db *sql.DB, ctx context.Context, question *Question) error {
 		return err
 	}
 
+	if question.Type == "" {
+		question.Type = QuestionTypeSingleChoice
+	}
+	numericAnswer, numericTolerance := question.numericColumns()
+
 	// Insert the question into the database
 	_, err = tx.Exec(
-		`INSERT INTO questions (id, question, image_url, article_id, quiz_id, points, time_limit_seconds)
-		VALUES ($1, $2, $3, $4, $5, $6, $7);`,
+		`INSERT INTO questions (id, question, image_url, article_id, quiz_id, points, time_limit_seconds,
+			question_type, numeric_answer, numeric_tolerance, numeric_unit, explanation)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);`,
 		question.ID, question.Text, question.ImageURL.String(), question.ArticleID, question.QuizID, question.Points, question.TimeLimitSeconds,
+		question.Type, numericAnswer, numericTolerance, question.NumericUnit, question.Explanation,
 	)
 
 	if err != nil {
@@ -564,8 +679,12 @@ func AddNewQuestion(db *sql.DB, ctx context.Context, question *Question) error {
 		return err
 	}
 
-	// Insert the alternatives into the database
+	// Insert the alternatives into the database.
+	// Empty alternatives are skipped, e.g. the ones a numeric estimate question does not use.
 	for _, a := range question.Alternatives {
+		if a.Text == "" {
+			continue
+		}
 		_, err = tx.Exec(
 			`INSERT INTO answer_alternatives (id, text, correct, question_id)
 			VALUES ($1, $2, $3, $4);`,
@@ -593,11 +712,18 @@ func UpdateQuestion(db *sql.DB, ctx context.Context, question *Question) error {
 		return err
 	}
 
+	if question.Type == "" {
+		question.Type = QuestionTypeSingleChoice
+	}
+	numericAnswer, numericTolerance := question.numericColumns()
+
 	result, err := tx.Exec(
 		`UPDATE questions
-		SET question = $1, image_url = $2, article_id = $3, quiz_id = $4, points = $5, time_limit_seconds = $6
+		SET question = $1, image_url = $2, article_id = $3, quiz_id = $4, points = $5, time_limit_seconds = $6,
+			question_type = $8, numeric_answer = $9, numeric_tolerance = $10, numeric_unit = $11, explanation = $12
 		WHERE id = $7;`,
 		question.Text, question.ImageURL.String(), question.ArticleID, question.QuizID, question.Points, question.TimeLimitSeconds, question.ID,
+		question.Type, numericAnswer, numericTolerance, question.NumericUnit, question.Explanation,
 	)
 
 	if err != nil {
@@ -641,6 +767,24 @@ func UpdateQuestion(db *sql.DB, ctx context.Context, question *Question) error {
 		}
 	}
 
+	// New alternatives are given the next arrangement by a trigger, which may not be their place in the form.
+	// The order matters for ordering questions, so all alternatives are given their place afterwards.
+	for _, a := range question.Alternatives {
+		if a.Text == "" {
+			continue
+		}
+		_, err := tx.Exec(
+			`UPDATE answer_alternatives
+			SET arrangement = $1
+			WHERE id = $2;`,
+			a.Arrangement, a.ID,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return err
 	}
@@ -0,0 +1,51 @@
+//go:build unit
+
+package questions_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
+)
+
+// TestCreateQuestionFromFormExplanation tests that explanations are trimmed and limited in length
+func TestCreateQuestionFromFormExplanation(t *testing.T) {
+	form := questionForm(questions.QuestionTypeSingleChoice, "A", "B")
+	form.Explanation = "  Fordi artikkelen sier det.\n"
+	question, errorText := questions.CreateQuestionFromForm(form)
+	if errorText != "" {
+		t.Fatalf("Expected question to be valid, but got %s", errorText)
+	}
+	if question.Explanation != "Fordi artikkelen sier det." {
+		t.Errorf("Expected the explanation to be trimmed, but got %q", question.Explanation)
+	}
+
+	form.Explanation = strings.Repeat("æ", questions.MaxExplanationLength)
+	if _, errorText := questions.CreateQuestionFromForm(form); errorText != "" {
+		t.Errorf("Expected explanation of max length to be valid, but got %s", errorText)
+	}
+	form.Explanation += "æ"
+	if _, errorText := questions.CreateQuestionFromForm(form); errorText == "" {
+		t.Error("Expected too long explanation to be invalid")
+	}
+}
+
+// TestAnswerDeadline tests that answers are accepted until the time limit plus the grace period has passed
+func TestAnswerDeadline(t *testing.T) {
+	question := questions.Question{TimeLimitSeconds: 30}
+	presentedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	deadline := question.AnswerDeadline(presentedAt, 3*time.Second)
+	expected := time.Date(2024, 5, 1, 12, 0, 33, 0, time.UTC)
+	if !deadline.Equal(expected) {
+		t.Errorf("Expected the deadline to be %v, but got %v", expected, deadline)
+	}
+
+	deadline = question.AnswerDeadline(presentedAt, 0)
+	expected = time.Date(2024, 5, 1, 12, 0, 30, 0, time.UTC)
+	if !deadline.Equal(expected) {
+		t.Errorf("Expected the deadline without grace period to be %v, but got %v", expected, deadline)
+	}
+}
@@ -0,0 +1,192 @@
+// Package quiz_analytics aggregates how users have played a quiz.
+// Used by editors to find questions that are too hard or badly worded.
+package quiz_analytics
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type QuizAnalytics struct {
+	QuizID             uuid.UUID
+	QuizTitle          string
+	Participants       uint // Users who have been presented at least one question.
+	Completed          uint // Users who have answered every question.
+	ParticipantsPerDay []DailyParticipants
+	Questions          []QuestionAnalytics
+}
+
+// Number of users who started the quiz on a given day.
+type DailyParticipants struct {
+	Date         time.Time
+	Participants uint
+}
+
+type QuestionAnalytics struct {
+	QuestionID        uuid.UUID
+	Arrangement       uint
+	Text              string
+	Presented         uint // Users who have been presented the question.
+	Answered          uint
+	AnsweredCorrectly uint
+	AverageAnswerTime time.Duration
+	MostChosenWrong   *WrongAlternative // Nil if no one has answered the question wrong.
+}
+
+type WrongAlternative struct {
+	ID       uuid.UUID
+	Text     string
+	ChosenBy uint
+}
+
+// Share of participants who answered every question, between 0 and 1.
+func (qa *QuizAnalytics) CompletionRate() float64 {
+	return ratio(qa.Completed, qa.Participants)
+}
+
+// Share of users answering the question who answered correctly, between 0 and 1.
+func (qa *QuestionAnalytics) PercentCorrect() float64 {
+	return ratio(qa.AnsweredCorrectly, qa.Answered)
+}
+
+// Share of users presented the question who never answered it, between 0 and 1.
+// These users left the quiz at this question.
+func (qa *QuestionAnalytics) DropOff() float64 {
+	return ratio(qa.Presented-qa.Answered, qa.Presented)
+}
+
+// Share of the most chosen wrong alternative among all answers to the question, between 0 and 1.
+func (qa *QuestionAnalytics) PercentMostChosenWrong() float64 {
+	if qa.MostChosenWrong == nil {
+		return 0
+	}
+	return ratio(qa.MostChosenWrong.ChosenBy, qa.Answered)
+}
+
+func ratio(part uint, total uint) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+// Gets the analytics of the quiz with the given ID.
+// Returns sql.ErrNoRows if the quiz does not exist.
+func GetQuizAnalytics(db *sql.DB, quizID uuid.UUID) (*QuizAnalytics, error) {
+	analytics := QuizAnalytics{QuizID: quizID}
+	err := db.QueryRow(
+		`SELECT qz.title,
+			(SELECT COUNT(DISTINCT ua.user_id)
+				FROM user_answers ua
+				JOIN questions q ON ua.question_id = q.id
+				WHERE q.quiz_id = qz.id),
+			(SELECT COUNT(*)
+				FROM user_quizzes uq
+				WHERE uq.quiz_id = qz.id AND uq.is_completed)
+		FROM quizzes qz
+		WHERE qz.id = $1`, quizID).Scan(&analytics.QuizTitle, &analytics.Participants, &analytics.Completed)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics.ParticipantsPerDay, err = getParticipantsPerDay(db, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics.Questions, err = getQuestionAnalytics(db, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &analytics, nil
+}
+
+// Gets the number of users who started the quiz per day, based on when they were presented their first question.
+func getParticipantsPerDay(db *sql.DB, quizID uuid.UUID) ([]DailyParticipants, error) {
+	rows, err := db.Query(
+		`SELECT date_trunc('day', started_at) AS day, COUNT(*)
+		FROM (
+			SELECT MIN(ua.question_presented_at) AS started_at
+			FROM user_answers ua
+			JOIN questions q ON ua.question_id = q.id
+			WHERE q.quiz_id = $1
+			GROUP BY ua.user_id
+		) started
+		GROUP BY day
+		ORDER BY day;`, quizID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participantsPerDay := []DailyParticipants{}
+	for rows.Next() {
+		var dp DailyParticipants
+		if err := rows.Scan(&dp.Date, &dp.Participants); err != nil {
+			return nil, err
+		}
+		participantsPerDay = append(participantsPerDay, dp)
+	}
+	return participantsPerDay, rows.Err()
+}
+
+// Gets the analytics of each question in the quiz, ordered by arrangement.
+func getQuestionAnalytics(db *sql.DB, quizID uuid.UUID) ([]QuestionAnalytics, error) {
+	rows, err := db.Query(
+		`SELECT q.id, q.arrangement, q.question,
+			COUNT(ua.user_id),
+			COUNT(ua.answered_at),
+			COUNT(ua.answered_at) FILTER (WHERE uqp.score >= 1),
+			COALESCE(AVG(EXTRACT(EPOCH FROM ua.answered_at - ua.question_presented_at)), 0),
+			wrong.id, wrong.text, COALESCE(wrong.chosen_by, 0)
+		FROM questions q
+		LEFT JOIN user_answers ua ON ua.question_id = q.id
+		LEFT JOIN user_question_points uqp ON uqp.user_id = ua.user_id AND uqp.question_id = ua.question_id
+		LEFT JOIN LATERAL (
+			SELECT a.id, a.text, COUNT(*) AS chosen_by
+			FROM user_answers wua
+			JOIN answer_alternatives a ON wua.chosen_answer_alternative_id = a.id OR a.id = ANY(wua.chosen_alternative_ids)
+			WHERE wua.question_id = q.id AND NOT a.correct
+			GROUP BY a.id, a.text, a.arrangement
+			ORDER BY chosen_by DESC, a.arrangement
+			LIMIT 1
+		) wrong ON true
+		WHERE q.quiz_id = $1
+		GROUP BY q.id, q.arrangement, q.question, wrong.id, wrong.text, wrong.chosen_by
+		ORDER BY q.arrangement;`, quizID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questionAnalytics := []QuestionAnalytics{}
+	for rows.Next() {
+		var qa QuestionAnalytics
+		var averageAnswerSeconds float64
+		var wrongID uuid.NullUUID
+		var wrongText sql.NullString
+		var wrongChosenBy uint
+		err := rows.Scan(
+			&qa.QuestionID, &qa.Arrangement, &qa.Text,
+			&qa.Presented, &qa.Answered, &qa.AnsweredCorrectly,
+			&averageAnswerSeconds,
+			&wrongID, &wrongText, &wrongChosenBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		qa.AverageAnswerTime = time.Duration(averageAnswerSeconds * float64(time.Second))
+		if wrongID.Valid {
+			qa.MostChosenWrong = &WrongAlternative{
+				ID:       wrongID.UUID,
+				Text:     wrongText.String,
+				ChosenBy: wrongChosenBy,
+			}
+		}
+		questionAnalytics = append(questionAnalytics, qa)
+	}
+	return questionAnalytics, rows.Err()
+}
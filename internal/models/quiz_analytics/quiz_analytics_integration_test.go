@@ -0,0 +1,52 @@
+//go:build integration
+
+package quiz_analytics
+
+import (
+	"testing"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type QuizAnalyticsIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestQuizAnalyticsIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(QuizAnalyticsIntegrationTestSuite))
+}
+
+func (s *QuizAnalyticsIntegrationTestSuite) TestGetQuizAnalytics() {
+	quiz := quizzes.CreateDefaultQuiz()
+	_, err := quizzes.CreateQuiz(s.DB, quiz)
+	s.Require().NoError(err)
+
+	questionID := uuid.New()
+	wrongID := uuid.New()
+	_, err = s.DB.Exec(`INSERT INTO questions (id, question, quiz_id, points) VALUES ($1, 'Spørsmål', $2, 100)`, questionID, quiz.ID)
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(`INSERT INTO answer_alternatives (id, text, correct, question_id) VALUES
+		(gen_random_uuid(), 'Riktig', true, $1), ($2, 'Feil', false, $1)`, questionID, wrongID)
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(`INSERT INTO user_answers (user_id, question_id, question_presented_at, chosen_answer_alternative_id, answered_at)
+		VALUES ($1, $2, now() - interval '10 seconds', $3, now())`, s.InsertedValues.UserId, questionID, wrongID)
+	s.Require().NoError(err)
+
+	analytics, err := GetQuizAnalytics(s.DB, quiz.ID)
+	s.Require().NoError(err)
+
+	s.Require().Equal(uint(1), analytics.Participants)
+	s.Require().Equal(uint(1), analytics.Completed)
+	s.Require().Len(analytics.ParticipantsPerDay, 1)
+	s.Require().Len(analytics.Questions, 1)
+
+	question := analytics.Questions[0]
+	s.Require().Equal(uint(1), question.Answered)
+	s.Require().Equal(uint(0), question.AnsweredCorrectly)
+	s.Require().InDelta(10, question.AverageAnswerTime.Seconds(), 1)
+	s.Require().NotNil(question.MostChosenWrong)
+	s.Require().Equal(wrongID, question.MostChosenWrong.ID)
+}
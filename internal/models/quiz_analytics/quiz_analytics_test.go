@@ -0,0 +1,43 @@
+//go:build unit
+
+package quiz_analytics
+
+import "testing"
+
+// TestCompletionRate tests the completion rate, including a quiz without participants
+func TestCompletionRate(t *testing.T) {
+	analytics := QuizAnalytics{Participants: 4, Completed: 3}
+	if rate := analytics.CompletionRate(); rate != 0.75 {
+		t.Errorf("Expected 0.75, but got %v", rate)
+	}
+
+	empty := QuizAnalytics{}
+	if rate := empty.CompletionRate(); rate != 0 {
+		t.Errorf("Expected 0 for a quiz without participants, but got %v", rate)
+	}
+}
+
+// TestQuestionRates tests percent correct, drop-off and most chosen wrong alternative of a question
+func TestQuestionRates(t *testing.T) {
+	question := QuestionAnalytics{
+		Presented:         10,
+		Answered:          8,
+		AnsweredCorrectly: 2,
+		MostChosenWrong:   &WrongAlternative{ChosenBy: 4},
+	}
+
+	if percent := question.PercentCorrect(); percent != 0.25 {
+		t.Errorf("Expected percent correct 0.25, but got %v", percent)
+	}
+	if dropOff := question.DropOff(); dropOff != 0.2 {
+		t.Errorf("Expected drop-off 0.2, but got %v", dropOff)
+	}
+	if percent := question.PercentMostChosenWrong(); percent != 0.5 {
+		t.Errorf("Expected most chosen wrong 0.5, but got %v", percent)
+	}
+
+	unanswered := QuestionAnalytics{}
+	if unanswered.PercentCorrect() != 0 || unanswered.DropOff() != 0 || unanswered.PercentMostChosenWrong() != 0 {
+		t.Errorf("Expected all rates to be 0 for a question no one has been presented")
+	}
+}
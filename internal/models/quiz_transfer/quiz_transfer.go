@@ -0,0 +1,360 @@
+// Package quiz_transfer exports quizzes to, and imports them from, a portable versioned JSON document.
+// Used to move quizzes between environments and to back up single quizzes.
+package quiz_transfer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/google/uuid"
+)
+
+// Version of the document format written by Export. Bump when the format changes.
+// Version 2 added the scoring policy. Quizzes imported from version 1 documents get the default policy.
+// Version 3 added question types. Older documents only have single choice questions.
+const FormatVersion = 3
+
+var ErrUnsupportedVersion = errors.New("quiz_transfer: unsupported document version")
+var ErrInvalidDocument = errors.New("quiz_transfer: invalid document")
+
+// QuizDocument is the portable representation of a quiz.
+// IDs are not included, labels are referenced by name and articles by URL.
+type QuizDocument struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Quiz       QuizData  `json:"quiz"`
+}
+
+type QuizData struct {
+	Title      string         `json:"title"`
+	ImageURL   string         `json:"image_url"`
+	ActiveFrom time.Time      `json:"active_from"`
+	ActiveTo   time.Time      `json:"active_to"`
+	Labels     []string       `json:"labels"`
+	Articles   []ArticleData  `json:"articles"`
+	Questions  []QuestionData `json:"questions"`
+	Scoring    *ScoringData   `json:"scoring,omitempty"` // Nil in version 1 documents.
+}
+
+type ScoringData struct {
+	Rule                      string `json:"rule"`
+	GraceSeconds              uint   `json:"grace_seconds"`
+	MinPointsPercent          uint   `json:"min_points_percent"`
+	StreakBonusPercent        uint   `json:"streak_bonus_percent"`
+	WrongAnswerPenaltyPercent uint   `json:"wrong_answer_penalty_percent"`
+}
+
+// Returns the scoring policy of the quiz with the given ID described by the data.
+func (sd *ScoringData) policy(quizID uuid.UUID) quizzes.ScoringPolicy {
+	return quizzes.ScoringPolicy{
+		QuizID:                    quizID,
+		Rule:                      quizzes.ScoringRule(sd.Rule),
+		GraceSeconds:              sd.GraceSeconds,
+		MinPointsPercent:          sd.MinPointsPercent,
+		StreakBonusPercent:        sd.StreakBonusPercent,
+		WrongAnswerPenaltyPercent: sd.WrongAnswerPenaltyPercent,
+	}
+}
+
+type ArticleData struct {
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	ImageURL string `json:"image_url"`
+}
+
+type QuestionData struct {
+	Text             string            `json:"text"`
+	ImageURL         string            `json:"image_url"`
+	ArticleURL       string            `json:"article_url"` // Empty if the question is not linked to an article.
+	TimeLimitSeconds uint              `json:"time_limit_seconds"`
+	Points           uint              `json:"points"`
+	Type             string            `json:"type,omitempty"` // Empty for single choice questions.
+	NumericAnswer    *float64          `json:"numeric_answer,omitempty"`
+	NumericTolerance *float64          `json:"numeric_tolerance,omitempty"`
+	NumericUnit      string            `json:"numeric_unit,omitempty"`
+	Explanation      string            `json:"explanation,omitempty"`
+	Alternatives     []AlternativeData `json:"alternatives"`
+}
+
+// Returns the type of the question, which is single choice if not set.
+func (q *QuestionData) questionType() questions.QuestionType {
+	if q.Type == "" {
+		return questions.QuestionTypeSingleChoice
+	}
+	return questions.QuestionType(q.Type)
+}
+
+type AlternativeData struct {
+	Text    string `json:"text"`
+	Correct bool   `json:"correct"`
+}
+
+// Export builds a document of the quiz with the given ID, including its questions,
+// alternatives, labels, articles and scoring policy, in their current order.
+func Export(db *sql.DB, quizID uuid.UUID) (*QuizDocument, error) {
+	quiz, err := quizzes.GetQuizByID(db, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	articleList, err := articles.GetArticlesByQuizID(db, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	questionList, err := questions.GetQuestionsByQuizID(db, &quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := quizzes.GetScoringPolicyByQuizID(db, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := QuizData{
+		Title:      quiz.Title,
+		ImageURL:   quiz.ImageURL.String(),
+		ActiveFrom: quiz.ActiveFrom,
+		ActiveTo:   quiz.ActiveTo,
+		Labels:     []string{},
+		Articles:   []ArticleData{},
+		Questions:  []QuestionData{},
+		Scoring: &ScoringData{
+			Rule:                      string(policy.Rule),
+			GraceSeconds:              policy.GraceSeconds,
+			MinPointsPercent:          policy.MinPointsPercent,
+			StreakBonusPercent:        policy.StreakBonusPercent,
+			WrongAnswerPenaltyPercent: policy.WrongAnswerPenaltyPercent,
+		},
+	}
+
+	for _, label := range quiz.Labels {
+		data.Labels = append(data.Labels, label.Name)
+	}
+
+	articleURLs := make(map[uuid.UUID]string)
+	for _, article := range *articleList {
+		articleURLs[article.ID.UUID] = article.ArticleURL.String()
+		data.Articles = append(data.Articles, ArticleData{
+			URL:      article.ArticleURL.String(),
+			Title:    article.Title,
+			ImageURL: article.ImgURL.String(),
+		})
+	}
+
+	for _, question := range *questionList {
+		questionData := QuestionData{
+			Text:             question.Text,
+			ImageURL:         question.ImageURL.String(),
+			TimeLimitSeconds: question.TimeLimitSeconds,
+			Points:           question.Points,
+			Explanation:      question.Explanation,
+			Alternatives:     []AlternativeData{},
+		}
+		if question.Type != questions.QuestionTypeSingleChoice {
+			questionData.Type = string(question.Type)
+		}
+		if question.Type == questions.QuestionTypeNumericEstimate {
+			answer, tolerance := question.NumericAnswer, question.NumericTolerance
+			questionData.NumericAnswer = &answer
+			questionData.NumericTolerance = &tolerance
+			questionData.NumericUnit = question.NumericUnit
+		}
+		if question.ArticleID.Valid {
+			questionData.ArticleURL = articleURLs[question.ArticleID.UUID]
+		}
+		for _, alternative := range question.Alternatives {
+			questionData.Alternatives = append(questionData.Alternatives, AlternativeData{
+				Text:    alternative.Text,
+				Correct: alternative.IsCorrect,
+			})
+		}
+		data.Questions = append(data.Questions, questionData)
+	}
+
+	return &QuizDocument{
+		Version:    FormatVersion,
+		ExportedAt: time.Now().UTC(),
+		Quiz:       data,
+	}, nil
+}
+
+// Write encodes the document as indented JSON.
+func (d *QuizDocument) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
+
+// Read decodes and validates a document.
+// Returns ErrUnsupportedVersion if the document was written by a newer or unknown format version.
+func Read(r io.Reader) (*QuizDocument, error) {
+	var document QuizDocument
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	if document.Version < 1 || document.Version > FormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, document.Version)
+	}
+	if err := document.validate(); err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// Checks the parts of the document the database would otherwise reject halfway through an import.
+func (d *QuizDocument) validate() error {
+	if d.Quiz.Title == "" {
+		return fmt.Errorf("%w: missing quiz title", ErrInvalidDocument)
+	}
+	if !d.Quiz.ActiveTo.After(d.Quiz.ActiveFrom) {
+		return fmt.Errorf("%w: active_to must be after active_from", ErrInvalidDocument)
+	}
+	if d.Quiz.Scoring != nil {
+		policy := d.Quiz.Scoring.policy(uuid.Nil)
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("%w: invalid scoring policy: %v", ErrInvalidDocument, err)
+		}
+	}
+
+	articleURLs := make(map[string]bool)
+	for _, article := range d.Quiz.Articles {
+		articleURLs[article.URL] = true
+	}
+	for i, question := range d.Quiz.Questions {
+		if question.TimeLimitSeconds == 0 {
+			return fmt.Errorf("%w: question %d has no time limit", ErrInvalidDocument, i+1)
+		}
+		if question.ArticleURL != "" && !articleURLs[question.ArticleURL] {
+			return fmt.Errorf("%w: question %d links to an article not in the document", ErrInvalidDocument, i+1)
+		}
+		if !question.questionType().IsValid() {
+			return fmt.Errorf("%w: question %d has an unknown type", ErrInvalidDocument, i+1)
+		}
+		if question.questionType() == questions.QuestionTypeNumericEstimate &&
+			(question.NumericAnswer == nil || question.NumericTolerance == nil || *question.NumericTolerance < 0) {
+			return fmt.Errorf("%w: question %d has no numeric answer and tolerance", ErrInvalidDocument, i+1)
+		}
+		if utf8.RuneCountInString(question.Explanation) > questions.MaxExplanationLength {
+			return fmt.Errorf("%w: question %d has a too long explanation", ErrInvalidDocument, i+1)
+		}
+	}
+	return nil
+}
+
+// Import creates a new unpublished quiz from the document in a single transaction.
+// Labels are matched by name and articles by URL, and are created if they do not exist.
+// The quiz gets the scoring policy of the document, or the default policy if the document has none.
+// Returns the ID of the new quiz.
+func Import(db *sql.DB, ctx context.Context, document *QuizDocument) (uuid.UUID, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback()
+
+	quiz := document.Quiz
+	quizID := uuid.New()
+	_, err = tx.Exec(
+		`INSERT INTO quizzes (id, title, image_url, active_from, active_to, published)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, false)`,
+		quizID, quiz.Title, quiz.ImageURL, quiz.ActiveFrom, quiz.ActiveTo)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	for _, labelName := range quiz.Labels {
+		_, err = tx.Exec(
+			`WITH label AS (
+				INSERT INTO labels (name) VALUES ($1)
+				ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+				RETURNING id
+			)
+			INSERT INTO quiz_labels (quiz_id, label_id) SELECT $2, id FROM label`,
+			labelName, quizID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	articleIDs := make(map[string]uuid.UUID)
+	for _, article := range quiz.Articles {
+		var articleID uuid.UUID
+		err = tx.QueryRow(
+			`INSERT INTO articles (title, url, image_url) VALUES ($1, $2, NULLIF($3, ''))
+			ON CONFLICT (url) DO UPDATE SET url = EXCLUDED.url
+			RETURNING id`,
+			article.Title, article.URL, article.ImageURL).Scan(&articleID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		articleIDs[article.URL] = articleID
+
+		_, err = tx.Exec(
+			`INSERT INTO quiz_articles (quiz_id, article_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`,
+			quizID, articleID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	// Questions and alternatives are inserted in document order, which the arrangement triggers preserve.
+	for _, question := range quiz.Questions {
+		articleID := uuid.NullUUID{}
+		if question.ArticleURL != "" {
+			articleID = uuid.NullUUID{UUID: articleIDs[question.ArticleURL], Valid: true}
+		}
+
+		questionID := uuid.New()
+		_, err = tx.Exec(
+			`INSERT INTO questions (id, question, image_url, article_id, quiz_id, time_limit_seconds, points,
+				question_type, numeric_answer, numeric_tolerance, numeric_unit, explanation)
+			VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			questionID, question.Text, question.ImageURL, articleID, quizID, question.TimeLimitSeconds, question.Points,
+			question.questionType(), question.NumericAnswer, question.NumericTolerance, question.NumericUnit, question.Explanation)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		for _, alternative := range question.Alternatives {
+			_, err = tx.Exec(
+				`INSERT INTO answer_alternatives (id, text, correct, question_id)
+				VALUES ($1, $2, $3, $4)`,
+				uuid.New(), alternative.Text, alternative.Correct, questionID)
+			if err != nil {
+				return uuid.Nil, err
+			}
+		}
+	}
+
+	if quiz.Scoring != nil {
+		policy := quiz.Scoring.policy(quizID)
+		_, err = tx.Exec(
+			`INSERT INTO quiz_scoring_policies
+				(quiz_id, rule, grace_seconds, min_points_percent, streak_bonus_percent, wrong_answer_penalty_percent)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (quiz_id) DO UPDATE
+			SET rule = $2, grace_seconds = $3, min_points_percent = $4, streak_bonus_percent = $5, wrong_answer_penalty_percent = $6`,
+			policy.QuizID, policy.Rule, policy.GraceSeconds, policy.MinPointsPercent, policy.StreakBonusPercent,
+			policy.WrongAnswerPenaltyPercent)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, err
+	}
+	return quizID, nil
+}
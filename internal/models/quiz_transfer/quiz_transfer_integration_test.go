@@ -0,0 +1,64 @@
+//go:build integration
+
+package quiz_transfer
+
+import (
+	"context"
+	"testing"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type QuizTransferIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestQuizTransferIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(QuizTransferIntegrationTestSuite))
+}
+
+func (s *QuizTransferIntegrationTestSuite) TestExportImportRoundTrip() {
+	quiz := quizzes.CreateDefaultQuiz()
+	_, err := quizzes.CreateQuiz(s.DB, quiz)
+	s.Require().NoError(err)
+
+	policy := quizzes.ScoringPolicy{QuizID: quiz.ID, Rule: quizzes.ScoringRuleWrongAnswerPenalty, GraceSeconds: 5,
+		MinPointsPercent: 10, StreakBonusPercent: 0, WrongAnswerPenaltyPercent: 50}
+	s.Require().NoError(quizzes.UpdateScoringPolicy(s.DB, &policy))
+
+	questionID := uuid.New()
+	_, err = s.DB.Exec(`INSERT INTO questions (id, question, quiz_id, points) VALUES ($1, 'Spørsmål', $2, 100)`, questionID, quiz.ID)
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(`INSERT INTO answer_alternatives (id, text, correct, question_id) VALUES
+		(gen_random_uuid(), 'Riktig', true, $1), (gen_random_uuid(), 'Feil', false, $1)`, questionID)
+	s.Require().NoError(err)
+
+	document, err := Export(s.DB, quiz.ID)
+	s.Require().NoError(err)
+	s.Require().Len(document.Quiz.Questions, 1)
+
+	newID, err := Import(s.DB, context.Background(), document)
+	s.Require().NoError(err)
+	s.Require().NotEqual(quiz.ID, newID)
+
+	imported, err := quizzes.GetQuizByID(s.DB, newID)
+	s.Require().NoError(err)
+	s.Require().Equal(quiz.Title, imported.Title)
+	s.Require().False(imported.Published)
+
+	importedQuestions, err := questions.GetQuestionsByQuizID(s.DB, &newID)
+	s.Require().NoError(err)
+	s.Require().Len(*importedQuestions, 1)
+	s.Require().Equal("Spørsmål", (*importedQuestions)[0].Text)
+	s.Require().Len((*importedQuestions)[0].Alternatives, 2)
+	s.Require().Equal("Riktig", (*importedQuestions)[0].Alternatives[0].Text)
+
+	importedPolicy, err := quizzes.GetScoringPolicyByQuizID(s.DB, newID)
+	s.Require().NoError(err)
+	policy.QuizID = newID
+	s.Require().Equal(policy, *importedPolicy)
+}
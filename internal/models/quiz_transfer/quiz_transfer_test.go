@@ -0,0 +1,115 @@
+//go:build unit
+
+package quiz_transfer_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/quiz_transfer"
+)
+
+func validDocument() *quiz_transfer.QuizDocument {
+	from := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC)
+	return &quiz_transfer.QuizDocument{
+		Version: quiz_transfer.FormatVersion,
+		Quiz: quiz_transfer.QuizData{
+			Title:      "Quiz: Uke 18",
+			ActiveFrom: from,
+			ActiveTo:   from.Add(7 * 24 * time.Hour),
+			Labels:     []string{"Ukesquiz"},
+			Articles:   []quiz_transfer.ArticleData{{URL: "https://example.com/a", Title: "A"}},
+			Questions: []quiz_transfer.QuestionData{{
+				Text:             "Spørsmål",
+				ArticleURL:       "https://example.com/a",
+				TimeLimitSeconds: 30,
+				Points:           100,
+				Alternatives:     []quiz_transfer.AlternativeData{{Text: "Ja", Correct: true}, {Text: "Nei"}},
+			}},
+			Scoring: &quiz_transfer.ScoringData{
+				Rule:                      "streak",
+				GraceSeconds:              5,
+				MinPointsPercent:          10,
+				StreakBonusPercent:        25,
+				WrongAnswerPenaltyPercent: 20,
+			},
+		},
+	}
+}
+
+// TestWriteAndRead tests that a written document is read back unchanged
+func TestWriteAndRead(t *testing.T) {
+	document := validDocument()
+
+	var buffer bytes.Buffer
+	if err := document.Write(&buffer); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	read, err := quiz_transfer.Read(&buffer)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if read.Quiz.Title != document.Quiz.Title || len(read.Quiz.Questions) != 1 ||
+		len(read.Quiz.Questions[0].Alternatives) != 2 || !read.Quiz.ActiveTo.Equal(document.Quiz.ActiveTo) {
+		t.Errorf("Expected %+v, but got %+v", document.Quiz, read.Quiz)
+	}
+	if read.Quiz.Scoring == nil || *read.Quiz.Scoring != *document.Quiz.Scoring {
+		t.Errorf("Expected scoring %+v, but got %+v", document.Quiz.Scoring, read.Quiz.Scoring)
+	}
+}
+
+// TestReadUnsupportedVersion tests that documents from unknown versions are rejected
+func TestReadUnsupportedVersion(t *testing.T) {
+	_, err := quiz_transfer.Read(strings.NewReader(`{"version": 99}`))
+	if !errors.Is(err, quiz_transfer.ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, but got %v", err)
+	}
+}
+
+// TestReadWithoutScoring tests that documents from before the scoring policy was added can still be read
+func TestReadWithoutScoring(t *testing.T) {
+	document := validDocument()
+	document.Version = 1
+	document.Quiz.Scoring = nil
+
+	var buffer bytes.Buffer
+	document.Write(&buffer)
+	read, err := quiz_transfer.Read(&buffer)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if read.Quiz.Scoring != nil {
+		t.Errorf("Expected no scoring policy, but got %+v", read.Quiz.Scoring)
+	}
+}
+
+// TestReadInvalidDocument tests that documents the database would reject are caught before importing
+func TestReadInvalidDocument(t *testing.T) {
+	unknownArticle := validDocument()
+	unknownArticle.Quiz.Questions[0].ArticleURL = "https://example.com/unknown"
+
+	noTimeLimit := validDocument()
+	noTimeLimit.Quiz.Questions[0].TimeLimitSeconds = 0
+
+	reversedPeriod := validDocument()
+	reversedPeriod.Quiz.ActiveTo = reversedPeriod.Quiz.ActiveFrom.Add(-time.Hour)
+
+	unknownScoringRule := validDocument()
+	unknownScoringRule.Quiz.Scoring.Rule = "unknown"
+
+	for _, document := range []*quiz_transfer.QuizDocument{unknownArticle, noTimeLimit, reversedPeriod, unknownScoringRule} {
+		var buffer bytes.Buffer
+		document.Write(&buffer)
+		if _, err := quiz_transfer.Read(&buffer); !errors.Is(err, quiz_transfer.ErrInvalidDocument) {
+			t.Errorf("Expected ErrInvalidDocument, but got %v", err)
+		}
+	}
+
+	if _, err := quiz_transfer.Read(strings.NewReader("not json")); !errors.Is(err, quiz_transfer.ErrInvalidDocument) {
+		t.Errorf("Expected ErrInvalidDocument for malformed json, but got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package quizzes
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Default shift of the active period when duplicating a quiz, so a weekly quiz becomes next week's quiz.
+const DefaultDuplicateShift = 7 * 24 * time.Hour
+
+// DuplicateQuiz deep-copies a quiz into a new unpublished quiz with the given title.
+// Questions, alternatives, labels, image, articles, the end action and the scoring policy are copied,
+// and the active period is moved to start at activeFrom while keeping its length.
+// If asTemplate is true, the copy is saved as a template instead of a regular quiz.
+// Returns the ID of the new quiz.
+func DuplicateQuiz(db *sql.DB, ctx context.Context, id uuid.UUID, title string, activeFrom time.Time, asTemplate bool) (uuid.UUID, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback()
+
+	newID := uuid.New()
+	result, err := tx.Exec(
+		`INSERT INTO quizzes
+			(id, title, image_url, active_from, active_to, published, is_template, end_action)
+		SELECT
+			$1, $2, image_url, $3, $3 + (active_to - active_from), false, $4, end_action
+		FROM
+			quizzes
+		WHERE
+			id = $5 AND
+			is_deleted = false`,
+		newID, title, activeFrom, asTemplate, id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return uuid.Nil, sql.ErrNoRows
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO quiz_labels (quiz_id, label_id)
+		SELECT $1, label_id FROM quiz_labels WHERE quiz_id = $2`,
+		newID, id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// Articles not used by any question are also attached to the quiz, so they are copied separately.
+	_, err = tx.Exec(
+		`INSERT INTO quiz_articles (quiz_id, article_id)
+		SELECT $1, article_id FROM quiz_articles WHERE quiz_id = $2`,
+		newID, id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO quiz_scoring_policies
+			(quiz_id, rule, grace_seconds, min_points_percent, streak_bonus_percent, wrong_answer_penalty_percent)
+		SELECT $1, rule, grace_seconds, min_points_percent, streak_bonus_percent, wrong_answer_penalty_percent
+		FROM quiz_scoring_policies WHERE quiz_id = $2
+		ON CONFLICT (quiz_id) DO UPDATE
+		SET rule = EXCLUDED.rule, grace_seconds = EXCLUDED.grace_seconds, min_points_percent = EXCLUDED.min_points_percent,
+			streak_bonus_percent = EXCLUDED.streak_bonus_percent,
+			wrong_answer_penalty_percent = EXCLUDED.wrong_answer_penalty_percent`,
+		newID, id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := duplicateQuestions(tx, id, newID); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, err
+	}
+	return newID, nil
+}
+
+// Copies all questions and their alternatives from one quiz to another.
+// The questions and alternatives are inserted in their current order,
+// so the arrangement triggers give them the same arrangement as the originals.
+func duplicateQuestions(tx *sql.Tx, fromQuizID uuid.UUID, toQuizID uuid.UUID) error {
+	rows, err := tx.Query(
+		`SELECT id FROM questions WHERE quiz_id = $1 ORDER BY arrangement`,
+		fromQuizID)
+	if err != nil {
+		return err
+	}
+	questionIDs := []uuid.UUID{}
+	for rows.Next() {
+		var questionID uuid.UUID
+		if err := rows.Scan(&questionID); err != nil {
+			rows.Close()
+			return err
+		}
+		questionIDs = append(questionIDs, questionID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, questionID := range questionIDs {
+		newQuestionID := uuid.New()
+		_, err := tx.Exec(
+			`INSERT INTO questions (id, question, image_url, article_id, quiz_id, time_limit_seconds, points,
+				question_type, numeric_answer, numeric_tolerance, numeric_unit, explanation)
+			SELECT $1, question, image_url, article_id, $2, time_limit_seconds, points,
+				question_type, numeric_answer, numeric_tolerance, numeric_unit, explanation
+			FROM questions WHERE id = $3`,
+			newQuestionID, toQuizID, questionID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO answer_alternatives (id, text, correct, question_id)
+			SELECT gen_random_uuid(), text, correct, $1
+			FROM answer_alternatives WHERE question_id = $2
+			ORDER BY arrangement`,
+			newQuestionID, questionID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get all templates that are not deleted, ordered by title.
+func GetTemplates(db *sql.DB) ([]Quiz, error) {
+	rows, err := db.Query(
+		`SELECT
+			id, title, image_url, active_from, active_to, created_at, last_modified_at, published, is_deleted, is_template,
+			publish_at, end_action, is_archived, open_for_guests
+		FROM
+			quizzes
+		WHERE
+			is_template = true AND
+			is_deleted = false
+		ORDER BY
+			title`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanQuizzesFromFullRows(rows)
+}
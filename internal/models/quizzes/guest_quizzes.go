@@ -0,0 +1,30 @@
+package quizzes
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrTemplateNotOpenForGuests = errors.New("quizzes: templates can not be open for guests")
+
+// Updates whether guests can play the quiz without logging in.
+// Returns ErrTemplateNotOpenForGuests if a template is opened for guests.
+func UpdateOpenForGuestsByQuizID(db *sql.DB, id uuid.UUID, openForGuests bool) error {
+	var isTemplate bool
+	err := db.QueryRow(`SELECT is_template FROM quizzes WHERE id = $1 AND is_deleted = false`, id).Scan(&isTemplate)
+	if err != nil {
+		return err
+	}
+	if isTemplate && openForGuests {
+		return ErrTemplateNotOpenForGuests
+	}
+
+	_, err = db.Exec(
+		`UPDATE quizzes
+		SET open_for_guests = $1
+		WHERE id = $2`,
+		openForGuests, id)
+	return err
+}
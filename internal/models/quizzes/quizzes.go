@@ -9,12 +9,14 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/Molnes/Nyhetsjeger/internal/live_updates"
 	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
 	data_handling "github.com/Molnes/Nyhetsjeger/internal/utils/data"
 	"github.com/google/uuid"
 )
 
 var ErrNoQuestions = errors.New("quizzes: no questions in quiz")
+var ErrTemplateNotPublishable = errors.New("quizzes: templates can not be published")
 
 // Quiz represents a quiz in the database.
 type Quiz struct {
@@ -27,6 +29,11 @@ type Quiz struct {
 	LastModifiedAt time.Time
 	Published      bool
 	IsDeleted      bool
+	IsTemplate     bool
+	PublishAt      sql.NullTime // When the quiz is scheduled to be published, if it is.
+	EndAction      EndAction    // What happens to the quiz once active_to has passed.
+	IsArchived     bool
+	OpenForGuests  bool // Whether guests can play the quiz without logging in.
 	Labels         []labels.Label
 }
 
@@ -63,6 +70,7 @@ func CreateDefaultQuiz() Quiz {
 		LastModifiedAt: time.Now(),
 		Published:      false,
 		IsDeleted:      false,
+		EndAction:      EndActionNone,
 	}
 }
 
@@ -70,7 +78,8 @@ func CreateDefaultQuiz() Quiz {
 func GetQuizByID(db *sql.DB, id uuid.UUID) (*Quiz, error) {
 	row := db.QueryRow(
 		`SELECT
-			id, title, image_url, active_from, active_to, created_at, last_modified_at, published, is_deleted
+			id, title, image_url, active_from, active_to, created_at, last_modified_at, published, is_deleted, is_template,
+			publish_at, end_action, is_archived, open_for_guests
     FROM
 			quizzes
 		WHERE
@@ -127,7 +136,8 @@ func UpdateTitleByQuizID(db *sql.DB, id uuid.UUID, title string) error {
 func GetQuizzes(db *sql.DB) ([]Quiz, error) {
 	rows, err := db.Query(
 		`SELECT
-			id, title, image_url, active_from, active_to, created_at, last_modified_at, published, is_deleted
+			id, title, image_url, active_from, active_to, created_at, last_modified_at, published, is_deleted, is_template,
+			publish_at, end_action, is_archived, open_for_guests
     FROM
 			quizzes
 		WHERE
@@ -305,16 +315,19 @@ func GetQuizzesByUserIDAndFinishedOrNotAndNotActive(db *sql.DB, userID uuid.UUID
 
 }
 
-// Get all the quizzes that are not published and not deleted.
+// Get all the quizzes with the given published status that are not deleted. Templates and archived quizzes are not included.
 func GetQuizzesByPublishStatus(db *sql.DB, published bool) ([]Quiz, error) {
 	rows, err := db.Query(
 		`SELECT
-			id, title, image_url, active_from, active_to, created_at, last_modified_at, published, is_deleted
+			id, title, image_url, active_from, active_to, created_at, last_modified_at, published, is_deleted, is_template,
+			publish_at, end_action, is_archived, open_for_guests
 		FROM
 			quizzes
 		WHERE
 			published = $1 AND
-			is_deleted = false
+			is_deleted = false AND
+			is_template = false AND
+			is_archived = false
 		ORDER BY
 			active_from DESC`,
 		published)
@@ -329,7 +342,8 @@ func GetQuizzesByPublishStatus(db *sql.DB, published bool) ([]Quiz, error) {
 }
 
 // Converts a row from the database to a Quiz.
-// It expects the row to contain ID, Title, ImageURL, ActiveFrom, ActiveTo, CreatedAt, LastModifiedAt, Published, IsDeleted.
+// It expects the row to contain ID, Title, ImageURL, ActiveFrom, ActiveTo, CreatedAt, LastModifiedAt, Published, IsDeleted, IsTemplate,
+// PublishAt, EndAction, IsArchived, OpenForGuests.
 // It will return a Quiz with these values.
 func scanQuizFromFullRow(row *sql.Row) (*Quiz, error) {
 	var quiz Quiz
@@ -344,6 +358,11 @@ func scanQuizFromFullRow(row *sql.Row) (*Quiz, error) {
 		&quiz.LastModifiedAt,
 		&quiz.Published,
 		&quiz.IsDeleted,
+		&quiz.IsTemplate,
+		&quiz.PublishAt,
+		&quiz.EndAction,
+		&quiz.IsArchived,
+		&quiz.OpenForGuests,
 	)
 	if err != nil {
 		return nil, err
@@ -360,7 +379,8 @@ func scanQuizFromFullRow(row *sql.Row) (*Quiz, error) {
 }
 
 // Converts rows from the database to a list of Quizzes.
-// It expects the row to contain ID, Title, ImageURL, ActiveFrom, ActiveTo, CreatedAt, LastModifiedAt, Published, IsDeleted.
+// It expects the row to contain ID, Title, ImageURL, ActiveFrom, ActiveTo, CreatedAt, LastModifiedAt, Published, IsDeleted, IsTemplate,
+// PublishAt, EndAction, IsArchived, OpenForGuests.
 // It will return a Quiz with these values.
 func scanQuizzesFromFullRows(rows *sql.Rows) ([]Quiz, error) {
 	quizzes := []Quiz{}
@@ -377,6 +397,11 @@ func scanQuizzesFromFullRows(rows *sql.Rows) ([]Quiz, error) {
 			&quiz.LastModifiedAt,
 			&quiz.Published,
 			&quiz.IsDeleted,
+			&quiz.IsTemplate,
+			&quiz.PublishAt,
+			&quiz.EndAction,
+			&quiz.IsArchived,
+			&quiz.OpenForGuests,
 		)
 		if err != nil {
 			return nil, err
@@ -398,9 +423,9 @@ func scanQuizzesFromFullRows(rows *sql.Rows) ([]Quiz, error) {
 func CreateQuiz(db *sql.DB, quiz Quiz) (*uuid.UUID, error) {
 	_, err := db.Exec(
 		`INSERT INTO quizzes
-			(id, title, image_url, active_from, active_to, created_at, last_modified_at, published, is_deleted)
+			(id, title, image_url, active_from, active_to, created_at, last_modified_at, published, is_deleted, is_template)
 		VALUES
-			($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
 		quiz.ID,
 		quiz.Title,
 		quiz.ImageURL.String(),
@@ -410,6 +435,7 @@ func CreateQuiz(db *sql.DB, quiz Quiz) (*uuid.UUID, error) {
 		quiz.LastModifiedAt,
 		quiz.Published,
 		quiz.IsDeleted,
+		quiz.IsTemplate,
 	)
 
 	return &quiz.ID, err
@@ -426,15 +452,28 @@ func DeleteQuizByID(db *sql.DB, id uuid.UUID) error {
 }
 
 // Update the published status of a quiz by its ID.
-func UpdatePublishedStatusByQuizID(db *sql.DB, ctx context.Context, id uuid.UUID, published bool) error {
+// On success a QuizPublished or QuizUnpublished and a ScoreboardChanged event is published,
+// since only published quizzes count in the ranking.
+func UpdatePublishedStatusByQuizID(db *sql.DB, ctx context.Context, publisher live_updates.Publisher, id uuid.UUID, published bool) error {
 	// Start a transaction
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	// If quiz is published, but the quiz has no questions, then return an error.
+	// If quiz is published, but the quiz has no questions or is a template, then return an error.
 	if published {
+		var isTemplate bool
+		err = tx.QueryRow(`SELECT is_template FROM quizzes WHERE id = $1`, id).Scan(&isTemplate)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if isTemplate {
+			tx.Rollback()
+			return ErrTemplateNotPublishable
+		}
+
 		result := tx.QueryRow(
 			`SELECT COUNT(*)
 			FROM questions q
@@ -449,9 +488,10 @@ func UpdatePublishedStatusByQuizID(db *sql.DB, ctx context.Context, id uuid.UUID
 		}
 	}
 
+	// Publishing manually replaces any scheduled publishing.
 	_, err = tx.Exec(
 		`UPDATE quizzes
-		SET published = $1
+		SET published = $1, publish_at = CASE WHEN $1 THEN NULL ELSE publish_at END
 		WHERE id = $2`,
 		published,
 		id)
@@ -464,6 +504,15 @@ func UpdatePublishedStatusByQuizID(db *sql.DB, ctx context.Context, id uuid.UUID
 		return err
 	}
 
+	if publisher != nil {
+		eventType := live_updates.QuizPublished
+		if !published {
+			eventType = live_updates.QuizUnpublished
+		}
+		publisher.Publish(live_updates.Event{Type: eventType, QuizID: id})
+		publisher.Publish(live_updates.Event{Type: live_updates.ScoreboardChanged, QuizID: id})
+	}
+
 	return err
 }
 
@@ -3,9 +3,15 @@
 package quizzes
 
 import (
+	"context"
+	"database/sql"
 	"testing"
+	"time"
 
 	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/Molnes/Nyhetsjeger/internal/live_updates"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -41,3 +47,375 @@ func (s *UsersIntegrationTestSuite) TestGetQuizById() {
 	s.Require().Equal(quiz.Published, createdQuiz.Published)
 	s.Require().Equal(quiz.Title, createdQuiz.Title)
 }
+
+func (s *UsersIntegrationTestSuite) TestDuplicateQuiz() {
+	quiz := CreateDefaultQuiz()
+	_, err := CreateQuiz(s.DB, quiz)
+	s.Require().NoError(err)
+
+	questionID := uuid.New()
+	_, err = s.DB.Exec(`INSERT INTO questions (id, question, quiz_id, points) VALUES ($1, 'Spørsmål', $2, 100)`, questionID, quiz.ID)
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(`INSERT INTO answer_alternatives (id, text, correct, question_id) VALUES
+		(gen_random_uuid(), 'Riktig', true, $1), (gen_random_uuid(), 'Feil', false, $1)`, questionID)
+	s.Require().NoError(err)
+
+	activeFrom := quiz.ActiveFrom.Add(DefaultDuplicateShift)
+	newID, err := DuplicateQuiz(s.DB, context.Background(), quiz.ID, "Kopi", activeFrom, false)
+	s.Require().NoError(err)
+
+	duplicate, err := GetQuizByID(s.DB, newID)
+	s.Require().NoError(err)
+	s.Require().Equal("Kopi", duplicate.Title)
+	s.Require().False(duplicate.Published)
+	s.Require().False(duplicate.IsTemplate)
+	s.Require().WithinDuration(activeFrom, duplicate.ActiveFrom, time.Millisecond)
+	s.Require().WithinDuration(quiz.ActiveTo.Add(DefaultDuplicateShift), duplicate.ActiveTo, time.Millisecond)
+
+	var questionCount, alternativeCount int
+	err = s.DB.QueryRow(`SELECT COUNT(DISTINCT q.id), COUNT(a.id) FROM questions q
+		JOIN answer_alternatives a ON a.question_id = q.id WHERE q.quiz_id = $1`, newID).Scan(&questionCount, &alternativeCount)
+	s.Require().NoError(err)
+	s.Require().Equal(1, questionCount)
+	s.Require().Equal(2, alternativeCount)
+}
+
+func (s *UsersIntegrationTestSuite) TestTemplateCannotBePublished() {
+	quiz := CreateDefaultQuiz()
+	_, err := CreateQuiz(s.DB, quiz)
+	s.Require().NoError(err)
+
+	templateID, err := DuplicateQuiz(s.DB, context.Background(), quiz.ID, quiz.Title, quiz.ActiveFrom, true)
+	s.Require().NoError(err)
+
+	templates, err := GetTemplates(s.DB)
+	s.Require().NoError(err)
+	s.Require().Len(templates, 1)
+	s.Require().Equal(templateID, templates[0].ID)
+
+	err = UpdatePublishedStatusByQuizID(s.DB, context.Background(), nil, templateID, true)
+	s.Require().ErrorIs(err, ErrTemplateNotPublishable)
+}
+
+func (s *UsersIntegrationTestSuite) TestScheduledActions() {
+	scheduled := CreateDefaultQuiz()
+	_, err := CreateQuiz(s.DB, scheduled)
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(`INSERT INTO questions (id, question, quiz_id, points) VALUES (gen_random_uuid(), 'Spørsmål', $1, 100)`, scheduled.ID)
+	s.Require().NoError(err)
+
+	now := time.Now().UTC()
+	err = UpdateScheduleByQuizID(s.DB, scheduled.ID, sql.NullTime{Time: now.Add(-time.Minute), Valid: true}, EndActionNone)
+	s.Require().NoError(err)
+
+	ended := CreateDefaultQuiz()
+	ended.Published = true
+	ended.ActiveFrom = now.Add(-48 * time.Hour)
+	ended.ActiveTo = now.Add(-time.Hour)
+	_, err = CreateQuiz(s.DB, ended)
+	s.Require().NoError(err)
+	err = UpdateScheduleByQuizID(s.DB, ended.ID, sql.NullTime{}, EndActionUnpublish)
+	s.Require().NoError(err)
+
+	hub := live_updates.NewHub()
+	subscriber := hub.Subscribe()
+	err = runScheduledActions(context.Background(), s.DB, hub, now)
+	s.Require().NoError(err)
+
+	published, err := GetQuizByID(s.DB, scheduled.ID)
+	s.Require().NoError(err)
+	s.Require().True(published.Published)
+	s.Require().False(published.PublishAt.Valid)
+
+	unpublished, err := GetQuizByID(s.DB, ended.ID)
+	s.Require().NoError(err)
+	s.Require().False(unpublished.Published)
+	s.Require().Equal(EndActionNone, unpublished.EndAction)
+
+	// Subscribers are told which quiz was published and which was taken down
+	events := map[live_updates.Event]bool{}
+	for len(subscriber.Events()) > 0 {
+		events[<-subscriber.Events()] = true
+	}
+	s.Require().Equal(map[live_updates.Event]bool{
+		{Type: live_updates.QuizPublished, QuizID: scheduled.ID}:     true,
+		{Type: live_updates.ScoreboardChanged, QuizID: scheduled.ID}: true,
+		{Type: live_updates.QuizUnpublished, QuizID: ended.ID}:       true,
+		{Type: live_updates.ScoreboardChanged, QuizID: ended.ID}:     true,
+	}, events)
+}
+
+func (s *UsersIntegrationTestSuite) TestOpenForGuests() {
+	err := UpdateOpenForGuestsByQuizID(s.DB, s.InsertedValues.QuizId1, true)
+	s.Require().NoError(err)
+
+	quiz, err := GetQuizByID(s.DB, s.InsertedValues.QuizId1)
+	s.Require().NoError(err)
+	s.Require().True(quiz.OpenForGuests)
+
+	templateID, err := DuplicateQuiz(s.DB, context.Background(), quiz.ID, quiz.Title, quiz.ActiveFrom, true)
+	s.Require().NoError(err)
+	err = UpdateOpenForGuestsByQuizID(s.DB, templateID, true)
+	s.Require().ErrorIs(err, ErrTemplateNotOpenForGuests)
+}
+
+type scoringAnswer struct {
+	correct bool
+	after   time.Duration
+}
+
+// Answers given in each scoring test, in the order of the questions. Each question is worth 100 points
+// and has a time limit of 30 seconds, so with the default policy the points decay from 100 after 3 seconds
+// to 20 after 30 seconds.
+var scoringAnswers = []scoringAnswer{
+	{true, 2 * time.Second},
+	{true, 16500 * time.Millisecond},
+	{false, 5 * time.Second},
+	{true, 2 * time.Second},
+	{true, 30 * time.Second},
+}
+
+// Creates a quiz with one question per answer in scoringAnswers, answered by the test user and a guest.
+// Returns the ID of the quiz and of the guest session.
+func (s *UsersIntegrationTestSuite) createScoredQuiz() (uuid.UUID, uuid.UUID) {
+	quiz := CreateDefaultQuiz()
+	_, err := CreateQuiz(s.DB, quiz)
+	s.Require().NoError(err)
+
+	var guestID uuid.UUID
+	err = s.DB.QueryRow(`INSERT INTO guest_sessions DEFAULT VALUES RETURNING id`).Scan(&guestID)
+	s.Require().NoError(err)
+
+	presentedAt := time.Now().Add(-time.Hour)
+	for _, answer := range scoringAnswers {
+		questionID, correctID, wrongID := uuid.New(), uuid.New(), uuid.New()
+		_, err := s.DB.Exec(`INSERT INTO questions (id, question, quiz_id, points) VALUES ($1, 'Spørsmål', $2, 100)`, questionID, quiz.ID)
+		s.Require().NoError(err)
+		_, err = s.DB.Exec(`INSERT INTO answer_alternatives (id, text, correct, question_id) VALUES
+			($1, 'Riktig', true, $3), ($2, 'Feil', false, $3)`, correctID, wrongID, questionID)
+		s.Require().NoError(err)
+
+		chosenID := wrongID
+		if answer.correct {
+			chosenID = correctID
+		}
+		_, err = s.DB.Exec(`INSERT INTO user_answers (user_id, question_id, question_presented_at, chosen_answer_alternative_id, answered_at)
+			VALUES ($1, $2, $3, $4, $5)`, s.InsertedValues.UserId, questionID, presentedAt, chosenID, presentedAt.Add(answer.after))
+		s.Require().NoError(err)
+		_, err = s.DB.Exec(`INSERT INTO guest_answers (guest_session_id, question_id, question_presented_at, chosen_answer_alternative_id, answered_at)
+			VALUES ($1, $2, $3, $4, $5)`, guestID, questionID, presentedAt, chosenID, presentedAt.Add(answer.after))
+		s.Require().NoError(err)
+	}
+	return quiz.ID, guestID
+}
+
+// Returns the points awarded to the test user and the guest in the quiz, in the order of the questions.
+func (s *UsersIntegrationTestSuite) pointsAwarded(quizID uuid.UUID, guestID uuid.UUID) ([]int, []int) {
+	query := func(query string, args ...any) []int {
+		rows, err := s.DB.Query(query, args...)
+		s.Require().NoError(err)
+		defer rows.Close()
+		points := []int{}
+		for rows.Next() {
+			var p int
+			s.Require().NoError(rows.Scan(&p))
+			points = append(points, p)
+		}
+		s.Require().NoError(rows.Err())
+		return points
+	}
+	userPoints := query(`SELECT uqp.points_awarded FROM user_question_points uqp JOIN questions q ON uqp.question_id = q.id
+		WHERE uqp.quiz_id = $1 AND uqp.user_id = $2 ORDER BY q.arrangement`, quizID, s.InsertedValues.UserId)
+	guestPoints := query(`SELECT gqp.points_awarded FROM guest_question_points gqp JOIN questions q ON gqp.question_id = q.id
+		WHERE gqp.quiz_id = $1 AND gqp.guest_session_id = $2 ORDER BY q.arrangement`, quizID, guestID)
+	return userPoints, guestPoints
+}
+
+func (s *UsersIntegrationTestSuite) TestScoringPolicies() {
+	quizID, guestID := s.createScoredQuiz()
+
+	// New quizzes get the defaults of the quiz_scoring_policies columns.
+	policy, err := GetScoringPolicyByQuizID(s.DB, quizID)
+	s.Require().NoError(err)
+	s.Require().Equal(ScoringPolicy{QuizID: quizID, Rule: ScoringRuleLinearDecay, GraceSeconds: 3,
+		MinPointsPercent: 20, StreakBonusPercent: 10, WrongAnswerPenaltyPercent: 20}, *policy)
+
+	_, err = GetScoringPolicyByQuizID(s.DB, uuid.New())
+	s.Require().ErrorIs(err, sql.ErrNoRows)
+
+	expected := map[ScoringRule][]int{
+		ScoringRuleLinearDecay:        {100, 60, 0, 100, 20},
+		ScoringRuleFlat:               {100, 100, 0, 100, 100},
+		ScoringRuleStreak:             {100, 66, 0, 100, 22},
+		ScoringRuleWrongAnswerPenalty: {100, 60, -20, 100, 20},
+	}
+	for _, rule := range ScoringRules {
+		policy.Rule = rule
+		s.Require().NoError(UpdateScoringPolicy(s.DB, policy))
+
+		userPoints, guestPoints := s.pointsAwarded(quizID, guestID)
+		s.Require().Equal(expected[rule], userPoints, rule)
+		s.Require().Equal(expected[rule], guestPoints, rule)
+	}
+
+	var total int
+	err = s.DB.QueryRow(`SELECT total_points_awarded FROM user_quizzes WHERE quiz_id = $1 AND user_id = $2`,
+		quizID, s.InsertedValues.UserId).Scan(&total)
+	s.Require().NoError(err)
+	s.Require().Equal(260, total)
+}
+
+func (s *UsersIntegrationTestSuite) TestScoringPolicySettings() {
+	quizID, guestID := s.createScoredQuiz()
+
+	policy, err := GetScoringPolicyByQuizID(s.DB, quizID)
+	s.Require().NoError(err)
+	policy.GraceSeconds = 0
+	policy.MinPointsPercent = 0
+	s.Require().NoError(UpdateScoringPolicy(s.DB, policy))
+	userPoints, _ := s.pointsAwarded(quizID, guestID)
+	s.Require().Equal([]int{93, 45, 0, 93, 0}, userPoints)
+
+	policy.MinPointsPercent = 101
+	s.Require().ErrorIs(UpdateScoringPolicy(s.DB, policy), ErrInvalidScoringPercent)
+
+	duplicateID, err := DuplicateQuiz(s.DB, context.Background(), quizID, "Kopi", time.Now(), false)
+	s.Require().NoError(err)
+	duplicatePolicy, err := GetScoringPolicyByQuizID(s.DB, duplicateID)
+	s.Require().NoError(err)
+	s.Require().Equal(uint(0), duplicatePolicy.GraceSeconds)
+	s.Require().Equal(uint(0), duplicatePolicy.MinPointsPercent)
+}
+
+// Creates a quiz with questions of every kind of answer the scoring has to handle, answered by the test user.
+// Each question is worth 100 points and has a time limit of 30 seconds. Returns the ID of the quiz.
+func (s *UsersIntegrationTestSuite) createTypedScoredQuiz() uuid.UUID {
+	quiz := CreateDefaultQuiz()
+	_, err := CreateQuiz(s.DB, quiz)
+	s.Require().NoError(err)
+
+	presentedAt := time.Now().Add(-time.Hour)
+	addQuestion := func(questionType string, numericAnswer sql.NullFloat64, numericTolerance sql.NullFloat64) uuid.UUID {
+		questionID := uuid.New()
+		_, err := s.DB.Exec(`INSERT INTO questions (id, question, quiz_id, points, question_type, numeric_answer, numeric_tolerance)
+			VALUES ($1, 'Spørsmål', $2, 100, $3, $4, $5)`, questionID, quiz.ID, questionType, numericAnswer, numericTolerance)
+		s.Require().NoError(err)
+		return questionID
+	}
+	addAlternative := func(questionID uuid.UUID, correct bool) uuid.UUID {
+		alternativeID := uuid.New()
+		_, err := s.DB.Exec(`INSERT INTO answer_alternatives (id, text, correct, question_id) VALUES ($1, 'Svar', $2, $3)`,
+			alternativeID, correct, questionID)
+		s.Require().NoError(err)
+		return alternativeID
+	}
+	answer := func(questionID uuid.UUID, chosenID uuid.NullUUID, chosenIDs []uuid.UUID, estimate sql.NullFloat64, after time.Duration, timedOut bool) {
+		_, err := s.DB.Exec(`INSERT INTO user_answers
+			(user_id, question_id, question_presented_at, chosen_answer_alternative_id, chosen_alternative_ids, numeric_answer, answered_at, timed_out)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			s.InsertedValues.UserId, questionID, presentedAt, chosenID, pq.Array(chosenIDs), estimate, presentedAt.Add(after), timedOut)
+		s.Require().NoError(err)
+	}
+	noEstimate := sql.NullFloat64{}
+	addSingleChoice := func() (uuid.UUID, uuid.NullUUID) {
+		questionID := addQuestion("single_choice", noEstimate, noEstimate)
+		correctID := addAlternative(questionID, true)
+		addAlternative(questionID, false)
+		return questionID, uuid.NullUUID{UUID: correctID, Valid: true}
+	}
+
+	// Half of the correct alternatives chosen
+	multiID := addQuestion("multi_select", noEstimate, noEstimate)
+	correctID := addAlternative(multiID, true)
+	addAlternative(multiID, true)
+	addAlternative(multiID, false)
+	answer(multiID, uuid.NullUUID{}, []uuid.UUID{correctID}, noEstimate, 2*time.Second, false)
+
+	// 4 off with a tolerance of 10
+	numericID := addQuestion("numeric_estimate", sql.NullFloat64{Float64: 100, Valid: true}, sql.NullFloat64{Float64: 10, Valid: true})
+	answer(numericID, uuid.NullUUID{}, nil, sql.NullFloat64{Float64: 104, Valid: true}, 2*time.Second, false)
+
+	// Halfway between the grace period and the time limit
+	decayID, chosenID := addSingleChoice()
+	answer(decayID, chosenID, nil, noEstimate, 16500*time.Millisecond, false)
+
+	// Not answered before the time limit
+	timedOutID, _ := addSingleChoice()
+	answer(timedOutID, uuid.NullUUID{}, nil, noEstimate, 30*time.Second, true)
+
+	// Two quick correct answers, the second one on a streak
+	for range 2 {
+		questionID, chosenID := addSingleChoice()
+		answer(questionID, chosenID, nil, noEstimate, 2*time.Second, false)
+	}
+
+	// Only the wrong alternative chosen
+	wrongID := addQuestion("multi_select", noEstimate, noEstimate)
+	addAlternative(wrongID, true)
+	wrongChoiceID := addAlternative(wrongID, false)
+	answer(wrongID, uuid.NullUUID{}, []uuid.UUID{wrongChoiceID}, noEstimate, 2*time.Second, false)
+
+	return quiz.ID
+}
+
+// Returns the points awarded to and the score of the test user in the quiz, in the order of the questions.
+func (s *UsersIntegrationTestSuite) userQuestionPoints(quizID uuid.UUID) ([]int, []float64) {
+	rows, err := s.DB.Query(`SELECT uqp.points_awarded, uqp.score FROM user_question_points uqp JOIN questions q ON uqp.question_id = q.id
+		WHERE uqp.quiz_id = $1 AND uqp.user_id = $2 ORDER BY q.arrangement`, quizID, s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	defer rows.Close()
+	points := []int{}
+	scores := []float64{}
+	for rows.Next() {
+		var p int
+		var score float64
+		s.Require().NoError(rows.Scan(&p, &score))
+		points = append(points, p)
+		scores = append(scores, score)
+	}
+	s.Require().NoError(rows.Err())
+	return points, scores
+}
+
+func (s *UsersIntegrationTestSuite) TestScoringPolicyQuestionTypes() {
+	quizID := s.createTypedScoredQuiz()
+
+	expectedScores := []float64{0.5, 0.6, 1, 0, 1, 1, 0}
+	expected := map[ScoringRule][]int{
+		ScoringRuleLinearDecay:        {50, 60, 60, 0, 100, 100, 0},
+		ScoringRuleFlat:               {50, 60, 100, 0, 100, 100, 0},
+		ScoringRuleStreak:             {50, 60, 60, 0, 100, 110, 0},
+		ScoringRuleWrongAnswerPenalty: {50, 60, 60, 0, 100, 100, -20},
+	}
+	policy, err := GetScoringPolicyByQuizID(s.DB, quizID)
+	s.Require().NoError(err)
+	for _, rule := range ScoringRules {
+		policy.Rule = rule
+		s.Require().NoError(UpdateScoringPolicy(s.DB, policy))
+
+		points, scores := s.userQuestionPoints(quizID)
+		s.Require().Equal(expected[rule], points, rule)
+		s.Require().Len(scores, len(expectedScores))
+		for i := range expectedScores {
+			s.Require().InDelta(expectedScores[i], scores[i], 1e-9, rule)
+		}
+	}
+}
+
+// A grace period as long as the time limit gives full points however late the answer is.
+func (s *UsersIntegrationTestSuite) TestScoringPolicyGraceCap() {
+	quizID := s.createTypedScoredQuiz()
+
+	policy, err := GetScoringPolicyByQuizID(s.DB, quizID)
+	s.Require().NoError(err)
+	policy.GraceSeconds = MaxGraceSeconds
+	s.Require().NoError(UpdateScoringPolicy(s.DB, policy))
+	points, _ := s.userQuestionPoints(quizID)
+	s.Require().Equal([]int{50, 60, 100, 0, 100, 100, 0}, points)
+
+	policy.GraceSeconds = MaxGraceSeconds + 1
+	s.Require().ErrorIs(UpdateScoringPolicy(s.DB, policy), ErrInvalidGraceSeconds)
+	saved, err := GetScoringPolicyByQuizID(s.DB, quizID)
+	s.Require().NoError(err)
+	s.Require().Equal(uint(MaxGraceSeconds), saved.GraceSeconds)
+}
@@ -0,0 +1,196 @@
+package quizzes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/live_updates"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidEndAction = errors.New("quizzes: invalid end action")
+
+// What happens to a quiz once its active_to has passed.
+type EndAction string
+
+const (
+	EndActionNone      EndAction = "none"
+	EndActionUnpublish EndAction = "unpublish"
+	EndActionArchive   EndAction = "archive" // Stays published, but is hidden from the dashboard's quiz lists.
+)
+
+// All end actions, in the order they are shown on the edit quiz page.
+var EndActions = []EndAction{EndActionNone, EndActionUnpublish, EndActionArchive}
+
+func (e EndAction) IsValid() bool {
+	switch e {
+	case EndActionNone, EndActionUnpublish, EndActionArchive:
+		return true
+	}
+	return false
+}
+
+// Key of the Postgres advisory lock held while the scheduled actions run,
+// so only one replica applies them at a time.
+const schedulerLockKey int64 = 0x5155495a // "QUIZ"
+
+// Updates when a quiz is scheduled to be published, and what happens to it once it has ended.
+// A publishAt that is not valid removes the scheduled publishing.
+// Returns ErrTemplateNotPublishable if a template is scheduled to be published.
+func UpdateScheduleByQuizID(db *sql.DB, id uuid.UUID, publishAt sql.NullTime, endAction EndAction) error {
+	if !endAction.IsValid() {
+		return ErrInvalidEndAction
+	}
+
+	var isTemplate bool
+	err := db.QueryRow(`SELECT is_template FROM quizzes WHERE id = $1 AND is_deleted = false`, id).Scan(&isTemplate)
+	if err != nil {
+		return err
+	}
+	if isTemplate && publishAt.Valid {
+		return ErrTemplateNotPublishable
+	}
+
+	_, err = db.Exec(
+		`UPDATE quizzes
+		SET publish_at = $1, end_action = $2
+		WHERE id = $3`,
+		publishAt, endAction, id)
+	return err
+}
+
+// Moves an archived quiz back to the dashboard's quiz lists.
+func UnarchiveQuizByID(db *sql.DB, id uuid.UUID) error {
+	_, err := db.Exec(
+		`UPDATE quizzes
+		SET is_archived = false
+		WHERE id = $1`,
+		id)
+	return err
+}
+
+// Get all archived quizzes that are not deleted, most recent first.
+func GetArchivedQuizzes(db *sql.DB) ([]Quiz, error) {
+	rows, err := db.Query(
+		`SELECT
+			id, title, image_url, active_from, active_to, created_at, last_modified_at, published, is_deleted, is_template,
+			publish_at, end_action, is_archived, open_for_guests
+		FROM
+			quizzes
+		WHERE
+			is_archived = true AND
+			is_deleted = false
+		ORDER BY
+			active_from DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanQuizzesFromFullRows(rows)
+}
+
+// Periodically publishes quizzes whose publish_at has passed, and applies the end action of quizzes whose active_to has passed.
+// Several replicas may run the scheduler, a Postgres advisory lock ensures only one of them applies the actions at a time.
+//
+// Blocks until the context is cancelled, so it should be started in its own goroutine.
+func RunScheduler(ctx context.Context, db *sql.DB, publisher live_updates.Publisher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := runScheduledActions(ctx, db, publisher, time.Now().UTC())
+			if err != nil {
+				log.Println("quizzes: failed to run scheduled actions:", err)
+			}
+		}
+	}
+}
+
+// Applies all scheduled actions due at the given time in a single transaction.
+// Does nothing if another replica holds the scheduler lock.
+func runScheduledActions(ctx context.Context, db *sql.DB, publisher live_updates.Publisher, now time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The lock is released when the transaction ends.
+	var locked bool
+	err = tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, schedulerLockKey).Scan(&locked)
+	if err != nil || !locked {
+		return err
+	}
+
+	// Quizzes without questions can not be published, so they stay scheduled until questions are added.
+	published, err := queryIDs(tx,
+		`UPDATE quizzes
+		SET published = true, publish_at = NULL
+		WHERE publish_at <= $1 AND is_deleted = false AND is_template = false
+		AND EXISTS (SELECT 1 FROM questions q WHERE q.quiz_id = quizzes.id)
+		RETURNING id;`, now)
+	if err != nil {
+		return err
+	}
+
+	// End actions are cleared once applied, so a quiz published again by an editor stays published.
+	unpublished, err := queryIDs(tx,
+		`UPDATE quizzes
+		SET published = false, end_action = 'none'
+		WHERE end_action = 'unpublish' AND active_to <= $1 AND is_deleted = false
+		RETURNING id;`, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE quizzes
+		SET is_archived = true, end_action = 'none'
+		WHERE end_action = 'archive' AND active_to <= $1 AND is_deleted = false;`, now)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if publisher != nil {
+		for _, id := range published {
+			publisher.Publish(live_updates.Event{Type: live_updates.QuizPublished, QuizID: id})
+			publisher.Publish(live_updates.Event{Type: live_updates.ScoreboardChanged, QuizID: id})
+		}
+		for _, id := range unpublished {
+			publisher.Publish(live_updates.Event{Type: live_updates.QuizUnpublished, QuizID: id})
+			publisher.Publish(live_updates.Event{Type: live_updates.ScoreboardChanged, QuizID: id})
+		}
+	}
+	return nil
+}
+
+// Runs a query returning a single column of IDs.
+func queryIDs(tx *sql.Tx, query string, args ...any) ([]uuid.UUID, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
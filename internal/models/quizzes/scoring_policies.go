@@ -0,0 +1,110 @@
+package quizzes
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidScoringRule = errors.New("quizzes: invalid scoring rule")
+var ErrInvalidScoringPercent = errors.New("quizzes: scoring percent must be between 0 and 100")
+var ErrInvalidGraceSeconds = errors.New("quizzes: grace seconds too large")
+
+// The longest grace period a scoring policy can have. Longer than any question's time limit should need.
+const MaxGraceSeconds = 3600
+
+// How points are awarded for the answers in a quiz.
+type ScoringRule string
+
+const (
+	// Correct answers get the question's points within the grace period, then fewer the longer the user takes,
+	// down to the minimum points at the time limit.
+	ScoringRuleLinearDecay ScoringRule = "linear_decay"
+	// Correct answers get the question's points, however long the user takes.
+	ScoringRuleFlat ScoringRule = "flat"
+	// Like linear decay, with a bonus for each correct answer in a row before this one.
+	ScoringRuleStreak ScoringRule = "streak"
+	// Like linear decay, but wrong answers lose points.
+	ScoringRuleWrongAnswerPenalty ScoringRule = "wrong_answer_penalty"
+)
+
+// All scoring rules, in the order they are shown on the edit quiz page.
+var ScoringRules = []ScoringRule{ScoringRuleLinearDecay, ScoringRuleFlat, ScoringRuleStreak, ScoringRuleWrongAnswerPenalty}
+
+func (r ScoringRule) IsValid() bool {
+	switch r {
+	case ScoringRuleLinearDecay, ScoringRuleFlat, ScoringRuleStreak, ScoringRuleWrongAnswerPenalty:
+		return true
+	}
+	return false
+}
+
+// Whether correct answers get fewer points the longer the user takes.
+func (r ScoringRule) HasTimeDecay() bool {
+	return r != ScoringRuleFlat
+}
+
+// The scoring policy of a quiz. The points are calculated by the database, see the user_question_points view.
+type ScoringPolicy struct {
+	QuizID                    uuid.UUID
+	Rule                      ScoringRule
+	GraceSeconds              uint // Correct answers within the grace period get full points when they decay.
+	MinPointsPercent          uint // The least a correct answer gets when the points decay, in percent of the question's points.
+	StreakBonusPercent        uint // Bonus per correct answer in a row before this one with the streak rule. The bonus is at most 100%.
+	WrongAnswerPenaltyPercent uint // Points lost for a wrong answer with the wrong answer penalty rule, in percent of the question's points.
+}
+
+// Returns ErrInvalidScoringRule, ErrInvalidScoringPercent or ErrInvalidGraceSeconds if the policy can not be saved.
+func (p *ScoringPolicy) Validate() error {
+	if !p.Rule.IsValid() {
+		return ErrInvalidScoringRule
+	}
+	if p.MinPointsPercent > 100 || p.StreakBonusPercent > 100 || p.WrongAnswerPenaltyPercent > 100 {
+		return ErrInvalidScoringPercent
+	}
+	if p.GraceSeconds > MaxGraceSeconds {
+		return ErrInvalidGraceSeconds
+	}
+	return nil
+}
+
+// Returns the scoring policy of the quiz. Every quiz gets the default policy when it is created,
+// so sql.ErrNoRows means the quiz does not exist.
+func GetScoringPolicyByQuizID(db *sql.DB, quizID uuid.UUID) (*ScoringPolicy, error) {
+	policy := ScoringPolicy{QuizID: quizID}
+	err := db.QueryRow(
+		`SELECT rule, grace_seconds, min_points_percent, streak_bonus_percent, wrong_answer_penalty_percent
+		FROM quiz_scoring_policies
+		WHERE quiz_id = $1`,
+		quizID,
+	).Scan(
+		&policy.Rule,
+		&policy.GraceSeconds,
+		&policy.MinPointsPercent,
+		&policy.StreakBonusPercent,
+		&policy.WrongAnswerPenaltyPercent,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Saves the scoring policy of a quiz. The points of answers already given are recalculated with the new policy.
+// Returns ErrInvalidScoringRule or ErrInvalidScoringPercent if the policy is not valid.
+func UpdateScoringPolicy(db *sql.DB, policy *ScoringPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(
+		`INSERT INTO quiz_scoring_policies
+			(quiz_id, rule, grace_seconds, min_points_percent, streak_bonus_percent, wrong_answer_penalty_percent)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (quiz_id) DO UPDATE
+		SET rule = $2, grace_seconds = $3, min_points_percent = $4, streak_bonus_percent = $5, wrong_answer_penalty_percent = $6`,
+		policy.QuizID, policy.Rule, policy.GraceSeconds, policy.MinPointsPercent, policy.StreakBonusPercent,
+		policy.WrongAnswerPenaltyPercent)
+	return err
+}
@@ -0,0 +1,87 @@
+//go:build unit
+
+package quizzes_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/google/uuid"
+)
+
+// Returns a policy with the same settings as the database defaults.
+func validPolicy() quizzes.ScoringPolicy {
+	return quizzes.ScoringPolicy{
+		QuizID:                    uuid.New(),
+		Rule:                      quizzes.ScoringRuleLinearDecay,
+		GraceSeconds:              3,
+		MinPointsPercent:          20,
+		StreakBonusPercent:        10,
+		WrongAnswerPenaltyPercent: 20,
+	}
+}
+
+// TestScoringPolicyValidate tests that a policy with the default settings can be saved
+func TestScoringPolicyValidate(t *testing.T) {
+	policy := validPolicy()
+	if err := policy.Validate(); err != nil {
+		t.Errorf("Expected the policy to be valid, but got %v", err)
+	}
+}
+
+// TestScoringPolicyValidateRule tests that only the known rules are valid
+func TestScoringPolicyValidateRule(t *testing.T) {
+	for _, rule := range quizzes.ScoringRules {
+		policy := validPolicy()
+		policy.Rule = rule
+		if err := policy.Validate(); err != nil {
+			t.Errorf("Expected rule %s to be valid, but got %v", rule, err)
+		}
+	}
+
+	policy := validPolicy()
+	policy.Rule = "double_or_nothing"
+	if err := policy.Validate(); err != quizzes.ErrInvalidScoringRule {
+		t.Errorf("Expected %v, but got %v", quizzes.ErrInvalidScoringRule, err)
+	}
+}
+
+// TestScoringPolicyValidatePercent tests that percents above 100 are not valid
+func TestScoringPolicyValidatePercent(t *testing.T) {
+	settings := map[string]func(*quizzes.ScoringPolicy){
+		"min points":           func(p *quizzes.ScoringPolicy) { p.MinPointsPercent = 101 },
+		"streak bonus":         func(p *quizzes.ScoringPolicy) { p.StreakBonusPercent = 101 },
+		"wrong answer penalty": func(p *quizzes.ScoringPolicy) { p.WrongAnswerPenaltyPercent = 101 },
+	}
+	for name, set := range settings {
+		policy := validPolicy()
+		set(&policy)
+		if err := policy.Validate(); err != quizzes.ErrInvalidScoringPercent {
+			t.Errorf("Expected %v for %s, but got %v", quizzes.ErrInvalidScoringPercent, name, err)
+		}
+	}
+}
+
+// TestScoringPolicyValidateGraceSeconds tests that the grace period is limited, so it fits the database
+func TestScoringPolicyValidateGraceSeconds(t *testing.T) {
+	policy := validPolicy()
+	policy.GraceSeconds = quizzes.MaxGraceSeconds
+	if err := policy.Validate(); err != nil {
+		t.Errorf("Expected the longest grace period to be valid, but got %v", err)
+	}
+	policy.GraceSeconds = math.MaxUint32
+	if err := policy.Validate(); err != quizzes.ErrInvalidGraceSeconds {
+		t.Errorf("Expected %v, but got %v", quizzes.ErrInvalidGraceSeconds, err)
+	}
+}
+
+// TestScoringRuleHasTimeDecay tests that only the flat rule ignores the time spent
+func TestScoringRuleHasTimeDecay(t *testing.T) {
+	for _, rule := range quizzes.ScoringRules {
+		expected := rule != quizzes.ScoringRuleFlat
+		if rule.HasTimeDecay() != expected {
+			t.Errorf("Expected HasTimeDecay of %s to be %v", rule, expected)
+		}
+	}
+}
@@ -20,6 +20,8 @@ const (
 	SESSION_NAME = "session"
 	// USER_DATA_VALUE is the key for the user data in the session
 	USER_DATA_VALUE = "user"
+	// GUEST_ID_VALUE is the key for the ID of the guest session, for visitors who have not logged in
+	GUEST_ID_VALUE = "guest"
 )
 
 // Creates and sets up a new session store
@@ -0,0 +1,352 @@
+// Package achievements awards badges to users for completing quizzes and keeps track of their weekly streaks.
+// Everything is computed from the quizzes the users have completed, see the user_quizzes view.
+package achievements
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// A badge a user can be awarded when completing a quiz. Each badge is only awarded once,
+// except BadgeLabelCompleted which is awarded once for each label.
+type Badge string
+
+const (
+	// Completed a quiz for the first time.
+	BadgeFirstQuiz Badge = "first_quiz"
+	// Answered every question of a quiz correctly.
+	BadgePerfectScore Badge = "perfect_score"
+	// Answered every question of a quiz correctly within FastAnswerSeconds.
+	BadgeFastAnswers Badge = "fast_answers"
+	// Completed a quiz 4 weeks in a row.
+	BadgeStreak4Weeks Badge = "streak_4_weeks"
+	// Completed a quiz 12 weeks in a row.
+	BadgeStreak12Weeks Badge = "streak_12_weeks"
+	// Completed every published quiz with a label.
+	BadgeLabelCompleted Badge = "label_completed"
+)
+
+// All badges, in the order they are shown.
+var Badges = []Badge{
+	BadgeFirstQuiz, BadgePerfectScore, BadgeFastAnswers, BadgeStreak4Weeks, BadgeStreak12Weeks, BadgeLabelCompleted,
+}
+
+// The number of seconds each answer must be given within for BadgeFastAnswers.
+const FastAnswerSeconds = 5
+
+// Returns the name of the badge as shown to users.
+func (b Badge) Name() string {
+	switch b {
+	case BadgeFirstQuiz:
+		return "Første quiz"
+	case BadgePerfectScore:
+		return "Alt riktig"
+	case BadgeFastAnswers:
+		return "Lynrask"
+	case BadgeStreak4Weeks:
+		return "4 uker på rad"
+	case BadgeStreak12Weeks:
+		return "12 uker på rad"
+	case BadgeLabelCompleted:
+		return "Fullført kategori"
+	default:
+		return string(b)
+	}
+}
+
+// Returns a description of what the badge is awarded for, as shown to users.
+func (b Badge) Description() string {
+	switch b {
+	case BadgeFirstQuiz:
+		return "Fullfør din første quiz."
+	case BadgePerfectScore:
+		return "Svar riktig på alle spørsmålene i en quiz."
+	case BadgeFastAnswers:
+		return "Svar riktig på alle spørsmålene i en quiz innen 5 sekunder hver."
+	case BadgeStreak4Weeks:
+		return "Fullfør en quiz hver uke i 4 uker på rad."
+	case BadgeStreak12Weeks:
+		return "Fullfør en quiz hver uke i 12 uker på rad."
+	case BadgeLabelCompleted:
+		return "Fullfør alle quizene i en kategori."
+	default:
+		return ""
+	}
+}
+
+// A badge awarded to a user.
+type EarnedBadge struct {
+	Badge     Badge
+	LabelID   uuid.NullUUID // Only set for BadgeLabelCompleted.
+	LabelName string        // Empty unless the badge is BadgeLabelCompleted.
+	QuizID    uuid.NullUUID // The quiz which completion awarded the badge. Not set if the quiz is deleted.
+	AwardedAt time.Time
+}
+
+// The streaks and badges of a user, as shown on their profile.
+type Achievements struct {
+	CurrentWeekStreak int
+	LongestWeekStreak int
+	Badges            []EarnedBadge
+}
+
+// Whether the user has been awarded the badge. Labels are not considered.
+func (a *Achievements) HasBadge(badge Badge) bool {
+	for _, earned := range a.Badges {
+		if earned.Badge == badge {
+			return true
+		}
+	}
+	return false
+}
+
+// What a user has achieved by completing a quiz, used to decide which badges to award.
+type CompletionStats struct {
+	QuizID uuid.UUID
+	// When each of the user's completed quizzes were completed, including this one.
+	CompletedAt []time.Time
+	// Whether every question in the quiz was answered correctly.
+	PerfectScore bool
+	// Whether every question in the quiz was answered correctly within FastAnswerSeconds.
+	FastAnswers bool
+	// The labels of the quiz in which the user has now completed every published quiz.
+	CompletedLabels []CompletedLabel
+}
+
+type CompletedLabel struct {
+	ID   uuid.UUID
+	Name string
+}
+
+// Returns the badges the completion of a quiz qualifies for, whether or not the user already has them.
+func BadgesEarned(stats CompletionStats, now time.Time) []EarnedBadge {
+	quizID := uuid.NullUUID{UUID: stats.QuizID, Valid: true}
+	earned := []EarnedBadge{}
+	award := func(badge Badge) {
+		earned = append(earned, EarnedBadge{Badge: badge, QuizID: quizID, AwardedAt: now})
+	}
+
+	if len(stats.CompletedAt) > 0 {
+		award(BadgeFirstQuiz)
+	}
+	if stats.PerfectScore {
+		award(BadgePerfectScore)
+	}
+	if stats.FastAnswers {
+		award(BadgeFastAnswers)
+	}
+	_, longest := WeekStreaks(stats.CompletedAt, now)
+	if longest >= 4 {
+		award(BadgeStreak4Weeks)
+	}
+	if longest >= 12 {
+		award(BadgeStreak12Weeks)
+	}
+	for _, label := range stats.CompletedLabels {
+		earned = append(earned, EarnedBadge{
+			Badge:     BadgeLabelCompleted,
+			LabelID:   uuid.NullUUID{UUID: label.ID, Valid: true},
+			LabelName: label.Name,
+			QuizID:    quizID,
+			AwardedAt: now,
+		})
+	}
+	return earned
+}
+
+// Awards the badges the user has earned by completing the quiz, within the given transaction,
+// so the badges are only awarded if the answer completing the quiz is saved.
+// Does nothing if the user has not completed the quiz.
+//
+// Returns the badges the user did not already have.
+func AwardBadges(tx *sql.Tx, userID uuid.UUID, quizID uuid.UUID, now time.Time) ([]EarnedBadge, error) {
+	var isCompleted bool
+	err := tx.QueryRow(
+		`SELECT is_completed
+		FROM user_quizzes
+		WHERE user_id = $1 AND quiz_id = $2;`, userID, quizID,
+	).Scan(&isCompleted)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !isCompleted {
+		return nil, nil
+	}
+
+	stats, err := getCompletionStats(tx, userID, quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	awarded := []EarnedBadge{}
+	for _, badge := range BadgesEarned(*stats, now) {
+		result, err := tx.Exec(
+			`INSERT INTO user_badges (user_id, badge, label_id, quiz_id, awarded_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT ON CONSTRAINT user_badges_unique DO NOTHING;`,
+			userID, badge.Badge, badge.LabelID, badge.QuizID, badge.AwardedAt)
+		if err != nil {
+			return nil, err
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+			awarded = append(awarded, badge)
+		}
+	}
+	return awarded, nil
+}
+
+// Gets what the user has achieved by completing the quiz.
+func getCompletionStats(tx *sql.Tx, userID uuid.UUID, quizID uuid.UUID) (*CompletionStats, error) {
+	stats := CompletionStats{QuizID: quizID}
+
+	completedAt, err := getCompletionTimes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	stats.CompletedAt = completedAt
+
+	err = tx.QueryRow(
+		`SELECT
+			COUNT(*) FILTER (WHERE uqp.score < 1) = 0,
+			COUNT(*) FILTER (WHERE uqp.score < 1 OR ua.answered_at - ua.question_presented_at > make_interval(secs => $3)) = 0
+		FROM user_question_points uqp
+		JOIN user_answers ua ON ua.user_id = uqp.user_id AND ua.question_id = uqp.question_id
+		WHERE uqp.user_id = $1 AND uqp.quiz_id = $2;`, userID, quizID, FastAnswerSeconds,
+	).Scan(&stats.PerfectScore, &stats.FastAnswers)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(
+		`SELECT l.id, l.name
+		FROM labels l
+		JOIN quiz_labels ql ON ql.label_id = l.id
+		WHERE ql.quiz_id = $2
+		AND NOT EXISTS (
+			SELECT 1
+			FROM quiz_labels lq
+			JOIN quizzes q ON q.id = lq.quiz_id
+			WHERE lq.label_id = l.id
+			AND q.published = true
+			AND q.is_deleted = false
+			AND NOT EXISTS (
+				SELECT 1
+				FROM user_quizzes uq
+				WHERE uq.user_id = $1 AND uq.quiz_id = q.id AND uq.is_completed = true
+			)
+		)
+		ORDER BY l.name;`, userID, quizID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var label CompletedLabel
+		if err := rows.Scan(&label.ID, &label.Name); err != nil {
+			return nil, err
+		}
+		stats.CompletedLabels = append(stats.CompletedLabels, label)
+	}
+	return &stats, rows.Err()
+}
+
+// Either a database or a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Returns when each of the user's completed quizzes were completed. Only published quizzes which are not deleted count.
+func getCompletionTimes(q querier, userID uuid.UUID) ([]time.Time, error) {
+	rows, err := q.Query(
+		`SELECT uq.finished_at
+		FROM user_quizzes uq
+		JOIN quizzes q ON q.id = uq.quiz_id
+		WHERE uq.user_id = $1
+		AND uq.is_completed = true
+		AND q.published = true
+		AND q.is_deleted = false
+		ORDER BY uq.finished_at;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	completedAt := []time.Time{}
+	for rows.Next() {
+		var at time.Time
+		if err := rows.Scan(&at); err != nil {
+			return nil, err
+		}
+		completedAt = append(completedAt, at)
+	}
+	return completedAt, rows.Err()
+}
+
+// Returns the streaks and badges of the user.
+func GetAchievements(db *sql.DB, userID uuid.UUID, now time.Time) (*Achievements, error) {
+	completedAt, err := getCompletionTimes(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	achievements := Achievements{Badges: []EarnedBadge{}}
+	achievements.CurrentWeekStreak, achievements.LongestWeekStreak = WeekStreaks(completedAt, now)
+
+	rows, err := db.Query(
+		`SELECT ub.badge, ub.label_id, COALESCE(l.name, ''), ub.quiz_id, ub.awarded_at
+		FROM user_badges ub
+		LEFT JOIN labels l ON l.id = ub.label_id
+		WHERE ub.user_id = $1
+		ORDER BY ub.badge, l.name;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var badge EarnedBadge
+		if err := rows.Scan(&badge.Badge, &badge.LabelID, &badge.LabelName, &badge.QuizID, &badge.AwardedAt); err != nil {
+			return nil, err
+		}
+		achievements.Badges = append(achievements.Badges, badge)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &achievements, nil
+}
+
+// Returns the different badges each of the given users has, without when or for which quiz or label they were awarded,
+// so they can be shown on the scoreboard without revealing what the users have played.
+// Users without badges are not in the map.
+func GetBadgesByUsers(db *sql.DB, userIDs []uuid.UUID) (map[uuid.UUID][]Badge, error) {
+	ids := pq.StringArray{}
+	for _, id := range userIDs {
+		ids = append(ids, id.String())
+	}
+
+	rows, err := db.Query(
+		`SELECT DISTINCT user_id, badge
+		FROM user_badges
+		WHERE user_id = ANY($1::uuid[])
+		ORDER BY user_id, badge;`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	badges := make(map[uuid.UUID][]Badge)
+	for rows.Next() {
+		var userID uuid.UUID
+		var badge Badge
+		if err := rows.Scan(&userID, &badge); err != nil {
+			return nil, err
+		}
+		badges[userID] = append(badges[userID], badge)
+	}
+	return badges, rows.Err()
+}
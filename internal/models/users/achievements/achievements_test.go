@@ -0,0 +1,138 @@
+//go:build unit
+
+package achievements_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/achievements"
+	"github.com/google/uuid"
+)
+
+var oslo, _ = time.LoadLocation("Europe/Oslo")
+
+// Returns noon on the given date in Norway.
+func day(year int, month time.Month, date int) time.Time {
+	return time.Date(year, month, date, 12, 0, 0, 0, oslo)
+}
+
+func TestWeekStreaks(t *testing.T) {
+	// Monday 2024-03-04 is the first day of week 10
+	now := day(2024, time.March, 6)
+
+	tests := []struct {
+		name        string
+		completedAt []time.Time
+		current     int
+		longest     int
+	}{
+		{"no quizzes", []time.Time{}, 0, 0},
+		{"this week", []time.Time{day(2024, time.March, 4)}, 1, 1},
+		{"several in one week", []time.Time{day(2024, time.March, 4), day(2024, time.March, 5), day(2024, time.March, 6)}, 1, 1},
+		{"last week keeps streak", []time.Time{day(2024, time.February, 19), day(2024, time.February, 26)}, 2, 2},
+		{"two weeks ago breaks streak", []time.Time{day(2024, time.February, 12), day(2024, time.February, 19)}, 0, 2},
+		{"gap", []time.Time{
+			day(2024, time.February, 5), day(2024, time.February, 12), day(2024, time.February, 19),
+			day(2024, time.March, 4),
+		}, 1, 3},
+		{"unsorted", []time.Time{day(2024, time.March, 4), day(2024, time.February, 19), day(2024, time.February, 26)}, 3, 3},
+		{"sunday evening and monday morning", []time.Time{
+			time.Date(2024, time.February, 25, 23, 30, 0, 0, oslo),
+			time.Date(2024, time.February, 26, 0, 30, 0, 0, oslo),
+		}, 2, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current, longest := achievements.WeekStreaks(test.completedAt, now)
+			if current != test.current || longest != test.longest {
+				t.Errorf("expected current %d and longest %d, got %d and %d", test.current, test.longest, current, longest)
+			}
+		})
+	}
+}
+
+func TestWeekStreaksUsesNorwayTime(t *testing.T) {
+	// Monday 00:30 in Norway is still Sunday in UTC
+	mondayInNorway := time.Date(2024, time.February, 25, 23, 30, 0, 0, time.UTC)
+	current, _ := achievements.WeekStreaks([]time.Time{mondayInNorway}, day(2024, time.March, 6))
+	if current != 1 {
+		t.Errorf("expected the quiz to count for the week starting 2024-02-26, got current streak %d", current)
+	}
+}
+
+func TestWeekStreaksAcrossDaylightSavingTime(t *testing.T) {
+	// Daylight saving time starts on 2024-03-31
+	completedAt := []time.Time{day(2024, time.March, 25), day(2024, time.April, 1), day(2024, time.April, 8)}
+	current, longest := achievements.WeekStreaks(completedAt, day(2024, time.April, 9))
+	if current != 3 || longest != 3 {
+		t.Errorf("expected current and longest streak of 3, got %d and %d", current, longest)
+	}
+}
+
+func badgesOf(earned []achievements.EarnedBadge) []achievements.Badge {
+	badges := []achievements.Badge{}
+	for _, badge := range earned {
+		badges = append(badges, badge.Badge)
+	}
+	return badges
+}
+
+func TestBadgesEarned(t *testing.T) {
+	now := day(2024, time.March, 6)
+	weeksInARow := func(weeks int) []time.Time {
+		completedAt := []time.Time{}
+		for i := weeks - 1; i >= 0; i-- {
+			completedAt = append(completedAt, now.AddDate(0, 0, -7*i))
+		}
+		return completedAt
+	}
+
+	tests := []struct {
+		name     string
+		stats    achievements.CompletionStats
+		expected []achievements.Badge
+	}{
+		{"first quiz", achievements.CompletionStats{CompletedAt: weeksInARow(1)},
+			[]achievements.Badge{achievements.BadgeFirstQuiz}},
+		{"perfect score", achievements.CompletionStats{CompletedAt: weeksInARow(1), PerfectScore: true},
+			[]achievements.Badge{achievements.BadgeFirstQuiz, achievements.BadgePerfectScore}},
+		{"fast answers", achievements.CompletionStats{CompletedAt: weeksInARow(1), PerfectScore: true, FastAnswers: true},
+			[]achievements.Badge{achievements.BadgeFirstQuiz, achievements.BadgePerfectScore, achievements.BadgeFastAnswers}},
+		{"3 weeks", achievements.CompletionStats{CompletedAt: weeksInARow(3)},
+			[]achievements.Badge{achievements.BadgeFirstQuiz}},
+		{"4 weeks", achievements.CompletionStats{CompletedAt: weeksInARow(4)},
+			[]achievements.Badge{achievements.BadgeFirstQuiz, achievements.BadgeStreak4Weeks}},
+		{"12 weeks", achievements.CompletionStats{CompletedAt: weeksInARow(12)},
+			[]achievements.Badge{achievements.BadgeFirstQuiz, achievements.BadgeStreak4Weeks, achievements.BadgeStreak12Weeks}},
+		{"labels", achievements.CompletionStats{
+			CompletedAt:     weeksInARow(1),
+			CompletedLabels: []achievements.CompletedLabel{{ID: uuid.New(), Name: "Sport"}, {ID: uuid.New(), Name: "Kultur"}},
+		}, []achievements.Badge{achievements.BadgeFirstQuiz, achievements.BadgeLabelCompleted, achievements.BadgeLabelCompleted}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.stats.QuizID = uuid.New()
+			earned := achievements.BadgesEarned(test.stats, now)
+			badges := badgesOf(earned)
+			if len(badges) != len(test.expected) {
+				t.Fatalf("expected badges %v, got %v", test.expected, badges)
+			}
+			for i := range badges {
+				if badges[i] != test.expected[i] {
+					t.Fatalf("expected badges %v, got %v", test.expected, badges)
+				}
+			}
+			for _, badge := range earned {
+				if badge.QuizID.UUID != test.stats.QuizID || !badge.AwardedAt.Equal(now) {
+					t.Errorf("expected badge %s to be awarded for the quiz at %v, got %v at %v", badge.Badge, now, badge.QuizID, badge.AwardedAt)
+				}
+				if badge.LabelID.Valid != (badge.Badge == achievements.BadgeLabelCompleted) {
+					t.Errorf("expected only %s to have a label, got %s with label %v", achievements.BadgeLabelCompleted, badge.Badge, badge.LabelID)
+				}
+			}
+		})
+	}
+}
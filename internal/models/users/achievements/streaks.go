@@ -0,0 +1,53 @@
+package achievements
+
+import (
+	"sort"
+	"time"
+
+	data_handling "github.com/Molnes/Nyhetsjeger/internal/utils/data"
+)
+
+// Returns the start of the week (Monday 00:00) containing the given time, in Norway's timezone.
+func startOfWeek(at time.Time) time.Time {
+	norwayTime := data_handling.GetNorwayTime(at)
+	daysSinceMonday := (int(norwayTime.Weekday()) + 6) % 7
+	return time.Date(norwayTime.Year(), norwayTime.Month(), norwayTime.Day()-daysSinceMonday, 0, 0, 0, 0, norwayTime.Location())
+}
+
+// Returns the current and the longest number of consecutive weeks with a completed quiz,
+// given the times the quizzes were completed.
+//
+// The current streak is kept alive through the week after the last completed quiz,
+// so it is not broken before the user has had a whole week to complete a new quiz.
+func WeekStreaks(completedAt []time.Time, now time.Time) (current int, longest int) {
+	weeks := make([]time.Time, 0, len(completedAt))
+	seen := make(map[time.Time]bool)
+	for _, at := range completedAt {
+		week := startOfWeek(at)
+		if !seen[week] {
+			seen[week] = true
+			weeks = append(weeks, week)
+		}
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].Before(weeks[j]) })
+
+	streak := 0
+	for i, week := range weeks {
+		// Adding 7 days instead of 168 hours keeps the weeks aligned across daylight saving time
+		if i > 0 && weeks[i-1].AddDate(0, 0, 7).Equal(week) {
+			streak++
+		} else {
+			streak = 1
+		}
+		longest = max(longest, streak)
+	}
+
+	if len(weeks) > 0 {
+		thisWeek := startOfWeek(now)
+		lastWeek := weeks[len(weeks)-1]
+		if lastWeek.Equal(thisWeek) || lastWeek.AddDate(0, 0, 7).Equal(thisWeek) {
+			current = streak
+		}
+	}
+	return current, longest
+}
@@ -0,0 +1,133 @@
+// Package guest_sessions keeps track of anonymous guests playing the open quiz, so their answers can be stored on the server.
+package guest_sessions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/achievements"
+	"github.com/google/uuid"
+)
+
+var ErrNoSuchSession = errors.New("guest_sessions: no such guest session")
+
+const (
+	GUEST_ID_CONTEXT_KEY = "guestID" // The key used to store the guest session ID in the context
+
+	// Guest sessions not seen for this long are deleted, along with their answers.
+	MaxInactivity = 30 * 24 * time.Hour
+)
+
+// Creates a new guest session and returns its ID.
+// Also deletes guest sessions which have been inactive for longer than MaxInactivity.
+func Create(db *sql.DB) (uuid.UUID, error) {
+	_, err := db.Exec(
+		`DELETE FROM guest_sessions WHERE last_seen_at < $1;`,
+		time.Now().Add(-MaxInactivity))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var id uuid.UUID
+	err = db.QueryRow(
+		`INSERT INTO guest_sessions DEFAULT VALUES
+		RETURNING id;`).Scan(&id)
+	return id, err
+}
+
+// Marks the guest session as active.
+// Returns ErrNoSuchSession if it does not exist, e.g. because it was deleted for being inactive.
+func Touch(db *sql.DB, id uuid.UUID) error {
+	result, err := db.Exec(
+		`UPDATE guest_sessions
+		SET last_seen_at = now()
+		WHERE id = $1 AND last_seen_at >= $2;`,
+		id, time.Now().Add(-MaxInactivity))
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoSuchSession
+	}
+	return nil
+}
+
+// Moves the answers of the guest session to the user, and deletes the guest session.
+//
+// Answers are only moved for quizzes the user has not started, so a guest can not overwrite
+// or add to what the user has already answered. Answers to quizzes that have not ended are kept out too,
+// as a guest can rehearse a quiz open for guests before playing it for the ranking. The user is awarded the badges earned by the quizzes
+// the guest completed. Returns the number of answers moved.
+func MergeIntoUser(ctx context.Context, db *sql.DB, guestID uuid.UUID, userID uuid.UUID) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`WITH merged AS (
+		INSERT INTO user_answers
+		(user_id, question_id, question_presented_at, chosen_answer_alternative_id, chosen_alternative_ids, numeric_answer, answered_at, timed_out)
+		SELECT $2, ga.question_id, ga.question_presented_at, ga.chosen_answer_alternative_id, ga.chosen_alternative_ids,
+			ga.numeric_answer, ga.answered_at, ga.timed_out
+		FROM guest_answers ga
+		JOIN questions q ON ga.question_id = q.id
+		JOIN quizzes qz ON q.quiz_id = qz.id
+		WHERE ga.guest_session_id = $1
+		AND qz.active_to < NOW()
+		AND NOT EXISTS (
+			SELECT 1
+			FROM user_answers ua
+			JOIN questions uq ON ua.question_id = uq.id
+			WHERE ua.user_id = $2
+			AND uq.quiz_id = q.quiz_id
+		)
+		ON CONFLICT DO NOTHING
+		RETURNING question_id
+		)
+		SELECT q.quiz_id, COUNT(*)
+		FROM merged m
+		JOIN questions q ON m.question_id = q.id
+		GROUP BY q.quiz_id;`,
+		guestID, userID)
+	if err != nil {
+		return 0, err
+	}
+	var merged int64
+	quizIDs := []uuid.UUID{}
+	for rows.Next() {
+		var quizID uuid.UUID
+		var count int64
+		if err := rows.Scan(&quizID, &count); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		quizIDs = append(quizIDs, quizID)
+		merged += count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	for _, quizID := range quizIDs {
+		if _, err := achievements.AwardBadges(tx, userID, quizID, now); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM guest_sessions WHERE id = $1;`, guestID)
+	if err != nil {
+		return 0, err
+	}
+
+	return merged, tx.Commit()
+}
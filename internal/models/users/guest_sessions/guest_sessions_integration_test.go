@@ -0,0 +1,157 @@
+//go:build integration
+
+package guest_sessions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
+	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/achievements"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_ranking"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type GuestSessionsIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestGuestSessionsIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(GuestSessionsIntegrationTestSuite))
+}
+
+// Presents the first question of the quiz to the guest and answers it correctly.
+func (s *GuestSessionsIntegrationTestSuite) answerFirstQuestion(guestID uuid.UUID, quizID uuid.UUID) *user_quiz.UserAnsweredQuestion {
+	data, err := user_quiz.NextQuestionInQuizGuest(s.DB, guestID, quizID, user_quiz.DefaultAnswerGracePeriod)
+	s.Require().NoError(err)
+
+	var correctID uuid.UUID
+	for _, alternative := range data.CurrentQuestion.Alternatives {
+		if alternative.IsCorrect {
+			correctID = alternative.ID
+		}
+	}
+	answer := questions.Answer{AlternativeIDs: []uuid.UUID{correctID}}
+	answered, err := user_quiz.AnswerQuestionGuest(s.DB, guestID, data.CurrentQuestion.ID, answer, user_quiz.DefaultAnswerGracePeriod)
+	s.Require().NoError(err)
+	return answered
+}
+
+// Plays the whole quiz as the guest, answering every question correctly.
+func (s *GuestSessionsIntegrationTestSuite) completeQuiz(guestID uuid.UUID, quizID uuid.UUID) {
+	for {
+		_, err := user_quiz.NextQuestionInQuizGuest(s.DB, guestID, quizID, user_quiz.DefaultAnswerGracePeriod)
+		if err == user_quiz.ErrNoMoreQuestions {
+			return
+		}
+		s.Require().NoError(err)
+		s.answerFirstQuestion(guestID, quizID)
+	}
+}
+
+// Ends the quiz, so guest answers to it can be merged.
+func (s *GuestSessionsIntegrationTestSuite) endQuiz(quizID uuid.UUID) {
+	_, err := s.DB.Exec(`UPDATE quizzes SET active_to = now() WHERE id = $1`, quizID)
+	s.Require().NoError(err)
+}
+
+func (s *GuestSessionsIntegrationTestSuite) TestGuestAnswerOnce() {
+	guestID, err := Create(s.DB)
+	s.Require().NoError(err)
+
+	answered := s.answerFirstQuestion(guestID, s.InsertedValues.QuizId1)
+	s.Require().Greater(answered.PointsAwarded, 0)
+
+	_, err = user_quiz.AnswerQuestionGuest(s.DB, guestID, answered.Question.ID, answered.Answer, user_quiz.DefaultAnswerGracePeriod)
+	s.Require().ErrorIs(err, user_quiz.ErrQuestionAlreadyAnswered)
+
+	data, err := user_quiz.NextQuestionInQuizGuest(s.DB, guestID, s.InsertedValues.QuizId1, user_quiz.DefaultAnswerGracePeriod)
+	s.Require().NoError(err)
+	s.Require().Equal(answered.NextQuestionID, data.CurrentQuestion.ID)
+	s.Require().Equal(answered.PointsAwarded, data.PointsGathered)
+}
+
+func (s *GuestSessionsIntegrationTestSuite) TestMergeIntoUser() {
+	guestID, err := Create(s.DB)
+	s.Require().NoError(err)
+	answered := s.answerFirstQuestion(guestID, s.InsertedValues.QuizId1)
+	s.endQuiz(s.InsertedValues.QuizId1)
+
+	merged, err := MergeIntoUser(context.Background(), s.DB, guestID, s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(1), merged)
+
+	var chosenID uuid.UUID
+	err = s.DB.QueryRow(
+		`SELECT chosen_answer_alternative_id FROM user_answers WHERE user_id = $1 AND question_id = $2`,
+		s.InsertedValues.UserId, answered.Question.ID).Scan(&chosenID)
+	s.Require().NoError(err)
+	s.Require().Equal(answered.Answer.AlternativeIDs[0], chosenID)
+
+	s.Require().ErrorIs(Touch(s.DB, guestID), ErrNoSuchSession)
+}
+
+func (s *GuestSessionsIntegrationTestSuite) TestMergeAwardsBadges() {
+	guestID, err := Create(s.DB)
+	s.Require().NoError(err)
+	s.completeQuiz(guestID, s.InsertedValues.QuizId1)
+	s.endQuiz(s.InsertedValues.QuizId1)
+
+	_, err = MergeIntoUser(context.Background(), s.DB, guestID, s.InsertedValues.UserId)
+	s.Require().NoError(err)
+
+	userAchievements, err := achievements.GetAchievements(s.DB, s.InsertedValues.UserId, time.Now())
+	s.Require().NoError(err)
+	s.Require().True(userAchievements.HasBadge(achievements.BadgeFirstQuiz))
+}
+
+func (s *GuestSessionsIntegrationTestSuite) TestMergeSkipsStartedQuiz() {
+	_, err := user_quiz.NextQuestionInQuiz(s.DB, s.InsertedValues.UserId, s.InsertedValues.QuizId1, user_quiz.DefaultAnswerGracePeriod)
+	s.Require().NoError(err)
+
+	guestID, err := Create(s.DB)
+	s.Require().NoError(err)
+	s.answerFirstQuestion(guestID, s.InsertedValues.QuizId1)
+	s.answerFirstQuestion(guestID, s.InsertedValues.Quiz2Id2)
+	s.endQuiz(s.InsertedValues.QuizId1)
+	s.endQuiz(s.InsertedValues.Quiz2Id2)
+
+	merged, err := MergeIntoUser(context.Background(), s.DB, guestID, s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(1), merged)
+}
+
+func (s *GuestSessionsIntegrationTestSuite) TestMergeSkipsLiveQuiz() {
+	labelID, err := labels.CreateLabel(s.DB, "Gjestequiz")
+	s.Require().NoError(err)
+	s.Require().NoError(labels.AddLabelToQuiz(s.DB, s.InsertedValues.QuizId1, labelID))
+	rankingBefore, err := user_ranking.GetRanking(s.DB, labelID)
+	s.Require().NoError(err)
+
+	guestID, err := Create(s.DB)
+	s.Require().NoError(err)
+	s.completeQuiz(guestID, s.InsertedValues.QuizId1)
+
+	merged, err := MergeIntoUser(context.Background(), s.DB, guestID, s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(0), merged)
+
+	rankingAfter, err := user_ranking.GetRanking(s.DB, labelID)
+	s.Require().NoError(err)
+	s.Require().Equal(rankingBefore, rankingAfter)
+}
+
+func (s *GuestSessionsIntegrationTestSuite) TestTouchInactive() {
+	guestID, err := Create(s.DB)
+	s.Require().NoError(err)
+	s.Require().NoError(Touch(s.DB, guestID))
+
+	_, err = s.DB.Exec(`UPDATE guest_sessions SET last_seen_at = now() - interval '31 days' WHERE id = $1`, guestID)
+	s.Require().NoError(err)
+	s.Require().ErrorIs(Touch(s.DB, guestID), ErrNoSuchSession)
+}
@@ -0,0 +1,416 @@
+// Package user_data_export collects the data stored about a user, so they can download it.
+package user_data_export
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/achievements"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz_summary"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_ranking"
+	"github.com/google/uuid"
+)
+
+// Everything stored about a user.
+type UserDataExport struct {
+	ExportedAt    time.Time             `json:"exportedAt"`
+	User          ExportedUser          `json:"user"`
+	Answers       []ExportedAnswer      `json:"answers"`
+	QuizSummaries []ExportedQuizSummary `json:"quizSummaries"`
+	Rankings      []ExportedRanking     `json:"rankings"`
+	Badges        []ExportedBadge       `json:"badges"`
+	Sessions      []ExportedSession     `json:"sessions"`
+
+	CompetitionResults   []ExportedCompetitionResult   `json:"competitionResults"`
+	PrizeDraws           []ExportedPrizeDrawEntry      `json:"prizeDraws"`
+	UsernameReservations []ExportedUsernameReservation `json:"usernameReservations"`
+}
+
+type ExportedUser struct {
+	ID            uuid.UUID `json:"id"`
+	LoginProvider string    `json:"loginProvider"`
+	LoginID       string    `json:"loginId"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	Phone         string    `json:"phone"`
+	OptInRanking  bool      `json:"optInRanking"`
+	AcceptedTerms bool      `json:"acceptedTerms"`
+	Role          string    `json:"role"`
+	// Whether tokens from the login provider are stored. The tokens themselves are encrypted and not exported.
+	HasStoredLoginToken bool `json:"hasStoredLoginToken"`
+}
+
+type ExportedAnswer struct {
+	QuizID                uuid.UUID  `json:"quizId"`
+	QuizTitle             string     `json:"quizTitle"`
+	QuestionID            uuid.UUID  `json:"questionId"`
+	QuestionText          string     `json:"questionText"`
+	ChosenAlternativeText string     `json:"chosenAlternativeText"` // Describes the answer of any type of question.
+	IsCorrect             bool       `json:"isCorrect"`
+	Score                 float64    `json:"score"`
+	PointsAwarded         int        `json:"pointsAwarded"`
+	QuestionPresentedAt   time.Time  `json:"questionPresentedAt"`
+	AnsweredAt            *time.Time `json:"answeredAt"`
+}
+
+type ExportedQuizSummary struct {
+	QuizID            uuid.UUID                            `json:"quizId"`
+	QuizTitle         string                               `json:"quizTitle"`
+	MaxScore          uint                                 `json:"maxScore"`
+	AchievedScore     int                                  `json:"achievedScore"`
+	AnsweredQuestions []user_quiz_summary.AnsweredQuestion `json:"answeredQuestions"`
+}
+
+// Placement of the user in a ranking. Label is empty for the ranking across all labels.
+type ExportedRanking struct {
+	Label     string `json:"label"`
+	Period    string `json:"period"`
+	Points    int    `json:"points"`
+	Placement int    `json:"placement"`
+}
+
+// A badge awarded to the user. Label is empty unless the badge is for completing a label.
+type ExportedBadge struct {
+	Badge     string     `json:"badge"`
+	Label     string     `json:"label"`
+	QuizID    *uuid.UUID `json:"quizId"`
+	AwardedAt time.Time  `json:"awardedAt"`
+}
+
+type ExportedSession struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// The user's place in the frozen standings of a competition, with the username it was frozen with.
+type ExportedCompetitionResult struct {
+	Competition      string `json:"competition"`
+	Username         string `json:"username"`
+	Points           int    `json:"points"`
+	Placement        int    `json:"placement"`
+	QuizzesCompleted int    `json:"quizzesCompleted"`
+}
+
+// A prize draw the user took part in. WinnerPosition is 0 if the user did not win.
+type ExportedPrizeDrawEntry struct {
+	DrawID           uuid.UUID `json:"drawId"`
+	Label            string    `json:"label"`
+	DrawnAt          time.Time `json:"drawnAt"`
+	Points           int       `json:"points"`
+	QuizzesCompleted int       `json:"quizzesCompleted"`
+	Won              bool      `json:"won"`
+	WinnerPosition   int       `json:"winnerPosition"`
+}
+
+// A username the user has picked but not yet saved.
+type ExportedUsernameReservation struct {
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+var exportedDateRanges = []user_ranking.DateRange{user_ranking.Month, user_ranking.Year, user_ranking.All}
+
+// Collects the data stored about the given user.
+// The session with the given key is included, as sessions can not be looked up by user.
+//
+// Returns sql.ErrNoRows if the user does not exist.
+func Export(db *sql.DB, userID uuid.UUID, sessionKey string) (*UserDataExport, error) {
+	user, err := users.GetUserByID(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := UserDataExport{
+		ExportedAt: time.Now().UTC(),
+		User: ExportedUser{
+			ID:            user.ID,
+			LoginProvider: user.SsoProvider,
+			LoginID:       user.SsoID,
+			Username:      user.Username,
+			Email:         user.Email,
+			Phone:         user.Phone,
+			OptInRanking:  user.OptInRanking,
+			AcceptedTerms: user.AcceptedTerms,
+			Role:          user.Role.String(),
+		},
+	}
+
+	err = db.QueryRow(
+		`SELECT access_token IS NOT NULL
+		FROM users
+		WHERE id = $1;`, userID).Scan(&export.User.HasStoredLoginToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if export.Answers, err = getAnswers(db, userID); err != nil {
+		return nil, err
+	}
+	if export.QuizSummaries, err = getQuizSummaries(db, userID); err != nil {
+		return nil, err
+	}
+	if export.Rankings, err = getRankings(db, userID, export.ExportedAt); err != nil {
+		return nil, err
+	}
+	if export.Badges, err = getBadges(db, userID, export.ExportedAt); err != nil {
+		return nil, err
+	}
+	if export.Sessions, err = getSessions(db, sessionKey); err != nil {
+		return nil, err
+	}
+	if export.CompetitionResults, err = getCompetitionResults(db, userID); err != nil {
+		return nil, err
+	}
+	if export.PrizeDraws, err = getPrizeDrawEntries(db, userID); err != nil {
+		return nil, err
+	}
+	if export.UsernameReservations, err = getUsernameReservations(db, userID); err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+// Returns the badges awarded to the user.
+func getBadges(db *sql.DB, userID uuid.UUID, at time.Time) ([]ExportedBadge, error) {
+	userAchievements, err := achievements.GetAchievements(db, userID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	badges := []ExportedBadge{}
+	for _, badge := range userAchievements.Badges {
+		exported := ExportedBadge{
+			Badge:     string(badge.Badge),
+			Label:     badge.LabelName,
+			AwardedAt: badge.AwardedAt,
+		}
+		if badge.QuizID.Valid {
+			exported.QuizID = &badge.QuizID.UUID
+		}
+		badges = append(badges, exported)
+	}
+	return badges, nil
+}
+
+// Returns all answers of the user, with the text of the question and the answer.
+func getAnswers(db *sql.DB, userID uuid.UUID) ([]ExportedAnswer, error) {
+	rows, err := db.Query(
+		`SELECT q.quiz_id, qz.title, ua.question_id, q.question,
+		answer_text(q.question_type, ua.chosen_answer_alternative_id, ua.chosen_alternative_ids, ua.numeric_answer, q.numeric_unit),
+		COALESCE(uqp.score >= 1, false), COALESCE(uqp.score, 0),
+		COALESCE(uqp.points_awarded, 0), ua.question_presented_at, ua.answered_at
+		FROM user_answers ua
+		JOIN questions q ON ua.question_id = q.id
+		JOIN quizzes qz ON q.quiz_id = qz.id
+		LEFT JOIN user_question_points uqp ON uqp.user_id = ua.user_id AND uqp.question_id = ua.question_id
+		WHERE ua.user_id = $1
+		ORDER BY ua.question_presented_at;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answers := []ExportedAnswer{}
+	for rows.Next() {
+		var answer ExportedAnswer
+		var answeredAt sql.NullTime
+		err := rows.Scan(
+			&answer.QuizID,
+			&answer.QuizTitle,
+			&answer.QuestionID,
+			&answer.QuestionText,
+			&answer.ChosenAlternativeText,
+			&answer.IsCorrect,
+			&answer.Score,
+			&answer.PointsAwarded,
+			&answer.QuestionPresentedAt,
+			&answeredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if answeredAt.Valid {
+			answer.AnsweredAt = &answeredAt.Time
+		}
+		answers = append(answers, answer)
+	}
+	return answers, rows.Err()
+}
+
+// Returns the summaries of all quizzes the user has completed.
+func getQuizSummaries(db *sql.DB, userID uuid.UUID) ([]ExportedQuizSummary, error) {
+	rows, err := db.Query(
+		`SELECT quiz_id
+		FROM user_quizzes
+		WHERE user_id = $1 AND is_completed = true;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	quizIDs := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		quizIDs = append(quizIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	summaries := []ExportedQuizSummary{}
+	for _, quizID := range quizIDs {
+		summary, err := user_quiz_summary.GetQuizSummary(db, userID, quizID)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, ExportedQuizSummary{
+			QuizID:            summary.QuizID,
+			QuizTitle:         summary.QuizTitle,
+			MaxScore:          summary.MaxScore,
+			AchievedScore:     summary.AchievedScore,
+			AnsweredQuestions: summary.AnsweredQuestions,
+		})
+	}
+	return summaries, nil
+}
+
+// Returns the placements of the user in the rankings across all labels and in each label,
+// for the month and year containing the given time and for all time.
+// Rankings the user is not part of are left out.
+func getRankings(db *sql.DB, userID uuid.UUID, at time.Time) ([]ExportedRanking, error) {
+	allLabels, err := labels.GetLabels(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rankings := []ExportedRanking{}
+	add := func(labelName string, dateRange user_ranking.DateRange, ranking user_ranking.UserRanking, err error) error {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rankings = append(rankings, ExportedRanking{
+			Label:     labelName,
+			Period:    dateRange.String(),
+			Points:    ranking.Points,
+			Placement: ranking.Placement,
+		})
+		return nil
+	}
+
+	for _, dateRange := range exportedDateRanges {
+		ranking, err := user_ranking.GetUserRankingAllLabelsInRange(db, userID, dateRange, at)
+		if err := add("", dateRange, ranking, err); err != nil {
+			return nil, err
+		}
+		for _, label := range allLabels {
+			ranking, err := user_ranking.GetUserRankingInRange(db, userID, label, dateRange, at)
+			if err := add(label.Name, dateRange, ranking, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rankings, nil
+}
+
+// Returns the stored session with the given key, if any.
+func getSessions(db *sql.DB, sessionKey string) ([]ExportedSession, error) {
+	sessions := []ExportedSession{}
+	if sessionKey == "" {
+		return sessions, nil
+	}
+
+	var session ExportedSession
+	err := db.QueryRow(
+		`SELECT created_on, expires_on
+		FROM http_sessions
+		WHERE key = $1;`, sessionKey).Scan(&session.CreatedAt, &session.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(sessions, session), nil
+}
+
+// Returns the frozen results of the competitions the user placed in.
+func getCompetitionResults(db *sql.DB, userID uuid.UUID) ([]ExportedCompetitionResult, error) {
+	rows, err := db.Query(
+		`SELECT l.name, COALESCE(cr.username, ''), cr.points, cr.placement, cr.quizzes_completed
+		FROM competition_results cr
+		JOIN labels l ON l.id = cr.label_id
+		WHERE cr.user_id = $1
+		ORDER BY l.ends_at;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []ExportedCompetitionResult{}
+	for rows.Next() {
+		var result ExportedCompetitionResult
+		err := rows.Scan(&result.Competition, &result.Username, &result.Points, &result.Placement, &result.QuizzesCompleted)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// Returns the prize draws the user was an entrant in, including the ones they won.
+func getPrizeDrawEntries(db *sql.DB, userID uuid.UUID) ([]ExportedPrizeDrawEntry, error) {
+	rows, err := db.Query(
+		`SELECT d.id, d.label_name, d.created_at, e.points, e.quizzes_completed, COALESCE(e.winner_position, 0)
+		FROM prize_draw_entrants e
+		JOIN prize_draws d ON d.id = e.draw_id
+		WHERE e.user_id = $1
+		ORDER BY d.created_at;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []ExportedPrizeDrawEntry{}
+	for rows.Next() {
+		var entry ExportedPrizeDrawEntry
+		err := rows.Scan(&entry.DrawID, &entry.Label, &entry.DrawnAt, &entry.Points, &entry.QuizzesCompleted, &entry.WinnerPosition)
+		if err != nil {
+			return nil, err
+		}
+		entry.Won = entry.WinnerPosition > 0
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Returns the username the user has reserved, if any. Expired reservations are included until they are taken over.
+func getUsernameReservations(db *sql.DB, userID uuid.UUID) ([]ExportedUsernameReservation, error) {
+	rows, err := db.Query(
+		`SELECT CONCAT(adjective, ' ', noun), expires_at
+		FROM username_reservations
+		WHERE user_id = $1;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservations := []ExportedUsernameReservation{}
+	for rows.Next() {
+		var reservation ExportedUsernameReservation
+		if err := rows.Scan(&reservation.Username, &reservation.ExpiresAt); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+	return reservations, rows.Err()
+}
@@ -0,0 +1,86 @@
+//go:build integration
+
+package user_data_export
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
+	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/usernames"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type UserDataExportIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestUserDataExportIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(UserDataExportIntegrationTestSuite))
+}
+
+func (s *UserDataExportIntegrationTestSuite) TestExport() {
+	data, err := user_quiz.NextQuestionInQuiz(s.DB, s.InsertedValues.UserId, s.InsertedValues.QuizId1, user_quiz.DefaultAnswerGracePeriod)
+	s.Require().NoError(err)
+	chosen := data.CurrentQuestion.Alternatives[0]
+	_, err = user_quiz.AnswerQuestion(s.DB, nil, s.InsertedValues.UserId, data.CurrentQuestion.ID,
+		questions.Answer{AlternativeIDs: []uuid.UUID{chosen.ID}}, user_quiz.DefaultAnswerGracePeriod)
+	s.Require().NoError(err)
+
+	export, err := Export(s.DB, s.InsertedValues.UserId, "")
+	s.Require().NoError(err)
+	s.Require().Equal(s.InsertedValues.UserId, export.User.ID)
+	s.Require().Empty(export.Sessions)
+
+	s.Require().Len(export.Answers, 1)
+	answer := export.Answers[0]
+	s.Require().Equal(s.InsertedValues.QuizId1, answer.QuizID)
+	s.Require().Equal(data.CurrentQuestion.Text, answer.QuestionText)
+	s.Require().Equal(chosen.Text, answer.ChosenAlternativeText)
+	s.Require().Equal(chosen.IsCorrect, answer.IsCorrect)
+	s.Require().NotNil(answer.AnsweredAt)
+}
+
+func (s *UserDataExportIntegrationTestSuite) TestExportCompetitionsAndUsernames() {
+	labelID, err := labels.CreateLabel(s.DB, "Konkurranse")
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(`INSERT INTO competition_results (label_id, user_id, username, points, placement, quizzes_completed)
+		VALUES ($1, $2, 'adj1 noun1', 300, 2, 3)`, labelID, s.InsertedValues.UserId)
+	s.Require().NoError(err)
+
+	var drawID uuid.UUID
+	err = s.DB.QueryRow(`INSERT INTO prize_draws (method, label_name, winner_count, created_by_email)
+		VALUES ('top', 'Konkurranse', 1, 'admin@example.com') RETURNING id`).Scan(&drawID)
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(`INSERT INTO prize_draw_entrants (draw_id, entry_order, user_id, points, quizzes_completed, winner_position)
+		VALUES ($1, 1, $2, 300, 3, 1)`, drawID, s.InsertedValues.UserId)
+	s.Require().NoError(err)
+
+	_, err = usernames.ReserveUsername(s.DB, context.Background(), s.InsertedValues.UserId, "adj1", "noun2", time.Now())
+	s.Require().NoError(err)
+
+	export, err := Export(s.DB, s.InsertedValues.UserId, "")
+	s.Require().NoError(err)
+	s.Require().Equal([]ExportedCompetitionResult{
+		{Competition: "Konkurranse", Username: "adj1 noun1", Points: 300, Placement: 2, QuizzesCompleted: 3},
+	}, export.CompetitionResults)
+
+	s.Require().Len(export.PrizeDraws, 1)
+	s.Require().Equal(drawID, export.PrizeDraws[0].DrawID)
+	s.Require().True(export.PrizeDraws[0].Won)
+	s.Require().Equal(1, export.PrizeDraws[0].WinnerPosition)
+
+	s.Require().Len(export.UsernameReservations, 1)
+	s.Require().Equal("adj1 noun2", export.UsernameReservations[0].Username)
+}
+
+func (s *UserDataExportIntegrationTestSuite) TestExportNoSuchUser() {
+	_, err := Export(s.DB, uuid.New(), "")
+	s.Require().ErrorIs(err, sql.ErrNoRows)
+}
@@ -7,17 +7,189 @@ import (
 	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
 	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-// Gets the n-th question in the given quiz. Questions are numbered (indexed) from 1.
-func GetQuestionByNumberInQuiz(db *sql.DB, quizID uuid.UUID, questionNumber uint) (*QuizData, error) {
+// Returns the IDs of the quizzes that are currently available to guests, most recently started first.
+//
+// These are the published quizzes editors have opened for guests, once they have become active.
+// If none of those are available, the most recently ended published quiz is available instead,
+// so the returned list is only empty if there are no such quizzes either. Archived quizzes are never available.
+func GetOpenQuizIds(db *sql.DB) ([]uuid.UUID, error) {
+	ids, err := queryQuizIds(db, `
+	SELECT id
+	FROM quizzes
+	WHERE published = true AND is_deleted = false AND is_archived = false
+	AND open_for_guests = true
+	AND active_from < NOW()
+	ORDER BY active_from DESC;`)
+	if err != nil || len(ids) > 0 {
+		return ids, err
+	}
+
+	return queryQuizIds(db, `
+	SELECT id
+	FROM quizzes
+	WHERE published = true AND is_deleted = false AND is_archived = false
+	AND active_from < NOW()
+	AND active_to < NOW()
+	ORDER BY active_to DESC
+	limit 1;`)
+}
+
+func queryQuizIds(db *sql.DB, query string) ([]uuid.UUID, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Checks whether the quiz is currently available to guests.
+func IsOpenQuiz(db *sql.DB, quizID uuid.UUID) (bool, error) {
+	ids, err := GetOpenQuizIds(db)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		if id == quizID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Returns the quizzes that are currently available to guests, most recently started first.
+func GetOpenQuizzes(db *sql.DB) ([]quizzes.PartialQuiz, error) {
+	ids, err := GetOpenQuizIds(db)
+	if err != nil {
+		return nil, err
+	}
+
+	openQuizzes := []quizzes.PartialQuiz{}
+	for _, id := range ids {
+		quiz, err := quizzes.GetPartialQuizByID(db, id)
+		if err != nil {
+			return nil, err
+		}
+		openQuizzes = append(openQuizzes, *quiz)
+	}
+	return openQuizzes, nil
+}
+
+// Returns the ID of the next question the provided guest has not answered in the provided quiz.
+//
+// If there are no more questions, returns ErrNoMoreQuestions.
+func getNextUnansweredQuestionIDGuest(db *sql.DB, guestID uuid.UUID, quizID uuid.UUID) (uuid.UUID, error) {
+	row := db.QueryRow(
+		`SELECT id
+		FROM questions
+		WHERE quiz_id = $1
+		AND id NOT IN (
+			SELECT question_id
+			FROM guest_answers
+			WHERE answered_at IS NOT NULL
+			AND guest_session_id = $2
+		)
+		ORDER BY arrangement
+		LIMIT 1;`, quizID, guestID)
+
+	var id uuid.UUID
+	err := row.Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, ErrNoMoreQuestions
+		}
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+// Saves that the guest was presented with the question. If the guest has already started answering the question, returns errQuestionAlreadyStarted.
+func startQuestionGuest(db *sql.DB, guestID uuid.UUID, questionID uuid.UUID) error {
+	_, err := db.Exec(
+		`INSERT INTO guest_answers
+		(guest_session_id, question_id, question_presented_at)
+		VALUES ($1, $2, $3)`, guestID, questionID, time.Now().UTC())
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return errQuestionAlreadyStarted
+		}
+	}
+
+	return err
+}
+
+// Same as expireOpenQuestions, but for guests.
+func expireOpenQuestionsGuest(db *sql.DB, guestID uuid.UUID, quizID uuid.UUID, grace time.Duration) error {
+	_, err := db.Exec(
+		`UPDATE guest_answers ga
+		SET answered_at = ga.question_presented_at + make_interval(secs => q.time_limit_seconds), timed_out = true
+		FROM questions q
+		WHERE ga.question_id = q.id
+		AND ga.guest_session_id = $1 AND q.quiz_id = $2
+		AND ga.answered_at IS NULL
+		AND ga.question_presented_at + make_interval(secs => q.time_limit_seconds) + make_interval(secs => $3) < $4;`,
+		guestID, quizID, grace.Seconds(), time.Now().UTC())
+	return err
+}
+
+// Returns the next question in the quiz for the guest and saves the time it was presented.
+// Questions left open past their time limit and the grace period are timed out first.
+//
+// May return:
+//
+// ErrNoSuchQuiz if the quiz does not exist.
+// ErrNoMoreQuestions if there are no more unanswered questions for the guest.
+func NextQuestionInQuizGuest(db *sql.DB, guestID uuid.UUID, quizID uuid.UUID, grace time.Duration) (*QuizData, error) {
 	partialQuiz, err := quizzes.GetPartialQuizByID(db, quizID)
 	if err != nil || !partialQuiz.Published || partialQuiz.QuestionNumber == 0 {
 		return nil, ErrNoSuchQuiz
 	}
 
-	question, err := questions.GetNthQuestionByQuizId(db, quizID, questionNumber)
+	if err := expireOpenQuestionsGuest(db, guestID, quizID, grace); err != nil {
+		return nil, err
+	}
+
+	questionID, err := getNextUnansweredQuestionIDGuest(db, guestID, quizID)
+	if err != nil {
+		return nil, err
+	}
+	question, err := questions.GetQuestionByID(db, questionID)
+	if err != nil {
+		return nil, err
+	}
+	timeLeft := question.TimeLimitSeconds
+
+	err = startQuestionGuest(db, guestID, questionID)
+	if err != nil {
+		if err != errQuestionAlreadyStarted {
+			return nil, err
+		}
+		var timePresented time.Time
+		err = db.QueryRow(
+			`SELECT question_presented_at
+			FROM guest_answers
+			WHERE guest_session_id = $1 AND question_id = $2;`, guestID, questionID,
+		).Scan(&timePresented)
+		if err != nil {
+			return nil, err
+		}
+		timeLeft = question.GetRemainingTimeSeconds(time.Since(timePresented))
+	}
 
+	pointsSoFar, err := getPointsGatheredInQuizGuest(db, quizID, guestID)
 	if err != nil {
 		return nil, err
 	}
@@ -25,66 +197,114 @@ func GetQuestionByNumberInQuiz(db *sql.DB, quizID uuid.UUID, questionNumber uint
 	return &QuizData{
 		*partialQuiz,
 		*question,
-		0,
-		question.TimeLimitSeconds,
+		pointsSoFar,
+		timeLeft,
 	}, nil
 }
 
-// Returns the ID of the quiz that is currently available to guests.
-// May return sql.ErrNoRows if no quizzes match the requirements to be open.
-func GetOpenQuizId(db *sql.DB) (uuid.UUID, error) {
-	var id uuid.UUID
-	err := db.QueryRow(`
-	SELECT id
-	FROM quizzes
-	WHERE published = true AND is_deleted = false
-	AND active_from < NOW()
-	AND active_to < NOW()
-	ORDER BY active_to DESC
-	limit 1;`).Scan(&id)
-
-	return id, err
-}
+// Saves the guest's answer to a question and returns the result as a UserAnsweredQuestion.
+// Points are calculated from the time the question was presented by the server, not by the guest.
+// Answers arriving after the question's time limit and the grace period are not saved,
+// the question is instead marked as timed out and gives no points.
+//
+// May return:
+//
+// ErrQuestionAlreadyAnswered if the guest has already answered the question.
+// sql.ErrNoRows if the guest was never presented with the question.
+// questions.ErrInvalidAnswer if the answer does not fit the type or alternatives of the question.
+func AnswerQuestionGuest(db *sql.DB, guestID uuid.UUID, questionID uuid.UUID, answer questions.Answer, grace time.Duration) (*UserAnsweredQuestion, error) {
+	var answeredAt sql.NullTime
+	var presentedAt time.Time
+	err := db.QueryRow(
+		`SELECT answered_at, question_presented_at
+		FROM guest_answers
+		WHERE guest_session_id = $1 AND question_id = $2;`, guestID, questionID,
+	).Scan(&answeredAt, &presentedAt)
+	if err != nil {
+		return nil, err
+	}
+	if answeredAt.Valid {
+		return nil, ErrQuestionAlreadyAnswered
+	}
 
-// Gets UserAnsweredQuestion data for the answer without saving any data in the database.
-func AnswerQuestionGuest(db *sql.DB, questionId uuid.UUID, chosenAnswerId uuid.UUID, questionPresentedAt time.Time) (*UserAnsweredQuestion, error) {
-	answeredQuestion := UserAnsweredQuestion{
-		ChosenAnswerID: chosenAnswerId,
+	question, err := questions.GetQuestionByID(db, questionID)
+	if err != nil {
+		return nil, err
 	}
+	if err := question.ValidateAnswer(answer); err != nil {
+		return nil, err
+	}
+	chosenAlternative, chosenAlternatives, number := question.AnswerColumns(answer)
 
-	question, err := questions.GetQuestionByID(db, questionId)
+	nowTime := time.Now().UTC()
+	timedOut := nowTime.After(question.AnswerDeadline(presentedAt, grace))
+	var result sql.Result
+	if timedOut {
+		answer = questions.Answer{}
+		result, err = db.Exec(
+			`UPDATE guest_answers
+			SET answered_at = $1, timed_out = true
+			WHERE guest_session_id = $2 AND question_id = $3 AND answered_at IS NULL;`,
+			question.AnswerDeadline(presentedAt, 0), guestID, questionID)
+	} else {
+		result, err = db.Exec(
+			`UPDATE guest_answers
+			SET chosen_answer_alternative_id = $1, chosen_alternative_ids = $2, numeric_answer = $3, answered_at = $4
+			WHERE guest_session_id = $5 AND question_id = $6 AND answered_at IS NULL;`,
+			chosenAlternative, chosenAlternatives, number, nowTime, guestID, questionID)
+	}
 	if err != nil {
 		return nil, err
 	}
-	answeredQuestion.Question = *question
+	if err := requireOneRowAnswered(result); err != nil {
+		return nil, err
+	}
 
-	if question.IsAnswerCorrect(chosenAnswerId) {
-		points, err := calculatePointsWithSqlFunction(db, question, questionPresentedAt)
-		if err != nil {
-			return nil, err
-		}
-		answeredQuestion.PointsAwarded = points
+	var pointsAwarded int
+	var score float64
+	err = db.QueryRow(`SELECT points_awarded, score
+		FROM guest_question_points
+		WHERE guest_session_id = $1
+		AND question_id = $2;`, guestID, questionID).Scan(&pointsAwarded, &score)
+	if err != nil {
+		return nil, err
 	}
 
-	nextQuestionId, err := questions.GetNextQuestionInQuizByQuestionId(db, questionId)
+	// Fetched again to include this answer in the percentages of the alternatives
+	question, err = questions.GetQuestionByID(db, questionID)
+	if err != nil {
+		return nil, err
+	}
+	nextQuestionID, err := getNextUnansweredQuestionIDGuest(db, guestID, question.QuizID)
 	if err != nil {
-		if err != sql.ErrNoRows {
+		if err != ErrNoMoreQuestions {
 			return nil, err
 		}
-		nextQuestionId = uuid.Nil
+		nextQuestionID = uuid.Nil
+	}
+	article, err := getQuestionArticle(db, question)
+	if err != nil {
+		return nil, err
 	}
-	answeredQuestion.NextQuestionID = nextQuestionId
-
-	return &answeredQuestion, nil
 
+	return &UserAnsweredQuestion{
+		Question:       *question,
+		Answer:         answer,
+		Score:          score,
+		PointsAwarded:  pointsAwarded,
+		NextQuestionID: nextQuestionID,
+		Article:        article,
+		TimedOut:       timedOut,
+	}, nil
 }
 
-// Uses the function defined in the database to calculatem how many points would be awarded. No data saved in the database.
-func calculatePointsWithSqlFunction(db *sql.DB, question *questions.Question, questionPresentedAt time.Time) (uint, error) {
-	row := db.QueryRow(`
-	SELECT calculate_points_awarded($1, $2, $3, $4);
-	`, questionPresentedAt, time.Now(), question.TimeLimitSeconds, question.Points)
-	var points uint
-	err := row.Scan(&points)
+// Returns the number of points gathered by the guest in the given quiz.
+func getPointsGatheredInQuizGuest(db *sql.DB, quizID uuid.UUID, guestID uuid.UUID) (int, error) {
+	var points int
+	err := db.QueryRow(
+		`SELECT COALESCE(SUM(points_awarded), 0)
+		FROM guest_question_points
+		WHERE quiz_id = $1
+		AND guest_session_id = $2;`, quizID, guestID).Scan(&points)
 	return points, err
 }
@@ -0,0 +1,73 @@
+//go:build integration
+
+package user_quiz
+
+import (
+	"testing"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type GuestQuizIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestGuestQuizIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(GuestQuizIntegrationTestSuite))
+}
+
+func (s *GuestQuizIntegrationTestSuite) TestOpenQuizFallback() {
+	ids, err := GetOpenQuizIds(s.DB)
+	s.Require().NoError(err)
+	s.Require().Empty(ids)
+
+	_, err = s.DB.Exec(`UPDATE quizzes SET active_from = now() - interval '2 days', active_to = now() - interval '1 day' WHERE id = $1`,
+		s.InsertedValues.QuizId1)
+	s.Require().NoError(err)
+
+	ids, err = GetOpenQuizIds(s.DB)
+	s.Require().NoError(err)
+	s.Require().Equal([]uuid.UUID{s.InsertedValues.QuizId1}, ids)
+}
+
+func (s *GuestQuizIntegrationTestSuite) TestOpenQuizFlagged() {
+	_, err := s.DB.Exec(`UPDATE quizzes SET active_from = now() - interval '2 days', active_to = now() - interval '1 day' WHERE id = $1`,
+		s.InsertedValues.QuizId1)
+	s.Require().NoError(err)
+	s.Require().NoError(quizzes.UpdateOpenForGuestsByQuizID(s.DB, s.InsertedValues.Quiz2Id2, true))
+
+	// The flagged quiz replaces the fallback
+	ids, err := GetOpenQuizIds(s.DB)
+	s.Require().NoError(err)
+	s.Require().Equal([]uuid.UUID{s.InsertedValues.Quiz2Id2}, ids)
+
+	isOpen, err := IsOpenQuiz(s.DB, s.InsertedValues.QuizId1)
+	s.Require().NoError(err)
+	s.Require().False(isOpen)
+
+	// Several quizzes can be open at once, most recently started first
+	s.Require().NoError(quizzes.UpdateOpenForGuestsByQuizID(s.DB, s.InsertedValues.QuizId1, true))
+	openQuizzes, err := GetOpenQuizzes(s.DB)
+	s.Require().NoError(err)
+	s.Require().Len(openQuizzes, 2)
+	s.Require().Equal(s.InsertedValues.Quiz2Id2, openQuizzes[0].ID)
+	s.Require().Equal(s.InsertedValues.QuizId1, openQuizzes[1].ID)
+}
+
+func (s *GuestQuizIntegrationTestSuite) TestArchivedQuizNotOpen() {
+	_, err := s.DB.Exec(`UPDATE quizzes SET active_from = now() - interval '2 days', active_to = now() - interval '1 day' WHERE id = $1`,
+		s.InsertedValues.QuizId1)
+	s.Require().NoError(err)
+	s.Require().NoError(quizzes.UpdateOpenForGuestsByQuizID(s.DB, s.InsertedValues.Quiz2Id2, true))
+	_, err = s.DB.Exec(`UPDATE quizzes SET is_archived = true WHERE id IN ($1, $2)`,
+		s.InsertedValues.QuizId1, s.InsertedValues.Quiz2Id2)
+	s.Require().NoError(err)
+
+	// Neither the flagged quiz nor the fallback is open once archived
+	ids, err := GetOpenQuizIds(s.DB)
+	s.Require().NoError(err)
+	s.Require().Empty(ids)
+}
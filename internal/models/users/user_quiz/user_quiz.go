@@ -5,8 +5,11 @@ import (
 	"errors"
 	"time"
 
+	"github.com/Molnes/Nyhetsjeger/internal/live_updates"
+	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
 	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
 	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/achievements"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
@@ -15,6 +18,10 @@ var ErrNoSuchQuiz = errors.New("user_quiz: no such quiz")
 var ErrNoMoreQuestions = errors.New("user_quiz: no more unanswered questions in quiz")
 var ErrNoSuchAnswer = errors.New("user_quiz: no such answer")
 
+// Default time an answer may arrive after the question's time limit and still count,
+// to make up for the time it takes to reach the server.
+const DefaultAnswerGracePeriod = 3 * time.Second
+
 // Returns the ID of the next question the provided user has not answered in the provided quiz.
 //
 // If there are no more questions, returns ErrNoMoreQuestions.
@@ -26,7 +33,7 @@ func getNextUnansweredQuestionID(db *sql.DB, userID uuid.UUID, quizID uuid.UUID)
 		AND id NOT IN (
 			SELECT question_id
 			FROM user_answers
-			WHERE chosen_answer_alternative_id IS NOT NULL
+			WHERE answered_at IS NOT NULL
 			AND user_id = $2
 		)
 		ORDER BY arrangement
@@ -78,25 +85,62 @@ func getQuestionPresentedAtTime(db *sql.DB, userId uuid.UUID, questionId uuid.UU
 	return questionPresentedAt, nil
 }
 
+// Marks the questions in the quiz the user was presented with, but did not answer before the time limit and grace period ran out,
+// as timed out. They are saved as answered at the time limit, giving no points, so the user moves on to the next question.
+// If this completes the quiz, the badges earned are awarded in the same transaction.
+func expireOpenQuestions(db *sql.DB, userID uuid.UUID, quizID uuid.UUID, grace time.Duration) error {
+	nowTime := time.Now().UTC()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE user_answers ua
+		SET answered_at = ua.question_presented_at + make_interval(secs => q.time_limit_seconds), timed_out = true
+		FROM questions q
+		WHERE ua.question_id = q.id
+		AND ua.user_id = $1 AND q.quiz_id = $2
+		AND ua.answered_at IS NULL
+		AND ua.question_presented_at + make_interval(secs => q.time_limit_seconds) + make_interval(secs => $3) < $4;`,
+		userID, quizID, grace.Seconds(), nowTime)
+	if err != nil {
+		return err
+	}
+	expired, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if expired == 0 {
+		return nil
+	}
+	if _, err := achievements.AwardBadges(tx, userID, quizID, nowTime); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 type QuizData struct {
 	PartialQuiz     quizzes.PartialQuiz
 	CurrentQuestion questions.Question
-	PointsGathered  uint
+	PointsGathered  int
 	SecondsLeft     uint // Time left for this question for this user (in seconds), same as question time if not presented earlier
 }
 
 // Returns the next question in the quiz for the user and saves the time it was presented.
+// Questions left open past their time limit and the grace period are timed out first.
 //
 // May return:
 //
 // ErrNoSuchQuiz if the quiz does not exist.
 // ErrNoMoreQuestions if there are no more unanswered questions for the user.
-func NextQuestionInQuiz(db *sql.DB, userID uuid.UUID, quizID uuid.UUID) (*QuizData, error) {
+func NextQuestionInQuiz(db *sql.DB, userID uuid.UUID, quizID uuid.UUID, grace time.Duration) (*QuizData, error) {
 	partialQuiz, err := quizzes.GetPartialQuizByID(db, quizID)
 	if err != nil || !partialQuiz.Published || partialQuiz.QuestionNumber == 0 {
 		return nil, ErrNoSuchQuiz
 	}
-	nextQuestion, secondsLeft, err := startNextQuestion(db, userID, quizID)
+	nextQuestion, secondsLeft, err := startNextQuestion(db, userID, quizID, grace)
 	if err != nil {
 		return nil, err
 	}
@@ -117,11 +161,15 @@ func NextQuestionInQuiz(db *sql.DB, userID uuid.UUID, quizID uuid.UUID) (*QuizDa
 
 // Returns the next unanswered question by the given user and time user has left.
 // If question presented for the first time, it is marked as presented and time user has left=question time limit
+// Questions left open past their time limit and the grace period are timed out, so they are skipped.
 //
 // May return:
 // ErrNoMoreQuestions if there are no more unanswered questions for the user in the quiz.
 // ErrNoSuchQuiz if the quiz does not exist.
-func startNextQuestion(db *sql.DB, userID uuid.UUID, quizID uuid.UUID) (*questions.Question, uint, error) {
+func startNextQuestion(db *sql.DB, userID uuid.UUID, quizID uuid.UUID, grace time.Duration) (*questions.Question, uint, error) {
+	if err := expireOpenQuestions(db, userID, quizID, grace); err != nil {
+		return nil, 0, err
+	}
 	questionID, err := getNextUnansweredQuestionID(db, userID, quizID)
 	if err != nil {
 		return nil, 0, err
@@ -150,56 +198,113 @@ func startNextQuestion(db *sql.DB, userID uuid.UUID, quizID uuid.UUID) (*questio
 
 type UserAnsweredQuestion struct {
 	Question       questions.Question
-	ChosenAnswerID uuid.UUID
-	PointsAwarded  uint
+	Answer         questions.Answer
+	Score          float64 // How correct the answer is, from 0 to 1. Some types of questions give partial credit.
+	PointsAwarded  int     // Negative if the quiz's scoring policy penalizes wrong answers.
 	NextQuestionID uuid.UUID
+	Article        *articles.Article // The article the question is based on, shown with the explanation. Nil if none.
+	// Whether the answer arrived after the time limit and grace period. The answer is then not saved, and gives no points.
+	TimedOut bool
+	// Badges awarded for completing the quiz with this answer. Always empty for guests.
+	NewBadges []achievements.EarnedBadge
+}
+
+// Returns the article the question is based on, or nil if the question is not linked to an article.
+func getQuestionArticle(db *sql.DB, question *questions.Question) (*articles.Article, error) {
+	if !question.ArticleID.Valid {
+		return nil, nil
+	}
+	return articles.GetArticleByID(db, question.ArticleID.UUID)
 }
 
 var ErrQuestionAlreadyAnswered = errors.New("user quiz: question already answered")
 
 // Saves the user's answer to a question and returns the result as a UserAnsweredQuestion.
+// Answers arriving after the question's time limit and the grace period are not saved,
+// the question is instead marked as timed out and gives no points.
+//
+// Publishes a QuestionAnswered event, and a ScoreboardChanged event if the answer completed the quiz.
+// If the answer completed the quiz, the badges earned are awarded in the same transaction as the answer is saved.
 //
 // May return:
 //
 // ErrQuestionAlreadyAnswered if the user has already answered the question.
-func AnswerQuestion(db *sql.DB, userId uuid.UUID, questionId uuid.UUID, chosenAlternative uuid.UUID) (*UserAnsweredQuestion, error) {
-	var questionPresentedAt time.Time
-	var chosenAnswerIdNull uuid.UUID
-	var maxPoints uint
-	var timeLimit uint
+// questions.ErrInvalidAnswer if the answer does not fit the type or alternatives of the question.
+func AnswerQuestion(db *sql.DB, publisher live_updates.Publisher, userId uuid.UUID, questionId uuid.UUID, answer questions.Answer, grace time.Duration) (*UserAnsweredQuestion, error) {
+	var answeredAt sql.NullTime
+	var presentedAt time.Time
 	var quizID uuid.UUID
 	err := db.QueryRow(
-		`SELECT question_presented_at, questions.points, questions.time_limit_seconds, chosen_answer_alternative_id, questions.quiz_id
+		`SELECT answered_at, question_presented_at, questions.quiz_id
 		FROM user_answers JOIN questions ON user_answers.question_id = questions.id
 		WHERE user_id = $1 AND question_id = $2;`, userId, questionId,
-	).Scan(&questionPresentedAt, &maxPoints, &timeLimit, &chosenAnswerIdNull, &quizID)
+	).Scan(&answeredAt, &presentedAt, &quizID)
 	if err != nil {
 		return nil, err
 	}
-	if chosenAnswerIdNull != uuid.Nil {
+	if answeredAt.Valid {
 		return nil, ErrQuestionAlreadyAnswered
 	}
 
-	nowTime := time.Now().UTC()
-	_, err = db.Exec(
-		`UPDATE user_answers
-		SET chosen_answer_alternative_id = $1, answered_at = $2
-		WHERE user_id = $3 AND question_id = $4;`,
-		chosenAlternative, nowTime, userId, questionId)
+	question, err := questions.GetQuestionByID(db, questionId)
+	if err != nil {
+		return nil, err
+	}
+	if err := question.ValidateAnswer(answer); err != nil {
+		return nil, err
+	}
+	chosenAlternative, chosenAlternatives, number := question.AnswerColumns(answer)
 
+	nowTime := time.Now().UTC()
+	timedOut := nowTime.After(question.AnswerDeadline(presentedAt, grace))
+	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	var pointsAwarded uint
-	err = db.QueryRow(`SELECT points_awarded
+	defer tx.Rollback()
+	// Only answers that are still open are updated, so an answer racing with another answer
+	// or with the question expiring can not overwrite it.
+	var result sql.Result
+	if timedOut {
+		answer = questions.Answer{}
+		result, err = tx.Exec(
+			`UPDATE user_answers
+			SET answered_at = $1, timed_out = true
+			WHERE user_id = $2 AND question_id = $3 AND answered_at IS NULL;`,
+			question.AnswerDeadline(presentedAt, 0), userId, questionId)
+	} else {
+		result, err = tx.Exec(
+			`UPDATE user_answers
+			SET chosen_answer_alternative_id = $1, chosen_alternative_ids = $2, numeric_answer = $3, answered_at = $4
+			WHERE user_id = $5 AND question_id = $6 AND answered_at IS NULL;`,
+			chosenAlternative, chosenAlternatives, number, nowTime, userId, questionId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOneRowAnswered(result); err != nil {
+		return nil, err
+	}
+	newBadges, err := achievements.AwardBadges(tx, userId, quizID, nowTime)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	var pointsAwarded int
+	var score float64
+	err = db.QueryRow(`SELECT points_awarded, score
 		FROM user_question_points
 		WHERE user_id = $1
-		AND question_id = $2;`, userId, questionId).Scan(&pointsAwarded)
+		AND question_id = $2;`, userId, questionId).Scan(&pointsAwarded, &score)
 	if err != nil {
 		return nil, err
 	}
 
-	question, err := questions.GetQuestionByID(db, questionId)
+	// Fetched again to include this answer in the percentages of the alternatives
+	question, err = questions.GetQuestionByID(db, questionId)
 	if err != nil {
 		return nil, err
 	}
@@ -210,18 +315,45 @@ func AnswerQuestion(db *sql.DB, userId uuid.UUID, questionId uuid.UUID, chosenAl
 		}
 		nextQuestionID = uuid.Nil
 	}
+	article, err := getQuestionArticle(db, question)
+	if err != nil {
+		return nil, err
+	}
+
+	if publisher != nil {
+		publisher.Publish(live_updates.Event{Type: live_updates.QuestionAnswered, QuizID: quizID, QuestionID: questionId})
+		if nextQuestionID == uuid.Nil {
+			publisher.Publish(live_updates.Event{Type: live_updates.ScoreboardChanged, QuizID: quizID})
+		}
+	}
 
 	return &UserAnsweredQuestion{
 		Question:       *question,
-		ChosenAnswerID: chosenAlternative,
+		Answer:         answer,
+		Score:          score,
 		PointsAwarded:  pointsAwarded,
 		NextQuestionID: nextQuestionID,
+		Article:        article,
+		TimedOut:       timedOut,
+		NewBadges:      newBadges,
 	}, nil
 }
 
+// Returns ErrQuestionAlreadyAnswered if the update saving an answer did not change exactly one open answer.
+func requireOneRowAnswered(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return ErrQuestionAlreadyAnswered
+	}
+	return nil
+}
+
 // Returns the number of points gathered by the user in the given quiz.
 // Returns 0 poitns if quiz not started.
-func getPointsGatheredInQuiz(db *sql.DB, quizID uuid.UUID, userID uuid.UUID) (uint, error) {
+func getPointsGatheredInQuiz(db *sql.DB, quizID uuid.UUID, userID uuid.UUID) (int, error) {
 	row := db.QueryRow(
 		`SELECT total_points_awarded
 	FROM user_quizzes
@@ -229,7 +361,7 @@ func getPointsGatheredInQuiz(db *sql.DB, quizID uuid.UUID, userID uuid.UUID) (ui
 	AND user_id = $2;
 	`, quizID, userID)
 
-	var points uint
+	var points int
 	err := row.Scan(&points)
 	if err == sql.ErrNoRows {
 		err = nil
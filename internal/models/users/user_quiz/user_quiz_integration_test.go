@@ -0,0 +1,87 @@
+//go:build integration
+
+package user_quiz
+
+import (
+	"testing"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type UserQuizIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestUserQuizIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(UserQuizIntegrationTestSuite))
+}
+
+// Presents the next question of the quiz to the user, as if it was presented the given number of seconds past its time limit.
+func (s *UserQuizIntegrationTestSuite) presentQuestionLate(quizID uuid.UUID, secondsPastLimit int) *questions.Question {
+	data, err := NextQuestionInQuiz(s.DB, s.InsertedValues.UserId, quizID, DefaultAnswerGracePeriod)
+	s.Require().NoError(err)
+	_, err = s.DB.Exec(
+		`UPDATE user_answers
+		SET question_presented_at = now() - make_interval(secs => $1)
+		WHERE user_id = $2 AND question_id = $3`,
+		int(data.CurrentQuestion.TimeLimitSeconds)+secondsPastLimit, s.InsertedValues.UserId, data.CurrentQuestion.ID)
+	s.Require().NoError(err)
+	return &data.CurrentQuestion
+}
+
+func correctAnswer(question *questions.Question) questions.Answer {
+	answer := questions.Answer{}
+	for _, alternative := range question.Alternatives {
+		if alternative.IsCorrect {
+			answer.AlternativeIDs = append(answer.AlternativeIDs, alternative.ID)
+		}
+	}
+	return answer
+}
+
+func (s *UserQuizIntegrationTestSuite) TestLateAnswerTimesOut() {
+	question := s.presentQuestionLate(s.InsertedValues.QuizId1, 10)
+
+	answered, err := AnswerQuestion(s.DB, nil, s.InsertedValues.UserId, question.ID, correctAnswer(question), DefaultAnswerGracePeriod)
+	s.Require().NoError(err)
+	s.Require().True(answered.TimedOut)
+	s.Require().Equal(0, answered.PointsAwarded)
+	s.Require().Equal(0.0, answered.Score)
+	s.Require().Empty(answered.Answer.AlternativeIDs)
+
+	var timedOut bool
+	var chosenID uuid.NullUUID
+	err = s.DB.QueryRow(
+		`SELECT timed_out, chosen_answer_alternative_id FROM user_answers WHERE user_id = $1 AND question_id = $2`,
+		s.InsertedValues.UserId, question.ID).Scan(&timedOut, &chosenID)
+	s.Require().NoError(err)
+	s.Require().True(timedOut)
+	s.Require().False(chosenID.Valid)
+}
+
+func (s *UserQuizIntegrationTestSuite) TestAnswerWithinGracePeriod() {
+	question := s.presentQuestionLate(s.InsertedValues.QuizId1, 1)
+
+	answered, err := AnswerQuestion(s.DB, nil, s.InsertedValues.UserId, question.ID, correctAnswer(question), DefaultAnswerGracePeriod)
+	s.Require().NoError(err)
+	s.Require().False(answered.TimedOut)
+	s.Require().Equal(1.0, answered.Score)
+	s.Require().Greater(answered.PointsAwarded, 0)
+}
+
+func (s *UserQuizIntegrationTestSuite) TestExpiredQuestionIsSkipped() {
+	question := s.presentQuestionLate(s.InsertedValues.QuizId1, 10)
+
+	data, err := NextQuestionInQuiz(s.DB, s.InsertedValues.UserId, s.InsertedValues.QuizId1, DefaultAnswerGracePeriod)
+	if err != ErrNoMoreQuestions {
+		s.Require().NoError(err)
+		s.Require().NotEqual(question.ID, data.CurrentQuestion.ID)
+		s.Require().Equal(0, data.PointsGathered)
+	}
+
+	_, err = AnswerQuestion(s.DB, nil, s.InsertedValues.UserId, question.ID, correctAnswer(question), DefaultAnswerGracePeriod)
+	s.Require().ErrorIs(err, ErrQuestionAlreadyAnswered)
+}
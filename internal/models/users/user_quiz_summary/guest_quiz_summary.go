@@ -0,0 +1,81 @@
+package user_quiz_summary
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+// Returns UserQuizSummary of given quiz and given guest session.
+// If quiz does not exists, returns ErrNoSuchQuiz. If Quiz isn't completed ErrQuizNotCompleted.
+func GetGuestQuizSummary(db *sql.DB, guestID uuid.UUID, quizID uuid.UUID) (*UserQuizSummary, error) {
+	var summary UserQuizSummary
+	var unanswered uint
+	err := db.QueryRow(
+		`SELECT qz.id, qz.title, qz.active_to, COALESCE(SUM(q.points), 0) AS max_score,
+		COUNT(q.id) FILTER (WHERE q.id NOT IN (
+			SELECT question_id
+			FROM guest_answers
+			WHERE answered_at IS NOT NULL
+			AND guest_session_id = $1
+		)) AS unanswered
+		FROM quizzes qz
+		LEFT JOIN questions q ON qz.id = q.quiz_id
+		WHERE qz.id = $2
+		GROUP BY qz.id, qz.title, qz.active_to;
+		`, guestID, quizID,
+	).Scan(&summary.QuizID, &summary.QuizTitle, &summary.QuizActiveTo, &summary.MaxScore, &unanswered)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNoSuchQuiz
+		}
+		return nil, err
+	}
+	if unanswered > 0 {
+		return nil, ErrQuizNotCompleted
+	}
+
+	rows, err := db.Query(
+		`SELECT gqp.question_id, q.question, q.points, gqp.chosen_answer_alternative_id,
+		answer_text(q.question_type, ga.chosen_answer_alternative_id, ga.chosen_alternative_ids, ga.numeric_answer, q.numeric_unit),
+		gqp.score >= 1, gqp.score, gqp.points_awarded,
+		q.explanation, COALESCE(a.title, ''), COALESCE(a.url, '')
+		FROM guest_question_points gqp
+		LEFT JOIN questions q ON gqp.question_id = q.id
+		LEFT JOIN guest_answers ga ON ga.guest_session_id = gqp.guest_session_id AND ga.question_id = gqp.question_id
+		LEFT JOIN articles a ON q.article_id = a.id
+		WHERE gqp.quiz_id = $1
+		AND gqp.guest_session_id = $2
+		ORDER BY q.arrangement;`, quizID, guestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var aq AnsweredQuestion
+		err := rows.Scan(
+			&aq.QuestionID,
+			&aq.QuestionText,
+			&aq.MaxPoints,
+			&aq.ChosenAlternativeID,
+			&aq.ChosenAlternativeText,
+			&aq.IsCorrect,
+			&aq.Score,
+			&aq.PointsAwarded,
+			&aq.Explanation,
+			&aq.ArticleTitle,
+			&aq.ArticleURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+		summary.AnsweredQuestions = append(summary.AnsweredQuestions, aq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	summary.CalculateAchievedScoreFromAnswered()
+
+	return &summary, nil
+}
@@ -14,14 +14,14 @@ type UserQuizSummary struct {
 	QuizTitle         string
 	QuizActiveTo      time.Time
 	MaxScore          uint
-	AchievedScore     uint
+	AchievedScore     int
 	AnsweredQuestions []AnsweredQuestion
 	HasArticlesToShow bool
 }
 
 // Sums individual achieved points for each question in AnsweredQuestions and sets the AchievedScore
 func (uqs *UserQuizSummary) CalculateAchievedScoreFromAnswered() {
-	var total uint
+	var total int
 	for _, answeredQuestion := range uqs.AnsweredQuestions {
 		total += answeredQuestion.PointsAwarded
 	}
@@ -32,10 +32,14 @@ type AnsweredQuestion struct {
 	QuestionID            uuid.UUID `json:"questionId"`
 	QuestionText          string    `json:"questionText"`
 	MaxPoints             uint      `json:"maxPoints"`
-	ChosenAlternativeID   uuid.UUID `json:"chosenAlternativeId"`
-	ChosenAlternativeText string    `json:"chosenAlternativeText"`
+	ChosenAlternativeID   uuid.UUID `json:"chosenAlternativeId"`   // Nil unless the question is single choice or true/false.
+	ChosenAlternativeText string    `json:"chosenAlternativeText"` // Describes the answer of any type of question.
 	IsCorrect             bool      `json:"isCorrect"`
-	PointsAwarded         uint      `json:"pointsAwarded"`
+	Score                 float64   `json:"score"` // How correct the answer is, from 0 to 1. Some types of questions give partial credit.
+	PointsAwarded         int       `json:"pointsAwarded"`
+	Explanation           string    `json:"explanation"`  // May be empty.
+	ArticleTitle          string    `json:"articleTitle"` // Empty if the question is not linked to an article.
+	ArticleURL            string    `json:"articleUrl"`   // Empty if the question is not linked to an article.
 }
 
 var ErrNoSuchQuiz = errors.New("quiz_summary: no such quiz")
@@ -84,10 +88,14 @@ func GetQuizSummary(db *sql.DB, userID uuid.UUID, quizID uuid.UUID) (*UserQuizSu
 // Gets questions answered by the given user in a given quiz.
 func getAnsweredQuestions(db *sql.DB, userID uuid.UUID, quizID uuid.UUID) ([]AnsweredQuestion, error) {
 	rows, err := db.Query(
-		`SELECT uqp.question_id, q.question, q.points, uqp.chosen_answer_alternative_id, a.text, a.correct, uqp.points_awarded
+		`SELECT uqp.question_id, q.question, q.points, uqp.chosen_answer_alternative_id,
+		answer_text(q.question_type, ua.chosen_answer_alternative_id, ua.chosen_alternative_ids, ua.numeric_answer, q.numeric_unit),
+		uqp.score >= 1, uqp.score, uqp.points_awarded,
+		q.explanation, COALESCE(a.title, ''), COALESCE(a.url, '')
 		FROM user_question_points uqp
 		LEFT JOIN questions q ON uqp.question_id = q.id
-		LEFT JOIN answer_alternatives a ON uqp.chosen_answer_alternative_id = a.id
+		LEFT JOIN user_answers ua ON ua.user_id = uqp.user_id AND ua.question_id = uqp.question_id
+		LEFT JOIN articles a ON q.article_id = a.id
 		WHERE uqp.quiz_id = $1
 		AND uqp.user_id = $2
 		ORDER BY q.arrangement;`, quizID, userID)
@@ -107,7 +115,11 @@ func getAnsweredQuestions(db *sql.DB, userID uuid.UUID, quizID uuid.UUID) ([]Ans
 			&aq.ChosenAlternativeID,
 			&aq.ChosenAlternativeText,
 			&aq.IsCorrect,
+			&aq.Score,
 			&aq.PointsAwarded,
+			&aq.Explanation,
+			&aq.ArticleTitle,
+			&aq.ArticleURL,
 		)
 		if err != nil {
 			return nil, err
@@ -2,8 +2,10 @@ package user_ranking
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
+	"github.com/Molnes/Nyhetsjeger/internal/utils/data"
 	"github.com/google/uuid"
 )
 
@@ -30,6 +32,7 @@ type RankingByLabel struct {
 	Ranking []UserRanking
 }
 
+// DateRange is the calendar period a ranking is calculated over.
 type DateRange int
 
 const (
@@ -38,6 +41,50 @@ const (
 	Year  DateRange = 2
 )
 
+// Parses the value of a period query parameter ("month" or "year").
+// Anything else results in All.
+func ParseDateRange(value string) DateRange {
+	switch value {
+	case "month":
+		return Month
+	case "year":
+		return Year
+	default:
+		return All
+	}
+}
+
+// Returns the query parameter value of the DateRange, the inverse of ParseDateRange.
+func (d DateRange) String() string {
+	switch d {
+	case Month:
+		return "month"
+	case Year:
+		return "year"
+	default:
+		return "all"
+	}
+}
+
+// Returns the start (inclusive) and end (exclusive) of the calendar month or year
+// containing the given time, in Norway's timezone.
+// For All both bounds are invalid, meaning the ranking is not limited in time.
+func (d DateRange) Bounds(at time.Time) (sql.NullTime, sql.NullTime) {
+	norwayTime := data_handling.GetNorwayTime(at)
+	var start, end time.Time
+	switch d {
+	case Month:
+		start = time.Date(norwayTime.Year(), norwayTime.Month(), 1, 0, 0, 0, 0, norwayTime.Location())
+		end = start.AddDate(0, 1, 0)
+	case Year:
+		start = time.Date(norwayTime.Year(), time.January, 1, 0, 0, 0, 0, norwayTime.Location())
+		end = start.AddDate(1, 0, 0)
+	default:
+		return sql.NullTime{}, sql.NullTime{}
+	}
+	return sql.NullTime{Time: start, Valid: true}, sql.NullTime{Time: end, Valid: true}
+}
+
 // Returns an empty UserRankingWithLabel struct.
 func EmptyRanking() UserRankingWithLabel {
 	return UserRankingWithLabel{
@@ -51,6 +98,13 @@ func EmptyRanking() UserRankingWithLabel {
 
 // Returns the ranking of all users who have opted in to the ranking.
 func GetRanking(db *sql.DB, labelID uuid.UUID) ([]UserRanking, error) {
+	return GetRankingInRange(db, labelID, All, time.Now())
+}
+
+// Returns the ranking of all users who have opted in to the ranking,
+// counting only quizzes finished within the DateRange containing the given time.
+func GetRankingInRange(db *sql.DB, labelID uuid.UUID, dateRange DateRange, at time.Time) ([]UserRanking, error) {
+	from, to := dateRange.Bounds(at)
 
 	rows, err := db.Query(`
         SELECT user_id, SUM(total_points_awarded) AS total_points, 
@@ -77,10 +131,12 @@ AND answered_within_active_time = true
 AND q.published = true
 AND q.is_deleted = false
 AND u.opt_in_ranking = true
+AND ($2::timestamptz IS NULL OR user_quizzes.finished_at >= $2)
+AND ($3::timestamptz IS NULL OR user_quizzes.finished_at < $3)
 
 GROUP BY user_id, username
 ORDER BY total_points DESC;
-    `, labelID)
+    `, labelID, from, to)
 
 	if err != nil {
 		return nil, err
@@ -106,6 +162,13 @@ ORDER BY total_points DESC;
 
 // Returns the ranking of the specified user.
 func GetUserRanking(db *sql.DB, userID uuid.UUID, label labels.Label) (UserRanking, error) {
+	return GetUserRankingInRange(db, userID, label, All, time.Now())
+}
+
+// Returns the ranking of the specified user,
+// counting only quizzes finished within the DateRange containing the given time.
+func GetUserRankingInRange(db *sql.DB, userID uuid.UUID, label labels.Label, dateRange DateRange, at time.Time) (UserRanking, error) {
+	from, to := dateRange.Bounds(at)
 
 	row := db.QueryRow(`
     SELECT * FROM (
@@ -134,12 +197,14 @@ AND answered_within_active_time = true
 AND q.published = true
 AND q.is_deleted = false
 AND u.opt_in_ranking = true 
+AND ($3::timestamptz IS NULL OR user_quizzes.finished_at >= $3)
+AND ($4::timestamptz IS NULL OR user_quizzes.finished_at < $4)
 
 GROUP BY user_id, username
 ORDER BY total_points DESC) AS ranking
 WHERE user_id = $2;
 
-    `, label.ID, userID)
+    `, label.ID, userID, from, to)
 
 	ranking := UserRanking{}
 	err := row.Scan(
@@ -155,6 +220,13 @@ WHERE user_id = $2;
 
 // Returns the ranking of the specified user regardless of label.
 func GetUserRankingAllLabels(db *sql.DB, userID uuid.UUID) (UserRanking, error) {
+	return GetUserRankingAllLabelsInRange(db, userID, All, time.Now())
+}
+
+// Returns the ranking of the specified user regardless of label,
+// counting only quizzes finished within the DateRange containing the given time.
+func GetUserRankingAllLabelsInRange(db *sql.DB, userID uuid.UUID, dateRange DateRange, at time.Time) (UserRanking, error) {
+	from, to := dateRange.Bounds(at)
 
 	row := db.QueryRow(`
     SELECT * FROM (
@@ -179,12 +251,14 @@ AND answered_within_active_time = true
 AND q.published = true
 AND q.is_deleted = false
 AND u.opt_in_ranking = true 
+AND ($2::timestamptz IS NULL OR user_quizzes.finished_at >= $2)
+AND ($3::timestamptz IS NULL OR user_quizzes.finished_at < $3)
 
 GROUP BY user_id, username
 ORDER BY total_points DESC) AS ranking
 WHERE user_id = $1;
 
-    `, userID)
+    `, userID, from, to)
 
 	ranking := UserRanking{}
 	err := row.Scan(
@@ -198,14 +272,20 @@ WHERE user_id = $1;
 	return ranking, nil
 }
 
-// Data transfer object wrapping 3 user rankings in the three DateRanges
+// Data transfer object wrapping 3 user rankings in the three DateRanges,
+// in addition to the ranking in a single label.
 type RankingCollection struct {
 	ByLabel UserRankingWithLabel
+	Monthly UserRanking
+	Yearly  UserRanking
 	AllTime UserRanking
+	// Any time within the month and year the Monthly and Yearly rankings were calculated for.
+	Period time.Time
 }
 
 // Gets a colleciton of user rankings, Monthly, Yearly and AllTime for the given period.
-func GetUserRankingsInAllRanges(db *sql.DB, userId uuid.UUID, label labels.Label) (*RankingCollection, error) {
+// The monthly and yearly rankings are for the month and year containing the given time.
+func GetUserRankingsInAllRanges(db *sql.DB, userId uuid.UUID, label labels.Label, period time.Time) (*RankingCollection, error) {
 	labelRank, err := GetUserRanking(db, userId, label)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -215,13 +295,17 @@ func GetUserRankingsInAllRanges(db *sql.DB, userId uuid.UUID, label labels.Label
 		}
 	}
 
-	allTimeRank, err := GetUserRankingAllLabels(db, userId)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			allTimeRank = createEmptyRanking(userId, "")
-		} else {
-			return nil, err
+	rankings := make(map[DateRange]UserRanking, 3)
+	for _, dateRange := range []DateRange{Month, Year, All} {
+		rank, err := GetUserRankingAllLabelsInRange(db, userId, dateRange, period)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				rank = createEmptyRanking(userId, "")
+			} else {
+				return nil, err
+			}
 		}
+		rankings[dateRange] = rank
 	}
 
 	return &RankingCollection{
@@ -232,7 +316,10 @@ func GetUserRankingsInAllRanges(db *sql.DB, userId uuid.UUID, label labels.Label
 			Placement: labelRank.Placement,
 			Label:     label,
 		},
-		AllTime: allTimeRank,
+		Monthly: rankings[Month],
+		Yearly:  rankings[Year],
+		AllTime: rankings[All],
+		Period:  period,
 	}, nil
 }
 
@@ -0,0 +1,64 @@
+//go:build unit
+
+package user_ranking_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_ranking"
+)
+
+// TestDateRangeBoundsMonth tests that the month bounds cover the calendar month in Norway's timezone
+func TestDateRangeBoundsMonth(t *testing.T) {
+	// 23:30 UTC on the last day of January is already February in Norway
+	at := time.Date(2024, time.January, 31, 23, 30, 0, 0, time.UTC)
+	from, to := user_ranking.Month.Bounds(at)
+	if !from.Valid || !to.Valid {
+		t.Fatalf("Expected valid bounds, got %v and %v", from, to)
+	}
+
+	expectedFrom := time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC)
+	expectedTo := time.Date(2024, time.February, 29, 23, 0, 0, 0, time.UTC)
+	if !from.Time.Equal(expectedFrom) {
+		t.Errorf("Expected start %s, but got %s", expectedFrom, from.Time.UTC())
+	}
+	if !to.Time.Equal(expectedTo) {
+		t.Errorf("Expected end %s, but got %s", expectedTo, to.Time.UTC())
+	}
+}
+
+// TestDateRangeBoundsYear tests that the year bounds cover the calendar year in Norway's timezone
+func TestDateRangeBoundsYear(t *testing.T) {
+	at := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	from, to := user_ranking.Year.Bounds(at)
+
+	expectedFrom := time.Date(2023, time.December, 31, 23, 0, 0, 0, time.UTC)
+	expectedTo := time.Date(2024, time.December, 31, 23, 0, 0, 0, time.UTC)
+	if !from.Time.Equal(expectedFrom) {
+		t.Errorf("Expected start %s, but got %s", expectedFrom, from.Time.UTC())
+	}
+	if !to.Time.Equal(expectedTo) {
+		t.Errorf("Expected end %s, but got %s", expectedTo, to.Time.UTC())
+	}
+}
+
+// TestDateRangeBoundsAll tests that the all time range is not limited
+func TestDateRangeBoundsAll(t *testing.T) {
+	from, to := user_ranking.All.Bounds(time.Now())
+	if from.Valid || to.Valid {
+		t.Errorf("Expected no bounds, got %v and %v", from, to)
+	}
+}
+
+// TestParseDateRange tests that the query parameter values round trip
+func TestParseDateRange(t *testing.T) {
+	for _, dateRange := range []user_ranking.DateRange{user_ranking.All, user_ranking.Month, user_ranking.Year} {
+		if parsed := user_ranking.ParseDateRange(dateRange.String()); parsed != dateRange {
+			t.Errorf("Expected %v, but got %v", dateRange, parsed)
+		}
+	}
+	if parsed := user_ranking.ParseDateRange("invalid"); parsed != user_ranking.All {
+		t.Errorf("Expected All for unknown value, but got %v", parsed)
+	}
+}
@@ -0,0 +1,212 @@
+// Package user_tokens stores the tokens users get from their login provider, encrypted at rest.
+package user_tokens
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Molnes/Nyhetsjeger/internal/auth"
+	"github.com/Molnes/Nyhetsjeger/internal/encryption"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrNoToken         = errors.New("user_tokens: user has no stored token")
+	ErrUnknownProvider = errors.New("user_tokens: user's login provider is not configured")
+)
+
+// TokenService encrypts, refreshes and revokes the tokens users get from their login provider.
+type TokenService struct {
+	db        *sql.DB
+	keys      *encryption.KeyRing
+	providers auth.Providers
+}
+
+// Creates a token service encrypting tokens with the key ring.
+// The providers are used to refresh and revoke tokens.
+func NewTokenService(db *sql.DB, keys *encryption.KeyRing, providers auth.Providers) *TokenService {
+	return &TokenService{db, keys, providers}
+}
+
+// Associated data binding an encrypted token to the user and column it is stored in.
+func associatedData(userID uuid.UUID, column string) []byte {
+	return []byte(userID.String() + ":" + column)
+}
+
+// Encrypts and stores the token of the user, replacing any stored token.
+func (ts *TokenService) Store(ctx context.Context, userID uuid.UUID, token *oauth2.Token) error {
+	accessToken, err := ts.encrypt(userID, "access_token", token.AccessToken)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := ts.encrypt(userID, "refresh_token", token.RefreshToken)
+	if err != nil {
+		return err
+	}
+	expiry := sql.NullTime{Time: token.Expiry, Valid: !token.Expiry.IsZero()}
+
+	_, err = ts.db.ExecContext(ctx,
+		`UPDATE users
+		SET access_token = $2, token_expires_at = $3, refresh_token = $4
+		WHERE id = $1`,
+		userID, accessToken, expiry, refreshToken)
+	return err
+}
+
+// Encrypts a token, or returns NULL if it is empty.
+func (ts *TokenService) encrypt(userID uuid.UUID, column string, token string) (sql.NullString, error) {
+	if token == "" {
+		return sql.NullString{}, nil
+	}
+	encrypted, err := ts.keys.Encrypt([]byte(token), associatedData(userID, column))
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: encrypted, Valid: true}, nil
+}
+
+func (ts *TokenService) decrypt(userID uuid.UUID, column string, encrypted sql.NullString) (string, error) {
+	if !encrypted.Valid || encrypted.String == "" {
+		return "", nil
+	}
+	token, err := ts.keys.Decrypt(encrypted.String, associatedData(userID, column))
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+// Gets the stored token of the user and the provider it belongs to.
+// Returns ErrNoToken if the user has no stored token.
+func (ts *TokenService) load(ctx context.Context, userID uuid.UUID) (*oauth2.Token, auth.Provider, error) {
+	var providerName string
+	var accessToken, refreshToken sql.NullString
+	var expiry sql.NullTime
+	err := ts.db.QueryRowContext(ctx,
+		`SELECT sso_provider, access_token, token_expires_at, refresh_token
+		FROM users
+		WHERE id = $1`,
+		userID).Scan(&providerName, &accessToken, &expiry, &refreshToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !accessToken.Valid || accessToken.String == "" {
+		return nil, nil, ErrNoToken
+	}
+
+	token := &oauth2.Token{TokenType: "Bearer", Expiry: expiry.Time}
+	if token.AccessToken, err = ts.decrypt(userID, "access_token", accessToken); err != nil {
+		return nil, nil, err
+	}
+	if token.RefreshToken, err = ts.decrypt(userID, "refresh_token", refreshToken); err != nil {
+		return nil, nil, err
+	}
+
+	provider, ok := ts.providers.Get(providerName)
+	if !ok {
+		return token, nil, fmt.Errorf("%w: %q", ErrUnknownProvider, providerName)
+	}
+	return token, provider, nil
+}
+
+// Gets the token of the user, refreshing it with the provider if it has expired.
+// A refreshed token is stored again, encrypted with the current key.
+// Returns ErrNoToken if the user has no stored token.
+func (ts *TokenService) Token(ctx context.Context, userID uuid.UUID) (*oauth2.Token, error) {
+	token, provider, err := ts.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if token.Valid() {
+		return token, nil
+	}
+
+	refreshed, err := provider.Refresh(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+	if refreshed.RefreshToken == "" {
+		// Providers may leave out the refresh token when it is unchanged.
+		refreshed.RefreshToken = token.RefreshToken
+	}
+	if err := ts.Store(ctx, userID, refreshed); err != nil {
+		return nil, err
+	}
+	return refreshed, nil
+}
+
+// Revokes the user's token with the provider, and removes it.
+// The token is removed even if the provider does not support revoking tokens.
+// Does nothing if the user has no stored token.
+func (ts *TokenService) Revoke(ctx context.Context, userID uuid.UUID) error {
+	token, provider, err := ts.load(ctx, userID)
+	if err == ErrNoToken {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = provider.Revoke(ctx, token)
+	if err != nil && err != auth.ErrRevokeUnsupported {
+		return err
+	}
+	return ts.Clear(ctx, userID)
+}
+
+// Removes the user's stored token.
+func (ts *TokenService) Clear(ctx context.Context, userID uuid.UUID) error {
+	_, err := ts.db.ExecContext(ctx,
+		`UPDATE users
+		SET access_token = NULL, token_expires_at = NULL, refresh_token = NULL
+		WHERE id = $1`,
+		userID)
+	return err
+}
+
+// Re-encrypts all tokens encrypted with another key than the current one, after the key has been rotated.
+// Returns the number of users whose tokens were re-encrypted.
+func (ts *TokenService) ReencryptAll(ctx context.Context) (int, error) {
+	prefix := ts.keys.CurrentKeyID() + ":%"
+	rows, err := ts.db.QueryContext(ctx,
+		`SELECT id
+		FROM users
+		WHERE (access_token IS NOT NULL AND access_token NOT LIKE $1)
+		OR (refresh_token IS NOT NULL AND refresh_token NOT LIKE $1)`,
+		prefix)
+	if err != nil {
+		return 0, err
+	}
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	reencrypted := 0
+	for _, id := range ids {
+		token, _, err := ts.load(ctx, id)
+		if err == ErrNoToken {
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrUnknownProvider) {
+			return reencrypted, err
+		}
+		if err := ts.Store(ctx, id, token); err != nil {
+			return reencrypted, err
+		}
+		reencrypted++
+	}
+	return reencrypted, nil
+}
@@ -0,0 +1,201 @@
+//go:build integration
+
+package user_tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/Molnes/Nyhetsjeger/internal/auth"
+	"github.com/Molnes/Nyhetsjeger/internal/encryption"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/oauth2"
+)
+
+type UserTokensIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestUserTokensIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(UserTokensIntegrationTestSuite))
+}
+
+// Provider recording refreshed and revoked tokens.
+// The test user logged in with Google, so it is named "google".
+type fakeProvider struct {
+	auth.Provider
+	refreshed []string
+	revoked   []string
+	fail      bool // Whether revoking fails, as if the provider could not be reached.
+}
+
+func (fp *fakeProvider) Name() string {
+	return auth.ProviderGoogle
+}
+
+// Rotates both tokens, like providers that issue a new refresh token on every refresh.
+func (fp *fakeProvider) Refresh(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	fp.refreshed = append(fp.refreshed, token.RefreshToken)
+	return &oauth2.Token{
+		AccessToken:  "refreshed-access",
+		RefreshToken: "rotated-refresh",
+		Expiry:       time.Now().Add(time.Hour),
+	}, nil
+}
+
+func (fp *fakeProvider) Revoke(ctx context.Context, token *oauth2.Token) error {
+	if fp.fail {
+		return errors.New("provider unavailable")
+	}
+	fp.revoked = append(fp.revoked, token.RefreshToken)
+	return nil
+}
+
+var (
+	oldKey = []byte("0123456789abcdef0123456789abcdef")
+	newKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func (s *UserTokensIntegrationTestSuite) newService(currentID string, provider *fakeProvider) *TokenService {
+	keys, err := encryption.NewKeyRing(currentID, map[string][]byte{"1": oldKey, "2": newKey})
+	s.Require().NoError(err)
+	return NewTokenService(s.DB, keys, auth.Providers{provider})
+}
+
+func (s *UserTokensIntegrationTestSuite) TestStoreEncrypted() {
+	ts := s.newService("1", &fakeProvider{})
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	err := ts.Store(context.Background(), s.InsertedValues.UserId,
+		&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: expiry})
+	s.Require().NoError(err)
+
+	var accessToken string
+	err = s.DB.QueryRow(`SELECT access_token FROM users WHERE id = $1`, s.InsertedValues.UserId).Scan(&accessToken)
+	s.Require().NoError(err)
+	s.Require().NotContains(accessToken, "access")
+
+	token, _, err := ts.load(context.Background(), s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal("access", token.AccessToken)
+	s.Require().Equal("refresh", token.RefreshToken)
+	s.Require().True(expiry.Equal(token.Expiry))
+}
+
+func (s *UserTokensIntegrationTestSuite) TestReencryptAll() {
+	provider := &fakeProvider{}
+	err := s.newService("1", provider).Store(context.Background(), s.InsertedValues.UserId,
+		&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)})
+	s.Require().NoError(err)
+
+	rotated := s.newService("2", provider)
+	count, err := rotated.ReencryptAll(context.Background())
+	s.Require().NoError(err)
+	s.Require().GreaterOrEqual(count, 1)
+
+	onlyNewKey, err := encryption.NewKeyRing("2", map[string][]byte{"2": newKey})
+	s.Require().NoError(err)
+	token, _, err := NewTokenService(s.DB, onlyNewKey, auth.Providers{provider}).load(context.Background(), s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal("access", token.AccessToken)
+}
+
+func (s *UserTokensIntegrationTestSuite) TestTokenRefreshesExpired() {
+	provider := &fakeProvider{}
+	ts := s.newService("1", provider)
+
+	err := ts.Store(context.Background(), s.InsertedValues.UserId,
+		&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)})
+	s.Require().NoError(err)
+
+	// A valid token is returned as it is
+	token, err := ts.Token(context.Background(), s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal("access", token.AccessToken)
+	s.Require().Empty(provider.refreshed)
+
+	err = ts.Store(context.Background(), s.InsertedValues.UserId,
+		&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Minute)})
+	s.Require().NoError(err)
+
+	token, err = ts.Token(context.Background(), s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal("refreshed-access", token.AccessToken)
+	s.Require().Equal([]string{"refresh"}, provider.refreshed)
+
+	// The rotated token is stored encrypted
+	var refreshToken string
+	err = s.DB.QueryRow(`SELECT refresh_token FROM users WHERE id = $1`, s.InsertedValues.UserId).Scan(&refreshToken)
+	s.Require().NoError(err)
+	s.Require().NotContains(refreshToken, "rotated-refresh")
+
+	stored, _, err := ts.load(context.Background(), s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal("refreshed-access", stored.AccessToken)
+	s.Require().Equal("rotated-refresh", stored.RefreshToken)
+}
+
+func (s *UserTokensIntegrationTestSuite) TestRevoke() {
+	provider := &fakeProvider{}
+	ts := s.newService("1", provider)
+
+	err := ts.Store(context.Background(), s.InsertedValues.UserId,
+		&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)})
+	s.Require().NoError(err)
+
+	err = ts.Revoke(context.Background(), s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"refresh"}, provider.revoked)
+
+	_, _, err = ts.load(context.Background(), s.InsertedValues.UserId)
+	s.Require().ErrorIs(err, ErrNoToken)
+
+	// Revoking again does nothing
+	err = ts.Revoke(context.Background(), s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Len(provider.revoked, 1)
+}
+
+// Logging out revokes the token, so the grant is revoked even though no token is left when the profile is deleted.
+func (s *UserTokensIntegrationTestSuite) TestLogoutThenDeleteProfile() {
+	provider := &fakeProvider{}
+	ts := s.newService("1", provider)
+
+	err := ts.Store(context.Background(), s.InsertedValues.UserId,
+		&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)})
+	s.Require().NoError(err)
+
+	// Logging out
+	err = ts.Revoke(context.Background(), s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"refresh"}, provider.revoked)
+
+	// Deleting the profile later
+	err = ts.Revoke(context.Background(), s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().NoError(users.DeleteUserByID(s.DB, s.InsertedValues.UserId))
+	s.Require().Equal([]string{"refresh"}, provider.revoked)
+}
+
+// A token the provider failed to revoke at logout is kept, so it is revoked when the profile is deleted.
+func (s *UserTokensIntegrationTestSuite) TestRevokeRetriedAfterFailedLogout() {
+	provider := &fakeProvider{fail: true}
+	ts := s.newService("1", provider)
+
+	err := ts.Store(context.Background(), s.InsertedValues.UserId,
+		&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)})
+	s.Require().NoError(err)
+
+	err = ts.Revoke(context.Background(), s.InsertedValues.UserId)
+	s.Require().Error(err)
+	s.Require().Empty(provider.revoked)
+
+	provider.fail = false
+	err = ts.Revoke(context.Background(), s.InsertedValues.UserId)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"refresh"}, provider.revoked)
+}
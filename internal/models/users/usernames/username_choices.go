@@ -0,0 +1,267 @@
+package usernames
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How long a username picked by a user is reserved for them before it must be saved.
+const ReservationDuration = 10 * time.Minute
+
+// The most words returned when searching the word lists.
+const MaxSearchResults = 50
+
+var (
+	ErrUnknownWord     = errors.New("usernames: the adjective or noun does not exist")
+	ErrUsernameBlocked = errors.New("usernames: the combination of adjective and noun is blocked")
+	ErrUsernameTaken   = errors.New("usernames: the username is taken or reserved by another user")
+	ErrNoReservation   = errors.New("usernames: the user has not reserved the username, or the reservation has expired")
+)
+
+// A combination of an adjective and a noun which is not allowed as a username.
+type BlockedUsername struct {
+	Adjective string
+	Noun      string
+	CreatedAt time.Time
+}
+
+// Returns the adjectives containing the search, sorted alphabetically.
+func SearchAdjectives(db *sql.DB, search string) ([]string, error) {
+	return searchWords(db,
+		`SELECT adjective
+		FROM adjectives
+		WHERE strpos(adjective, $1) > 0
+		ORDER BY adjective
+		LIMIT $2;`, normalizeWord(search))
+}
+
+// Returns the nouns containing the search which make an available username with the adjective, sorted alphabetically.
+// Blocked combinations and usernames taken or reserved by other users are left out.
+func SearchAvailableNouns(db *sql.DB, adjective string, search string) ([]string, error) {
+	return searchWords(db,
+		`SELECT noun
+		FROM available_usernames
+		WHERE adjective = $3
+		AND strpos(noun, $1) > 0
+		ORDER BY noun
+		LIMIT $2;`, normalizeWord(search), adjective)
+}
+
+func searchWords(db *sql.DB, query string, search string, args ...any) ([]string, error) {
+	rows, err := db.Query(query, append([]any{search, MaxSearchResults}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := []string{}
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	return words, rows.Err()
+}
+
+// Checks that the words exist, the combination is not blocked, and no other user has the username.
+// Reservations are not checked.
+func checkUsername(tx *sql.Tx, ctx context.Context, userID uuid.UUID, adjective string, noun string) error {
+	var wordsExist, isBlocked, isTaken bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT
+			EXISTS (SELECT 1 FROM adjectives WHERE adjective = $2) AND EXISTS (SELECT 1 FROM nouns WHERE noun = $3),
+			EXISTS (SELECT 1 FROM blocked_usernames WHERE adjective = $2 AND noun = $3),
+			EXISTS (SELECT 1 FROM users WHERE username_adjective = $2 AND username_noun = $3 AND id != $1);`,
+		userID, adjective, noun,
+	).Scan(&wordsExist, &isBlocked, &isTaken)
+	switch {
+	case err != nil:
+		return err
+	case !wordsExist:
+		return ErrUnknownWord
+	case isBlocked:
+		return ErrUsernameBlocked
+	case isTaken:
+		return ErrUsernameTaken
+	default:
+		return nil
+	}
+}
+
+// Reserves the username for the user for ReservationDuration, so no other user can pick it before it is saved.
+// Replaces any username the user has already reserved.
+//
+// Returns when the reservation expires.
+// Returns ErrUsernameTaken if another user has the username or has reserved it.
+func ReserveUsername(db *sql.DB, ctx context.Context, userID uuid.UUID, adjective string, noun string, now time.Time) (time.Time, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	if err = checkUsername(tx, ctx, userID, adjective, noun); err != nil {
+		return time.Time{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM username_reservations WHERE user_id = $1;`, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// An expired reservation by another user is taken over
+	expiresAt := now.Add(ReservationDuration)
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO username_reservations (adjective, noun, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (adjective, noun) DO UPDATE
+		SET user_id = EXCLUDED.user_id, expires_at = EXCLUDED.expires_at
+		WHERE username_reservations.expires_at <= $5;`,
+		adjective, noun, userID, expiresAt, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return time.Time{}, err
+	} else if rowsAffected == 0 {
+		return time.Time{}, ErrUsernameTaken
+	}
+
+	if err = tx.Commit(); err != nil {
+		return time.Time{}, err
+	}
+	return expiresAt, nil
+}
+
+// Gives the user the username they have reserved with ReserveUsername, and removes the reservation.
+//
+// Returns the new username.
+// Returns ErrNoReservation if the user has not reserved the username or the reservation has expired.
+func ChooseUsername(db *sql.DB, ctx context.Context, userID uuid.UUID, adjective string, noun string, now time.Time) (string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Locking the reservation keeps it from being taken over while the username is saved
+	var reserved bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT true
+		FROM username_reservations
+		WHERE user_id = $1 AND adjective = $2 AND noun = $3 AND expires_at > $4
+		FOR UPDATE;`, userID, adjective, noun, now,
+	).Scan(&reserved)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNoReservation
+		}
+		return "", err
+	}
+
+	// The combination may have been blocked, or given to someone else by an admin, after it was reserved
+	if err = checkUsername(tx, ctx, userID, adjective, noun); err != nil {
+		return "", err
+	}
+
+	var username string
+	err = tx.QueryRowContext(ctx,
+		`UPDATE users
+		SET username_adjective = $2, username_noun = $3
+		WHERE id = $1
+		RETURNING CONCAT(username_adjective, ' ', username_noun);`, userID, adjective, noun,
+	).Scan(&username)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM username_reservations WHERE user_id = $1;`, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+// Returns the blocked combinations of adjectives and nouns, sorted alphabetically.
+func GetBlockedUsernames(db *sql.DB) ([]BlockedUsername, error) {
+	rows, err := db.Query(
+		`SELECT adjective, noun, created_at
+		FROM blocked_usernames
+		ORDER BY adjective, noun;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := []BlockedUsername{}
+	for rows.Next() {
+		var b BlockedUsername
+		if err := rows.Scan(&b.Adjective, &b.Noun, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, b)
+	}
+	return blocked, rows.Err()
+}
+
+// Blocks the combination of the adjective and noun from being used as a username.
+// Users who already have the username are given a new random one within the same transaction.
+//
+// Returns the number of users who were renamed.
+// Returns ErrUnknownWord if the adjective or noun does not exist, and ErrNoAvailableUsername if
+// there are no usernames left to rename the users to.
+func BlockUsername(db *sql.DB, ctx context.Context, adjective string, noun string) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = checkUsername(tx, ctx, uuid.Nil, adjective, noun)
+	if err != nil && err != ErrUsernameBlocked && err != ErrUsernameTaken {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO blocked_usernames (adjective, noun)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING;`, adjective, noun)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM username_reservations WHERE adjective = $1 AND noun = $2;`, adjective, noun)
+	if err != nil {
+		return 0, err
+	}
+
+	affectedUsers, err := lockUsers(tx, ctx, `username_adjective = $1 AND username_noun = $2`, adjective, noun)
+	if err != nil {
+		return 0, err
+	}
+	// The blocked combination is no longer in available_usernames, so no words need to be excluded
+	if err = reassignUsernames(tx, ctx, affectedUsers, []string{}); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(affectedUsers), nil
+}
+
+// Allows the combination of the adjective and noun to be used as a username again.
+func UnblockUsername(db *sql.DB, adjective string, noun string) error {
+	_, err := db.Exec(`DELETE FROM blocked_usernames WHERE adjective = $1 AND noun = $2;`, adjective, noun)
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
@@ -151,7 +152,14 @@ func AddWordToTable(db *sql.DB, word string, tableId string) error {
 	}
 }
 
-// DeleteWordsFromTable deletes the words from the adjectives and nouns tables and updates the usernames in the users table.
+// Returned when there are not enough usernames left to give every affected user a new one.
+var ErrNoAvailableUsername = errors.New("usernames: no available username to reassign")
+
+// DeleteWordsFromTable deletes the words from the adjectives and nouns tables.
+// Every user whose username contains one of the words is given a new random username without the deleted words,
+// within the same transaction, so either all the words are deleted and the users renamed or nothing is changed.
+//
+// Returns ErrNoAvailableUsername if there are too few usernames left to rename every affected user.
 func DeleteWordsFromTable(db *sql.DB, ctx context.Context, words []string) error {
 
 	tx, err := db.BeginTx(ctx, nil)
@@ -161,29 +169,13 @@ func DeleteWordsFromTable(db *sql.DB, ctx context.Context, words []string) error
 
 	defer tx.Rollback()
 
-	_, err = tx.ExecContext(ctx, `
-					UPDATE users
-					SET
-						username_adjective = random_username.adjective,
-						username_noun = random_username.noun
-					FROM (
-						SELECT adjective, noun
-						FROM available_usernames 
-						OFFSET floor(random() * (
-						SELECT COUNT(*) FROM available_usernames)
-						) 
-					LIMIT 1) AS random_username
-						WHERE users.id = ( 
-							SELECT id
-							FROM users
-							WHERE username_adjective = ANY($1)
-							OR
-							username_noun = ANY($1)
-						);
-					`, pq.Array(words))
+	affectedUsers, err := lockUsers(tx, ctx, `username_adjective = ANY($1) OR username_noun = ANY($1)`, pq.Array(words))
 	if err != nil {
 		return err
 	}
+	if err = reassignUsernames(tx, ctx, affectedUsers, words); err != nil {
+		return err
+	}
 
 	_, err = tx.ExecContext(ctx, `DELETE FROM adjectives WHERE adjective = ANY($1);`, pq.Array(words))
 	if err != nil {
@@ -201,6 +193,68 @@ func DeleteWordsFromTable(db *sql.DB, ctx context.Context, words []string) error
 	return nil
 }
 
+// Locks and returns the IDs of the users matching the condition, until the transaction ends.
+func lockUsers(tx *sql.Tx, ctx context.Context, condition string, args ...any) ([]uuid.UUID, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM users WHERE `+condition+` FOR UPDATE;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
+
+// Gives each of the users a new random username without any of the excluded words.
+// Returns ErrNoAvailableUsername if there are too few usernames left.
+func reassignUsernames(tx *sql.Tx, ctx context.Context, userIDs []uuid.UUID, excludedWords []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	// The random usernames are picked once and paired with the users by row number,
+	// so no two users are given the same username
+	result, err := tx.ExecContext(ctx, `
+		WITH affected_users AS (
+			SELECT id, ROW_NUMBER() OVER () AS n
+			FROM unnest($1::uuid[]) AS id
+		),
+		random_usernames AS (
+			SELECT adjective, noun, ROW_NUMBER() OVER () AS n
+			FROM (
+				SELECT adjective, noun
+				FROM available_usernames
+				WHERE adjective != ALL($2)
+				AND noun != ALL($2)
+				ORDER BY random()
+				LIMIT cardinality($1::uuid[])
+			) AS candidates
+		)
+		UPDATE users
+		SET
+			username_adjective = random_usernames.adjective,
+			username_noun = random_usernames.noun
+		FROM affected_users
+		JOIN random_usernames ON random_usernames.n = affected_users.n
+		WHERE users.id = affected_users.id;`, pq.Array(userIDs), pq.Array(excludedWords))
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected < int64(len(userIDs)) {
+		return ErrNoAvailableUsername
+	}
+	return nil
+}
+
 // UpdateAdjectives updates the adjectives in the adjectives table.
 func UpdateAdjectives(db *sql.DB, oldNewAdj []OldNew) error {
 
@@ -0,0 +1,158 @@
+//go:build integration
+
+package usernames
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type UsernamesIntegrationTestSuite struct {
+	db_integration_test_suite.DbIntegrationTestBaseSuite
+}
+
+func TestUsernamesIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(UsernamesIntegrationTestSuite))
+}
+
+// Inserts another user with the username "test noun1", so two users have the adjective "test".
+func (s *UsernamesIntegrationTestSuite) insertSecondTestUser() uuid.UUID {
+	id := uuid.New()
+	_, err := s.DB.Exec(
+		`INSERT INTO users (id, sso_user_id, email, phone, opt_in_ranking, role, username_adjective, username_noun)
+		VALUES ($1, 'second_test_user', 'second_test_user@email.com', 'no phone', true, 'user', 'test', 'noun1');`, id)
+	s.Require().NoError(err)
+	return id
+}
+
+func (s *UsernamesIntegrationTestSuite) TestDeleteWordsRenamesEveryAffectedUser() {
+	secondUserID := s.insertSecondTestUser()
+
+	err := DeleteWordsFromTable(s.DB, context.Background(), []string{"test"})
+	s.Require().NoError(err)
+
+	usernames := map[string]bool{}
+	for _, id := range []uuid.UUID{s.InsertedValues.UserId, secondUserID} {
+		var adjective, noun string
+		err = s.DB.QueryRow(`SELECT username_adjective, username_noun FROM users WHERE id = $1;`, id).Scan(&adjective, &noun)
+		s.Require().NoError(err)
+		s.Require().NotEqual("test", adjective)
+		usernames[adjective+" "+noun] = true
+	}
+	s.Require().Len(usernames, 2, "the users should not be given the same username")
+
+	var adjectives int
+	err = s.DB.QueryRow(`SELECT COUNT(*) FROM adjectives WHERE adjective = 'test';`).Scan(&adjectives)
+	s.Require().NoError(err)
+	s.Require().Zero(adjectives)
+}
+
+func (s *UsernamesIntegrationTestSuite) TestDeleteWordsWithoutAvailableUsernames() {
+	s.insertSecondTestUser()
+
+	err := DeleteWordsFromTable(s.DB, context.Background(), []string{"test", "adj1", "adj2"})
+	s.Require().ErrorIs(err, ErrNoAvailableUsername)
+
+	var adjectives int
+	err = s.DB.QueryRow(`SELECT COUNT(*) FROM adjectives;`).Scan(&adjectives)
+	s.Require().NoError(err)
+	s.Require().Equal(3, adjectives, "no words should be deleted")
+}
+
+func (s *UsernamesIntegrationTestSuite) TestImportWords() {
+	list := &WordList{Adjectives: []string{"adj1", "ny", "adj3"}, Nouns: []string{"noun3", "ny", "test"}}
+
+	preview, err := PreviewImport(s.DB, context.Background(), list)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"adj3"}, preview.NewAdjectives)
+	s.Require().Equal([]string{"noun3"}, preview.NewNouns)
+	s.Require().Equal(1, preview.SkippedCount(SkipDuplicate))
+	s.Require().Equal(3, preview.SkippedCount(SkipConflict))
+
+	imported, err := ImportWords(s.DB, context.Background(), list)
+	s.Require().NoError(err)
+	s.Require().Equal(preview, imported)
+
+	words, err := GetAllWords(s.DB)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"adj1", "adj2", "adj3", "test"}, words.Adjectives)
+	s.Require().Equal([]string{"noun1", "noun2", "noun3", "user"}, words.Nouns)
+}
+
+func (s *UsernamesIntegrationTestSuite) TestReserveAndChooseUsername() {
+	ctx := context.Background()
+	now := time.Now()
+	secondUserID := s.insertSecondTestUser()
+
+	_, err := ReserveUsername(s.DB, ctx, s.InsertedValues.UserId, "adj1", "noun2", now)
+	s.Require().NoError(err)
+
+	_, err = ReserveUsername(s.DB, ctx, secondUserID, "adj1", "noun2", now)
+	s.Require().ErrorIs(err, ErrUsernameTaken, "the username is reserved by the first user")
+	_, err = ChooseUsername(s.DB, ctx, secondUserID, "adj1", "noun2", now)
+	s.Require().ErrorIs(err, ErrNoReservation)
+
+	nouns, err := SearchAvailableNouns(s.DB, "adj1", "")
+	s.Require().NoError(err)
+	s.Require().NotContains(nouns, "noun2", "reserved usernames are not available")
+
+	username, err := ChooseUsername(s.DB, ctx, s.InsertedValues.UserId, "adj1", "noun2", now)
+	s.Require().NoError(err)
+	s.Require().Equal("adj1 noun2", username)
+
+	_, err = ReserveUsername(s.DB, ctx, secondUserID, "adj1", "noun2", now)
+	s.Require().ErrorIs(err, ErrUsernameTaken, "the username is taken by the first user")
+}
+
+func (s *UsernamesIntegrationTestSuite) TestExpiredReservationCanBeTakenOver() {
+	ctx := context.Background()
+	now := time.Now()
+	secondUserID := s.insertSecondTestUser()
+
+	_, err := ReserveUsername(s.DB, ctx, s.InsertedValues.UserId, "adj2", "noun2", now.Add(-2*ReservationDuration))
+	s.Require().NoError(err)
+
+	_, err = ReserveUsername(s.DB, ctx, secondUserID, "adj2", "noun2", now)
+	s.Require().NoError(err)
+
+	_, err = ChooseUsername(s.DB, ctx, s.InsertedValues.UserId, "adj2", "noun2", now)
+	s.Require().ErrorIs(err, ErrNoReservation)
+	_, err = ChooseUsername(s.DB, ctx, secondUserID, "adj2", "noun2", now)
+	s.Require().NoError(err)
+}
+
+func (s *UsernamesIntegrationTestSuite) TestBlockUsername() {
+	ctx := context.Background()
+	secondUserID := s.insertSecondTestUser()
+
+	renamed, err := BlockUsername(s.DB, ctx, "test", "noun1")
+	s.Require().NoError(err)
+	s.Require().Equal(1, renamed)
+
+	var adjective, noun string
+	err = s.DB.QueryRow(`SELECT username_adjective, username_noun FROM users WHERE id = $1;`, secondUserID).Scan(&adjective, &noun)
+	s.Require().NoError(err)
+	s.Require().NotEqual("test noun1", adjective+" "+noun)
+
+	_, err = ReserveUsername(s.DB, ctx, s.InsertedValues.UserId, "test", "noun1", time.Now())
+	s.Require().ErrorIs(err, ErrUsernameBlocked)
+
+	nouns, err := SearchAvailableNouns(s.DB, "test", "")
+	s.Require().NoError(err)
+	s.Require().NotContains(nouns, "noun1")
+
+	s.Require().NoError(UnblockUsername(s.DB, "test", "noun1"))
+	blocked, err := GetBlockedUsernames(s.DB)
+	s.Require().NoError(err)
+	s.Require().Empty(blocked)
+}
+
+func (s *UsernamesIntegrationTestSuite) TestBlockUnknownWords() {
+	_, err := BlockUsername(s.DB, context.Background(), "finnesikke", "noun1")
+	s.Require().ErrorIs(err, ErrUnknownWord)
+}
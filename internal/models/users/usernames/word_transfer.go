@@ -0,0 +1,276 @@
+package usernames
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// The longest word allowed in a username.
+const MaxWordLength = 30
+
+// The header of the CSV file with username words, the same format as data/whitelist-words.csv.
+var wordsCSVHeader = []string{"Adjektiv", "Substantiv"}
+
+var ErrInvalidWordsCSV = errors.New("usernames: invalid CSV file with words")
+
+// Adjectives and nouns read from a CSV file, in the order they were read.
+type WordList struct {
+	Adjectives []string
+	Nouns      []string
+}
+
+// Why a word in an import is not added.
+type SkipReason string
+
+const (
+	// The word is already in the table, or more than once in the file.
+	SkipDuplicate SkipReason = "duplicate"
+	// The word is, or would be, both an adjective and a noun.
+	// Deleting such a word would remove it from both tables, so it is not allowed.
+	SkipConflict SkipReason = "conflict"
+	// The word is too long or contains whitespace, which would break the username.
+	SkipInvalid SkipReason = "invalid"
+)
+
+// A word from an import which will not be added.
+type SkippedWord struct {
+	Word   string
+	Table  string // NounTable or AdjectiveTable
+	Reason SkipReason
+}
+
+// What importing a list of words does, shown to the admin before the words are imported.
+type ImportPreview struct {
+	NewAdjectives []string
+	NewNouns      []string
+	Skipped       []SkippedWord
+}
+
+// The number of skipped words with the given reason.
+func (p *ImportPreview) SkippedCount(reason SkipReason) int {
+	count := 0
+	for _, word := range p.Skipped {
+		if word.Reason == reason {
+			count++
+		}
+	}
+	return count
+}
+
+// Reads adjectives and nouns from a CSV file with the columns "Adjektiv;Substantiv".
+// Either column may be empty, as there are not usually as many adjectives as nouns.
+// Words are trimmed and lowercased, empty cells are ignored.
+func ReadWordsCSV(r io.Reader) (*WordList, error) {
+	reader := csv.NewReader(skipByteOrderMark(r))
+	reader.Comma = ';'
+	reader.FieldsPerRecord = len(wordsCSVHeader)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Join(ErrInvalidWordsCSV, err)
+	}
+	for i, column := range wordsCSVHeader {
+		if !strings.EqualFold(strings.TrimSpace(header[i]), column) {
+			return nil, ErrInvalidWordsCSV
+		}
+	}
+
+	list := WordList{Adjectives: []string{}, Nouns: []string{}}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Join(ErrInvalidWordsCSV, err)
+		}
+		if adjective := normalizeWord(record[0]); adjective != "" {
+			list.Adjectives = append(list.Adjectives, adjective)
+		}
+		if noun := normalizeWord(record[1]); noun != "" {
+			list.Nouns = append(list.Nouns, noun)
+		}
+	}
+	return &list, nil
+}
+
+// Spreadsheet programs often save CSV files with a byte order mark, which is not part of the header.
+func skipByteOrderMark(r io.Reader) io.Reader {
+	buffered := bufio.NewReader(r)
+	if next, _, err := buffered.ReadRune(); err == nil && next != '\uFEFF' {
+		buffered.UnreadRune()
+	}
+	return buffered
+}
+
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.TrimSpace(word))
+}
+
+// Whether the word can be part of a username.
+func isValidWord(word string) bool {
+	return utf8.RuneCountInString(word) <= MaxWordLength && strings.IndexFunc(word, unicode.IsSpace) == -1
+}
+
+// Decides which of the words to add, given the words already in the tables.
+func PreviewWords(list *WordList, existingAdjectives map[string]bool, existingNouns map[string]bool) *ImportPreview {
+	preview := ImportPreview{NewAdjectives: []string{}, NewNouns: []string{}, Skipped: []SkippedWord{}}
+
+	adjectivesInFile := make(map[string]bool)
+	for _, adjective := range list.Adjectives {
+		adjectivesInFile[adjective] = true
+	}
+	nounsInFile := make(map[string]bool)
+	for _, noun := range list.Nouns {
+		nounsInFile[noun] = true
+	}
+
+	classify := func(words []string, table string, existing map[string]bool, other map[string]bool, otherInFile map[string]bool) []string {
+		added := []string{}
+		seen := make(map[string]bool)
+		for _, word := range words {
+			reason := SkipReason("")
+			switch {
+			case !isValidWord(word):
+				reason = SkipInvalid
+			case existing[word] || seen[word]:
+				reason = SkipDuplicate
+			case other[word] || otherInFile[word]:
+				reason = SkipConflict
+			}
+			seen[word] = true
+			if reason != "" {
+				preview.Skipped = append(preview.Skipped, SkippedWord{Word: word, Table: table, Reason: reason})
+			} else {
+				added = append(added, word)
+			}
+		}
+		return added
+	}
+	preview.NewAdjectives = classify(list.Adjectives, AdjectiveTable, existingAdjectives, existingNouns, nounsInFile)
+	preview.NewNouns = classify(list.Nouns, NounTable, existingNouns, existingAdjectives, adjectivesInFile)
+	return &preview
+}
+
+// Either a database or a transaction.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Returns which of the words are in the adjectives and nouns tables.
+func getExistingWords(q querier, ctx context.Context, list *WordList) (adjectives map[string]bool, nouns map[string]bool, err error) {
+	words := append(append([]string{}, list.Adjectives...), list.Nouns...)
+	adjectives = make(map[string]bool)
+	nouns = make(map[string]bool)
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT adjective, true FROM adjectives WHERE adjective = ANY($1)
+		UNION ALL
+		SELECT noun, false FROM nouns WHERE noun = ANY($1);`, pq.Array(words))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var word string
+		var isAdjective bool
+		if err := rows.Scan(&word, &isAdjective); err != nil {
+			return nil, nil, err
+		}
+		if isAdjective {
+			adjectives[word] = true
+		} else {
+			nouns[word] = true
+		}
+	}
+	return adjectives, nouns, rows.Err()
+}
+
+// Returns which of the words would be added by ImportWords, and why the others would be skipped.
+func PreviewImport(db *sql.DB, ctx context.Context, list *WordList) (*ImportPreview, error) {
+	adjectives, nouns, err := getExistingWords(db, ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	return PreviewWords(list, adjectives, nouns), nil
+}
+
+// Adds the new words in the list to the adjectives and nouns tables in a single transaction.
+// Duplicates, conflicts and invalid words are skipped, see PreviewWords.
+//
+// Returns what was imported.
+func ImportWords(db *sql.DB, ctx context.Context, list *WordList) (*ImportPreview, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	adjectives, nouns, err := getExistingWords(tx, ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	preview := PreviewWords(list, adjectives, nouns)
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO adjectives SELECT unnest($1::text[]) ON CONFLICT DO NOTHING;`, pq.Array(preview.NewAdjectives))
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO nouns SELECT unnest($1::text[]) ON CONFLICT DO NOTHING;`, pq.Array(preview.NewNouns))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// Returns every adjective and noun, sorted alphabetically.
+func GetAllWords(db *sql.DB) (*WordList, error) {
+	list := WordList{}
+	err := db.QueryRow(
+		`SELECT
+			ARRAY(SELECT adjective FROM adjectives ORDER BY adjective),
+			ARRAY(SELECT noun FROM nouns ORDER BY noun);`,
+	).Scan(pq.Array(&list.Adjectives), pq.Array(&list.Nouns))
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Writes the words as a CSV file which can be imported again with ReadWordsCSV.
+func (list *WordList) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = ';'
+	if err := writer.Write(wordsCSVHeader); err != nil {
+		return err
+	}
+	for i := 0; i < max(len(list.Adjectives), len(list.Nouns)); i++ {
+		record := []string{"", ""}
+		if i < len(list.Adjectives) {
+			record[0] = list.Adjectives[i]
+		}
+		if i < len(list.Nouns) {
+			record[1] = list.Nouns[i]
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,94 @@
+//go:build unit
+
+package usernames_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/usernames"
+)
+
+func TestReadWordsCSV(t *testing.T) {
+	file := "\uFEFFAdjektiv;Substantiv\noransje;lefse\n Raud ;taco\n;snigel\n"
+	list, err := usernames.ReadWordsCSV(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(list.Adjectives, []string{"oransje", "raud"}) {
+		t.Errorf("expected adjectives [oransje raud], got %v", list.Adjectives)
+	}
+	if !reflect.DeepEqual(list.Nouns, []string{"lefse", "taco", "snigel"}) {
+		t.Errorf("expected nouns [lefse taco snigel], got %v", list.Nouns)
+	}
+}
+
+func TestReadWordsCSVInvalid(t *testing.T) {
+	files := map[string]string{
+		"empty":           "",
+		"wrong header":    "Ord;Type\nblå;and\n",
+		"wrong columns":   "Adjektiv;Substantiv\nblå;and;fisk\n",
+		"wrong delimiter": "Adjektiv,Substantiv\nblå,and\n",
+	}
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			_, err := usernames.ReadWordsCSV(strings.NewReader(file))
+			if !errors.Is(err, usernames.ErrInvalidWordsCSV) {
+				t.Errorf("expected ErrInvalidWordsCSV, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteCSVCanBeReadAgain(t *testing.T) {
+	list := &usernames.WordList{Adjectives: []string{"blå", "grøn"}, Nouns: []string{"and", "lefse", "taco"}}
+	var buffer bytes.Buffer
+	if err := list.WriteCSV(&buffer); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	read, err := usernames.ReadWordsCSV(&buffer)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(read, list) {
+		t.Errorf("expected %v, got %v", list, read)
+	}
+}
+
+func TestPreviewWords(t *testing.T) {
+	list := &usernames.WordList{
+		Adjectives: []string{"blå", "raud", "blå", "to ord", strings.Repeat("a", usernames.MaxWordLength+1), "gul"},
+		Nouns:      []string{"and", "lefse", "raud", "fisk"},
+	}
+	existingAdjectives := map[string]bool{"gul": true}
+	existingNouns := map[string]bool{"lefse": true}
+
+	preview := usernames.PreviewWords(list, existingAdjectives, existingNouns)
+
+	if !reflect.DeepEqual(preview.NewAdjectives, []string{"blå"}) {
+		t.Errorf("expected new adjectives [blå], got %v", preview.NewAdjectives)
+	}
+	if !reflect.DeepEqual(preview.NewNouns, []string{"and", "fisk"}) {
+		t.Errorf("expected new nouns [and fisk], got %v", preview.NewNouns)
+	}
+
+	expectedSkipped := []usernames.SkippedWord{
+		{Word: "raud", Table: usernames.AdjectiveTable, Reason: usernames.SkipConflict},
+		{Word: "blå", Table: usernames.AdjectiveTable, Reason: usernames.SkipDuplicate},
+		{Word: "to ord", Table: usernames.AdjectiveTable, Reason: usernames.SkipInvalid},
+		{Word: strings.Repeat("a", usernames.MaxWordLength+1), Table: usernames.AdjectiveTable, Reason: usernames.SkipInvalid},
+		{Word: "gul", Table: usernames.AdjectiveTable, Reason: usernames.SkipDuplicate},
+		{Word: "lefse", Table: usernames.NounTable, Reason: usernames.SkipDuplicate},
+		{Word: "raud", Table: usernames.NounTable, Reason: usernames.SkipConflict},
+	}
+	if !reflect.DeepEqual(preview.Skipped, expectedSkipped) {
+		t.Errorf("expected skipped %v, got %v", expectedSkipped, preview.Skipped)
+	}
+	if count := preview.SkippedCount(usernames.SkipDuplicate); count != 3 {
+		t.Errorf("expected 3 duplicates, got %d", count)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"encoding/gob"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_roles"
 	"github.com/google/uuid"
@@ -17,26 +16,23 @@ const (
 )
 
 type User struct {
-	ID                 uuid.UUID
-	SsoID              string
-	Username           string
-	Email              string
-	Phone              string
-	OptInRanking       bool
-	AcceptedTerms      bool
-	Role               user_roles.Role
-	AccessTokenCypher  []byte
-	TokenExpire        time.Time
-	RefreshTokenCypher []byte
+	ID            uuid.UUID
+	SsoProvider   string // Name of the login provider the SSO ID belongs to.
+	SsoID         string
+	Username      string
+	Email         string
+	Phone         string
+	OptInRanking  bool
+	AcceptedTerms bool
+	Role          user_roles.Role
 }
 
 // Parital User struct contains only fields needed for creating a new user
+// The login provider's tokens are stored separately, see user_tokens.
 type PartialUser struct {
-	SsoID        string
-	Email        string
-	AccessToken  string
-	TokenExpire  time.Time
-	RefreshToken string
+	SsoProvider string
+	SsoID       string
+	Email       string
 }
 
 type UserSessionData struct {
@@ -62,7 +58,7 @@ func init() {
 // Returns a user from the database with the uuid provided
 func GetUserByID(db *sql.DB, id uuid.UUID) (*User, error) {
 	row := db.QueryRow(
-		`SELECT id, sso_user_id, email, phone, opt_in_ranking, accepted_terms, role, access_token, token_expires_at, refresh_token,
+		`SELECT id, sso_provider, sso_user_id, email, phone, opt_in_ranking, accepted_terms, role,
 		CONCAT(username_adjective, ' ', username_noun) AS username
 		FROM users
 		WHERE id = $1`,
@@ -73,7 +69,7 @@ func GetUserByID(db *sql.DB, id uuid.UUID) (*User, error) {
 // Returns a user from the database with the email provided
 func GetUserByEmail(db *sql.DB, email string) (*User, error) {
 	row := db.QueryRow(
-		`SELECT id, sso_user_id, email, phone, opt_in_ranking, accepted_terms, role, access_token, token_expires_at, refresh_token,
+		`SELECT id, sso_provider, sso_user_id, email, phone, opt_in_ranking, accepted_terms, role,
 		CONCAT(username_adjective, ' ', username_noun) AS username
 		FROM users
 		WHERE email = $1`,
@@ -81,47 +77,42 @@ func GetUserByEmail(db *sql.DB, email string) (*User, error) {
 	return scanUserFromFullRow(row)
 }
 
-// Returns a user from the database with the SSO ID provided
-func GetUserBySsoID(db *sql.DB, ssoID string) (*User, error) {
+// Returns a user from the database with the SSO ID provided by the given login provider
+func GetUserBySsoID(db *sql.DB, ssoProvider string, ssoID string) (*User, error) {
 	row := db.QueryRow(
-		`SELECT id, sso_user_id, email, phone, opt_in_ranking, accepted_terms, role, access_token, token_expires_at, refresh_token,
+		`SELECT id, sso_provider, sso_user_id, email, phone, opt_in_ranking, accepted_terms, role,
 		CONCAT(username_adjective, ' ', username_noun) AS username
 		FROM users
-		WHERE sso_user_id = $1`,
-		ssoID)
+		WHERE sso_provider = $1 AND sso_user_id = $2`,
+		ssoProvider, ssoID)
 	return scanUserFromFullRow(row)
 }
 
 // Creates a new user in the database
 func CreateUser(db *sql.DB, ctx context.Context, partialUser *PartialUser) (*User, error) {
-	accessTokenCypher := []byte("TODO")
-	refreshtokenCypher := []byte("TODO")
 	user := User{
-		ID:                 uuid.New(),
-		SsoID:              partialUser.SsoID,
-		Email:              partialUser.Email,
-		Phone:              "ikke tilgjengelig",
-		OptInRanking:       true,
-		Role:               user_roles.User,
-		AccessTokenCypher:  accessTokenCypher,
-		TokenExpire:        partialUser.TokenExpire,
-		RefreshTokenCypher: refreshtokenCypher,
+		ID:           uuid.New(),
+		SsoProvider:  partialUser.SsoProvider,
+		SsoID:        partialUser.SsoID,
+		Email:        partialUser.Email,
+		Phone:        "ikke tilgjengelig",
+		OptInRanking: true,
+		Role:         user_roles.User,
 	}
 
 	row := db.QueryRow(
 		`INSERT INTO users
-		(id, sso_user_id, email, phone, opt_in_ranking, role, access_token, token_expires_at, refresh_token, username_adjective, username_noun)
-		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, random_username.adjective, random_username.noun
+		(id, sso_provider, sso_user_id, email, phone, opt_in_ranking, role, username_adjective, username_noun)
+		SELECT $1, $2, $3, $4, $5, $6, $7, random_username.adjective, random_username.noun
 		FROM (
 			SELECT adjective, noun
 			FROM available_usernames 
 			OFFSET floor(random() * (SELECT COUNT(*) FROM available_usernames)) 
 		LIMIT 1) AS random_username
 		RETURNING
-		id, sso_user_id, email, phone, opt_in_ranking, accepted_terms, role, access_token,
-		token_expires_at, refresh_token,CONCAT(username_adjective, ' ', username_noun) AS username;`,
-		user.ID, user.SsoID, user.Email, user.Phone, user.OptInRanking, user.Role.String(),
-		user.AccessTokenCypher, user.TokenExpire, user.RefreshTokenCypher)
+		id, sso_provider, sso_user_id, email, phone, opt_in_ranking, accepted_terms, role,
+		CONCAT(username_adjective, ' ', username_noun) AS username;`,
+		user.ID, user.SsoProvider, user.SsoID, user.Email, user.Phone, user.OptInRanking, user.Role.String())
 
 	insertedUser, err := scanUserFromFullRow(row)
 	if err != nil {
@@ -151,34 +142,19 @@ func GetUserRole(db *sql.DB, id uuid.UUID) (user_roles.Role, error) {
 	return user_roles.RoleFromString(role), err
 }
 
-// Updates the token of the given user in the database.
-func UpdateUserToken(db *sql.DB, userId uuid.UUID, newAccessToken string, newExpiry time.Time, newRefreshToken string) error {
-	accessTokenCypher := []byte("TODO")
-	refreshTokenCypher := []byte("TODO")
-	_, err := db.Exec(
-		`UPDATE users
-		SET access_token = $2, token_expires_at = $3, refresh_token = $4
-		WHERE id = $1`,
-		userId, accessTokenCypher, newExpiry, refreshTokenCypher,
-	)
-	return err
-}
-
 // Updates the role of the given user in the database.
 func scanUserFromFullRow(row *sql.Row) (*User, error) {
 	user := User{}
 	roleString := ""
 	err := row.Scan(
 		&user.ID,
+		&user.SsoProvider,
 		&user.SsoID,
 		&user.Email,
 		&user.Phone,
 		&user.OptInRanking,
 		&user.AcceptedTerms,
 		&roleString,
-		&user.AccessTokenCypher,
-		&user.TokenExpire,
-		&user.RefreshTokenCypher,
 		&user.Username,
 	)
 	if err != nil {
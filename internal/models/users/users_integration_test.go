@@ -6,7 +6,6 @@ import (
 	"context"
 	"database/sql"
 	"testing"
-	"time"
 
 	db_integration_test_suite "github.com/Molnes/Nyhetsjeger/db"
 	"github.com/google/uuid"
@@ -28,14 +27,13 @@ func (s *UsersIntegrationTestSuite) TestCreateUser() {
 	)
 
 	user, err := CreateUser(s.DB, context.Background(), &PartialUser{
-		SsoID:        ssoId,
-		Email:        email,
-		AccessToken:  "nothing",
-		RefreshToken: "nothing2",
-		TokenExpire:  time.Now().Add(time.Hour),
+		SsoProvider: "subscriber",
+		SsoID:       ssoId,
+		Email:       email,
 	})
 	s.Require().NoError(err)
 
+	s.Require().Equal("subscriber", user.SsoProvider)
 	s.Require().Equal(ssoId, user.SsoID)
 	s.Require().Equal(email, user.Email)
 }
@@ -53,13 +51,18 @@ func (s *UsersIntegrationTestSuite) TestGetUserBySsoId() {
 	userById, err := GetUserByID(s.DB, s.InsertedValues.UserId)
 	s.Require().NoError(err)
 
-	user, err := GetUserBySsoID(s.DB, s.InsertedValues.UserSsoId)
+	user, err := GetUserBySsoID(s.DB, "google", s.InsertedValues.UserSsoId)
 	s.Require().NoError(err)
 
 	s.Require().Equal(userById, user)
 
 }
 
+func (s *UsersIntegrationTestSuite) TestGetUserBySsoIdOtherProvider() {
+	_, err := GetUserBySsoID(s.DB, "subscriber", s.InsertedValues.UserSsoId)
+	s.Require().EqualError(err, sql.ErrNoRows.Error())
+}
+
 func (s *UsersIntegrationTestSuite) TestGetUserByEmail() {
 	userById, err := GetUserByID(s.DB, s.InsertedValues.UserId)
 	s.Require().NoError(err)
@@ -75,7 +78,7 @@ func (s *UsersIntegrationTestSuite) TestNonexistentUserById() {
 	s.Require().EqualError(err, sql.ErrNoRows.Error())
 }
 func (s *UsersIntegrationTestSuite) TestNonexistentUserBySsoId() {
-	_, err := GetUserBySsoID(s.DB, "")
+	_, err := GetUserBySsoID(s.DB, "google", "")
 	s.Require().EqualError(err, sql.ErrNoRows.Error())
 }
 func (s *UsersIntegrationTestSuite) TestNonexistentUserByEmail() {
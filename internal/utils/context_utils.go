@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/Molnes/Nyhetsjeger/internal/models/users"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/guest_sessions"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
@@ -14,6 +15,12 @@ func GetUserIDFromCtx(ctx echo.Context) uuid.UUID {
 	return ctx.Get(users.USER_ID_CONTEXT_KEY).(uuid.UUID)
 }
 
+// Returns the guest session id from the given echo context
+// Must be used AFTER guest session middleware sets the guest id in the context!!
+func GetGuestIDFromCtx(ctx echo.Context) uuid.UUID {
+	return ctx.Get(guest_sessions.GUEST_ID_CONTEXT_KEY).(uuid.UUID)
+}
+
 // Adds a value to context.Context of the request in the given echo.Context
 // This key-value pair can be accessed in templates by `ctx.Value(key)`
 func AddToContext(c echo.Context, key any, value any) {
@@ -93,3 +93,8 @@ func englishMonthNameToNorwegian(month string) string {
 		return ""
 	}
 }
+
+// MonthToNorwegianString converts the month of a date to a string in the format "januar 2006"
+func MonthToNorwegianString(date time.Time) string {
+	return englishMonthNameToNorwegian(extractMonthFromDate(date)) + " " + strconv.Itoa(extractYearFromDate(date))
+}
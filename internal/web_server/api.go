@@ -1,18 +1,29 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Molnes/Nyhetsjeger/internal/auth"
 	"github.com/Molnes/Nyhetsjeger/internal/bucket"
 	"github.com/Molnes/Nyhetsjeger/internal/config"
 	"github.com/Molnes/Nyhetsjeger/internal/database"
+	"github.com/Molnes/Nyhetsjeger/internal/encryption"
+	"github.com/Molnes/Nyhetsjeger/internal/live_updates"
+	"github.com/Molnes/Nyhetsjeger/internal/mailer"
+	"github.com/Molnes/Nyhetsjeger/internal/models/ai"
+	"github.com/Molnes/Nyhetsjeger/internal/models/competitions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
 	"github.com/Molnes/Nyhetsjeger/internal/models/sessions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_tokens"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/router"
 	"github.com/labstack/echo/v4"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
 // Sets up the web server and starts it.
@@ -51,13 +62,39 @@ func Api() {
 	if !ok {
 		log.Fatal("No AES key provided. Expected AES_KEY")
 	}
-	cryptoKey := []byte(key_str)
+	keyRing, err := encryption.ParseKeyRing(key_str)
+	if err != nil {
+		log.Fatal("Error reading AES_KEY: ", err)
+	}
 
-	googleOauthConfig, err := getGoogleOauthConfig()
+	authProviders, err := getAuthProviders(context.Background())
 	if err != nil {
-		log.Fatal("Error getting google oauth config: ", err)
+		log.Fatal("Error setting up login providers: ", err)
 	}
 
+	mail, err := mailer.NewMailer(getMailerConfig())
+	if err != nil {
+		log.Fatal("Error setting up mailer: ", err)
+	}
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	// Separate from the session secret, so a leaked login link key can not be used to forge sessions or the other way around
+	linkSigningKey := os.Getenv("LINK_SIGNING_KEY")
+	if mail == nil {
+		log.Println("No mailer configured, login by email is disabled")
+	} else if publicURL == "" {
+		log.Fatal("No public url provided. Expected PUBLIC_URL when a mailer is configured")
+	} else if linkSigningKey == "" {
+		log.Fatal("No link signing key provided. Expected LINK_SIGNING_KEY when a mailer is configured")
+	} else if linkSigningKey == sessionKey {
+		log.Fatal("LINK_SIGNING_KEY must be different from SESSION_SECRET")
+	}
+	if len(authProviders) == 0 && mail == nil {
+		log.Fatal("No login providers configured. Expected GOOGLE_CLIENT_ID, OIDC_PROVIDERS or MAILER")
+	}
+
+	tokens := user_tokens.NewTokenService(databaseConn, keyRing, authProviders)
+	go reencryptTokens(tokens)
+
 	endpoint, ok := os.LookupEnv("BUCKET_URL")
 	accessKeyID, ok := os.LookupEnv("BUCKET_ACCESS_KEY")
 	secretAccessKey, ok := os.LookupEnv("BUCKET_SECRET_KEY")
@@ -68,20 +105,35 @@ func Api() {
 		log.Fatal("Error connecting to bucket: ", err)
 	}
 
-	openAIKey, ok := os.LookupEnv("OPENAI_KEY")
-	if !ok {
-		log.Fatal("No OpenAI key provided. Expected OPENAI_KEY")
+	questionGenerator, err := ai.NewQuestionGenerator(getAiProviderConfig())
+	if err != nil {
+		log.Fatal("Error setting up AI provider: ", err)
+	}
+	if questionGenerator == nil {
+		log.Println("No AI provider configured, AI features are disabled")
 	}
 
+	liveHub := live_updates.NewHub()
+	go live_updates.WatchQuizEndings(context.Background(), databaseConn, liveHub, time.Minute)
+	go quizzes.RunScheduler(context.Background(), databaseConn, liveHub, time.Minute)
+	go competitions.RunResultsFreezer(context.Background(), databaseConn, time.Minute)
+
 	sharedData := &config.SharedData{
 		DB:           databaseConn,
 		SessionStore: sessionStore,
-		CryptoKey:    cryptoKey,
 		Bucket:       minioClient,
-		OpenAIKey:    openAIKey,
+		LiveHub:      liveHub,
+
+		AuthProviders:     authProviders,
+		Tokens:            tokens,
+		Mailer:            mail,
+		PublicURL:         publicURL,
+		LinkSigningKey:    []byte(linkSigningKey),
+		QuestionGenerator: questionGenerator,
+		AnswerGracePeriod: getAnswerGracePeriod(),
 	}
 
-	router.SetupRouter(e, sharedData, googleOauthConfig)
+	router.SetupRouter(e, sharedData)
 
 	port, ok := os.LookupEnv("PORT")
 	if !ok {
@@ -93,28 +145,133 @@ func Api() {
 	e.Logger.Fatal(e.Start(address))
 }
 
-func getGoogleOauthConfig() (*oauth2.Config, error) {
+// Reads the AI provider configuration from the environment.
+//
+// AI_PROVIDER chooses the provider ("openai", "openai-compatible" or "fake").
+// For backwards compatibility OpenAI is used if only OPENAI_KEY is set.
+// AI features are disabled if neither is set.
+func getAiProviderConfig() ai.ProviderConfig {
+	provider := os.Getenv("AI_PROVIDER")
+	openAIKey := os.Getenv("OPENAI_KEY")
+	if provider == "" && openAIKey != "" {
+		provider = string(ai.ProviderOpenAI)
+	}
+
+	apiKey := os.Getenv("AI_API_KEY")
+	if apiKey == "" {
+		apiKey = openAIKey
+	}
+
+	return ai.ProviderConfig{
+		Provider: ai.Provider(provider),
+		APIKey:   apiKey,
+		Model:    os.Getenv("AI_MODEL"),
+		BaseURL:  os.Getenv("AI_BASE_URL"),
+	}
+}
+
+// Reads how many seconds after a question's time limit answers are still accepted from ANSWER_GRACE_SECONDS.
+// Falls back to user_quiz.DefaultAnswerGracePeriod if it is not set or not a valid number of seconds.
+func getAnswerGracePeriod() time.Duration {
+	value := os.Getenv("ANSWER_GRACE_SECONDS")
+	if value == "" {
+		return user_quiz.DefaultAnswerGracePeriod
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		log.Printf("Invalid ANSWER_GRACE_SECONDS %q, using %v", value, user_quiz.DefaultAnswerGracePeriod)
+		return user_quiz.DefaultAnswerGracePeriod
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Re-encrypts tokens encrypted with an old key, so the old key can be removed from AES_KEY once this is done.
+func reencryptTokens(tokens *user_tokens.TokenService) {
+	count, err := tokens.ReencryptAll(context.Background())
+	if err != nil {
+		log.Println("Error re-encrypting tokens: ", err)
+	}
+	if count > 0 {
+		log.Printf("Re-encrypted tokens of %d users with the current key", count)
+	}
+}
+
+// Reads the mailer configuration from the environment.
+//
+// MAILER chooses the mailer ("log", "file" or "smtp"). Emails are not sent if it is not set.
+// The file mailer writes to MAILER_DIR. The SMTP mailer sends through SMTP_ADDRESS, e.g. "smtp.example.com:587",
+// from MAIL_FROM, logging in with SMTP_USERNAME and SMTP_PASSWORD if set.
+func getMailerConfig() mailer.Config {
+	return mailer.Config{
+		Kind:         mailer.Kind(os.Getenv("MAILER")),
+		Dir:          os.Getenv("MAILER_DIR"),
+		SMTPAddress:  os.Getenv("SMTP_ADDRESS"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		From:         os.Getenv("MAIL_FROM"),
+	}
+}
+
+// Sets up the login providers from the environment.
+//
+// Google is enabled if GOOGLE_CLIENT_ID is set.
+// OIDC_PROVIDERS is a comma separated list of OpenID Connect provider names, e.g. "subscriber".
+// Each is configured with OIDC_<NAME>_ISSUER_URL, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET,
+// OIDC_<NAME>_REDIRECT_URL and optionally OIDC_<NAME>_DISPLAY_NAME, where dashes in the name are replaced by underscores.
+func getAuthProviders(ctx context.Context) (auth.Providers, error) {
+	providers := []auth.Provider{}
+
+	if _, ok := os.LookupEnv("GOOGLE_CLIENT_ID"); ok {
+		googleConfig, err := getGoogleSsoConfig()
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, auth.NewGoogleProvider(googleConfig))
+	}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider, err := auth.NewOIDCProvider(ctx, auth.OIDCConfig{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			IssuerURL:    os.Getenv(prefix + "ISSUER_URL"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("login provider %q: %w", name, err)
+		}
+		providers = append(providers, provider)
+	}
+
+	return auth.NewProviders(providers...)
+}
+
+func getGoogleSsoConfig() (auth.GoogleSsoConfig, error) {
 
 	redirectUrl, ok := os.LookupEnv("GOOGLE_REDIRECT_URL")
 	if !ok {
-		return nil, fmt.Errorf("No redirect url provided. Expected GOOGLE_REDIRECT_URL")
+		return auth.GoogleSsoConfig{}, fmt.Errorf("No redirect url provided. Expected GOOGLE_REDIRECT_URL")
 	}
 
 	clientId, ok := os.LookupEnv("GOOGLE_CLIENT_ID")
 	if !ok {
-		return nil, fmt.Errorf("No client id provided. Expected GOOGLE_CLIENT_ID")
+		return auth.GoogleSsoConfig{}, fmt.Errorf("No client id provided. Expected GOOGLE_CLIENT_ID")
 	}
 
 	clientSecret, ok := os.LookupEnv("GOOGLE_CLIENT_SECRET")
 	if !ok {
-		return nil, fmt.Errorf("No client secret provided. Expected GOOGLE_CLIENT_SECRET")
+		return auth.GoogleSsoConfig{}, fmt.Errorf("No client secret provided. Expected GOOGLE_CLIENT_SECRET")
 	}
 
-	return &oauth2.Config{
-		ClientID:     clientId,
+	return auth.GoogleSsoConfig{
+		RedirectUrl:  redirectUrl,
+		ClientId:     clientId,
 		ClientSecret: clientSecret,
-		RedirectURL:  redirectUrl,
-		Endpoint:     google.Endpoint,
-		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email"},
 	}, nil
 }
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"fmt"
+
+	"github.com/Molnes/Nyhetsjeger/internal/config"
+	"github.com/Molnes/Nyhetsjeger/internal/models/sessions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/guest_sessions"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware giving visitors who have not logged in an anonymous guest session, so their quiz progress can be stored on the server.
+type guestSession struct {
+	sharedData *config.SharedData
+}
+
+// Creates new instance of guestSession middleware
+func NewGuestSession(data *config.SharedData) *guestSession {
+	return &guestSession{data}
+}
+
+// Sets the ID of the visitor's guest session in the context, creating a new guest session if the visitor has none.
+func (m *guestSession) EnsureGuestSession(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// An invalid or expired cookie gives a new, empty session along with the error, which is fine for guests.
+		session, _ := m.sharedData.SessionStore.Get(c.Request(), sessions.SESSION_NAME)
+		if session == nil {
+			return fmt.Errorf("GuestSessionMiddleware: failed to get session")
+		}
+
+		guestIDValue, _ := session.Values[sessions.GUEST_ID_VALUE].(string)
+		guestID, err := uuid.Parse(guestIDValue)
+		if err == nil {
+			err = guest_sessions.Touch(m.sharedData.DB, guestID)
+			if err != nil && err != guest_sessions.ErrNoSuchSession {
+				return err
+			}
+		}
+		if err != nil {
+			guestID, err = guest_sessions.Create(m.sharedData.DB)
+			if err != nil {
+				return err
+			}
+			session.Values[sessions.GUEST_ID_VALUE] = guestID.String()
+			err = session.Save(c.Request(), c.Response())
+			if err != nil {
+				return fmt.Errorf("GuestSessionMiddleware: failed to save session: %s", err.Error())
+			}
+		}
+
+		c.Set(guest_sessions.GUEST_ID_CONTEXT_KEY, guestID)
+		return next(c)
+	}
+}
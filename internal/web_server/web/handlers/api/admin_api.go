@@ -15,8 +15,8 @@ import (
 	"time"
 
 	"github.com/Molnes/Nyhetsjeger/internal/config"
-	"github.com/Molnes/Nyhetsjeger/internal/models/ai"
 	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+	"github.com/Molnes/Nyhetsjeger/internal/models/audit_log"
 	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
 	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
 	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
@@ -59,7 +59,10 @@ const (
 	errorQuizElementID     = "error-quiz"
 	errorQuestionListID    = "error-question-list"
 	errorAiQuestion        = "error-ai-question"
+	errorGenerateDraftQuiz = "error-generate-draft-quiz"
+	errorAiDisabled        = "KI-funksjoner er ikke aktivert på denne serveren"
 	errorConvertingTime    = "Kunne ikke konvertere norsk tid til UTC+00"
+	errorBlockedUsername   = "error-blocked-username"
 	headerType             = "Content-Type"
 )
 
@@ -93,10 +96,20 @@ func (aah *AdminApiHandler) RegisterAdminApiHandlers(e *echo.Group) {
 	e.POST("/quiz/edit-start", aah.editQuizActiveStart)
 	e.POST("/quiz/edit-end", aah.editQuizActiveEnd)
 	e.POST("/quiz/edit-published-status", aah.editQuizPublished)
+	e.POST("/quiz/edit-schedule", aah.editQuizSchedule)
+	e.POST("/quiz/unarchive", aah.unarchiveQuiz)
+	e.POST("/quiz/edit-open-for-guests", aah.editQuizOpenForGuests)
+	e.POST("/quiz/edit-scoring", aah.editQuizScoring)
 	e.DELETE("/quiz/delete-quiz", aah.deleteQuiz)
+	e.POST("/quiz/duplicate", aah.duplicateQuiz)
+	e.POST("/quiz/save-as-template", aah.saveQuizAsTemplate)
+	e.POST("/quiz/create-from-template", aah.createQuizFromTemplate)
+	e.GET("/quiz/export", aah.exportQuiz)
+	e.POST("/quiz/import", aah.importQuiz)
 	e.POST("/quiz/add-article", aah.addArticleToQuiz)
 	e.DELETE("/quiz/delete-article", aah.deleteArticle)
 	e.POST("/quiz/rearrange-questions", aah.rearrangeQuestions)
+	e.POST("/quiz/generate-draft", aah.generateDraftQuiz)
 	e.GET("/quiz/image/update-suggestions", aah.imageSuggestionsQuiz)
 
 	e.POST("/question/edit", aah.editQuestion)
@@ -105,11 +118,17 @@ func (aah *AdminApiHandler) RegisterAdminApiHandlers(e *echo.Group) {
 	e.DELETE("/question/edit-image", aah.deleteQuestionImage)
 	e.DELETE("/question/delete", aah.deleteQuestion)
 	e.POST("/question/randomize-alternatives", aah.randomizeAlternatives)
+	e.POST("/question/type-editor", aah.questionTypeEditor)
 	e.GET("/question/image/update-suggestions", aah.imageSuggestionsQuestion)
 
 	e.POST("/username", aah.addUsername)
 	e.DELETE("/username", aah.deleteUsername)
 	e.POST("/username/edit", aah.editUsername)
+	e.GET("/username/export", aah.exportUsernameWords)
+	e.POST("/username/import/preview", aah.previewUsernameImport)
+	e.POST("/username/import", aah.importUsernameWords)
+	e.POST("/username/blocked", aah.blockUsername)
+	e.DELETE("/username/blocked", aah.unblockUsername)
 
 	e.POST("/user-ranking/generate-table", aah.generateUserRankingsTable)
 	e.POST("/username/page", aah.getUsernamePages)
@@ -119,6 +138,7 @@ func (aah *AdminApiHandler) RegisterAdminApiHandlers(e *echo.Group) {
 	e.DELETE("/label", aah.deleteLabel)
 	e.POST("/label/add", aah.addLabel)
 	e.POST("/label/edit-labels", aah.editLabels)
+	e.POST("/label/edit-competition", aah.editCompetition)
 
 	e.POST("/quiz/edit-labels", aah.editQuizLabels)
 	e.DELETE("/quiz/edit-labels", aah.deleteQuizLabel)
@@ -141,6 +161,7 @@ func (aah *AdminApiHandler) addLabel(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionCreate, audit_log.TargetLabel, labelID.String(), nil, label)
 
 	return utils.Render(c, http.StatusOK, label_components.LabelItem(label))
 }
@@ -168,10 +189,69 @@ func (aah *AdminApiHandler) editLabels(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetLabel, labelID.String(), currentLabel, label)
 
 	return utils.Render(c, http.StatusOK, label_components.LabelItem(label))
 }
 
+// Sets when the label as a competition starts and ends, and its prize. Leaving the end empty means the label is not a competition.
+func (aah *AdminApiHandler) editCompetition(c echo.Context) error {
+	const errorCompetition = "error-competition"
+
+	labelID, err := uuid.Parse(c.QueryParam("label-id"))
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorCompetition, "Ugyldig eller manglende label-id"))
+	}
+
+	startsAt, err := parseOptionalNorwayTime(c.FormValue("competition-start"))
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorCompetition, "Ugyldig starttidspunkt"))
+	}
+	endsAt, err := parseOptionalNorwayTime(c.FormValue("competition-end"))
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorCompetition, "Ugyldig sluttidspunkt"))
+	}
+	if startsAt.Valid && !endsAt.Valid {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorCompetition, "En konkurranse med start må også ha en slutt"))
+	}
+
+	currentLabel, err := labels.GetLabelByID(aah.sharedData.DB, labelID)
+	if err != nil {
+		return err
+	}
+
+	err = labels.UpdateCompetition(aah.sharedData.DB, labelID, startsAt, endsAt, strings.TrimSpace(c.FormValue("competition-prize")))
+	if err != nil {
+		switch err {
+		case labels.ErrInvalidCompetitionPeriod:
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorCompetition, "Starten må være før slutten"))
+		case labels.ErrResultsFrozen:
+			return utils.Render(c, http.StatusConflict, components.ErrorText(errorCompetition, "Resultatene er låst og konkurransen kan ikke endres"))
+		}
+		return err
+	}
+
+	label, err := labels.GetLabelByID(aah.sharedData.DB, labelID)
+	if err != nil {
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetLabel, labelID.String(), currentLabel, label)
+
+	return utils.Render(c, http.StatusOK, label_components.LabelItem(label))
+}
+
+// Parses a datetime-local value in Norway's timezone. An empty value gives a time which is not valid.
+func parseOptionalNorwayTime(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := data_handling.NorwayTimeToUtc(value)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
 func (aah *AdminApiHandler) editQuizLabels(c echo.Context) error {
 	// Get the quiz ID
 	quizID, err := uuid.Parse(c.QueryParam(queryParamQuizID))
@@ -190,6 +270,7 @@ func (aah *AdminApiHandler) editQuizLabels(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quizID.String(), nil, map[string]any{"label_id": labelID})
 
 	// get active labels
 	activeLabels, err := labels.GetActiveLabels(aah.sharedData.DB)
@@ -224,6 +305,7 @@ func (aah *AdminApiHandler) deleteQuizLabel(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quizID.String(), map[string]any{"label_id": labelID}, nil)
 
 	// get active labels
 	activeLabels, err := labels.GetActiveLabels(aah.sharedData.DB)
@@ -247,11 +329,17 @@ func (aah *AdminApiHandler) deleteLabel(c echo.Context) error {
 		return utils.Render(c, http.StatusBadRequest, components.ErrorText("error-label", "Ugyldig eller manglende label-id"))
 	}
 
+	label, err := labels.GetLabelByID(aah.sharedData.DB, labelID)
+	if err != nil {
+		return err
+	}
+
 	// Delete the label from the database
 	err = labels.RemoveLabel(aah.sharedData.DB, labelID)
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionDelete, audit_log.TargetLabel, labelID.String(), label, nil)
 
 	return c.NoContent(http.StatusOK)
 }
@@ -270,6 +358,10 @@ func (aah *AdminApiHandler) getAiQuestion(c echo.Context) error {
 		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorAiQuestion, errorArticleURL))
 	}
 
+	if aah.sharedData.QuestionGenerator == nil {
+		return utils.Render(c, http.StatusServiceUnavailable, components.ErrorText(errorAiQuestion, errorAiDisabled))
+	}
+
 	// Get the SMP articleSmp
 	articleSmp, err := articles.GetSmpArticleByURL(articleUrl)
 	if err != nil {
@@ -277,9 +369,10 @@ func (aah *AdminApiHandler) getAiQuestion(c echo.Context) error {
 	}
 
 	// Generate a question
-	aiQuestion, err := ai.GetJsonQuestions(c.Request().Context(), articleSmp, aah.sharedData.OpenAIKey)
+	aiQuestion, err := aah.sharedData.QuestionGenerator.GenerateQuestion(c.Request().Context(), articleSmp)
 	if err != nil {
-		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorAiQuestion, "Kunne ikke generere spørsmål"))
+		log.Println("Failed to generate question: ", err)
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorAiQuestion, aiErrorMessage(err)))
 	}
 
 	// Get the current article
@@ -309,6 +402,51 @@ func (aah *AdminApiHandler) getAiQuestion(c echo.Context) error {
 	return utils.Render(c, http.StatusOK, dashboard_components.EditQuestionForm(newQuestion, chosenArticle, articleList, quizId.String(), isNew))
 }
 
+// Generates draft questions for every article in the quiz using artificial intelligence.
+// Failures are reported per article in the rendered result.
+func (aah *AdminApiHandler) generateDraftQuiz(c echo.Context) error {
+	quizId, err := uuid.Parse(c.QueryParam("quiz-id"))
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorGenerateDraftQuiz, errorInvalidQuizID))
+	}
+
+	if aah.sharedData.QuestionGenerator == nil {
+		return utils.Render(c, http.StatusServiceUnavailable, components.ErrorText(errorGenerateDraftQuiz, errorAiDisabled))
+	}
+
+	questionsPerArticle, err := strconv.Atoi(c.FormValue(dashboard_components.QuestionsPerArticle))
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorGenerateDraftQuiz, "Ugyldig antall spørsmål per artikkel"))
+	}
+
+	results, err := questions.GenerateDraftQuestions(aah.sharedData.DB, c.Request().Context(), aah.sharedData.QuestionGenerator, quizId, questionsPerArticle)
+	if err != nil {
+		if err == questions.ErrInvalidQuestionsPerArticle {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorGenerateDraftQuiz,
+				fmt.Sprintf("Antall spørsmål per artikkel må være mellom 1 og %d", questions.MaxDraftQuestionsPerArticle)))
+		}
+		return utils.Render(c, http.StatusInternalServerError, components.ErrorText(errorGenerateDraftQuiz, "Kunne ikke hente artikler for quizen"))
+	}
+
+	generatedQuestions := []map[string]any{}
+	for _, result := range results {
+		if result.Err != nil {
+			log.Printf("Failed to generate draft questions for article %s: %v", result.Article.ArticleURL.String(), result.Err)
+		}
+		if result.Duplicates > 0 {
+			log.Printf("Dropped %d duplicate draft questions for article %s", result.Duplicates, result.Article.ArticleURL.String())
+		}
+		for i := range result.Questions {
+			generatedQuestions = append(generatedQuestions, questionAuditValue(&result.Questions[i]))
+		}
+	}
+	if len(generatedQuestions) > 0 {
+		recordAudit(c, aah.sharedData.DB, audit_log.ActionGenerate, audit_log.TargetQuiz, quizId.String(), nil, map[string]any{"questions": generatedQuestions})
+	}
+
+	return utils.Render(c, http.StatusOK, dashboard_components.GenerateDraftQuizResult(results))
+}
+
 // Handles the creation of a new default quiz in the DB.
 // Redirects to the edit quiz page for the newly created quiz.
 func (aah *AdminApiHandler) createDefaultQuiz(c echo.Context) error {
@@ -320,6 +458,7 @@ func (aah *AdminApiHandler) createDefaultQuiz(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionCreate, audit_log.TargetQuiz, quizID.String(), nil, quizAuditValue(&quiz))
 
 	c.Response().Header().Set("HX-Redirect", "/dashboard/edit-quiz?quiz-id="+quizID.String())
 	return c.Redirect(http.StatusOK, "/dashboard/edit-quiz?quiz-id="+quizID.String())
@@ -339,10 +478,17 @@ func (aah *AdminApiHandler) editQuizTitle(c echo.Context) error {
 		return utils.Render(c, http.StatusBadRequest, components.ErrorText("error-title", "Tittelen kan ikke være tom"))
 	}
 
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
 	err = quizzes.UpdateTitleByQuizID(aah.sharedData.DB, quiz_id, title)
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"title": quiz.Title}, map[string]any{"title": title})
 
 	return utils.Render(c, http.StatusOK, dashboard_components.EditTitleInput(
 		title, quiz_id.String(), dashboard_pages.QuizTitle, ""))
@@ -373,11 +519,18 @@ func (aah *AdminApiHandler) editQuizImage(c echo.Context) error {
 		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorImageElementID, errorUploadImage))
 	}
 
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
 	// Set the image URL for the quiz
 	err = quizzes.UpdateImageByQuizID(aah.sharedData.DB, quiz_id, *imageURL)
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"image_url": quiz.ImageURL.String()}, map[string]any{"image_url": imageURL.String()})
 
 	return utils.Render(c, http.StatusOK, dashboard_components.EditImageInput(
 		fmt.Sprintf(editQuizImageURL, quiz_id), fmt.Sprintf(editQuizImageFile, quiz_id),
@@ -416,11 +569,18 @@ func (aah *AdminApiHandler) uploadQuizImage(c echo.Context) error {
 		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorImageElementID, "Kunne ikke fullføre bildeopplastingen"))
 	}
 
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
 	err = quizzes.UpdateImageByQuizID(aah.sharedData.DB, quiz_id, *imageAsURL)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"image_url": quiz.ImageURL.String()}, map[string]any{"image_url": imageAsURL.String()})
 
 	return utils.Render(c, http.StatusOK, dashboard_components.EditImageInput(
 		fmt.Sprintf(editQuizImageURL, quiz_id), fmt.Sprintf(editQuizImageFile, quiz_id),
@@ -435,11 +595,18 @@ func (dph *AdminApiHandler) deleteQuizImage(c echo.Context) error {
 		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorImageElementID, errorInvalidQuizID))
 	}
 
+	quiz, err := quizzes.GetQuizByID(dph.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
 	// Set the image URL to nil
 	err = quizzes.RemoveImageByQuizID(dph.sharedData.DB, quiz_id)
 	if err != nil {
 		return err
 	}
+	recordAudit(c, dph.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"image_url": quiz.ImageURL.String()}, map[string]any{"image_url": ""})
 
 	return utils.Render(c, http.StatusOK, dashboard_components.EditImageInput(
 		fmt.Sprintf(editQuizImageURL, quiz_id), fmt.Sprintf(editQuizImageFile, quiz_id),
@@ -455,16 +622,101 @@ func (aah *AdminApiHandler) deleteQuiz(c echo.Context) error {
 			fmt.Sprintf("Kunne ikke slette quiz: %s. (Feil oppstod: %s)", errorInvalidQuizID, data_handling.GetNorwayTime(time.Now()))))
 	}
 
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
 	// Sets the quiz as deleted in the database
 	err = quizzes.DeleteQuizByID(aah.sharedData.DB, quiz_id)
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionDelete, audit_log.TargetQuiz, quiz_id.String(), quizAuditValue(quiz), nil)
 
 	c.Response().Header().Set("HX-Redirect", "/dashboard")
 	return c.Redirect(http.StatusOK, "/dashboard")
 }
 
+// Copies a quiz with all its questions into a new unpublished quiz, active one week later than the original.
+// Redirects to the edit quiz page for the copy.
+func (aah *AdminApiHandler) duplicateQuiz(c echo.Context) error {
+	quiz, err := aah.getQuizFromQueryParam(c)
+	if err != nil {
+		return err
+	}
+
+	newQuizID, err := quizzes.DuplicateQuiz(aah.sharedData.DB, c.Request().Context(), quiz.ID,
+		"Kopi av "+quiz.Title, quiz.ActiveFrom.Add(quizzes.DefaultDuplicateShift), false)
+	if err != nil {
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionDuplicate, audit_log.TargetQuiz, newQuizID.String(),
+		nil, map[string]any{"source_quiz_id": quiz.ID, "title": "Kopi av " + quiz.Title})
+
+	c.Response().Header().Set("HX-Redirect", "/dashboard/edit-quiz?quiz-id="+newQuizID.String())
+	return c.NoContent(http.StatusOK)
+}
+
+// Saves a copy of a quiz as a reusable template.
+// Redirects to the templates page.
+func (aah *AdminApiHandler) saveQuizAsTemplate(c echo.Context) error {
+	quiz, err := aah.getQuizFromQueryParam(c)
+	if err != nil {
+		return err
+	}
+
+	templateID, err := quizzes.DuplicateQuiz(aah.sharedData.DB, c.Request().Context(), quiz.ID, quiz.Title, quiz.ActiveFrom, true)
+	if err != nil {
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionDuplicate, audit_log.TargetQuiz, templateID.String(),
+		nil, map[string]any{"source_quiz_id": quiz.ID, "title": quiz.Title, "is_template": true})
+
+	c.Response().Header().Set("HX-Redirect", "/dashboard/templates")
+	return c.NoContent(http.StatusOK)
+}
+
+// Creates a new unpublished quiz from a template, active from now.
+// Redirects to the edit quiz page for the new quiz.
+func (aah *AdminApiHandler) createQuizFromTemplate(c echo.Context) error {
+	template, err := aah.getQuizFromQueryParam(c)
+	if err != nil {
+		return err
+	}
+	if !template.IsTemplate {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizElementID, "Quizen er ikke en mal"))
+	}
+
+	newQuizID, err := quizzes.DuplicateQuiz(aah.sharedData.DB, c.Request().Context(), template.ID, template.Title, time.Now(), false)
+	if err != nil {
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionDuplicate, audit_log.TargetQuiz, newQuizID.String(),
+		nil, map[string]any{"source_quiz_id": template.ID, "title": template.Title})
+
+	c.Response().Header().Set("HX-Redirect", "/dashboard/edit-quiz?quiz-id="+newQuizID.String())
+	return c.NoContent(http.StatusOK)
+}
+
+// Gets the quiz with the ID in the quiz-id query parameter.
+// Returns an echo.HTTPError if the ID is invalid or the quiz is not found.
+func (aah *AdminApiHandler) getQuizFromQueryParam(c echo.Context) (*quizzes.Quiz, error) {
+	quizID, err := uuid.Parse(c.QueryParam(queryParamQuizID))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, errorInvalidQuizID)
+	}
+
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quizID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Fant ikke quizen")
+		}
+		return nil, err
+	}
+	return quiz, nil
+}
+
 // Updates the published status of a quiz in the database.
 // If the quiz is published, it will be unpublished, and vice versa.
 func (aah *AdminApiHandler) editQuizPublished(c echo.Context) error {
@@ -475,21 +727,196 @@ func (aah *AdminApiHandler) editQuizPublished(c echo.Context) error {
 			fmt.Sprintf("Kunne ikke skjule/publisere quiz: %s. (Feil oppstod: %s)", errorInvalidQuizID, data_handling.GetNorwayTime(time.Now()))))
 	}
 
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
 	// Update the quiz published status
 	published := c.FormValue(dashboard_pages.QuizPublished)
-	err = quizzes.UpdatePublishedStatusByQuizID(aah.sharedData.DB, c.Request().Context(), quiz_id, published == "on")
+	err = quizzes.UpdatePublishedStatusByQuizID(aah.sharedData.DB, c.Request().Context(), aah.sharedData.LiveHub, quiz_id, published == "on")
 	if err != nil {
 		if err == quizzes.ErrNoQuestions {
 			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizElementID,
 				"Kan ikke publisere en quiz uten spørsmål. Legg til minst ett spørsmål før du publiserer quizen."))
 		}
+		if err == quizzes.ErrTemplateNotPublishable {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizElementID,
+				"Kan ikke publisere en mal. Lag en ny quiz fra malen i stedet."))
+		}
 
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"published": quiz.Published}, map[string]any{"published": published == "on"})
 
 	return utils.Render(c, http.StatusOK, dashboard_components.ToggleQuizPublished(published == "on", quiz_id.String(), dashboard_pages.QuizPublished))
 }
 
+const errorQuizScheduleElementID = "error-quiz-schedule"
+
+// Updates when a quiz is published automatically, and what happens to it once it is no longer active.
+// An empty publish time removes the scheduled publishing.
+func (aah *AdminApiHandler) editQuizSchedule(c echo.Context) error {
+	// Get the quiz ID
+	quiz_id, err := uuid.Parse(c.QueryParam(queryParamQuizID))
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScheduleElementID, errorInvalidQuizID))
+	}
+
+	// Get the time in Norway's timezone
+	var publishAt sql.NullTime
+	if publishAtValue := c.FormValue(dashboard_pages.QuizPublishAt); publishAtValue != "" {
+		publishAtTime, err := data_handling.NorwayTimeToUtc(publishAtValue)
+		if err != nil {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScheduleElementID, errorConvertingTime))
+		}
+		publishAt = sql.NullTime{Time: publishAtTime, Valid: true}
+	}
+
+	endAction := quizzes.EndAction(c.FormValue(dashboard_pages.QuizEndAction))
+	if !endAction.IsValid() {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScheduleElementID, "Ugyldig valg for når quizen ikke lenger er aktiv"))
+	}
+
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
+	err = quizzes.UpdateScheduleByQuizID(aah.sharedData.DB, quiz_id, publishAt, endAction)
+	if err != nil {
+		if err == quizzes.ErrTemplateNotPublishable {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScheduleElementID,
+				"Kan ikke publisere en mal. Lag en ny quiz fra malen i stedet."))
+		}
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"publish_at": nullTimeAuditValue(quiz.PublishAt), "end_action": quiz.EndAction},
+		map[string]any{"publish_at": nullTimeAuditValue(publishAt), "end_action": endAction})
+
+	return utils.Render(c, http.StatusOK, dashboard_components.EditQuizSchedule(quiz_id.String(), publishAt, endAction,
+		quiz.IsArchived, dashboard_pages.QuizPublishAt, dashboard_pages.QuizEndAction))
+}
+
+// Moves an archived quiz back to the quiz lists on the dashboard.
+func (aah *AdminApiHandler) unarchiveQuiz(c echo.Context) error {
+	// Get the quiz ID
+	quiz_id, err := uuid.Parse(c.QueryParam(queryParamQuizID))
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScheduleElementID, errorInvalidQuizID))
+	}
+
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
+	err = quizzes.UnarchiveQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"is_archived": quiz.IsArchived}, map[string]any{"is_archived": false})
+
+	return utils.Render(c, http.StatusOK, dashboard_components.EditQuizSchedule(quiz_id.String(), quiz.PublishAt, quiz.EndAction,
+		false, dashboard_pages.QuizPublishAt, dashboard_pages.QuizEndAction))
+}
+
+const errorQuizGuestsElementID = "error-quiz-guests"
+
+// Updates whether guests can play a quiz without logging in.
+func (aah *AdminApiHandler) editQuizOpenForGuests(c echo.Context) error {
+	// Get the quiz ID
+	quiz_id, err := uuid.Parse(c.QueryParam(queryParamQuizID))
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizGuestsElementID, errorInvalidQuizID))
+	}
+
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
+	openForGuests := c.FormValue(dashboard_pages.QuizOpenForGuests) == "on"
+	err = quizzes.UpdateOpenForGuestsByQuizID(aah.sharedData.DB, quiz_id, openForGuests)
+	if err != nil {
+		if err == quizzes.ErrTemplateNotOpenForGuests {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizGuestsElementID,
+				"Kan ikke åpne en mal for gjester. Lag en ny quiz fra malen i stedet."))
+		}
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"open_for_guests": quiz.OpenForGuests}, map[string]any{"open_for_guests": openForGuests})
+
+	return utils.Render(c, http.StatusOK, dashboard_components.ToggleQuizOpenForGuests(openForGuests, quiz_id.String(), dashboard_pages.QuizOpenForGuests))
+}
+
+const errorQuizScoringElementID = "error-quiz-scoring"
+
+// Updates how points are awarded in a quiz.
+// Settings not used by the chosen rule are not shown, so those missing from the form keep their current value.
+func (aah *AdminApiHandler) editQuizScoring(c echo.Context) error {
+	// Get the quiz ID
+	quiz_id, err := uuid.Parse(c.QueryParam(queryParamQuizID))
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScoringElementID, errorInvalidQuizID))
+	}
+
+	policy, err := quizzes.GetScoringPolicyByQuizID(aah.sharedData.DB, quiz_id)
+	if err == sql.ErrNoRows {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScoringElementID, errorInvalidQuizID))
+	} else if err != nil {
+		return err
+	}
+	before := scoringPolicyAuditValue(policy)
+
+	policy.Rule = quizzes.ScoringRule(c.FormValue(dashboard_pages.QuizScoringRule))
+	settings := map[string]*uint{
+		dashboard_pages.QuizScoringGraceSeconds:       &policy.GraceSeconds,
+		dashboard_pages.QuizScoringMinPoints:          &policy.MinPointsPercent,
+		dashboard_pages.QuizScoringStreakBonus:        &policy.StreakBonusPercent,
+		dashboard_pages.QuizScoringWrongAnswerPenalty: &policy.WrongAnswerPenaltyPercent,
+	}
+	for name, setting := range settings {
+		value := c.FormValue(name)
+		if value == "" {
+			continue
+		}
+		// Limited to 31 bits, so the value fits the INTEGER columns.
+		parsed, err := strconv.ParseUint(value, 10, 31)
+		if err != nil {
+			if errors.Is(err, strconv.ErrRange) {
+				return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScoringElementID, "Verdiene er for store"))
+			}
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScoringElementID, "Verdiene må være hele tall, 0 eller større"))
+		}
+		*setting = uint(parsed)
+	}
+
+	err = quizzes.UpdateScoringPolicy(aah.sharedData.DB, policy)
+	if err != nil {
+		switch err {
+		case quizzes.ErrInvalidScoringRule:
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScoringElementID, "Ugyldig poengregel"))
+		case quizzes.ErrInvalidScoringPercent:
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScoringElementID, "Prosentene kan ikke være over 100"))
+		case quizzes.ErrInvalidGraceSeconds:
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizScoringElementID,
+				fmt.Sprintf("Sekunder med fulle poeng kan ikke være over %d", quizzes.MaxGraceSeconds)))
+		}
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		before, scoringPolicyAuditValue(policy))
+
+	return utils.Render(c, http.StatusOK, dashboard_components.EditQuizScoring(quiz_id.String(), policy,
+		dashboard_pages.QuizScoringRule, dashboard_pages.QuizScoringGraceSeconds, dashboard_pages.QuizScoringMinPoints,
+		dashboard_pages.QuizScoringStreakBonus, dashboard_pages.QuizScoringWrongAnswerPenalty))
+}
+
 const errorActiveTimeElementID = "error-active-time"
 
 // Updates the active start time of a quiz in the database.
@@ -519,11 +946,18 @@ func (aah *AdminApiHandler) editQuizActiveStart(c echo.Context) error {
 			errorActiveTimeElementID, "Starttidspunktet må være før sluttidspunktet"))
 	}
 
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
 	// Update the quiz active start
 	err = quizzes.UpdateActiveStartByQuizID(aah.sharedData.DB, quiz_id, activeStartTime)
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"active_from": quiz.ActiveFrom}, map[string]any{"active_from": activeStartTime})
 
 	return utils.Render(c, http.StatusOK, composite_components.EditActiveTimeInput(
 		quiz_id.String(), activeStartTime, dashboard_pages.QuizActiveFrom,
@@ -557,11 +991,18 @@ func (aah *AdminApiHandler) editQuizActiveEnd(c echo.Context) error {
 			errorActiveTimeElementID, "Sluttidspunktet må være etter starttidspunktet"))
 	}
 
+	quiz, err := quizzes.GetQuizByID(aah.sharedData.DB, quiz_id)
+	if err != nil {
+		return err
+	}
+
 	// Update the quiz active end
 	err = quizzes.UpdateActiveEndByQuizID(aah.sharedData.DB, quiz_id, activeEndTime)
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"active_to": quiz.ActiveTo}, map[string]any{"active_to": activeEndTime})
 
 	return utils.Render(c, http.StatusOK, composite_components.EditActiveTimeInput(
 		quiz_id.String(), activeStartTime, dashboard_pages.QuizActiveFrom,
@@ -652,6 +1093,8 @@ func (aah *AdminApiHandler) addArticleToQuiz(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		nil, map[string]any{"article_id": article.ID.UUID, "article_url": article.ArticleURL.String()})
 
 	// Set HX-Reswap header to "outerHTML" for error response
 	c.Response().Header().Set(hxReswap, "beforeend")
@@ -679,6 +1122,8 @@ func (aah *AdminApiHandler) deleteArticle(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quiz_id.String(),
+		map[string]any{"article_id": article_id}, nil)
 
 	return c.NoContent(http.StatusOK)
 }
@@ -707,6 +1152,8 @@ func (aah *AdminApiHandler) rearrangeQuestions(c echo.Context) error {
 		}
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuiz, quizID.String(),
+		nil, map[string]any{"question_arrangement": questionsList})
 
 	return c.NoContent(http.StatusOK)
 }
@@ -762,19 +1209,26 @@ func (aah *AdminApiHandler) editQuestion(c echo.Context) error {
 		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuestionElementID, errorInvalidQuestionID))
 	}
 
+	questionType := questionTypeFromForm(c)
+	var numericAnswer, numericTolerance float64
+	if questionType == questions.QuestionTypeNumericEstimate {
+		numericAnswer, err = questions.ParseNumber(c.FormValue(dashboard_components.QuestionNumericAnswer))
+		if err != nil {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuestionElementID, "Klarte ikke å tolke det riktige svaret"))
+		}
+		numericTolerance, err = questions.ParseNumber(c.FormValue(dashboard_components.QuestionNumericTolerance))
+		if err != nil {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuestionElementID, "Klarte ikke å tolke toleransen"))
+		}
+	}
+
 	// Get the alternatives from the form.
 	// There are always 4 alternatives, but some may be empty.
-	var alternatives [4]questions.PartialAlternative
-	for index := range 4 {
-		// The alternatives match the arrangement number (1, 2, 3, 4, etc.) not the index number.
-		tempId := c.FormValue(fmt.Sprintf("question-alternative-%d-id", index+1))
-		alternativeId, _ := uuid.Parse(tempId)
-		alternativeText := c.FormValue(fmt.Sprintf("question-alternative-%d", index+1))
-		isCorrect := c.FormValue(fmt.Sprintf("question-alternative-%d-is-correct", index+1))
-		if alternativeText != "" && tempId == "" {
-			alternativeId = uuid.New()
+	alternatives := alternativesFromForm(c, questionType)
+	for index, alternative := range alternatives {
+		if alternative.Text != "" && alternative.ID == uuid.Nil {
+			alternatives[index].ID = uuid.New()
 		}
-		alternatives[index] = questions.PartialAlternative{ID: alternativeId, Text: alternativeText, IsCorrect: isCorrect == "on"}
 	}
 
 	var hasFile = true
@@ -822,10 +1276,15 @@ func (aah *AdminApiHandler) editQuestion(c echo.Context) error {
 		QuizID:           &quizID,
 		Points:           points,
 		TimeLimitSeconds: time,
+		Type:             questionType,
+		NumericAnswer:    numericAnswer,
+		NumericTolerance: numericTolerance,
+		NumericUnit:      c.FormValue(dashboard_components.QuestionNumericUnit),
+		Explanation:      c.FormValue(dashboard_components.QuestionExplanation),
 		Alternatives:     alternatives,
 	}
 
-	question, err := createOrEditQuestion(c, questionForm, aah.sharedData.DB)
+	question, previous, err := createOrEditQuestion(c, questionForm, aah.sharedData.DB)
 	if err != nil {
 		switch err {
 		case errQuestionFromForm:
@@ -835,11 +1294,45 @@ func (aah *AdminApiHandler) editQuestion(c echo.Context) error {
 			return err
 		}
 	}
+	if previous == nil {
+		recordAudit(c, aah.sharedData.DB, audit_log.ActionCreate, audit_log.TargetQuestion, question.ID.String(), nil, questionAuditValue(question))
+	} else {
+		recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuestion, question.ID.String(), questionAuditValue(previous), questionAuditValue(question))
+	}
 
 	// Return the "question item" element.
 	return utils.Render(c, http.StatusOK, dashboard_components.QuestionListItem(question))
 }
 
+// Returns the type of question chosen in the question form. Forms without a type are for single choice questions.
+func questionTypeFromForm(c echo.Context) questions.QuestionType {
+	questionType := questions.QuestionType(c.FormValue(dashboard_components.QuestionType))
+	if questionType == "" {
+		return questions.QuestionTypeSingleChoice
+	}
+	return questionType
+}
+
+// Returns the 4 alternatives of the question form, some of which may be empty.
+// The correct alternative of true/false questions is chosen with radio buttons instead of checkboxes.
+func alternativesFromForm(c echo.Context, questionType questions.QuestionType) [4]questions.PartialAlternative {
+	var alternatives [4]questions.PartialAlternative
+	for index := range 4 {
+		// The alternatives match the arrangement number (1, 2, 3, 4, etc.) not the index number.
+		alternativeId, _ := uuid.Parse(c.FormValue(fmt.Sprintf("question-alternative-%d-id", index+1)))
+		alternativeText := c.FormValue(fmt.Sprintf("question-alternative-%d", index+1))
+		isCorrect := c.FormValue(fmt.Sprintf("question-alternative-%d-is-correct", index+1))
+		alternatives[index] = questions.PartialAlternative{ID: alternativeId, Text: alternativeText, IsCorrect: isCorrect == "on"}
+	}
+
+	if questionType == questions.QuestionTypeTrueFalse {
+		isTrue := c.FormValue(dashboard_components.QuestionTrueFalseAnswer)
+		alternatives[0].IsCorrect = isTrue == "true"
+		alternatives[1].IsCorrect = isTrue == "false"
+	}
+	return alternatives
+}
+
 // Uploads an image to the bucket from a file.
 func (aah *AdminApiHandler) handleImageUploadFromFile(c echo.Context, imageFile *multipart.FileHeader) (*url.URL, error) {
 	// Upload image from File
@@ -880,12 +1373,13 @@ func (aah AdminApiHandler) handleImageUpload(imageName string) (*url.URL, error)
 }
 
 // Creates or edit a question in the database.
-func createOrEditQuestion(c echo.Context, questionForm questions.QuestionForm, db *sql.DB) (*questions.Question, error) {
+// Returns the question as it was before it was edited, or nil if it was created.
+func createOrEditQuestion(c echo.Context, questionForm questions.QuestionForm, db *sql.DB) (*questions.Question, *questions.Question, error) {
 	question, errorText := questions.CreateQuestionFromForm(questionForm)
 	if errorText != "" {
 
 		errQuestionFromForm = errors.New(errorText)
-		return nil, errQuestionFromForm
+		return nil, nil, errQuestionFromForm
 	}
 
 	// Get the question by ID from the database.
@@ -896,8 +1390,9 @@ func createOrEditQuestion(c echo.Context, questionForm questions.QuestionForm, d
 		// Save the question to the database.
 		err = questions.AddNewQuestion(db, c.Request().Context(), &question)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		tempQuestion = nil
 
 		// Set HX-Reswap header to "beforeend" for success response
 		c.Response().Header().Set(hxReswap, "beforeend")
@@ -907,14 +1402,14 @@ func createOrEditQuestion(c echo.Context, questionForm questions.QuestionForm, d
 		err = questions.UpdateQuestion(db, c.Request().Context(), &question)
 
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	} else if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Return the "question item" element.
-	return &question, nil
+	return &question, tempQuestion, nil
 }
 
 // Delete a question with the given ID from the database.
@@ -926,6 +1421,11 @@ func (aah *AdminApiHandler) deleteQuestion(c echo.Context) error {
 			fmt.Sprintf("Kunne ikke slette spørsmål: %s", errorInvalidQuestionID)))
 	}
 
+	question, err := questions.GetQuestionByID(aah.sharedData.DB, questionID)
+	if err != nil {
+		return err
+	}
+
 	// Delete the question from the database
 	err = questions.DeleteQuestionByID(aah.sharedData.DB, c.Request().Context(), &questionID)
 	if err != nil {
@@ -936,6 +1436,7 @@ func (aah *AdminApiHandler) deleteQuestion(c echo.Context) error {
 
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionDelete, audit_log.TargetQuestion, questionID.String(), questionAuditValue(question), nil)
 
 	return c.NoContent(http.StatusOK)
 }
@@ -966,6 +1467,11 @@ func (aah *AdminApiHandler) editQuestionImage(c echo.Context) error {
 		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorImageElementID, errorUploadImage))
 	}
 
+	question, err := questions.GetQuestionByID(aah.sharedData.DB, questionID)
+	if err != nil {
+		return err
+	}
+
 	// Set the image URL for the question
 	err = questions.SetImageByQuestionID(aah.sharedData.DB, &questionID, imageURL)
 	if err != nil {
@@ -976,6 +1482,8 @@ func (aah *AdminApiHandler) editQuestionImage(c echo.Context) error {
 
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuestion, questionID.String(),
+		map[string]any{"image_url": question.ImageURL.String()}, map[string]any{"image_url": imageURL.String()})
 
 	return utils.Render(c, http.StatusOK, dashboard_components.EditImageInput(
 		fmt.Sprintf(editQuestionImageURL, questionID), fmt.Sprintf(editQuestionImageFile, questionID),
@@ -1015,11 +1523,18 @@ func (aah *AdminApiHandler) uploadQuestionImage(c echo.Context) error {
 		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorImageElementID, errorUploadImage))
 	}
 
+	question, err := questions.GetQuestionByID(aah.sharedData.DB, questionID)
+	if err != nil {
+		return err
+	}
+
 	err = questions.SetImageByQuestionID(aah.sharedData.DB, &questionID, imageAsURL)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuestion, questionID.String(),
+		map[string]any{"image_url": question.ImageURL.String()}, map[string]any{"image_url": imageAsURL.String()})
 
 	return utils.Render(c, http.StatusOK, dashboard_components.EditImageInput(
 		fmt.Sprintf(editQuestionImageURL, questionID), fmt.Sprintf(editQuestionImageFile, questionID),
@@ -1036,6 +1551,11 @@ func (aah *AdminApiHandler) deleteQuestionImage(c echo.Context) error {
 			fmt.Sprintf(imageSuggestionsQuestion, questionID), &url.URL{}, true, errorInvalidQuestionID, dashboard_components.IdPrefixQuestion))
 	}
 
+	question, err := questions.GetQuestionByID(aah.sharedData.DB, questionID)
+	if err != nil {
+		return err
+	}
+
 	// Remove the image URL from the question
 	err = questions.RemoveImageByQuestionID(aah.sharedData.DB, &questionID)
 	if err != nil {
@@ -1047,6 +1567,8 @@ func (aah *AdminApiHandler) deleteQuestionImage(c echo.Context) error {
 
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetQuestion, questionID.String(),
+		map[string]any{"image_url": question.ImageURL.String()}, map[string]any{"image_url": ""})
 
 	return utils.Render(c, http.StatusOK, dashboard_components.EditImageInput(
 		fmt.Sprintf(editQuestionImageURL, questionID), fmt.Sprintf(editQuestionImageFile, questionID),
@@ -1166,6 +1688,37 @@ func (aah *AdminApiHandler) randomizeAlternatives(c echo.Context) error {
 	return utils.Render(c, http.StatusOK, dashboard_components.QuestionAlternativesInput(alternatives))
 }
 
+// Renders the part of the question form for the chosen type of question.
+// The alternatives filled in are kept, including their IDs, so alternatives the type does not use are deleted when the question is saved.
+func (aah *AdminApiHandler) questionTypeEditor(c echo.Context) error {
+	questionType := questionTypeFromForm(c)
+	if !questionType.IsValid() {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuestionElementID, "Ukjent spørsmålstype"))
+	}
+
+	question := questions.Question{
+		Type:         questionType,
+		NumericUnit:  c.FormValue(dashboard_components.QuestionNumericUnit),
+		Alternatives: []questions.Alternative{},
+	}
+	// Numbers that can not be parsed are left empty
+	question.NumericAnswer, _ = questions.ParseNumber(c.FormValue(dashboard_components.QuestionNumericAnswer))
+	question.NumericTolerance, _ = questions.ParseNumber(c.FormValue(dashboard_components.QuestionNumericTolerance))
+
+	for _, alternative := range alternativesFromForm(c, questionType) {
+		if alternative.ID == uuid.Nil {
+			alternative.ID = uuid.New()
+		}
+		question.Alternatives = append(question.Alternatives, questions.Alternative{
+			ID:        alternative.ID,
+			Text:      alternative.Text,
+			IsCorrect: alternative.IsCorrect,
+		})
+	}
+
+	return utils.Render(c, http.StatusOK, dashboard_components.QuestionTypeEditor(&question))
+}
+
 // Add the given word to the username tables.
 func (aah *AdminApiHandler) addUsername(c echo.Context) error {
 	word := c.FormValue("username-word")
@@ -1179,6 +1732,7 @@ func (aah *AdminApiHandler) addUsername(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionCreate, audit_log.TargetUsername, word, nil, map[string]any{"table": table, "word": word})
 	c.Response().Header().Set("HX-Refresh", "true")
 	return c.NoContent(http.StatusOK)
 }
@@ -1193,7 +1747,17 @@ func (aah *AdminApiHandler) deleteUsername(c echo.Context) error {
 		return err
 	}
 
-	usernames.DeleteWordsFromTable(aah.sharedData.DB, c.Request().Context(), words)
+	err = usernames.DeleteWordsFromTable(aah.sharedData.DB, c.Request().Context(), words)
+	if err != nil {
+		if errors.Is(err, usernames.ErrNoAvailableUsername) {
+			return utils.Render(c, http.StatusConflict, components.ErrorText("error-username",
+				"Det er ikke nok ledige brukernavn igjen til å gi nye brukernavn til alle brukerne med disse ordene. Legg til flere ord først."))
+		}
+		return err
+	}
+	for _, word := range words {
+		recordAudit(c, aah.sharedData.DB, audit_log.ActionDelete, audit_log.TargetUsername, word, map[string]any{"word": word}, nil)
+	}
 
 	return c.NoContent(http.StatusOK)
 }
@@ -1217,9 +1781,65 @@ func (aah *AdminApiHandler) editUsername(c echo.Context) error {
 		return err
 	}
 
+	for table, words := range wordList {
+		for _, word := range words {
+			recordAudit(c, aah.sharedData.DB, audit_log.ActionUpdate, audit_log.TargetUsername, word.New,
+				map[string]any{"table": table, "word": word.Old}, map[string]any{"table": table, "word": word.New})
+		}
+	}
+
 	return c.NoContent(http.StatusOK)
 }
 
+// Blocks the combination of the adjective and noun from being used as a username.
+// Users who already have the username are given a new random one.
+func (aah *AdminApiHandler) blockUsername(c echo.Context) error {
+	adjective := strings.TrimSpace(c.FormValue("blocked-adjective"))
+	noun := strings.TrimSpace(c.FormValue("blocked-noun"))
+	if adjective == "" || noun == "" {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorBlockedUsername, "Fyll inn både adjektiv og substantiv"))
+	}
+
+	renamed, err := usernames.BlockUsername(aah.sharedData.DB, c.Request().Context(), adjective, noun)
+	if err != nil {
+		switch {
+		case errors.Is(err, usernames.ErrUnknownWord):
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorBlockedUsername, "Adjektivet eller substantivet finnes ikke"))
+		case errors.Is(err, usernames.ErrNoAvailableUsername):
+			return utils.Render(c, http.StatusConflict, components.ErrorText(errorBlockedUsername, "Det er ingen ledige brukernavn å gi brukerne som har dette navnet"))
+		default:
+			return err
+		}
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionCreate, audit_log.TargetUsername, adjective+" "+noun,
+		nil, map[string]any{"blockedAdjective": adjective, "blockedNoun": noun, "renamedUsers": renamed})
+
+	blocked, err := usernames.GetBlockedUsernames(aah.sharedData.DB)
+	if err != nil {
+		return err
+	}
+	return utils.Render(c, http.StatusOK, user_admin.BlockedUsernames(blocked))
+}
+
+// Allows the combination of the adjective and noun to be used as a username again.
+func (aah *AdminApiHandler) unblockUsername(c echo.Context) error {
+	adjective := c.QueryParam("adjective")
+	noun := c.QueryParam("noun")
+
+	err := usernames.UnblockUsername(aah.sharedData.DB, adjective, noun)
+	if err != nil {
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionDelete, audit_log.TargetUsername, adjective+" "+noun,
+		map[string]any{"blockedAdjective": adjective, "blockedNoun": noun}, nil)
+
+	blocked, err := usernames.GetBlockedUsernames(aah.sharedData.DB)
+	if err != nil {
+		return err
+	}
+	return utils.Render(c, http.StatusOK, user_admin.BlockedUsernames(blocked))
+}
+
 // Get image suggestions for a quiz
 func (aah *AdminApiHandler) imageSuggestionsQuiz(c echo.Context) error {
 	// Get quiz ID
@@ -1315,8 +1935,9 @@ func (aah *AdminApiHandler) getUsernamePages(c echo.Context) error {
 	return utils.Render(c, http.StatusOK, user_admin.UsernameTables(uai, requestUrl))
 }
 
-// Renders a ranking table for the given user. Displays the label and all time ranking.
+// Renders a ranking table for the given user. Displays the label, monthly, yearly and all time ranking.
 // Expects user-id query parameter, and label-id query parameter.
+// The month and year are read from the chosen-month and chosen-year form values.
 func (h *AdminApiHandler) generateUserRankingsTable(c echo.Context) error {
 	uuid_id, err := uuid.Parse(c.QueryParam("user-id"))
 	if err != nil {
@@ -1337,7 +1958,17 @@ func (h *AdminApiHandler) generateUserRankingsTable(c echo.Context) error {
 		}
 	}
 
-	rankingCollection, err := user_ranking.GetUserRankingsInAllRanges(h.sharedData.DB, uuid_id, label)
+	month, err := strconv.Atoi(c.FormValue(dashboard_user_details_components.MonthFormName))
+	if err != nil || month < 1 || month > 12 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig eller manglende måned")
+	}
+	year, err := strconv.Atoi(c.FormValue(dashboard_user_details_components.YearFormName))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig eller manglende år")
+	}
+	period := time.Date(year, time.Month(month), 15, 12, 0, 0, 0, time.UTC)
+
+	rankingCollection, err := user_ranking.GetUserRankingsInAllRanges(h.sharedData.DB, uuid_id, label, period)
 	if err != nil {
 		return err
 	}
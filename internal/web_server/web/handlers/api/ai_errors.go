@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/ai"
+)
+
+// Norwegian descriptions of the rules a generated question can break.
+var aiValidationMessages = []struct {
+	err     error
+	message string
+}{
+	{ai.ErrEmptyQuestion, "spørsmålet mangler tekst"},
+	{ai.ErrTooFewAlternatives, "for få svaralternativer"},
+	{ai.ErrTooManyAlternatives, "for mange svaralternativer"},
+	{ai.ErrEmptyAlternative, "et svaralternativ mangler tekst"},
+	{ai.ErrAlternativeTooLong, "et svaralternativ er for langt"},
+	{ai.ErrNoCorrectAlternative, "ingen riktige svaralternativer"},
+	{ai.ErrMultipleCorrect, "flere enn ett riktig svaralternativ"},
+	{ai.ErrExplanationTooLong, "forklaringen er for lang"},
+}
+
+// Returns a message for the admin UI explaining why a question could not be generated.
+func aiErrorMessage(err error) string {
+	var validationErr *ai.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		problems := []string{}
+		for _, rule := range aiValidationMessages {
+			if errors.Is(validationErr, rule.err) {
+				problems = append(problems, rule.message)
+			}
+		}
+		return "KI-en laget et ugyldig spørsmål: " + strings.Join(problems, ", ") + ". Prøv igjen."
+	case errors.Is(err, ai.ErrNoJsonObject), errors.Is(err, ai.ErrInvalidJson):
+		return "KI-en svarte ikke med et gyldig spørsmål. Prøv igjen."
+	default:
+		return "Kunne ikke generere spørsmål"
+	}
+}
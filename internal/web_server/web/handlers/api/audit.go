@@ -0,0 +1,107 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/audit_log"
+	"github.com/Molnes/Nyhetsjeger/internal/models/prize_draws"
+	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/Molnes/Nyhetsjeger/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Records an action performed by the user making the request in the audit log.
+// The action has already been performed, so failing to record it is logged instead of failing the request.
+func recordAudit(c echo.Context, db *sql.DB, action audit_log.Action, targetType audit_log.TargetType, targetID string, before any, after any) {
+	err := audit_log.Record(db, utils.GetUserIDFromCtx(c), action, targetType, targetID, before, after)
+	if err != nil {
+		log.Printf("Failed to record %s of %s %s in audit log: %v", action, targetType, targetID, err)
+	}
+}
+
+// Values of a quiz shown in the audit log.
+func quizAuditValue(quiz *quizzes.Quiz) map[string]any {
+	return map[string]any{
+		"title":           quiz.Title,
+		"image_url":       quiz.ImageURL.String(),
+		"active_from":     quiz.ActiveFrom,
+		"active_to":       quiz.ActiveTo,
+		"published":       quiz.Published,
+		"is_template":     quiz.IsTemplate,
+		"publish_at":      nullTimeAuditValue(quiz.PublishAt),
+		"end_action":      quiz.EndAction,
+		"is_archived":     quiz.IsArchived,
+		"open_for_guests": quiz.OpenForGuests,
+	}
+}
+
+// Values of a quiz's scoring policy shown in the audit log.
+func scoringPolicyAuditValue(policy *quizzes.ScoringPolicy) map[string]any {
+	return map[string]any{
+		"scoring_rule":                 policy.Rule,
+		"grace_seconds":                policy.GraceSeconds,
+		"min_points_percent":           policy.MinPointsPercent,
+		"streak_bonus_percent":         policy.StreakBonusPercent,
+		"wrong_answer_penalty_percent": policy.WrongAnswerPenaltyPercent,
+	}
+}
+
+// A nullable time shown in the audit log, nil if it is not set.
+func nullTimeAuditValue(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// Values of a question and its alternatives shown in the audit log.
+func questionAuditValue(question *questions.Question) map[string]any {
+	alternatives := []map[string]any{}
+	for _, alternative := range question.Alternatives {
+		alternatives = append(alternatives, map[string]any{
+			"text":    alternative.Text,
+			"correct": alternative.IsCorrect,
+		})
+	}
+	value := map[string]any{
+		"quiz_id":            question.QuizID,
+		"text":               question.Text,
+		"image_url":          question.ImageURL.String(),
+		"article_id":         question.ArticleID,
+		"time_limit_seconds": question.TimeLimitSeconds,
+		"points":             question.Points,
+		"type":               question.Type,
+		"explanation":        question.Explanation,
+		"alternatives":       alternatives,
+	}
+	if question.Type == questions.QuestionTypeNumericEstimate {
+		value["numeric_answer"] = question.NumericAnswer
+		value["numeric_tolerance"] = question.NumericTolerance
+		value["numeric_unit"] = question.NumericUnit
+	}
+	return value
+}
+
+// Values of a prize draw shown in the audit log. Winners are identified by user id only, so no contact details are logged.
+func prizeDrawAuditValue(draw *prize_draws.Draw) map[string]any {
+	winners := []string{}
+	for _, winner := range draw.Winners() {
+		if winner.UserID.Valid {
+			winners = append(winners, winner.UserID.UUID.String())
+		}
+	}
+	return map[string]any{
+		"method":       draw.Method,
+		"label_id":     draw.Scope.LabelID,
+		"label_name":   draw.LabelName,
+		"from":         nullTimeAuditValue(draw.Scope.From),
+		"to":           nullTimeAuditValue(draw.Scope.To),
+		"winner_count": draw.WinnerCount,
+		"seed":         draw.Seed,
+		"entrants":     len(draw.Entrants),
+		"winners":      winners,
+	}
+}
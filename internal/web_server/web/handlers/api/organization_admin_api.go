@@ -1,17 +1,24 @@
 package api
 
 import (
+	"database/sql"
 	"net/http"
 	"net/mail"
+	"strconv"
 	"strings"
 
 	"github.com/Molnes/Nyhetsjeger/internal/config"
+	"github.com/Molnes/Nyhetsjeger/internal/models/audit_log"
+	"github.com/Molnes/Nyhetsjeger/internal/models/prize_draws"
 	"github.com/Molnes/Nyhetsjeger/internal/models/sessions"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/access_control"
 	"github.com/Molnes/Nyhetsjeger/internal/utils"
+	data_handling "github.com/Molnes/Nyhetsjeger/internal/utils/data"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components/dashboard_components/access_settings_components"
+	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/pages/dashboard_pages"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
@@ -19,6 +26,7 @@ const (
 	hxReswap            = "HX-Reswap"
 	hxOuterHTML         = "outerHTML"
 	classPostAdminError = "post-admin-error"
+	classPrizeDrawError = "error-prize-draw"
 )
 
 type OrganizationAdminApiHandler struct {
@@ -34,6 +42,7 @@ func NewOrganizationAdminApiHandler(sharedData *config.SharedData) *Organization
 func (oah *OrganizationAdminApiHandler) RegisterOrganizationAdminHandlers(g *echo.Group) {
 	g.POST("/access-control/admin", oah.postAddAdminByEmail)
 	g.DELETE("/access-control/admin", oah.deleteAdminByEmail)
+	g.POST("/prize-draws", oah.postPrizeDraw)
 }
 
 // Handles a post request to add an admin by email. Email expected in form data.
@@ -73,6 +82,7 @@ func (oah *OrganizationAdminApiHandler) postAddAdminByEmail(c echo.Context) erro
 		}
 		return err
 	}
+	recordAudit(c, oah.sharedData.DB, audit_log.ActionCreate, audit_log.TargetAdmin, userAdmin.Email, nil, userAdmin)
 
 	return utils.Render(c, http.StatusCreated, access_settings_components.AdminTableRow(userAdmin))
 }
@@ -101,8 +111,74 @@ func (oah *OrganizationAdminApiHandler) deleteAdminByEmail(c echo.Context) error
 		}
 		return err
 	}
+	recordAudit(c, oah.sharedData.DB, audit_log.ActionDelete, audit_log.TargetAdmin, email, map[string]any{"email": email}, nil)
+
 	// Returning no content with `200 OK` instead of `204 No Content`
 	// due to HTMX refusing to replace content if response code is 204.
 	// This is necessary to remove the row from the table.
 	return c.NoContent(http.StatusOK)
 }
+
+// Handles a post request to pick the winners of a prize. The label, period, method and number of winners are expected in form data.
+// The draw is recorded, and the user is redirected to the page showing the winners.
+func (oah *OrganizationAdminApiHandler) postPrizeDraw(c echo.Context) error {
+	var scope prize_draws.Scope
+	if labelIDParam := c.FormValue(dashboard_pages.PrizeDrawLabelParam); labelIDParam != "" {
+		labelID, err := uuid.Parse(labelIDParam)
+		if err != nil {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(classPrizeDrawError, "Ugyldig etikett"))
+		}
+		scope.LabelID = uuid.NullUUID{UUID: labelID, Valid: true}
+	}
+	// Dates are inclusive, in Norwegian time.
+	if fromDate := c.FormValue(dashboard_pages.PrizeDrawFromParam); fromDate != "" {
+		from, err := data_handling.NorwayTimeToUtc(fromDate + "T00:00")
+		if err != nil {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(classPrizeDrawError, "Ugyldig fra-dato"))
+		}
+		scope.From = sql.NullTime{Time: from, Valid: true}
+	}
+	if toDate := c.FormValue(dashboard_pages.PrizeDrawToParam); toDate != "" {
+		to, err := data_handling.NorwayTimeToUtc(toDate + "T00:00")
+		if err != nil {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(classPrizeDrawError, "Ugyldig til-dato"))
+		}
+		scope.To = sql.NullTime{Time: to.AddDate(0, 0, 1), Valid: true}
+	}
+
+	winnerCount, err := strconv.Atoi(c.FormValue(dashboard_pages.PrizeDrawWinnerCountParam))
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(classPrizeDrawError, "Ugyldig antall vinnere"))
+	}
+	method := prize_draws.Method(c.FormValue(dashboard_pages.PrizeDrawMethodParam))
+
+	drawID, err := prize_draws.CreateDraw(oah.sharedData.DB, c.Request().Context(), utils.GetUserIDFromCtx(c), method, scope, winnerCount)
+	if err != nil {
+		switch err {
+		case prize_draws.ErrNoScope:
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(classPrizeDrawError, "Velg en etikett, en periode eller begge"))
+		case prize_draws.ErrInvalidPeriod:
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(classPrizeDrawError, "Fra-datoen kan ikke være etter til-datoen"))
+		case prize_draws.ErrInvalidMethod:
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(classPrizeDrawError, "Ugyldig metode"))
+		case prize_draws.ErrInvalidWinnerCount:
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(classPrizeDrawError,
+				"Antall vinnere må være mellom 1 og "+strconv.Itoa(prize_draws.MaxWinnerCount)))
+		case prize_draws.ErrNoEntrants:
+			return utils.Render(c, http.StatusUnprocessableEntity, components.ErrorText(classPrizeDrawError, "Ingen brukere har fullført en quiz innenfor utvalget"))
+		case sql.ErrNoRows:
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(classPrizeDrawError, "Etiketten finnes ikke"))
+		}
+		return err
+	}
+
+	draw, err := prize_draws.GetDraw(oah.sharedData.DB, drawID)
+	if err != nil {
+		return err
+	}
+	recordAudit(c, oah.sharedData.DB, audit_log.ActionCreate, audit_log.TargetPrizeDraw, drawID.String(), nil, prizeDrawAuditValue(draw))
+
+	drawURL := "/dashboard/organization-admin/prize-draw?" + dashboard_pages.PrizeDrawIDParam + "=" + drawID.String()
+	c.Response().Header().Set("HX-Redirect", drawURL)
+	return c.Redirect(http.StatusOK, drawURL)
+}
@@ -2,13 +2,10 @@ package api
 
 import (
 	"database/sql"
-	"encoding/json"
 	"net/http"
-	"strconv"
-	"time"
 
 	"github.com/Molnes/Nyhetsjeger/internal/config"
-	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz_summary"
 	utils "github.com/Molnes/Nyhetsjeger/internal/utils"
@@ -35,122 +32,100 @@ func (h *publicApiHandler) RegisterPublicApiHandlers(g *echo.Group) {
 }
 
 // Handles a post request with question answer from a guest user.
+// Points are calculated from when the question was presented to the guest's session.
 func (h *publicApiHandler) postAnswer(c echo.Context) error {
 	questionID, err := uuid.Parse(c.QueryParam("question-id"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig eller manglende question-id")
 	}
-	pickedAnswerID, err := uuid.Parse(c.FormValue("answer-id"))
+	answer, err := parseAnswer(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig eller manglende answer-id i formdata")
+		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig eller manglende svar i formdata")
 	}
 
-	questionPresentedAt, err := time.Parse(time.RFC3339, c.FormValue("last_question_presented_at"))
-	if err != nil {
-		return err
-	}
-
-	answered, err := user_quiz.AnswerQuestionGuest(h.sharedData.DB, questionID, pickedAnswerID, questionPresentedAt)
+	question, err := questions.GetQuestionByID(h.sharedData.DB, questionID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Ingen spørsmål med den angitte ID-en")
+		}
 		return err
 	}
-
-	publicQuizId, err := user_quiz.GetOpenQuizId(h.sharedData.DB)
+	isOpen, err := user_quiz.IsOpenQuiz(h.sharedData.DB, question.QuizID)
 	if err != nil {
 		return err
 	}
-	if publicQuizId != answered.Question.QuizID {
+	if !isOpen {
 		return echo.NewHTTPError(http.StatusForbidden, "Kan ikke svare på spørsmål i uåpnede quizer uten å være innlogget.")
 	}
 
-	summaryRow := user_quiz_summary.AnsweredQuestion{
-		QuestionID:            questionID,
-		QuestionText:          answered.Question.Text,
-		MaxPoints:             answered.Question.Points,
-		ChosenAlternativeID:   answered.ChosenAnswerID,
-		ChosenAlternativeText: answered.Question.GetAnswerTextById(answered.ChosenAnswerID),
-		IsCorrect:             answered.Question.IsAnswerCorrect(answered.ChosenAnswerID),
-		PointsAwarded:         answered.PointsAwarded,
+	answered, err := user_quiz.AnswerQuestionGuest(h.sharedData.DB, utils.GetGuestIDFromCtx(c), questionID, answer, h.sharedData.AnswerGracePeriod)
+	if err != nil {
+		if err == user_quiz.ErrQuestionAlreadyAnswered {
+			return echo.NewHTTPError(http.StatusConflict, "Spørsmålet er allerede besvart")
+		} else if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusConflict, "Spørsmålet er ikke startet")
+		} else if err == questions.ErrInvalidAnswer {
+			return echo.NewHTTPError(http.StatusBadRequest, "Svaret passer ikke til spørsmålet")
+		}
+		return err
 	}
 
-	return utils.Render(c, http.StatusOK, play_quiz_components.FeedbackButtonsWithClientState(answered, &summaryRow))
-
+	return utils.Render(c, http.StatusOK, play_quiz_components.FeedbackButtons(answered))
 }
 
 // Handles a get request for next question in a public (open) quiz.
 func (h *publicApiHandler) getQuestion(c echo.Context) error {
-	openQuizId, err := user_quiz.GetOpenQuizId(h.sharedData.DB)
+	quizId, err := h.getOpenQuizIdParam(c)
 	if err != nil {
 		return err
 	}
 
-	quizId, err := uuid.Parse(c.QueryParam("quiz-id"))
+	data, err := user_quiz.NextQuestionInQuizGuest(h.sharedData.DB, utils.GetGuestIDFromCtx(c), quizId, h.sharedData.AnswerGracePeriod)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig eller manglende quiz-id")
-	}
-	if quizId != openQuizId {
-		return echo.NewHTTPError(http.StatusNotFound, "Ingen åpen quiz med den angitte ID-en")
-	}
-
-	currentQuestion, err := strconv.ParseUint(c.QueryParam("current-question"), 10, 64)
-	if err != nil || currentQuestion < 1 {
-		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig eller manglende spørsmålsnummer")
-	}
-
-	totalPoints, err := strconv.ParseUint(c.FormValue("total-points"), 10, 64)
-	if err != nil {
-		totalPoints = 0
-	}
-
-	data, err := user_quiz.GetQuestionByNumberInQuiz(h.sharedData.DB, quizId, uint(currentQuestion))
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return echo.NewHTTPError(http.StatusNotFound, "Ingen spørsmål med det angitte nummeret")
+		if err == user_quiz.ErrNoSuchQuiz {
+			return echo.NewHTTPError(http.StatusNotFound, "Ingen åpen quiz med den angitte ID-en")
+		} else if err == user_quiz.ErrNoMoreQuestions {
+			return echo.NewHTTPError(http.StatusNotFound, "Ingen flere spørsmål")
 		}
 		return err
 	}
 
-	data.PointsGathered = uint(totalPoints)
-
 	return utils.Render(c, http.StatusOK, play_quiz_components.QuizPlayContent(data))
-
 }
 
-// Handles a post request to generate a summary page out of data stored in the local storage.
-// Expects a formdata with name "summaryRows", with value as stringified JSON array of user_quiz_summary.AnsweredQuestion.
+// Handles a post request to generate a summary page out of the answers stored for the guest's session.
 func (h *publicApiHandler) postGenerateSummary(c echo.Context) error {
-	openQuizId, err := user_quiz.GetOpenQuizId(h.sharedData.DB)
+	quizId, err := h.getOpenQuizIdParam(c)
 	if err != nil {
 		return err
 	}
-	quizIdParam, err := uuid.Parse(c.QueryParam("quiz-id"))
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig eller manglende quiz-id")
-	}
-	if quizIdParam != openQuizId {
-		return echo.NewHTTPError(http.StatusNotFound, "Ingen åpen quiz med den angitte ID-en")
-	}
-	quiz, err := quizzes.GetPartialQuizByID(h.sharedData.DB, openQuizId)
+
+	summary, err := user_quiz_summary.GetGuestQuizSummary(h.sharedData.DB, utils.GetGuestIDFromCtx(c), quizId)
 	if err != nil {
+		if err == user_quiz_summary.ErrNoSuchQuiz {
+			return echo.NewHTTPError(http.StatusNotFound, "Ingen åpen quiz med den angitte ID-en")
+		} else if err == user_quiz_summary.ErrQuizNotCompleted {
+			return echo.NewHTTPError(http.StatusConflict, "Quizen er ikke fullført")
+		}
 		return err
 	}
 
-	stringifiedSummaryRows := c.FormValue("summaryRows")
-	var answeredQuestions []user_quiz_summary.AnsweredQuestion
-	err = json.Unmarshal([]byte(stringifiedSummaryRows), &answeredQuestions)
+	return utils.Render(c, http.StatusOK, quiz_pages.QuizSummaryContent(summary))
+}
+
+// Reads the quiz-id query parameter, and checks that it is the ID of a quiz open for guests.
+// Returns an echo.HTTPError if it is not.
+func (h *publicApiHandler) getOpenQuizIdParam(c echo.Context) (uuid.UUID, error) {
+	quizId, err := uuid.Parse(c.QueryParam("quiz-id"))
 	if err != nil {
-		return err
+		return uuid.Nil, echo.NewHTTPError(http.StatusBadRequest, "Ugyldig eller manglende quiz-id")
 	}
-
-	summary := user_quiz_summary.UserQuizSummary{
-		QuizID:            quiz.ID,
-		QuizTitle:         quiz.Title,
-		QuizActiveTo:      quiz.ActiveTo,
-		MaxScore:          quiz.MaxScore,
-		AchievedScore:     0,
-		AnsweredQuestions: answeredQuestions,
+	isOpen, err := user_quiz.IsOpenQuiz(h.sharedData.DB, quizId)
+	if err != nil {
+		return uuid.Nil, err
 	}
-	summary.CalculateAchievedScoreFromAnswered()
-
-	return utils.Render(c, http.StatusOK, quiz_pages.QuizSummaryContent(&summary))
+	if !isOpen {
+		return uuid.Nil, echo.NewHTTPError(http.StatusNotFound, "Ingen åpen quiz med den angitte ID-en")
+	}
+	return quizId, nil
 }
@@ -1,15 +1,24 @@
 package api
 
 import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/Molnes/Nyhetsjeger/internal/config"
 	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
 	"github.com/Molnes/Nyhetsjeger/internal/models/sessions"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_data_export"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/usernames"
 	"github.com/Molnes/Nyhetsjeger/internal/utils"
+	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components/profile_components"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components/quiz_components"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components/quiz_components/play_quiz_components"
@@ -32,7 +41,12 @@ func (qah *QuizApiHandler) RegisterQuizApiHandlers(e *echo.Group) {
 	e.GET("/next-question", qah.getNextQuestion)
 	e.POST("/user-answer", qah.postUserAnswer)
 	e.PATCH("/brukernavn", qah.patchRandomUsername)
+	e.GET("/brukernavn/adjektiv", qah.getUsernameAdjectives)
+	e.GET("/brukernavn/substantiv", qah.getUsernameNouns)
+	e.POST("/brukernavn/reserver", qah.postReserveUsername)
+	e.PUT("/brukernavn", qah.putChosenUsername)
 	e.DELETE("/profil", qah.deleteProfile)
+	e.GET("/profil/data", qah.getProfileData)
 	e.POST("/accept-terms", qah.postAcceptTerms)
 	e.POST("/participation", qah.postParticipation)
 }
@@ -73,7 +87,7 @@ func (qah *QuizApiHandler) getNextQuestion(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or missing quiz-id")
 	}
 
-	quizData, err := user_quiz.NextQuestionInQuiz(qah.sharedData.DB, utils.GetUserIDFromCtx(c), quizID)
+	quizData, err := user_quiz.NextQuestionInQuiz(qah.sharedData.DB, utils.GetUserIDFromCtx(c), quizID, qah.sharedData.AnswerGracePeriod)
 	if err != nil {
 		if err == user_quiz.ErrNoSuchQuiz {
 			return echo.NewHTTPError(http.StatusNotFound, "No such quiz")
@@ -87,9 +101,41 @@ func (qah *QuizApiHandler) getNextQuestion(c echo.Context) error {
 	return utils.Render(c, http.StatusOK, play_quiz_components.QuizPlayContent(quizData))
 }
 
+var errMissingAnswer = errors.New("api: missing answer")
+
+// Reads the answer to a question from the form data.
+// Chosen alternatives are given as answer-id, in the chosen order for ordering questions,
+// and numeric estimates as answer-number.
+func parseAnswer(c echo.Context) (questions.Answer, error) {
+	var answer questions.Answer
+	form, err := c.FormParams()
+	if err != nil {
+		return answer, err
+	}
+
+	for _, value := range form["answer-id"] {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return answer, err
+		}
+		answer.AlternativeIDs = append(answer.AlternativeIDs, id)
+	}
+
+	number := form.Get("answer-number")
+	if number != "" {
+		answer.Number, err = questions.ParseNumber(number)
+		if err != nil {
+			return answer, err
+		}
+	} else if len(answer.AlternativeIDs) == 0 {
+		return answer, errMissingAnswer
+	}
+	return answer, nil
+}
+
 // Handles post request for a user's answer to a question.
 //
-// Expects the question-id as a query parameter and the answer-id as form data.
+// Expects the question-id as a query parameter and the answer as form data, see parseAnswer.
 //
 // Renders the FeedbackButtons component with the answer feedback.
 func (qah *QuizApiHandler) postUserAnswer(c echo.Context) error {
@@ -97,15 +143,17 @@ func (qah *QuizApiHandler) postUserAnswer(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or missing question-id")
 	}
-	pickedAnswerID, err := uuid.Parse(c.FormValue("answer-id"))
+	answer, err := parseAnswer(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or missing answer-id in formdata")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or missing answer in formdata")
 	}
 
-	answered, err := user_quiz.AnswerQuestion(qah.sharedData.DB, utils.GetUserIDFromCtx(c), questionID, pickedAnswerID)
+	answered, err := user_quiz.AnswerQuestion(qah.sharedData.DB, qah.sharedData.LiveHub, utils.GetUserIDFromCtx(c), questionID, answer, qah.sharedData.AnswerGracePeriod)
 	if err != nil {
 		if err == user_quiz.ErrQuestionAlreadyAnswered {
 			return echo.NewHTTPError(http.StatusConflict, "Question already answered")
+		} else if err == questions.ErrInvalidAnswer {
+			return echo.NewHTTPError(http.StatusBadRequest, "The answer does not fit the question")
 		} else {
 			return err
 		}
@@ -124,10 +172,72 @@ func (qah *QuizApiHandler) patchRandomUsername(c echo.Context) error {
 	return utils.Render(c, http.StatusOK, user_management.UsernameInput("usrn-in", username))
 }
 
+// Renders the adjectives matching the search, for the user to pick their username from.
+func (qah *QuizApiHandler) getUsernameAdjectives(c echo.Context) error {
+	adjectives, err := usernames.SearchAdjectives(qah.sharedData.DB, c.QueryParam("sok"))
+	if err != nil {
+		return err
+	}
+	return utils.Render(c, http.StatusOK, profile_components.AdjectiveOptions(adjectives))
+}
+
+// Renders the nouns matching the search which make an available username with the chosen adjective.
+func (qah *QuizApiHandler) getUsernameNouns(c echo.Context) error {
+	adjective := c.QueryParam("adjektiv")
+	nouns, err := usernames.SearchAvailableNouns(qah.sharedData.DB, adjective, c.QueryParam("sok"))
+	if err != nil {
+		return err
+	}
+	return utils.Render(c, http.StatusOK, profile_components.NounPicker(adjective, nouns))
+}
+
+// Reserves the chosen username for the user until they save it.
+func (qah *QuizApiHandler) postReserveUsername(c echo.Context) error {
+	adjective, noun := c.QueryParam("adjektiv"), c.QueryParam("substantiv")
+	expiresAt, err := usernames.ReserveUsername(qah.sharedData.DB, c.Request().Context(), utils.GetUserIDFromCtx(c), adjective, noun, time.Now())
+	if err != nil {
+		return renderUsernameChoiceError(c, err)
+	}
+	return utils.Render(c, http.StatusOK, profile_components.UsernameChoice(adjective, noun, expiresAt))
+}
+
+// Gives the user the username they have reserved.
+func (qah *QuizApiHandler) putChosenUsername(c echo.Context) error {
+	username, err := usernames.ChooseUsername(qah.sharedData.DB, c.Request().Context(), utils.GetUserIDFromCtx(c),
+		c.QueryParam("adjektiv"), c.QueryParam("substantiv"), time.Now())
+	if err != nil {
+		return renderUsernameChoiceError(c, err)
+	}
+	return utils.Render(c, http.StatusOK, profile_components.UsernameChosen(username))
+}
+
+// Renders why the user can not have the username they chose.
+func renderUsernameChoiceError(c echo.Context, err error) error {
+	message := ""
+	switch {
+	case errors.Is(err, usernames.ErrUnknownWord), errors.Is(err, usernames.ErrUsernameBlocked):
+		message = "Dette brukernavnet er ikke tillatt. Velg et annet."
+	case errors.Is(err, usernames.ErrUsernameTaken):
+		message = "Noen andre har allerede tatt dette brukernavnet. Velg et annet."
+	case errors.Is(err, usernames.ErrNoReservation):
+		message = "Reservasjonen av brukernavnet har utløpt. Velg brukernavnet på nytt."
+	default:
+		return err
+	}
+	return utils.Render(c, http.StatusConflict, components.ErrorText("error-username-choice", message))
+}
+
 // Deletes the user from the database and logs the user out
+// The access given by the login provider is revoked first. The profile is deleted even if revoking fails.
 func (qah *QuizApiHandler) deleteProfile(c echo.Context) error {
 	//TODO: Avoid duplicate logout code. Have agreed to look at it later.
-	err := users.DeleteUserByID(qah.sharedData.DB, utils.GetUserIDFromCtx(c))
+	userID := utils.GetUserIDFromCtx(c)
+	err := qah.sharedData.Tokens.Revoke(c.Request().Context(), userID)
+	if err != nil {
+		log.Printf("Failed to revoke token of user %s: %v", userID, err)
+	}
+
+	err = users.DeleteUserByID(qah.sharedData.DB, userID)
 	if err != nil {
 		return err
 	}
@@ -145,6 +255,42 @@ func (qah *QuizApiHandler) deleteProfile(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
+// Handles a get request for all data stored about the caller.
+// Responds with a JSON file, or a ZIP archive containing it if the format query parameter is "zip".
+func (qah *QuizApiHandler) getProfileData(c echo.Context) error {
+	session, err := qah.sharedData.SessionStore.Get(c.Request(), sessions.SESSION_NAME)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %s", err.Error())
+	}
+
+	export, err := user_data_export.Export(qah.sharedData.DB, utils.GetUserIDFromCtx(c), session.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if c.QueryParam("format") != "zip" {
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="nyhetsjeger-data.json"`)
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, data)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="nyhetsjeger-data.zip"`)
+	c.Response().Header().Set(echo.HeaderContentType, "application/zip")
+	c.Response().WriteHeader(http.StatusOK)
+	archive := zip.NewWriter(c.Response())
+	file, err := archive.Create("nyhetsjeger-data.json")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
 // Handles a post request with expected accepted-terms=on form data. Sets te caller's accepted terms of service value.
 func (h *QuizApiHandler) postAcceptTerms(c echo.Context) error {
 	isAccepted := c.FormValue("accepted-terms") == "on"
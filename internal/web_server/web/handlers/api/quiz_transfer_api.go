@@ -0,0 +1,78 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/audit_log"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quiz_transfer"
+	"github.com/Molnes/Nyhetsjeger/internal/utils"
+	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	quizImportFileInput = "quiz-file"
+	errorQuizImport     = "error-quiz-import"
+)
+
+// Characters not safe to use in the file name of an exported quiz.
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Downloads the quiz as a versioned JSON document.
+func (aah *AdminApiHandler) exportQuiz(c echo.Context) error {
+	quizID, err := uuid.Parse(c.QueryParam(queryParamQuizID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, errorInvalidQuizID)
+	}
+
+	document, err := quiz_transfer.Export(aah.sharedData.DB, quizID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Fant ikke quizen")
+		}
+		return err
+	}
+
+	fileName := unsafeFileNameChars.ReplaceAllString(document.Quiz.Title, "_")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"%s.json\"", fileName))
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+	return document.Write(c.Response())
+}
+
+// Imports a quiz from an uploaded JSON document into a new unpublished quiz.
+// Redirects to the edit quiz page for the new quiz.
+func (aah *AdminApiHandler) importQuiz(c echo.Context) error {
+	fileHeader, err := c.FormFile(quizImportFileInput)
+	if err != nil {
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizImport, "Velg en fil å importere"))
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	document, err := quiz_transfer.Read(file)
+	if err != nil {
+		if errors.Is(err, quiz_transfer.ErrUnsupportedVersion) {
+			return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizImport, "Filen er laget av en versjon som ikke støttes"))
+		}
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorQuizImport, "Filen er ikke en gyldig quiz-eksport"))
+	}
+
+	quizID, err := quiz_transfer.Import(aah.sharedData.DB, c.Request().Context(), document)
+	if err != nil {
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionImport, audit_log.TargetQuiz, quizID.String(),
+		nil, map[string]any{"title": document.Quiz.Title, "questions": len(document.Quiz.Questions)})
+
+	c.Response().Header().Set("HX-Redirect", "/dashboard/edit-quiz?quiz-id="+quizID.String())
+	return c.NoContent(http.StatusOK)
+}
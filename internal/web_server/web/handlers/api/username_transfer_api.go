@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Molnes/Nyhetsjeger/internal/models/audit_log"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/usernames"
+	"github.com/Molnes/Nyhetsjeger/internal/utils"
+	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components"
+	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components/dashboard_components/user_admin"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	usernameImportFileInput = "username-words-file"
+	errorUsernameImport     = "error-username-import"
+)
+
+// Downloads every adjective and noun as a CSV file, in the same format as the import.
+func (aah *AdminApiHandler) exportUsernameWords(c echo.Context) error {
+	words, err := usernames.GetAllWords(aah.sharedData.DB)
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename=\"brukernavn-ord.csv\"")
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=UTF-8")
+	c.Response().WriteHeader(http.StatusOK)
+	return words.WriteCSV(c.Response())
+}
+
+var errNoUsernameImportFile = errors.New("ingen fil med ord er valgt")
+
+// Reads the uploaded CSV file with username words.
+func readUsernameWordsFile(c echo.Context) (*usernames.WordList, error) {
+	fileHeader, err := c.FormFile(usernameImportFileInput)
+	if err != nil {
+		return nil, errNoUsernameImportFile
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return usernames.ReadWordsCSV(file)
+}
+
+// Renders why the uploaded file could not be read, if it is the admin's fault.
+func renderUsernameImportError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errNoUsernameImportFile):
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorUsernameImport, "Velg en fil å importere"))
+	case errors.Is(err, usernames.ErrInvalidWordsCSV):
+		return utils.Render(c, http.StatusBadRequest, components.ErrorText(errorUsernameImport,
+			"Filen må være en CSV-fil med kolonnene \"Adjektiv;Substantiv\""))
+	default:
+		return err
+	}
+}
+
+// Shows which words in the uploaded CSV file would be added, and which are duplicates or conflicts.
+func (aah *AdminApiHandler) previewUsernameImport(c echo.Context) error {
+	words, err := readUsernameWordsFile(c)
+	if err != nil {
+		return renderUsernameImportError(c, err)
+	}
+
+	preview, err := usernames.PreviewImport(aah.sharedData.DB, c.Request().Context(), words)
+	if err != nil {
+		return err
+	}
+	return utils.Render(c, http.StatusOK, user_admin.WordImportPreview(preview, false))
+}
+
+// Adds the new words in the uploaded CSV file to the username tables.
+func (aah *AdminApiHandler) importUsernameWords(c echo.Context) error {
+	words, err := readUsernameWordsFile(c)
+	if err != nil {
+		return renderUsernameImportError(c, err)
+	}
+
+	imported, err := usernames.ImportWords(aah.sharedData.DB, c.Request().Context(), words)
+	if err != nil {
+		return err
+	}
+	recordAudit(c, aah.sharedData.DB, audit_log.ActionImport, audit_log.TargetUsername, "", nil, map[string]any{
+		"adjectives": imported.NewAdjectives,
+		"nouns":      imported.NewNouns,
+		"skipped":    len(imported.Skipped),
+	})
+	// Reloads the word tables on the page
+	c.Response().Header().Set("HX-Trigger", "usernameWordsImported")
+	return utils.Render(c, http.StatusOK, user_admin.WordImportPreview(imported, true))
+}
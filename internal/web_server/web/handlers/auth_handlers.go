@@ -3,46 +3,68 @@ package handlers
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/Molnes/Nyhetsjeger/internal/auth"
 	"github.com/Molnes/Nyhetsjeger/internal/config"
 	"github.com/Molnes/Nyhetsjeger/internal/models/sessions"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/guest_sessions"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/middlewares"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
-	"golang.org/x/oauth2"
 )
 
 type AuthHandler struct {
-	sharedData        *config.SharedData
-	googleOauthConfig *oauth2.Config
+	sharedData *config.SharedData
 }
 
 // Creates a new handler for auth related requests
-func NewAuthHandler(sharedData *config.SharedData, googleOauthConfig *oauth2.Config) *AuthHandler {
-	return &AuthHandler{sharedData, googleOauthConfig}
+func NewAuthHandler(sharedData *config.SharedData) *AuthHandler {
+	return &AuthHandler{sharedData}
 }
 
 // Registers the auth related handlers to the given echo group
 func (ah *AuthHandler) RegisterAuthHandlers(g *echo.Group) {
-	g.GET("/google/login", ah.oauthGoogleLogin)
-	g.GET("/google/callback", ah.oauthGoogleCallback)
+	g.GET("/:provider/login", ah.oauthLogin)
+	g.GET("/:provider/callback", ah.oauthCallback)
+	g.POST("/email", ah.sendMagicLink)
+	g.GET("/email/verify", ah.confirmMagicLink)
+	g.POST("/email/verify", ah.magicLinkLogin)
 	g.POST("/logout", ah.logout)
 }
 
-// Redirects the user to the Google OAuth2 login page
-func (ah *AuthHandler) oauthGoogleLogin(c echo.Context) error {
+// Gets the login provider named in the path
+func (ah *AuthHandler) getProvider(c echo.Context) (auth.Provider, error) {
+	provider, ok := ah.sharedData.AuthProviders.Get(c.Param("provider"))
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "unknown login provider")
+	}
+	return provider, nil
+}
+
+// Redirects the user to the login page of the provider
+func (ah *AuthHandler) oauthLogin(c echo.Context) error {
+	provider, err := ah.getProvider(c)
+	if err != nil {
+		return err
+	}
 	oauthState := auth.GenerateAndSetStateOauthCookie(c)
-	url := ah.googleOauthConfig.AuthCodeURL(oauthState)
+	url := provider.AuthCodeURL(oauthState)
 	return c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
-// Handles the callback from the Google OAuth2 login
+// Handles the callback from the login provider
 // If the user is not in the user store, a new user is created
-// If the user is in the user store, the user is updated with the new access token and refresh token
+// The provider's token is stored encrypted, replacing any previous token
 // The user is then logged in and a session is created
-func (ah *AuthHandler) oauthGoogleCallback(c echo.Context) error {
+func (ah *AuthHandler) oauthCallback(c echo.Context) error {
+	provider, err := ah.getProvider(c)
+	if err != nil {
+		return err
+	}
+
 	oauthState, err := c.Cookie(auth.OAUTH_STATE_COOKIE)
 	if err != nil {
 		return fmt.Errorf("failed to get oauth state cookie: %s", err.Error())
@@ -51,51 +73,86 @@ func (ah *AuthHandler) oauthGoogleCallback(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusUnauthorized, "invalid oauth state")
 	}
 
-	token, err := ah.googleOauthConfig.Exchange(c.Request().Context(), c.FormValue("code"))
+	token, err := provider.Exchange(c.Request().Context(), c.FormValue("code"))
 	if err != nil {
 		return fmt.Errorf("code exchange failed: %s", err.Error())
 	}
 
-	googleUser, err := auth.GetGoogleUserData(token.AccessToken)
+	providerUser, err := provider.GetUser(c.Request().Context(), token)
 	if err != nil {
 		return fmt.Errorf("failed to get user info: %s", err.Error())
 	}
+	// Roles are preassigned by email, so an unverified email could give access to the dashboard.
+	if !providerUser.EmailVerified {
+		return echo.NewHTTPError(http.StatusForbidden, "E-postadressen din er ikke bekreftet hos innloggingstjenesten.")
+	}
 
-	user, err := users.GetUserBySsoID(ah.sharedData.DB, googleUser.ID)
+	user, err := users.GetUserBySsoID(ah.sharedData.DB, provider.Name(), providerUser.ID)
 	if err != nil && err != sql.ErrNoRows {
 		return fmt.Errorf("failed to get user from user store: %s", err.Error())
 	}
 
 	if err == sql.ErrNoRows {
+		_, err = users.GetUserByEmail(ah.sharedData.DB, providerUser.Email)
+		if err == nil {
+			return echo.NewHTTPError(http.StatusConflict,
+				"E-postadressen er allerede registrert med en annen innlogging. Logg inn på samme måte som sist.")
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("failed to get user from user store: %s", err.Error())
+		}
+
 		newUser := users.PartialUser{
-			SsoID:        googleUser.ID,
-			Email:        googleUser.Email,
-			AccessToken:  token.AccessToken,
-			TokenExpire:  token.Expiry,
-			RefreshToken: token.RefreshToken,
+			SsoProvider: provider.Name(),
+			SsoID:       providerUser.ID,
+			Email:       providerUser.Email,
 		}
 		createdUser, err := users.CreateUser(ah.sharedData.DB, c.Request().Context(), &newUser)
 		if err != nil {
 			return fmt.Errorf("failed to create user: %s", err.Error())
 		}
 		user = createdUser
-	} else {
-		err = users.UpdateUserToken(ah.sharedData.DB, user.ID, token.AccessToken, token.Expiry, token.RefreshToken)
-		if err != nil {
-			return fmt.Errorf("failed to update user: %s", err.Error())
-		}
 	}
 
+	err = ah.sharedData.Tokens.Store(c.Request().Context(), user.ID, token)
+	if err != nil {
+		return fmt.Errorf("failed to store token: %s", err.Error())
+	}
+
+	redirectTo, err := ah.startSession(c, user)
+	if err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusTemporaryRedirect, redirectTo)
+}
+
+// Logs the user in by creating a session.
+// Progress the user made as a guest before logging in is moved to their account.
+// Returns where the user should be redirected, which is the page they tried to visit before logging in, if any.
+func (ah *AuthHandler) startSession(c echo.Context, user *users.User) (string, error) {
 	session, err := ah.sharedData.SessionStore.New(c.Request(), sessions.SESSION_NAME)
 	if err != nil {
-		return fmt.Errorf("failed to create session: %s", err.Error())
+		return "", fmt.Errorf("failed to create session: %s", err.Error())
+	}
+
+	if guestIDValue, ok := session.Values[sessions.GUEST_ID_VALUE].(string); ok {
+		guestID, err := uuid.Parse(guestIDValue)
+		if err == nil {
+			_, err = guest_sessions.MergeIntoUser(c.Request().Context(), ah.sharedData.DB, guestID, user.ID)
+			if err != nil {
+				return "", fmt.Errorf("failed to merge guest progress: %s", err.Error())
+			}
+		}
+		delete(session.Values, sessions.GUEST_ID_VALUE)
+		// Log in with a new session ID, so an anonymous session can not be used to take over the account.
+		session.ID = ""
 	}
 
 	userSessionData := user.IntoSessionData()
 	session.Values[sessions.USER_DATA_VALUE] = userSessionData
 	err = session.Save(c.Request(), c.Response())
 	if err != nil {
-		return fmt.Errorf("failed to save session: %s", err.Error())
+		return "", fmt.Errorf("failed to save session: %s", err.Error())
 	}
 
 	cookieRedirectTo, err := c.Cookie(middlewares.REDIRECT_COOKIE_NAME)
@@ -112,15 +169,23 @@ func (ah *AuthHandler) oauthGoogleCallback(c echo.Context) error {
 		c.SetCookie(&replacementCookie)
 	}
 
-	return c.Redirect(http.StatusTemporaryRedirect, redirectTo)
+	return redirectTo, nil
 }
 
 // Logs the user out by deleting the session
+// The login provider's token is no longer needed, so it is revoked with the provider and removed.
+// If revoking fails the token is kept, so it can be revoked when the profile is deleted.
 func (ah *AuthHandler) logout(c echo.Context) error {
 	session, err := ah.sharedData.SessionStore.Get(c.Request(), sessions.SESSION_NAME)
 	if err != nil {
 		return fmt.Errorf("failed to get session: %s", err.Error())
 	}
+	if userData, ok := session.Values[sessions.USER_DATA_VALUE].(users.UserSessionData); ok {
+		err = ah.sharedData.Tokens.Revoke(c.Request().Context(), userData.ID)
+		if err != nil {
+			log.Printf("Failed to revoke token of user %s: %v", userData.ID, err)
+		}
+	}
 	session.Options.MaxAge = -1
 	err = session.Save(c.Request(), c.Response())
 	if err != nil {
@@ -4,11 +4,16 @@ import (
 	"database/sql"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Molnes/Nyhetsjeger/internal/config"
 	"github.com/Molnes/Nyhetsjeger/internal/models/articles"
+	"github.com/Molnes/Nyhetsjeger/internal/models/audit_log"
 	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
+	"github.com/Molnes/Nyhetsjeger/internal/models/prize_draws"
 	"github.com/Molnes/Nyhetsjeger/internal/models/questions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/quiz_analytics"
 	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/access_control"
@@ -16,7 +21,9 @@ import (
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_roles"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/usernames"
 	"github.com/Molnes/Nyhetsjeger/internal/utils"
+	data_handling "github.com/Molnes/Nyhetsjeger/internal/utils/data"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/middlewares"
+	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components/dashboard_components/dashboard_user_details_components"
 	dashboard_components "github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components/dashboard_components/edit_quiz"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/components/dashboard_components/side_menu"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/pages/dashboard_pages"
@@ -39,15 +46,20 @@ func (dph *DashboardPagesHandler) RegisterDashboardHandlers(g *echo.Group) {
 	g.GET("/edit-quiz", dph.dashboardEditQuiz)
 	g.GET("/edit-quiz/new-question", dph.dashboardNewQuestionModal)
 	g.GET("/edit-question", dph.dashboardEditQuestionModal)
+	g.GET("/quiz-analytics", dph.quizAnalytics)
 	g.GET("/leaderboard", dph.leaderboard)
 
 	g.GET("/labels", dph.labels)
+	g.GET("/templates", dph.templates)
 	g.GET("/user", dph.userDetails)
 	g.GET("/username-admin", dph.getUsernameAdministration)
 
 	mw := middlewares.NewAuthorizationMiddleware(dph.sharedData, []user_roles.Role{user_roles.OrganizationAdmin})
 	organizationAdminGroup := g.Group("/organization-admin", mw.EnforceRole)
 	organizationAdminGroup.GET("/access-settings", dph.accessSettings)
+	organizationAdminGroup.GET("/audit-log", dph.auditLog)
+	organizationAdminGroup.GET("/prize-draws", dph.prizeDraws)
+	organizationAdminGroup.GET("/prize-draw", dph.prizeDraw)
 }
 
 // Renders the dashboard home page.
@@ -76,6 +88,16 @@ func (dph *DashboardPagesHandler) labels(c echo.Context) error {
 	return utils.Render(c, http.StatusOK, dashboard_pages.LabelsPage(labels))
 }
 
+// Renders the quiz templates page.
+func (dph *DashboardPagesHandler) templates(c echo.Context) error {
+	addMenuContext(c, side_menu.Templates)
+	templates, err := quizzes.GetTemplates(dph.sharedData.DB)
+	if err != nil {
+		return err
+	}
+	return utils.Render(c, http.StatusOK, dashboard_pages.TemplatesPage(templates))
+}
+
 // Renders the page for editing quiz.
 func (dph *DashboardPagesHandler) dashboardEditQuiz(c echo.Context) error {
 	// Get the quiz ID.
@@ -106,7 +128,12 @@ func (dph *DashboardPagesHandler) dashboardEditQuiz(c echo.Context) error {
 		return err
 	}
 
-	return utils.Render(c, http.StatusOK, dashboard_pages.EditQuiz(quiz, articles, questions, labels))
+	scoringPolicy, err := quizzes.GetScoringPolicyByQuizID(dph.sharedData.DB, uuid_id)
+	if err != nil {
+		return err
+	}
+
+	return utils.Render(c, http.StatusOK, dashboard_pages.EditQuiz(quiz, articles, questions, labels, scoringPolicy))
 }
 
 // Renders the modal for creating a new question.
@@ -162,6 +189,25 @@ func (dph *DashboardPagesHandler) dashboardEditQuestionModal(c echo.Context) err
 	return utils.Render(c, http.StatusOK, dashboard_components.EditQuestionForm(question, article, articles, question.QuizID.String(), false))
 }
 
+// Renders the analytics page of a quiz.
+func (dph *DashboardPagesHandler) quizAnalytics(c echo.Context) error {
+	quizID, err := uuid.Parse(c.QueryParam("quiz-id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or missing quiz id")
+	}
+
+	analytics, err := quiz_analytics.GetQuizAnalytics(dph.sharedData.DB, quizID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "No quiz with given id found.")
+		} else {
+			return err
+		}
+	}
+
+	return utils.Render(c, http.StatusOK, dashboard_pages.QuizAnalyticsPage(analytics))
+}
+
 // Renders the leaderboard page.
 func (dph *DashboardPagesHandler) leaderboard(c echo.Context) error {
 	addMenuContext(c, side_menu.Leaderboard)
@@ -200,6 +246,76 @@ func (dph *DashboardPagesHandler) accessSettings(c echo.Context) error {
 	return utils.Render(c, http.StatusOK, dashboard_pages.AccessSettingsPage(admins))
 }
 
+// Renders the audit log page, filtered by the query parameters.
+func (dph *DashboardPagesHandler) auditLog(c echo.Context) error {
+	addMenuContext(c, side_menu.AuditLog)
+
+	filter := audit_log.Filter{
+		ActorEmail: strings.TrimSpace(c.QueryParam(dashboard_pages.AuditLogActorParam)),
+		Action:     audit_log.Action(c.QueryParam(dashboard_pages.AuditLogActionParam)),
+		TargetType: audit_log.TargetType(c.QueryParam(dashboard_pages.AuditLogTargetTypeParam)),
+		TargetID:   strings.TrimSpace(c.QueryParam(dashboard_pages.AuditLogTargetIDParam)),
+	}
+	// Dates are inclusive, in Norwegian time.
+	if fromDate := c.QueryParam(dashboard_pages.AuditLogFromParam); fromDate != "" {
+		if from, err := data_handling.NorwayTimeToUtc(fromDate + "T00:00"); err == nil {
+			filter.From = from
+		}
+	}
+	if toDate := c.QueryParam(dashboard_pages.AuditLogToParam); toDate != "" {
+		if to, err := data_handling.NorwayTimeToUtc(toDate + "T00:00"); err == nil {
+			filter.To = to.AddDate(0, 0, 1)
+		}
+	}
+
+	page, err := strconv.Atoi(c.QueryParam(dashboard_pages.AuditLogPageParam))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	entries, total, err := audit_log.GetEntries(dph.sharedData.DB, filter, page, dashboard_pages.AuditLogEntriesPerPage)
+	if err != nil {
+		return err
+	}
+
+	return utils.Render(c, http.StatusOK, dashboard_pages.AuditLogPage(entries, total, page, c.Request().URL))
+}
+
+// Renders the page for picking the winners of prizes, listing the earlier draws.
+func (dph *DashboardPagesHandler) prizeDraws(c echo.Context) error {
+	addMenuContext(c, side_menu.PrizeDraws)
+
+	allLabels, err := labels.GetLabels(dph.sharedData.DB)
+	if err != nil {
+		return err
+	}
+	draws, err := prize_draws.GetDraws(dph.sharedData.DB)
+	if err != nil {
+		return err
+	}
+
+	return utils.Render(c, http.StatusOK, dashboard_pages.PrizeDrawsPage(allLabels, draws))
+}
+
+// Renders the page showing the winners of a draw with their contact details.
+func (dph *DashboardPagesHandler) prizeDraw(c echo.Context) error {
+	addMenuContext(c, side_menu.PrizeDraws)
+
+	drawID, err := uuid.Parse(c.QueryParam(dashboard_pages.PrizeDrawIDParam))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig eller manglende draw-id")
+	}
+	draw, err := prize_draws.GetDraw(dph.sharedData.DB, drawID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Fant ingen trekning med den angitte ID-en")
+		}
+		return err
+	}
+
+	return utils.Render(c, http.StatusOK, dashboard_pages.PrizeDrawPage(draw))
+}
+
 // Renders the user details page.
 func (dph *DashboardPagesHandler) userDetails(c echo.Context) error {
 	uuid_id, err := uuid.Parse(c.QueryParam("user-id"))
@@ -236,7 +352,15 @@ func (dph *DashboardPagesHandler) userDetails(c echo.Context) error {
 		}
 	}
 
-	rankingCollection, err := user_ranking.GetUserRankingsInAllRanges(dph.sharedData.DB, uuid_id, label)
+	// Defaults to the current month and year if not provided or invalid.
+	period := time.Now()
+	month, monthErr := strconv.Atoi(c.QueryParam(dashboard_user_details_components.MonthQueryParam))
+	year, yearErr := strconv.Atoi(c.QueryParam(dashboard_user_details_components.YearQueryParam))
+	if monthErr == nil && yearErr == nil && month >= 1 && month <= 12 {
+		period = time.Date(year, time.Month(month), 15, 12, 0, 0, 0, time.UTC)
+	}
+
+	rankingCollection, err := user_ranking.GetUserRankingsInAllRanges(dph.sharedData.DB, uuid_id, label, period)
 	if err != nil {
 		return err
 	}
@@ -274,5 +398,10 @@ func (dph *DashboardPagesHandler) getUsernameAdministration(c echo.Context) erro
 		return err
 	}
 
-	return utils.Render(c, http.StatusOK, dashboard_pages.UsernameAdminPage(uai, c.Request().URL))
+	blocked, err := usernames.GetBlockedUsernames(dph.sharedData.DB)
+	if err != nil {
+		return err
+	}
+
+	return utils.Render(c, http.StatusOK, dashboard_pages.UsernameAdminPage(uai, c.Request().URL, blocked))
 }
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/Molnes/Nyhetsjeger/internal/auth"
+	"github.com/Molnes/Nyhetsjeger/internal/mailer"
+	"github.com/Molnes/Nyhetsjeger/internal/models/magic_links"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users"
+	"github.com/Molnes/Nyhetsjeger/internal/utils"
+	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/pages/public_pages"
+	"github.com/labstack/echo/v4"
+)
+
+// Returns an error if no mailer is configured, in which case email login is disabled.
+func (ah *AuthHandler) requireMailer() error {
+	if ah.sharedData.Mailer == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Innlogging med e-post er ikke tilgjengelig.")
+	}
+	return nil
+}
+
+// Sends a login link to the email address in the form.
+// The same page is shown whether or not the address belongs to a user, so the form can not be used to look up users.
+func (ah *AuthHandler) sendMagicLink(c echo.Context) error {
+	if err := ah.requireMailer(); err != nil {
+		return err
+	}
+
+	address, err := mail.ParseAddress(c.FormValue(public_pages.MagicLinkEmail))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig e-postadresse.")
+	}
+	email := strings.ToLower(address.Address)
+
+	token, err := magic_links.Create(ah.sharedData.DB, ah.sharedData.LinkSigningKey, email)
+	if err != nil {
+		if err == magic_links.ErrTooManyRequests {
+			return echo.NewHTTPError(http.StatusTooManyRequests,
+				"Det er allerede sendt flere innloggingslenker til denne adressen. Sjekk e-posten din, eller prøv igjen om litt.")
+		}
+		return fmt.Errorf("failed to create login link: %s", err.Error())
+	}
+
+	link := fmt.Sprintf("%s/auth/email/verify?%s=%s", ah.sharedData.PublicURL, public_pages.MagicLinkToken, url.QueryEscape(token))
+	err = ah.sharedData.Mailer.Send(c.Request().Context(), mailer.Message{
+		To:      email,
+		Subject: "Logg inn på Nyhetsjeger",
+		Body: fmt.Sprintf("Hei!\n\nTrykk på lenken for å logge inn. Lenken kan brukes én gang, og varer i %d minutter.\n\n%s\n\n"+
+			"Hvis du ikke ba om å logge inn, kan du se bort fra denne e-posten.\n",
+			int(magic_links.TokenLifetime.Minutes()), link),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send login link: %s", err.Error())
+	}
+
+	return utils.Render(c, http.StatusOK, public_pages.MagicLinkSentPage(email))
+}
+
+// Asks the user to confirm the login.
+// The token is only used when the user confirms, so email clients opening links in advance do not use it up.
+func (ah *AuthHandler) confirmMagicLink(c echo.Context) error {
+	if err := ah.requireMailer(); err != nil {
+		return err
+	}
+	return utils.Render(c, http.StatusOK, public_pages.MagicLinkConfirmPage(c.QueryParam(public_pages.MagicLinkToken)))
+}
+
+// Logs in the user the login link was sent to.
+// If no user has the email address, a new user is created, getting any role preassigned to the address.
+func (ah *AuthHandler) magicLinkLogin(c echo.Context) error {
+	if err := ah.requireMailer(); err != nil {
+		return err
+	}
+
+	email, err := magic_links.Consume(ah.sharedData.DB, ah.sharedData.LinkSigningKey, c.FormValue(public_pages.MagicLinkToken))
+	if err != nil {
+		if err == magic_links.ErrInvalidToken {
+			return echo.NewHTTPError(http.StatusUnauthorized,
+				"Innloggingslenken er ugyldig, utløpt eller allerede brukt. Be om en ny lenke.")
+		}
+		return fmt.Errorf("failed to use login link: %s", err.Error())
+	}
+
+	user, err := users.GetUserByEmail(ah.sharedData.DB, email)
+	if err == sql.ErrNoRows {
+		user, err = users.CreateUser(ah.sharedData.DB, c.Request().Context(), &users.PartialUser{
+			SsoProvider: auth.ProviderEmail,
+			SsoID:       email,
+			Email:       email,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create user: %s", err.Error())
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get user from user store: %s", err.Error())
+	}
+
+	redirectTo, err := ah.startSession(c, user)
+	if err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, redirectTo)
+}
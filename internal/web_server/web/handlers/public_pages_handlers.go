@@ -4,15 +4,18 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/Molnes/Nyhetsjeger/internal/config"
-	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
+	"github.com/Molnes/Nyhetsjeger/internal/models/competitions"
+	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
 	"github.com/Molnes/Nyhetsjeger/internal/models/sessions"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz_summary"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_roles"
 	"github.com/Molnes/Nyhetsjeger/internal/utils"
+	"github.com/Molnes/Nyhetsjeger/internal/web_server/middlewares"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/pages/public_pages"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/pages/quiz_pages"
 	"github.com/google/uuid"
@@ -34,7 +37,10 @@ func (pph *PublicPagesHandler) RegisterPublicPages(e *echo.Echo) {
 	e.GET("/login", pph.loginPage)
 	e.GET("/betingelser", pph.termsPage)
 	e.GET("/gjest", pph.getGuestHomePage)
-	e.GET("/gjest-quiz", pph.getGuestQuiz)
+	guestSession := middlewares.NewGuestSession(pph.sharedData)
+	e.GET("/gjest-quiz", pph.getGuestQuiz, guestSession.EnsureGuestSession)
+	e.GET("/konkurranser", pph.getCompetitionsPage)
+	e.GET("/konkurranser/resultater", pph.getCompetitionResultsPage)
 }
 
 // Handles get request to get home page. If user is authenticated, they get redirected to /quiz or /dashboard
@@ -68,7 +74,7 @@ func (pph *PublicPagesHandler) loginPage(c echo.Context) error {
 	if err == nil && session.Values[sessions.USER_DATA_VALUE] != nil {
 		return c.Redirect(http.StatusTemporaryRedirect, "/quiz")
 	}
-	return utils.Render(c, http.StatusOK, public_pages.LoginPage())
+	return utils.Render(c, http.StatusOK, public_pages.LoginPage(pph.sharedData.AuthProviders, pph.sharedData.Mailer != nil))
 }
 
 // Handles get request to the terms of service page.
@@ -76,74 +82,81 @@ func (pph *PublicPagesHandler) termsPage(c echo.Context) error {
 	return utils.Render(c, http.StatusOK, public_pages.TermsOfServicePage())
 }
 
-// Handles get request to the guest home page.
+// Handles get request to the guest home page, listing the quizzes open for guests.
 func (pph *PublicPagesHandler) getGuestHomePage(c echo.Context) error {
-	quizId, err := user_quiz.GetOpenQuizId(pph.sharedData.DB)
-	if err != nil && err != sql.ErrNoRows {
+	openQuizzes, err := user_quiz.GetOpenQuizzes(pph.sharedData.DB)
+	if err != nil {
 		return err
 	}
 
-	var partialQuiz quizzes.PartialQuiz
-	if quizId != uuid.Nil {
-		selectedQuiz, err := quizzes.GetPartialQuizByID(pph.sharedData.DB, quizId)
-		if err != nil {
-			return err
-		}
-		partialQuiz = *selectedQuiz
-	}
-
-	return utils.Render(c, http.StatusOK, public_pages.GuestHomePage(&partialQuiz))
+	return utils.Render(c, http.StatusOK, public_pages.GuestHomePage(openQuizzes))
 }
 
 const quizIdQueryParam = "quiz-id"
-const currentQuestionQueryParam = "current-question"
-const totalPointsQueryParam = "total-points"
 
-// Handles get request to the guest play quiz page. If no parameters provided, an open quiz is found and user is redirected there.
+// Handles get request to the guest play quiz page. If no quiz is provided, the guest is redirected to the list of open quizzes.
+// Shows the next question the guest has not answered, or the summary if the guest has answered all of them.
 func (h *PublicPagesHandler) getGuestQuiz(c echo.Context) error {
-	openQuizId, err := user_quiz.GetOpenQuizId(h.sharedData.DB)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return echo.NewHTTPError(http.StatusNotFound, "Ingen åpen quiz")
-		}
-		return err
-	}
-
 	quizIdParam := c.QueryParam(quizIdQueryParam)
-	currentQuestionParam := c.QueryParam(currentQuestionQueryParam)
-
-	if quizIdParam == "" || currentQuestionParam == "" {
-		c.Redirect(http.StatusTemporaryRedirect,
-			fmt.Sprintf("/gjest-quiz?%s=%s&%s=%s", quizIdQueryParam, openQuizId.String(), currentQuestionQueryParam, "1"),
-		)
+	if quizIdParam == "" {
+		return c.Redirect(http.StatusTemporaryRedirect, "/gjest")
 	}
 
 	quizId, err := uuid.Parse(quizIdParam)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig quiz-id")
 	}
-	if quizId != openQuizId {
+	isOpen, err := user_quiz.IsOpenQuiz(h.sharedData.DB, quizId)
+	if err != nil {
+		return err
+	}
+	if !isOpen {
 		return echo.NewHTTPError(http.StatusNotFound, "Ingen åpen quiz med den angitte ID-en")
 	}
 
-	currentQuestion, err := strconv.ParseUint(currentQuestionParam, 10, 64)
-	if err != nil || currentQuestion < 1 {
-		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig spørsmålsnummer")
+	guestID := utils.GetGuestIDFromCtx(c)
+	data, err := user_quiz.NextQuestionInQuizGuest(h.sharedData.DB, guestID, quizId, h.sharedData.AnswerGracePeriod)
+	if err != nil {
+		if err == user_quiz.ErrNoSuchQuiz {
+			return echo.NewHTTPError(http.StatusNotFound, "Ingen åpen quiz med den angitte ID-en")
+		} else if err == user_quiz.ErrNoMoreQuestions {
+			summary, err := user_quiz_summary.GetGuestQuizSummary(h.sharedData.DB, guestID, quizId)
+			if err != nil {
+				return err
+			}
+			return utils.Render(c, http.StatusOK, quiz_pages.QuizSummaryPage(summary))
+		}
+		return err
+	}
+
+	return utils.Render(c, http.StatusOK, quiz_pages.QuizPlayPage(data.PartialQuiz.Title, data))
+}
+
+// Handles get request to the page listing the competitions.
+func (pph *PublicPagesHandler) getCompetitionsPage(c echo.Context) error {
+	competitionList, err := labels.GetCompetitions(pph.sharedData.DB)
+	if err != nil {
+		return err
 	}
 
-	totalPoints, err := strconv.ParseUint(c.FormValue(totalPointsQueryParam), 10, 64)
+	return utils.Render(c, http.StatusOK, public_pages.CompetitionsPage(competitionList, time.Now().UTC()))
+}
+
+// Handles get request to the results page of a competition.
+// The results are final once the competition has ended and its standings are frozen.
+func (pph *PublicPagesHandler) getCompetitionResultsPage(c echo.Context) error {
+	labelID, err := uuid.Parse(c.QueryParam("label-id"))
 	if err != nil {
-		totalPoints = 0
+		return echo.NewHTTPError(http.StatusBadRequest, "Ugyldig konkurranse-id")
 	}
 
-	data, err := user_quiz.GetQuestionByNumberInQuiz(h.sharedData.DB, quizId, uint(currentQuestion))
+	results, err := competitions.GetResults(pph.sharedData.DB, labelID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return echo.NewHTTPError(http.StatusNotFound, "Ingen spørsmål med det angitte nummeret")
+		if err == sql.ErrNoRows || err == competitions.ErrNotCompetition {
+			return echo.NewHTTPError(http.StatusNotFound, "Fant ingen konkurranse med den angitte ID-en")
 		}
 		return err
 	}
-	data.PointsGathered = uint(totalPoints)
 
-	return utils.Render(c, http.StatusOK, quiz_pages.QuizPlayPage(data.PartialQuiz.Title, data))
+	return utils.Render(c, http.StatusOK, public_pages.CompetitionResultsPage(results, time.Now().UTC()))
 }
@@ -3,14 +3,17 @@ package handlers
 import (
 	"database/sql"
 	"net/http"
+	"time"
 
 	"github.com/Molnes/Nyhetsjeger/internal/config"
 	"github.com/Molnes/Nyhetsjeger/internal/models/labels"
 	"github.com/Molnes/Nyhetsjeger/internal/models/quizzes"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/achievements"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_quiz_summary"
 	"github.com/Molnes/Nyhetsjeger/internal/models/users/user_ranking"
+	"github.com/Molnes/Nyhetsjeger/internal/models/users/usernames"
 	"github.com/Molnes/Nyhetsjeger/internal/utils"
 	"github.com/Molnes/Nyhetsjeger/internal/web_server/web/views/pages/quiz_pages"
 	"github.com/google/uuid"
@@ -147,7 +150,7 @@ func (qph *QuizPagesHandler) getPlayQuizPage(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, errInvalidOrMissingQuizID)
 	}
 
-	startQuizData, err := user_quiz.NextQuestionInQuiz(qph.sharedData.DB, utils.GetUserIDFromCtx(c), quizID)
+	startQuizData, err := user_quiz.NextQuestionInQuiz(qph.sharedData.DB, utils.GetUserIDFromCtx(c), quizID, qph.sharedData.AnswerGracePeriod)
 	if err != nil {
 		if err == user_quiz.ErrNoSuchQuiz {
 			return echo.NewHTTPError(http.StatusNotFound, errNoSuchQuiz)
@@ -185,7 +188,10 @@ func (qph *QuizPagesHandler) getQuizSummary(c echo.Context) error {
 }
 
 // Renders the scoreboard page.
+// The optional period query parameter ("month" or "year") limits the rankings to the current calendar month or year.
 func (qph *QuizPagesHandler) getScoreboard(c echo.Context) error {
+	dateRange := user_ranking.ParseDateRange(c.QueryParam("period"))
+	now := time.Now()
 
 	labels, err := labels.GetActiveLabels(qph.sharedData.DB)
 	if err != nil {
@@ -193,9 +199,10 @@ func (qph *QuizPagesHandler) getScoreboard(c echo.Context) error {
 	}
 
 	ranksByLabel := []user_ranking.RankingByLabel{}
+	rankedUserIDs := []uuid.UUID{}
 	for _, label := range labels {
 
-		ranking, err := user_ranking.GetRanking(qph.sharedData.DB, label.ID)
+		ranking, err := user_ranking.GetRankingInRange(qph.sharedData.DB, label.ID, dateRange, now)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				ranking = []user_ranking.UserRanking{}
@@ -209,12 +216,15 @@ func (qph *QuizPagesHandler) getScoreboard(c echo.Context) error {
 			Label:   label,
 			Ranking: ranking,
 		})
+		for _, userRanking := range ranking {
+			rankedUserIDs = append(rankedUserIDs, userRanking.UserID)
+		}
 
 	}
 
 	userRankingInfo := []user_ranking.UserRankingWithLabel{}
 	for _, label := range labels {
-		ranking, err := user_ranking.GetUserRanking(qph.sharedData.DB, utils.GetUserIDFromCtx(c), label)
+		ranking, err := user_ranking.GetUserRankingInRange(qph.sharedData.DB, utils.GetUserIDFromCtx(c), label, dateRange, now)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				user, err := users.GetUserByID(qph.sharedData.DB, utils.GetUserIDFromCtx(c))
@@ -244,7 +254,12 @@ func (qph *QuizPagesHandler) getScoreboard(c echo.Context) error {
 		userRankingInfo = append(userRankingInfo, rankInfo)
 	}
 
-	return utils.Render(c, http.StatusOK, quiz_pages.ScoreBoardContainer(ranksByLabel, userRankingInfo))
+	badges, err := achievements.GetBadgesByUsers(qph.sharedData.DB, rankedUserIDs)
+	if err != nil {
+		return err
+	}
+
+	return utils.Render(c, http.StatusOK, quiz_pages.ScoreBoardContainer(ranksByLabel, userRankingInfo, dateRange, badges))
 }
 
 // Renders the finished quizzes page.
@@ -271,7 +286,12 @@ func (qph *QuizPagesHandler) usernamePage(c echo.Context) error {
 		return err
 	}
 
-	return utils.Render(c, http.StatusOK, quiz_pages.UsernamePage(user))
+	adjectives, err := usernames.SearchAdjectives(qph.sharedData.DB, "")
+	if err != nil {
+		return err
+	}
+
+	return utils.Render(c, http.StatusOK, quiz_pages.UsernamePage(user, adjectives))
 }
 
 // Renders the profile page
@@ -282,7 +302,12 @@ func (qph *QuizPagesHandler) getProfile(c echo.Context) error {
 		return err
 	}
 
-	return utils.Render(c, http.StatusOK, quiz_pages.UserProfile(user))
+	userAchievements, err := achievements.GetAchievements(qph.sharedData.DB, user.ID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return utils.Render(c, http.StatusOK, quiz_pages.UserProfile(user, userAchievements))
 }
 
 // Renders the accept terms page
@@ -1,29 +1,55 @@
 package handlers
 
 import (
-	"fmt"
-
+	"github.com/Molnes/Nyhetsjeger/internal/config"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/net/websocket"
 )
 
-// empty websocket to be used for live updates
-func WebsocketHandler(c echo.Context) error {
+type WebsocketHandler struct {
+	sharedData *config.SharedData
+}
+
+// Creates a new WebsocketHandler
+func NewWebsocketHandler(sharedData *config.SharedData) *WebsocketHandler {
+	return &WebsocketHandler{sharedData}
+}
+
+// Registers the websocket used for live updates
+func (wh *WebsocketHandler) RegisterWebsocketHandler(e *echo.Echo) {
+	e.GET("/ws", wh.liveUpdates)
+}
+
+// Subscribes the connected client to the live updates hub and sends every event to it as JSON,
+// until the client disconnects.
+func (wh *WebsocketHandler) liveUpdates(c echo.Context) error {
 	websocket.Handler(func(ws *websocket.Conn) {
 		defer ws.Close()
-		for {
-			// do nothing but keep the connection open
 
-			err := websocket.Message.Send(ws, "Hello, Client!")
-			if err != nil {
-				return
-			}
+		subscriber := wh.sharedData.LiveHub.Subscribe()
+		defer wh.sharedData.LiveHub.Unsubscribe(subscriber)
+
+		// Clients are not expected to send anything, but reading is needed to notice when they disconnect.
+		disconnected := make(chan struct{})
+		go func() {
+			defer close(disconnected)
 			msg := ""
-			err = websocket.Message.Receive(ws, &msg)
-			if err != nil {
+			for websocket.Message.Receive(ws, &msg) == nil {
+			}
+		}()
+
+		for {
+			select {
+			case event, ok := <-subscriber.Events():
+				if !ok {
+					return
+				}
+				if err := websocket.JSON.Send(ws, event); err != nil {
+					return
+				}
+			case <-disconnected:
 				return
 			}
-			fmt.Println("Received message:", msg)
 		}
 	}).ServeHTTP(c.Response(), c.Request())
 	return nil
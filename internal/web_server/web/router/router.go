@@ -12,12 +12,11 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
-	"golang.org/x/oauth2"
 )
 
 // Sets up the router for the web server
 // Takes care of grouping routes, setting up middleware and registering handlers.
-func SetupRouter(e *echo.Echo, sharedData *config.SharedData, oauthConfig *oauth2.Config) {
+func SetupRouter(e *echo.Echo, sharedData *config.SharedData) {
 
 	e.Logger.SetLevel(log.DEBUG)
 	e.Pre(middleware.RemoveTrailingSlash())
@@ -37,7 +36,7 @@ func SetupRouter(e *echo.Echo, sharedData *config.SharedData, oauthConfig *oauth
 
 	// authentication routes, no authentication required
 	authGroup := e.Group("/auth")
-	authHandlers := handlers.NewAuthHandler(sharedData, oauthConfig)
+	authHandlers := handlers.NewAuthHandler(sharedData)
 	authHandlers.RegisterAuthHandlers(authGroup)
 
 	// routes requiring authentication
@@ -73,6 +72,8 @@ func SetupRouter(e *echo.Echo, sharedData *config.SharedData, oauthConfig *oauth
 	apiGroup.Use(handlers.SetApiErrorDisplay)
 
 	guestGroup := apiGroup.Group("/guest")
+	guestSession := middlewares.NewGuestSession(sharedData)
+	guestGroup.Use(guestSession.EnsureGuestSession)
 	guestApiHandler := api.NewPublicApiHandler(sharedData)
 	guestApiHandler.RegisterPublicApiHandlers(guestGroup)
 
@@ -119,7 +120,8 @@ func SetupRouter(e *echo.Echo, sharedData *config.SharedData, oauthConfig *oauth
 	e.File("/favicon.ico", "assets/favicon.ico")
 
 	// websocket for live updates
-	e.GET("/ws", handlers.WebsocketHandler)
+	websocketHandler := handlers.NewWebsocketHandler(sharedData)
+	websocketHandler.RegisterWebsocketHandler(e)
 
 	e.HTTPErrorHandler = handlers.HTTPErrorHandler
 